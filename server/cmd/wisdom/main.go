@@ -7,12 +7,26 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/shrik450/wisdom/internal/api"
+	"github.com/shrik450/wisdom/internal/baseurl"
+	"github.com/shrik450/wisdom/internal/calendar"
+	"github.com/shrik450/wisdom/internal/feed"
+	"github.com/shrik450/wisdom/internal/metrics"
 	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/oidc"
+	"github.com/shrik450/wisdom/internal/opds"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/report"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/share"
+	"github.com/shrik450/wisdom/internal/tokens"
 	"github.com/shrik450/wisdom/internal/ui"
+	"github.com/shrik450/wisdom/internal/watch"
 	"github.com/shrik450/wisdom/internal/workspace"
 )
 
@@ -25,33 +39,55 @@ func main() {
 		os.Exit(1)
 	}
 
-	var uiDir string
-	if os.Getenv("WISDOM_DEV") == "1" {
-		cwd, err := os.Getwd()
+	// With WISDOM_UI_PROXY set, the UI comes from a frontend dev server,
+	// such as Vite's, and isn't built here.
+	var uiHandler http.Handler
+	var assets *ui.Assets
+	if proxy := os.Getenv("WISDOM_UI_PROXY"); proxy != "" {
+		uiHandler, err = ui.Proxy(proxy, baseurl.Path())
 		if err != nil {
-			logger.Error("get working directory", "err", err)
+			logger.Error("ui proxy config", "err", err)
 			os.Exit(1)
 		}
-		uiDir, err = filepath.Abs(filepath.Join(cwd, "..", "ui"))
+		logger.Info("proxying the ui to a dev server", "url", proxy)
+	} else {
+		var uiDir string
+		dev := os.Getenv("WISDOM_DEV") == "1"
+		if dev {
+			cwd, err := os.Getwd()
+			if err != nil {
+				logger.Error("get working directory", "err", err)
+				os.Exit(1)
+			}
+			uiDir, err = filepath.Abs(filepath.Join(cwd, "..", "ui"))
+			if err != nil {
+				logger.Error("resolve ui directory", "err", err)
+				os.Exit(1)
+			}
+		} else {
+			uiDir, err = ws.Resolve("ui")
+			if err != nil {
+				logger.Error("resolve ui directory from workspace", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		assets, err = ui.NewAssets(uiDir)
 		if err != nil {
-			logger.Error("resolve ui directory", "err", err)
+			logger.Error("list ui files", "err", err)
 			os.Exit(1)
 		}
-	} else {
-		uiDir, err = ws.Resolve("ui")
+		// Precompressing is only worth it for a UI that isn't being worked
+		// on.
+		builder, err := ui.StartWatching(uiDir, !dev, assets.Refresh)
 		if err != nil {
-			logger.Error("resolve ui directory from workspace", "err", err)
+			logger.Error("ui build failed", "err", err)
 			os.Exit(1)
 		}
+		defer builder.Close()
+		uiHandler = ui.FileServer(assets, baseurl.Path())
 	}
 
-	builder, err := ui.StartWatching(uiDir)
-	if err != nil {
-		logger.Error("ui build failed", "err", err)
-		os.Exit(1)
-	}
-	defer builder.Close()
-
 	port := os.Getenv("WISDOM_PORT")
 	if port == "" {
 		port = "8080"
@@ -60,20 +96,140 @@ func main() {
 	addr := os.Getenv("WISDOM_ADDR")
 	addrStr := addr + ":" + port
 
+	scheduler := schedule.New(ws, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The note index is built in the background, so the server starts
+	// right away even on a big workspace.
+	noteIndex := notes.NewIndex()
+	go func() {
+		start := time.Now()
+		if err := noteIndex.Refresh(ws); err != nil {
+			logger.Error("note index build", "err", err)
+			return
+		}
+		logger.Info("note index built", "notes", noteIndex.Progress().Total, "duration", time.Since(start))
+	}()
+
+	watcher := watch.New(ws, logger)
+	watchInterval, err := watchIntervalFromEnv()
+	if err != nil {
+		logger.Error("watch config", "err", err)
+		os.Exit(1)
+	}
+	if watchInterval > 0 {
+		go watcher.Run(ctx, watchInterval)
+	}
+	if assets != nil {
+		watcher.Subscribe(func(ws *workspace.Workspace, events []watch.Event) {
+			for _, e := range events {
+				if strings.HasPrefix(e.Path, "ui/") {
+					if err := assets.Refresh(); err != nil {
+						logger.Warn("refresh ui files", "err", err)
+					}
+					return
+				}
+			}
+		})
+	}
+
+	slowThreshold, err := slowRequestFromEnv()
+	if err != nil {
+		logger.Error("slow request config", "err", err)
+		os.Exit(1)
+	}
+	slow := metrics.NewSlow(slowThreshold)
+	reporter, err := report.FromEnv(logger)
+	if err != nil {
+		logger.Error("error reporting config", "err", err)
+		os.Exit(1)
+	}
+
+	if err := baseurl.Check(); err != nil {
+		logger.Error("base url config", "err", err)
+		os.Exit(1)
+	}
+
+	sso, err := oidc.FromEnv()
+	if err != nil {
+		logger.Error("oidc config", "err", err)
+		os.Exit(1)
+	}
+
+	tokenStore := tokens.New()
+	adminToken := os.Getenv("WISDOM_ADMIN_TOKEN")
+	allowed, err := allowedIPsFromEnv()
+	if err != nil {
+		logger.Error("ip allowlist config", "err", err)
+		os.Exit(1)
+	}
+	tokensEnabled, err := tokenStore.Enabled(ws)
+	if err != nil {
+		logger.Error("reading tokens", "err", err)
+		os.Exit(1)
+	}
+	warnExposure(logger, addr, sso != nil || tokensEnabled || len(allowed) > 0)
+
+	conns := metrics.NewConns()
 	mux := http.NewServeMux()
-	mux.Handle("/api/", api.APIHandler())
-	mux.Handle("/", ui.FileServer(uiDir))
+	folders := protect.New()
+	mux.Handle("/api/", api.APIHandler(scheduler, noteIndex, watcher, folders))
+	mux.Handle("/readyz", api.ReadyHandler(noteIndex))
+	mux.Handle("/api/metrics", metrics.Handler(conns))
+	mux.Handle("/api/metrics/slow", metrics.SlowHandler(slow))
+	if adminToken != "" {
+		debug := debugHandler(adminToken)
+		mux.Handle("/debug/pprof/", debug)
+		mux.Handle("/api/metrics/runtime", debug)
+	}
+	if adminToken != "" || sso != nil {
+		tokensHandler := middleware.RequireAdmin(api.TokensHandler(tokenStore), adminToken, sso)
+		mux.Handle("/api/tokens", tokensHandler)
+		mux.Handle("/api/tokens/", tokensHandler)
+		sessionsHandler := middleware.RequireAdmin(api.SessionsHandler(tokenStore, sso), adminToken, sso)
+		mux.Handle("/api/sessions", sessionsHandler)
+		mux.Handle("/api/sessions/", sessionsHandler)
+	}
+	if token := os.Getenv("WISDOM_CALENDAR_TOKEN"); token != "" {
+		mux.Handle("/calendar.ics", calendar.Handler(token))
+	}
+	if sso != nil {
+		mux.Handle("/auth/", sso.Handler())
+	}
+	if cfg := feed.FromEnv(); cfg != nil {
+		mux.Handle("/feed.xml", feed.Handler(*cfg))
+		mux.Handle("/feed.json", feed.Handler(*cfg))
+	}
+	if signer := share.FromEnv(); signer != nil {
+		mux.Handle("/share/", signer.Handler())
+		mux.Handle("/capture/", signer.Handler())
+	}
+	mux.Handle("/opds/", opds.Handler())
+	mux.Handle("/", uiHandler)
 
-	handler := middleware.RequestLogger(mux, logger)
-	handler = middleware.WithWorkspace(handler, ws)
+	handler := stack{
+		ws:         ws,
+		logger:     logger,
+		basePath:   baseurl.Path(),
+		allowed:    allowed,
+		reporter:   reporter,
+		onPanic:    conns.CountPanic,
+		slow:       slow,
+		tokens:     tokenStore,
+		adminToken: adminToken,
+		sso:        sso,
+		folders:    folders,
+	}.wrap(mux)
 
-	server := &http.Server{
-		Addr:         addrStr,
-		Handler:      handler,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  30 * time.Second,
+	server, err := newServer(addrStr, handler)
+	if err != nil {
+		logger.Error("server config", "err", err)
+		os.Exit(1)
 	}
+	server.ConnState = conns.Track
+
+	go scheduler.Run(ctx)
 
 	errCh := make(chan error, 1)
 	go func() {
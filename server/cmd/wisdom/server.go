@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/metrics"
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/oidc"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/report"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// stack is what the middleware around the routes needs.
+type stack struct {
+	ws         *workspace.Workspace
+	logger     *slog.Logger
+	basePath   string
+	allowed    []netip.Prefix
+	reporter   *report.Reporter
+	onPanic    func()
+	slow       *metrics.Slow
+	tokens     *tokens.Store
+	adminToken string
+	sso        *oidc.Provider
+	folders    *protect.Folders
+}
+
+// wrap puts the middleware around mux, innermost first.
+func (s stack) wrap(mux http.Handler) http.Handler {
+	handler := middleware.PublishPattern(mux)
+	handler = middleware.LockFolders(handler, s.folders)
+	// A day covers a phone retrying after being offline overnight.
+	handler = middleware.Idempotent(handler, 24*time.Hour)
+	handler = middleware.Authorize(handler, s.tokens, s.adminToken, s.sso)
+	handler = middleware.LogSlowRequests(handler, s.slow)
+	handler = middleware.Recover(handler, s.reporter, s.onPanic)
+	handler = middleware.AllowIPs(handler, s.allowed)
+	handler = middleware.BasePath(handler, s.basePath)
+	handler = middleware.RequestLogger(handler, s.logger)
+	return middleware.WithWorkspace(handler, s.ws)
+}
+
+// newServer configures the HTTP server from the environment:
+//
+//   - WISDOM_READ_TIMEOUT, WISDOM_WRITE_TIMEOUT and WISDOM_IDLE_TIMEOUT
+//     override the timeouts, as Go durations ("2m"); "0" disables one.
+//     Raise the read and write timeouts for slow clients moving big files.
+//   - WISDOM_H2C=1 serves HTTP/2 without TLS, for a reverse proxy that
+//     speaks it to the backend. HTTP/2 over TLS needs no setting.
+func newServer(addr string, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		Protocols:         new(http.Protocols),
+		// Enough streams for a page's requests alongside long transfers on
+		// one connection.
+		HTTP2: &http.HTTP2Config{MaxConcurrentStreams: 250},
+	}
+	for name, timeout := range map[string]*time.Duration{
+		"WISDOM_READ_TIMEOUT":  &server.ReadTimeout,
+		"WISDOM_WRITE_TIMEOUT": &server.WriteTimeout,
+		"WISDOM_IDLE_TIMEOUT":  &server.IdleTimeout,
+	} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%s: invalid duration %q", name, s)
+		}
+		*timeout = d
+	}
+
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(true)
+	if os.Getenv("WISDOM_H2C") == "1" {
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return server, nil
+}
+
+// watchIntervalFromEnv returns how often to look for files changed on disk:
+// WISDOM_WATCH_INTERVAL as a Go duration, 5s by default. "0" turns watching
+// off, for a workspace only ever changed through the server.
+func watchIntervalFromEnv() (time.Duration, error) {
+	return durationFromEnv("WISDOM_WATCH_INTERVAL", 5*time.Second)
+}
+
+// slowRequestFromEnv returns how long a request takes before it is logged
+// as slow: WISDOM_SLOW_REQUEST as a Go duration, 2s by default. "0" logs
+// every request as slow, which is only useful briefly.
+func slowRequestFromEnv() (time.Duration, error) {
+	return durationFromEnv("WISDOM_SLOW_REQUEST", 2*time.Second)
+}
+
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q", name, s)
+	}
+	return d, nil
+}
+
+// allowedIPsFromEnv returns the networks allowed to connect:
+// WISDOM_ALLOW_IPS as a comma-separated list of CIDRs or addresses. Empty
+// allows any.
+func allowedIPsFromEnv() ([]netip.Prefix, error) {
+	var allowed []netip.Prefix
+	for _, s := range strings.Split(os.Getenv("WISDOM_ALLOW_IPS"), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("WISDOM_ALLOW_IPS: invalid address %q", s)
+			}
+			allowed = append(allowed, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("WISDOM_ALLOW_IPS: invalid CIDR %q", s)
+		}
+		allowed = append(allowed, p.Masked())
+	}
+	return allowed, nil
+}
+
+// warnExposure warns when the workspace is served beyond this machine with
+// nothing guarding it: no sign-in, no tokens and no IP allowlist. An empty
+// WISDOM_ADDR listens on every interface, which is easy to do by accident.
+// WISDOM_ALLOW_PUBLIC=1 silences it, for a network trusted as a whole.
+func warnExposure(logger *slog.Logger, addr string, guarded bool) {
+	if guarded || isLoopback(addr) || os.Getenv("WISDOM_ALLOW_PUBLIC") == "1" {
+		return
+	}
+	on := addr
+	if on == "" {
+		on = "every interface"
+	}
+	logger.Warn("serving the workspace without authentication on "+on+": "+
+		"set WISDOM_ADDR=127.0.0.1, configure tokens, sign-in or WISDOM_ALLOW_IPS, or set WISDOM_ALLOW_PUBLIC=1 if this is intended",
+		"addr", addr)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// debugHandler serves net/http/pprof and the runtime state, behind the
+// WISDOM_ADMIN_TOKEN bearer token. Without a token they aren't served at
+// all: profiles reveal more than the workspace does.
+func debugHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	// Index also serves delta profiles, given ?seconds= like the others.
+	mux.Handle("/debug/pprof/", untimed(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.Handle("/debug/pprof/profile", untimed(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.Handle("/debug/pprof/trace", untimed(pprof.Trace))
+	mux.Handle("/api/metrics/runtime", metrics.RuntimeHandler())
+	return middleware.RequireToken(mux, token)
+}
+
+// untimed lifts the server's write deadline for h. CPU profiles and traces
+// take 30 seconds by default, longer than the write timeout allows.
+func untimed(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		h(w, r)
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/metrics"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestStackRecordsSlowRoutes(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := http.NewServeMux()
+	api.HandleFunc("/api/fs/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("content"))
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api)
+	slow := metrics.NewSlow(10 * time.Millisecond)
+	handler := stack{
+		ws:      ws,
+		logger:  slog.New(slog.DiscardHandler),
+		slow:    slow,
+		tokens:  tokens.New(),
+		folders: protect.New(),
+	}.wrap(mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/fs/a.md", nil))
+	top := slow.Top(10)
+	if len(top) != 1 || top[0].Route != "GET /api/fs/{path...}" {
+		t.Errorf("slow routes = %+v, want the route the API matched", top)
+	}
+}
+
+func TestDebugProfilesOutlastWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(debugHandler("secret"))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/debug/pprof/trace?seconds=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("trace cut off: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("trace = %d, %v", resp.StatusCode, err)
+	}
+}
+
+func TestWarnExposure(t *testing.T) {
+	tests := []struct {
+		addr    string
+		guarded bool
+		want    string
+	}{
+		{"", false, "on every interface"},
+		{"192.168.1.5", false, "on 192.168.1.5"},
+		{"127.0.0.1", false, ""},
+		{"", true, ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		warnExposure(slog.New(slog.NewTextHandler(&buf, nil)), tt.addr, tt.guarded)
+		if tt.want == "" && buf.Len() > 0 {
+			t.Errorf("addr %q, guarded %v: warned %q", tt.addr, tt.guarded, buf.String())
+		}
+		if tt.want != "" && !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("addr %q: warning %q doesn't name %q", tt.addr, buf.String(), tt.want)
+		}
+	}
+}
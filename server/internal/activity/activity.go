@@ -0,0 +1,109 @@
+// Package activity summarizes what happened in the workspace over a
+// period and what is waiting for the user, as a nudge back into it.
+package activity
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/attachments"
+	"github.com/shrik450/wisdom/internal/library"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/srs"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// listed is how many items of each kind the text lists by name.
+const listed = 10
+
+type Summary struct {
+	Since time.Time `json:"since"`
+	// Edited are the notes changed since Since, most recent first. Edits
+	// aren't counted, so a note edited once and one edited daily rank by
+	// their last change alone.
+	Edited []string `json:"edited"`
+	// Books are the book notes added or changed since Since.
+	Books       []library.Book        `json:"books"`
+	DueCards    int                   `json:"dueCards"`
+	BrokenLinks []attachments.Missing `json:"brokenLinks"`
+}
+
+func Summarize(ws *workspace.Workspace, since, now time.Time) (Summary, error) {
+	s := Summary{Since: since, Edited: []string{}, Books: []library.Book{}}
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return s, err
+	}
+	modTimes := map[string]time.Time{}
+	for _, e := range entries {
+		if e.IsDir || !notes.IsNote(e.Path) {
+			continue
+		}
+		if info, err := ws.Stat(e.Path); err == nil && !info.ModTime().Before(since) {
+			modTimes[e.Path] = info.ModTime()
+			s.Edited = append(s.Edited, e.Path)
+		}
+	}
+	slices.SortFunc(s.Edited, func(a, b string) int {
+		return cmp.Or(modTimes[b].Compare(modTimes[a]), strings.Compare(a, b))
+	})
+
+	books, err := library.Scan(ws)
+	if err != nil {
+		return s, err
+	}
+	for _, b := range books {
+		if _, ok := modTimes[b.Path]; ok {
+			s.Books = append(s.Books, b)
+		}
+	}
+
+	cards, err := srs.Collect(ws)
+	if err != nil {
+		return s, err
+	}
+	state, err := srs.LoadState(ws)
+	if err != nil {
+		return s, err
+	}
+	s.DueCards = len(srs.Due(cards, state, now.Format(time.DateOnly)))
+
+	report, err := attachments.Scan(ws, nil)
+	if err != nil {
+		return s, err
+	}
+	s.BrokenLinks = report.Missing
+	return s, nil
+}
+
+// Text renders the summary as plain text, for an email.
+func (s Summary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Since %s:\n", s.Since.Format("Mon 2 Jan 2006"))
+
+	section := func(title string, items []string) {
+		fmt.Fprintf(&b, "\n%s (%d)\n", title, len(items))
+		for _, item := range items[:min(len(items), listed)] {
+			fmt.Fprintf(&b, "  - %s\n", item)
+		}
+		if len(items) > listed {
+			fmt.Fprintf(&b, "  and %d more\n", len(items)-listed)
+		}
+	}
+	section("Notes edited", s.Edited)
+	books := make([]string, len(s.Books))
+	for i, book := range s.Books {
+		books[i] = book.Title + " by " + strings.Join(book.Authors, ", ")
+	}
+	section("Books added or updated", books)
+	fmt.Fprintf(&b, "\nFlashcards due: %d\n", s.DueCards)
+	links := make([]string, len(s.BrokenLinks))
+	for i, m := range s.BrokenLinks {
+		links[i] = fmt.Sprintf("%s:%d links to %s", m.Note, m.Line, m.Target)
+	}
+	section("Broken links", links)
+	return b.String()
+}
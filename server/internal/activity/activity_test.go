@@ -0,0 +1,64 @@
+package activity_test
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/activity"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestSummarize(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for p, note := range map[string]struct {
+		content string
+		age     time.Duration
+	}{
+		"books/dune.md":    {"---\ntitle: Dune\nauthors: [Frank Herbert]\n---\n", time.Hour},
+		"books/emma.md":    {"---\ntitle: Emma\nauthors: [Jane Austen]\n---\n", 30 * 24 * time.Hour},
+		"journal/mon.md":   {"# Monday\n\n![map](map.png)\n", 2 * time.Hour},
+		"cards/bio.md":     {"Q: What is the powerhouse of the cell?\nA: The mitochondria.\n", 30 * 24 * time.Hour},
+		"journal/logo.png": {"png", time.Hour},
+	} {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(note.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.Chtimes(p, now.Add(-note.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := activity.Summarize(ws, now.AddDate(0, 0, -7), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(s.Edited, []string{"books/dune.md", "journal/mon.md"}) {
+		t.Errorf("edited = %v, want this week's notes, most recent first", s.Edited)
+	}
+	if len(s.Books) != 1 || s.Books[0].Title != "Dune" {
+		t.Errorf("books = %+v, want Dune", s.Books)
+	}
+	if s.DueCards != 1 {
+		t.Errorf("due cards = %d, want 1", s.DueCards)
+	}
+	if len(s.BrokenLinks) != 1 || s.BrokenLinks[0].Target != "map.png" {
+		t.Errorf("broken links = %+v, want map.png", s.BrokenLinks)
+	}
+
+	text := s.Text()
+	for _, want := range []string{"Notes edited (2)\n  - books/dune.md\n", "  - Dune by Frank Herbert\n", "Flashcards due: 1\n", "journal/mon.md:3 links to map.png"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+}
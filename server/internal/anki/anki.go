@@ -0,0 +1,97 @@
+// Package anki exports flashcards and highlights as a deck Anki can import,
+// for reviewing on devices Anki already syncs to.
+//
+// Decks are written in Anki's text import format rather than as .apkg
+// packages, which are SQLite databases. Each card carries a GUID derived
+// from where it comes from, so importing an export again updates the cards
+// already in the collection instead of adding them twice.
+package anki
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/quotes"
+	"github.com/shrik450/wisdom/internal/srs"
+)
+
+type Card struct {
+	GUID  string
+	Front string
+	Back  string
+	// Tags are Anki tags, which can't contain spaces.
+	Tags []string
+}
+
+// FromFlashcards turns review cards into Anki cards. A card's GUID is its
+// review ID, which survives edits to its answer.
+func FromFlashcards(cards []srs.Card) []Card {
+	out := make([]Card, 0, len(cards))
+	for _, c := range cards {
+		out = append(out, Card{GUID: "wisdom-" + c.ID, Front: c.Front, Back: c.Back, Tags: []string{"wisdom::" + c.Kind, source(c.Path)}})
+	}
+	return out
+}
+
+// FromHighlights turns quotes into cards showing the quote and, on the
+// back, where it is from. A GUID is derived from the note and the quote's
+// text, so moving a quote within its note keeps it.
+func FromHighlights(qs []quotes.Quote) []Card {
+	out := make([]Card, 0, len(qs))
+	for _, q := range qs {
+		sum := sha256.Sum256([]byte(q.Source + "\x00" + q.Text))
+		from := q.Title
+		if from == "" {
+			from = strings.TrimSuffix(path.Base(q.Source), path.Ext(q.Source))
+		}
+		out = append(out, Card{
+			GUID:  "wisdom-q" + hex.EncodeToString(sum[:8]),
+			Front: q.Text,
+			Back:  "— " + from,
+			Tags:  []string{"wisdom::highlight", source(q.Source)},
+		})
+	}
+	return out
+}
+
+// source tags a card with the note it is from.
+func source(p string) string {
+	return "wisdom::note::" + strings.ReplaceAll(strings.TrimSuffix(p, path.Ext(p)), " ", "_")
+}
+
+// field escapes text for an HTML field, where line breaks are <br>.
+func field(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// Write writes cards as a deck of Basic notes named deck.
+func Write(w io.Writer, deck string, cards []Card) error {
+	bw := bufio.NewWriter(w)
+	for _, header := range []string{
+		"#separator:tab",
+		"#html:true",
+		"#notetype:Basic",
+		"#deck:" + strings.ReplaceAll(deck, "\n", " "),
+		"#guid column:1",
+		"#tags column:4",
+	} {
+		bw.WriteString(header + "\n")
+	}
+	for _, c := range cards {
+		tags := make([]string, len(c.Tags))
+		for i, t := range c.Tags {
+			tags[i] = strings.Join(strings.Fields(t), "_")
+		}
+		row := []string{c.GUID, field(c.Front), field(c.Back), strings.Join(tags, " ")}
+		for i := range row {
+			row[i] = strings.ReplaceAll(row[i], "\t", " ")
+		}
+		bw.WriteString(strings.Join(row, "\t") + "\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,45 @@
+package anki_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/anki"
+	"github.com/shrik450/wisdom/internal/quotes"
+	"github.com/shrik450/wisdom/internal/srs"
+)
+
+func TestWrite(t *testing.T) {
+	note := "---\ntitle: Biology\n---\nQ: What is the powerhouse\nof the cell?\nA: The <mitochondria>\n\n> Life finds a way.\n"
+	cards := append(anki.FromFlashcards(srs.Parse("science/bio notes.md", note)),
+		anki.FromHighlights(quotes.Parse("science/bio notes.md", note))...)
+
+	var b strings.Builder
+	if err := anki.Write(&b, "Wisdom::Science", cards); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 8 || lines[3] != "#deck:Wisdom::Science" || lines[4] != "#guid column:1" {
+		t.Fatalf("deck =\n%s", b.String())
+	}
+	card := strings.Split(lines[6], "\t")
+	if len(card) != 4 || card[1] != "What is the powerhouse<br>of the cell?" || card[2] != "The &lt;mitochondria&gt;" ||
+		card[3] != "wisdom::qa wisdom::note::science/bio_notes" {
+		t.Errorf("flashcard = %q", card)
+	}
+	highlight := strings.Split(lines[7], "\t")
+	if len(highlight) != 4 || highlight[1] != "Life finds a way." || highlight[2] != "— Biology" {
+		t.Errorf("highlight = %q", highlight)
+	}
+
+	// Editing an answer or moving a quote keeps the GUIDs, so a new
+	// export updates the same notes in Anki.
+	edited := "---\ntitle: Biology\n---\n> Life finds a way.\n\nQ: What is the powerhouse\nof the cell?\nA: Mitochondria\n"
+	again := append(anki.FromFlashcards(srs.Parse("science/bio notes.md", edited)),
+		anki.FromHighlights(quotes.Parse("science/bio notes.md", edited))...)
+	for i := range cards {
+		if again[i].GUID != cards[i].GUID {
+			t.Errorf("card %d: GUID %s became %s", i, cards[i].GUID, again[i].GUID)
+		}
+	}
+}
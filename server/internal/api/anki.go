@@ -0,0 +1,75 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/anki"
+	"github.com/shrik450/wisdom/internal/quotes"
+	"github.com/shrik450/wisdom/internal/srs"
+	"github.com/shrik450/wisdom/internal/wlog"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// ankiExportHandler downloads flashcards, highlights or both
+// (?include=flashcards,highlights; flashcards by default) from the notes
+// under ?folder= as an Anki deck named ?deck=.
+func ankiExportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		include := []string{"flashcards"}
+		if s := q.Get("include"); s != "" {
+			include = strings.Split(s, ",")
+		}
+		for _, kind := range include {
+			if kind != "flashcards" && kind != "highlights" {
+				http.Error(w, "include must be flashcards, highlights or both", http.StatusBadRequest)
+				return
+			}
+		}
+		deck := q.Get("deck")
+		if deck == "" {
+			deck = "Wisdom"
+		}
+		prefix := ""
+		if q.Get("folder") != "" {
+			if prefix = normalizePath(q.Get("folder")) + "/"; prefix == "./" {
+				prefix = ""
+			}
+		}
+
+		ws := workspace.FromContext(r.Context())
+		var cards []anki.Card
+		if slices.Contains(include, "flashcards") {
+			all, err := srs.Collect(ws)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			all = slices.DeleteFunc(all, func(c srs.Card) bool { return !strings.HasPrefix(c.Path, prefix) })
+			cards = append(cards, anki.FromFlashcards(all)...)
+		}
+		if slices.Contains(include, "highlights") {
+			all, err := quotes.Collect(ws)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			all = slices.DeleteFunc(all, func(q quotes.Quote) bool { return !strings.HasPrefix(q.Source, prefix) })
+			cards = append(cards, anki.FromHighlights(all)...)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": deck + ".txt"}))
+		if err := anki.Write(w, deck, cards); err != nil {
+			wlog.FromContext(r.Context()).Warn("anki export", "err", err)
+		}
+	})
+}
@@ -1,11 +1,181 @@
 // Package api provides the HTTP API for the workspace
 package api
 
-import "net/http"
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/assist"
+	"github.com/shrik450/wisdom/internal/calibre"
+	"github.com/shrik450/wisdom/internal/changes"
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/enrich"
+	"github.com/shrik450/wisdom/internal/library"
+	"github.com/shrik450/wisdom/internal/mail"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/ocr"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/share"
+	"github.com/shrik450/wisdom/internal/tools"
+	"github.com/shrik450/wisdom/internal/transcribe"
+	"github.com/shrik450/wisdom/internal/watch"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// APIHandler serves the API. The server's tasks are registered as actions on
+// scheduler, which the caller runs. The caller also starts building
+// noteIndex, which otherwise happens on first use, and runs watcher, whose
+// changes refresh the note index and the change feed and are streamed to
+// clients.
+func APIHandler(scheduler *schedule.Scheduler, noteIndex *notes.Index, watcher *watch.Watcher, folders *protect.Folders) http.Handler {
+	uploads := newUploadStore(uploadDir)
+	pipeline := &importPipeline{
+		transcriber: newTranscriber(transcribe.FromEnv()),
+		ocr:         newOCRScanner(ocr.FromEnv()),
+	}
+	downloads := newDownloadManager(pipeline)
+	coverCache := covers.NewCache(covers.CacheDir)
+	metadataProvider := enrich.FromEnv()
+	languageModel := assist.FromEnv()
+	suggestions := newSuggester(languageModel)
+	reconciliations := newJobManager(reconcileTimeout)
+	toolRegistry := tools.FromEnv()
+	toolJobs := newJobManager(toolTimeout)
+	signer := share.FromEnv()
+	calibreLibrary := calibre.FromEnv()
+	calibreImports := newJobManager(calibreImportTimeout)
+	changeFeed := changes.NewFeed()
+	maint := &maintainer{uploads: uploads, covers: coverCache}
+	registerActions(scheduler, noteIndex, languageModel, toolRegistry, maint, mail.FromEnv())
+	watcher.Subscribe(func(ws *workspace.Workspace, events []watch.Event) {
+		paths := make([]string, len(events))
+		for i, e := range events {
+			coverCache.Invalidate(e.Path)
+			paths[i] = e.Path
+		}
+		noteIndex.Update(ws, paths...)
+		if err := changeFeed.Refresh(ws, time.Now()); err != nil {
+			slog.Warn("refresh change feed", "err", err)
+		}
+	})
+
+	features := enabledFeatures(map[string]bool{
+		"assist":        languageModel != nil,
+		"calibre":       calibreLibrary != "",
+		"enrichment":    enrich.Enabled(metadataProvider),
+		"ocr":           pipeline.ocr.enabled(),
+		"sharing":       signer != nil,
+		"tools":         toolRegistry != nil,
+		"transcription": pipeline.transcriber.enabled(),
+	})
+
+	walks := newWalkLimiter()
+	archives := newArchiveLimiter()
+	passwords := newPasswordLimiter()
+	unlocks := sealed.NewUnlocks()
 
-func APIHandler() http.Handler {
 	mux := http.NewServeMux()
-	mux.Handle("/api/fs/{path...}", fsHandler())
-	mux.Handle("/api/search/paths", searchPathsHandler())
+	mux.Handle("/api/{$}", capabilitiesHandler())
+	mux.Handle("/api/capabilities", capabilitiesHandler())
+	mux.Handle("/api/version", versionHandler(features))
+	mux.Handle("/api/fs/{path...}", fsHandler(noteIndex, unlocks))
+	mux.Handle("/api/fs:stat", walks.limit(statHandler(noteIndex)))
+	mux.Handle("/api/search/paths", walks.limit(searchPathsHandler()))
+	mux.Handle("/api/uploads", uploadsHandler(uploads))
+	mux.Handle("/api/uploads/{id}", uploadHandler(uploads))
+	mux.Handle("/api/uploads/{id}/finalize", uploadFinalizeHandler(uploads, pipeline))
+	mux.Handle("/api/downloads", downloadsHandler(downloads))
+	mux.Handle("/api/downloads/{id}", downloadHandler(downloads))
+	mux.Handle("/api/transcriptions", transcriptionsHandler(pipeline.transcriber))
+	mux.Handle("/api/transcriptions/{id}", jobHandler(pipeline.transcriber.jobs))
+	mux.Handle("/api/ocr", ocrHandler(pipeline.ocr))
+	mux.Handle("/api/ocr/{id}", jobHandler(pipeline.ocr.jobs))
+	mux.Handle("/api/imports/calibre", calibreImportHandler(calibreLibrary, calibreImports))
+	mux.Handle("/api/imports/calibre/jobs/{id}", jobHandler(calibreImports))
+	mux.Handle("/api/covers/{path...}", coversHandler(coverCache))
+	mux.Handle("/api/enrich/{path...}", enrichHandler(metadataProvider))
+	mux.Handle("/api/library/books", walks.limit(libraryBooksHandler()))
+	mux.Handle("/api/library/authors", walks.limit(libraryGroupsHandler(library.Authors)))
+	mux.Handle("/api/library/authors/{name}", walks.limit(libraryGroupHandler(library.ByAuthor)))
+	mux.Handle("/api/library/series", walks.limit(libraryGroupsHandler(library.Series)))
+	mux.Handle("/api/library/series/{name}", walks.limit(libraryGroupHandler(library.BySeries)))
+	mux.Handle("/api/library/collections", walks.limit(libraryGroupsHandler(library.Collections)))
+	mux.Handle("/api/library/collections/{name}", walks.limit(libraryCollectionHandler()))
+	mux.Handle("/api/library/reconcile", reconcileHandler(reconciliations))
+	mux.Handle("/api/library/reconcile/jobs/{id}", jobHandler(reconciliations))
+	mux.Handle("/api/reading/progress", readingProgressHandler())
+	mux.Handle("/api/reading/goal", readingGoalHandler())
+	mux.Handle("/api/reading/stats", readingStatsHandler())
+	mux.Handle("/api/timeline", walks.limit(timelineHandler()))
+	mux.Handle("/api/quotes/daily", dailyQuoteHandler())
+	mux.Handle("/api/srs/next", srsNextHandler())
+	mux.Handle("/api/srs/answer", srsAnswerHandler())
+	mux.Handle("/api/autocomplete", autocompleteHandler(noteIndex))
+	mux.Handle("/api/resolve", resolveHandler(noteIndex))
+	mux.Handle("/api/attachments/report", walks.limit(attachmentsReportHandler()))
+	mux.Handle("/api/attachments/fix", walks.limit(attachmentsFixHandler()))
+	mux.Handle("/api/viewprefs/{path...}", viewPrefsHandler())
+	mux.Handle("/api/tags", walks.limit(tagsTreeHandler()))
+	mux.Handle("/api/tags/notes", walks.limit(tagNotesHandler()))
+	mux.Handle("/api/tags/batch", walks.limit(tagsBatchHandler()))
+	mux.Handle("/api/reorganize", walks.limit(reorganizeHandler()))
+	mux.Handle("/api/retention/preview", walks.limit(retentionPreviewHandler()))
+	mux.Handle("/api/retention/apply", walks.limit(retentionApplyHandler()))
+	mux.Handle("/api/validate", validateHandler())
+	mux.Handle("/api/lint", walks.limit(lintHandler()))
+	mux.Handle("/api/filenames/violations", walks.limit(filenameViolationsHandler()))
+	mux.Handle("/api/query", walks.limit(queryHandler()))
+	mux.Handle("/api/boards/{path...}", boardHandler())
+	mux.Handle("/api/canvas/{path...}", canvasHandler())
+	mux.Handle("/api/people", walks.limit(peopleHandler(noteIndex)))
+	mux.Handle("/api/people/{path...}", walks.limit(personHandler(noteIndex)))
+	mux.Handle("/api/assist/summarize", summarizeHandler(languageModel))
+	mux.Handle("/api/assist/ask", askHandler(languageModel))
+	mux.Handle("/api/assist/suggestions", suggestionsHandler(suggestions))
+	mux.Handle("/api/assist/suggestions/{id}", suggestionHandler())
+	mux.Handle("/api/assist/suggestions/jobs/{id}", jobHandler(suggestions.jobs))
+	mux.Handle("/api/tools", toolsHandler(toolRegistry))
+	mux.Handle("/api/tools/{name}/run", toolRunHandler(toolRegistry, toolJobs))
+	mux.Handle("/api/tools/{name}/jobs/{id}", jobHandler(toolJobs))
+	mux.Handle("/api/protected", passwords.limit(protectedHandler(folders)))
+	mux.Handle("/api/protected/unlock", passwords.limit(unlockFolderHandler(folders)))
+	mux.Handle("/api/protected/lock", lockFoldersHandler(folders))
+	mux.Handle("/api/sealed/{path...}", passwords.limit(sealedHandler(unlocks)))
+	mux.Handle("/api/unlock/{path...}", passwords.limit(unlockHandler(unlocks)))
+	mux.Handle("/api/share", shareHandler(signer))
+	mux.Handle("/api/comments", commentsHandler())
+	mux.Handle("/api/comments/{id}", commentHandler())
+	mux.Handle("/api/schedules", schedulesHandler(scheduler))
+	mux.Handle("/api/schedules/{id}", scheduleHandler(scheduler))
+	mux.Handle("/api/schedules/{id}/run", scheduleRunHandler(scheduler))
+	mux.Handle("/api/snapshots", snapshotsHandler())
+	mux.Handle("/api/snapshots/{id}", snapshotHandler())
+	mux.Handle("/api/snapshots/{id}/fs/{path...}", snapshotFSHandler())
+	mux.Handle("/api/maintenance", maintenanceHandler(maint))
+	mux.Handle("/api/export", archives.limit(exportHandler()))
+	mux.Handle("/api/export/document", walks.limit(documentHandler(noteIndex)))
+	mux.Handle("/api/export/anki", walks.limit(ankiExportHandler()))
+	mux.Handle("/api/import", archives.limit(importHandler()))
+	mux.Handle("/api/diagnostics", diagnosticsHandler(diagnostics(noteIndex, scheduler, uploads, coverCache)))
+	mux.Handle("/api/metrics/caches", cacheMetricsHandler(coverCache))
+	mux.Handle("/api/changes", changesHandler(changeFeed))
+	mux.Handle("/api/sync/pull", syncPullHandler())
+	mux.Handle("/api/sync/push", syncPushHandler(unlocks))
+	mux.Handle("/api/events", eventsHandler(watcher))
 	return mux
 }
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
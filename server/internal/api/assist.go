@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/assist"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// summarizeHandler asks the model to summarize a note, or the section of
+// it under a heading, such as a chapter. The summary is returned and, if
+// asked, saved to the note's summary property ("frontmatter") or to a
+// summary note linking back to it ("note").
+func summarizeHandler(model assist.Model) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if model == nil {
+			http.Error(w, assist.ErrDisabled.Error()+"; set WISDOM_LLM_URL", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req struct {
+			Path    string `json:"path"`
+			Heading string `json:"heading"`
+			Save    string `json:"save"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		p := normalizePath(req.Path)
+		switch {
+		case !notes.IsNote(p):
+			http.Error(w, "only markdown notes can be summarized", http.StatusBadRequest)
+			return
+		case req.Save != "" && req.Save != "frontmatter" && req.Save != "note":
+			http.Error(w, "save must be frontmatter or note", http.StatusBadRequest)
+			return
+		case req.Save == "frontmatter" && req.Heading != "":
+			// A section's summary would overwrite the whole note's.
+			http.Error(w, "section summaries can only be saved as a note", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		data, err := ws.ReadFile(p)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		doc := frontmatter.Parse(string(data))
+		title := doc.String("title")
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(p), path.Ext(p))
+		}
+		content := doc.Body
+		if req.Heading != "" {
+			section, ok := assist.Section(doc.Body, req.Heading)
+			if !ok {
+				http.Error(w, "no heading named "+req.Heading, http.StatusNotFound)
+				return
+			}
+			content, title = section, title+": "+req.Heading
+		}
+
+		prompt, err := assist.Prompt(ws, "summarize", map[string]string{"title": title, "path": p, "content": content})
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		// The model can take longer to answer than the server's write
+		// timeout allows.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		summary, err := model.Complete(r.Context(), prompt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		var saved string
+		switch req.Save {
+		case "frontmatter":
+			if err := doc.Set("summary", summary); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			saved = p
+			err = ws.WriteFile(p, []byte(doc.Render()), 0o644)
+		case "note":
+			saved = summaryNotePath(p, req.Heading)
+			err = ws.WriteFile(saved, []byte(summaryNote(p, title, summary)), 0o644)
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"summary": summary, "saved": saved})
+	})
+}
+
+// askSources is how many excerpts are given to the model by default.
+const askSources = 6
+
+var citation = regexp.MustCompile(`\[(\d+)\]`)
+
+type askSource struct {
+	// N is the number the answer cites the source by, as [N].
+	N int `json:"n"`
+	assist.Chunk
+	Cited bool `json:"cited"`
+}
+
+// askHandler answers a question from the notes: the most relevant
+// excerpts are given to the model, and the answer comes back with the
+// excerpts as sources, marked with whether the answer cites them.
+func askHandler(model assist.Model) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if model == nil {
+			http.Error(w, assist.ErrDisabled.Error()+"; set WISDOM_LLM_URL", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req struct {
+			Question string `json:"question"`
+			Limit    int    `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Question) == "" {
+			http.Error(w, "question is required", http.StatusBadRequest)
+			return
+		}
+		if req.Limit <= 0 {
+			req.Limit = askSources
+		}
+
+		ws := workspace.FromContext(r.Context())
+		chunks, err := assist.Retrieve(ws, req.Question, req.Limit)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		if len(chunks) == 0 {
+			http.Error(w, "no notes match the question", http.StatusNotFound)
+			return
+		}
+
+		var excerpts strings.Builder
+		sources := make([]askSource, len(chunks))
+		for i, c := range chunks {
+			sources[i] = askSource{N: i + 1, Chunk: c}
+			fmt.Fprintf(&excerpts, "[%d] %s\n%s\n\n", i+1, c.Path, c.Text)
+		}
+		prompt, err := assist.Prompt(ws, "ask", map[string]string{"question": req.Question, "content": excerpts.String()})
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		// As when summarizing, the answer can outlast the write timeout.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		answer, err := model.Complete(r.Context(), prompt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, m := range citation.FindAllStringSubmatch(answer, -1) {
+			if n, _ := strconv.Atoi(m[1]); n >= 1 && n <= len(sources) {
+				sources[n-1].Cited = true
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"answer": answer, "sources": sources})
+	})
+}
+
+func summaryNotePath(p, heading string) string {
+	stem := strings.TrimSuffix(p, path.Ext(p))
+	if heading != "" {
+		stem += " - " + sanitizeFilename(heading)
+	}
+	return stem + ".summary.md"
+}
+
+func summaryNote(source, title, summary string) string {
+	doc := &frontmatter.Document{}
+	doc.Set("title", "Summary of "+title)
+	doc.Set("source", source)
+	name := path.Base(source)
+	doc.Body = "Summary of [" + title + "](" + (&url.URL{Path: name}).EscapedPath() + ").\n\n" + summary + "\n"
+	return doc.Render()
+}
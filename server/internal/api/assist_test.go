@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	var prompts []string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[0].Content)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"A desert planet."}}]}`))
+	}))
+	t.Cleanup(llm.Close)
+	t.Setenv("WISDOM_LLM_URL", llm.URL)
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("dune.md", []byte("---\ntitle: Dune\n---\n# Dune\n## Part 1\nArrakis.\n## Part 2\nMuad'Dib.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	summarize := func(t *testing.T, body string) (int, map[string]string) {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/assist/summarize", strings.NewReader(body))
+		defer resp.Body.Close()
+		var result map[string]string
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	t.Run("into frontmatter", func(t *testing.T) {
+		status, result := summarize(t, `{"path":"dune.md","save":"frontmatter"}`)
+		if status != http.StatusOK || result["summary"] != "A desert planet." || result["saved"] != "dune.md" {
+			t.Fatalf("status=%d result=%v", status, result)
+		}
+		data, _ := ws.ReadFile("dune.md")
+		if !strings.Contains(string(data), "summary: \"A desert planet.\"\n---\n") {
+			t.Errorf("note = %s", data)
+		}
+	})
+
+	t.Run("a chapter into a note", func(t *testing.T) {
+		status, result := summarize(t, `{"path":"dune.md","heading":"Part 1","save":"note"}`)
+		if status != http.StatusOK || result["saved"] != "dune - Part 1.summary.md" {
+			t.Fatalf("status=%d result=%v", status, result)
+		}
+		if p := prompts[len(prompts)-1]; !strings.Contains(p, "Arrakis.") || strings.Contains(p, "Muad'Dib") {
+			t.Errorf("prompt = %q", p)
+		}
+		data, _ := ws.ReadFile(result["saved"])
+		if !strings.Contains(string(data), "Summary of [Dune: Part 1](dune.md).\n\nA desert planet.\n") {
+			t.Errorf("summary note = %s", data)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for body, want := range map[string]int{
+			`{"path":"dune.md","heading":"Part 3"}`:                      http.StatusNotFound,
+			`{"path":"dune.md","heading":"Part 1","save":"frontmatter"}`: http.StatusBadRequest,
+			`{"path":"cover.jpg"}`:                                       http.StatusBadRequest,
+			`{"path":"missing.md"}`:                                      http.StatusNotFound,
+		} {
+			if status, _ := summarize(t, body); status != want {
+				t.Errorf("%s: status = %d, want %d", body, status, want)
+			}
+		}
+	})
+}
+
+func TestAsk(t *testing.T) {
+	var prompt string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Messages[0].Content
+		w.Write([]byte(`{"choices":[{"message":{"content":"Full sun [1], and more [9]."}}]}`))
+	}))
+	t.Cleanup(llm.Close)
+	t.Setenv("WISDOM_LLM_URL", llm.URL)
+	srv, ws := newTestServer(t)
+	for p, content := range map[string]string{
+		"garden.md":  "Tomatoes need full sun.\n",
+		"kitchen.md": "Roast tomatoes slowly.\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/assist/ask", strings.NewReader(`{"question":"What sun do tomatoes need?"}`))
+	defer resp.Body.Close()
+	var result struct {
+		Answer  string `json:"answer"`
+		Sources []struct {
+			N     int    `json:"n"`
+			Path  string `json:"path"`
+			Start int    `json:"start"`
+			End   int    `json:"end"`
+			Cited bool   `json:"cited"`
+		} `json:"sources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Answer != "Full sun [1], and more [9]." || len(result.Sources) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if s := result.Sources[0]; s.Path != "garden.md" || s.Start != 0 || s.End != 23 || !s.Cited || result.Sources[1].Cited {
+		t.Errorf("sources = %+v", result.Sources)
+	}
+	if !strings.Contains(prompt, "Question: What sun do tomatoes need?") || !strings.Contains(prompt, "[1] garden.md\nTomatoes need full sun.") {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/assist/ask", strings.NewReader(`{"question":"quantum physics"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unanswerable status = %d, want 404", resp.StatusCode)
+	}
+}
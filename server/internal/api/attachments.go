@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/attachments"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func attachmentFolders(param string) []string {
+	if param == "" {
+		return attachments.DefaultFolders
+	}
+	return strings.Split(param, ",")
+}
+
+// attachmentsReportHandler lists broken attachment links and orphaned
+// files in attachment folders (?folders=attachments,assets by default).
+func attachmentsReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		report, err := attachments.Scan(ws, attachmentFolders(r.URL.Query().Get("folders")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+}
+
+// attachmentsFixHandler applies the fixes chosen in the body: relinking
+// missing files with a single same-named candidate, and moving orphans to
+// the trash.
+func attachmentsFixHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Folders []string `json:"folders"`
+			Relink  bool     `json:"relink"`
+			Trash   bool     `json:"trash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if len(req.Folders) == 0 {
+			req.Folders = attachments.DefaultFolders
+		}
+
+		ws := workspace.FromContext(r.Context())
+		report, err := attachments.Scan(ws, req.Folders)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := struct {
+			Relinked []string `json:"relinked"`
+			Trashed  []string `json:"trashed"`
+		}{Relinked: []string{}, Trashed: []string{}}
+		if req.Relink {
+			if resp.Relinked, err = attachments.Relink(ws, report.Missing); err != nil {
+				mapError(w, err)
+				return
+			}
+		}
+		if req.Trash {
+			// Files that were just relinked are no longer orphans.
+			if req.Relink {
+				if report, err = attachments.Scan(ws, req.Folders); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			if resp.Trashed, err = attachments.Trash(ws, report.Orphans); err != nil {
+				mapError(w, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
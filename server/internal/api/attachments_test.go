@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type attachmentsReport struct {
+	Missing []struct {
+		Note       string   `json:"note"`
+		Line       int      `json:"line"`
+		Target     string   `json:"target"`
+		Candidates []string `json:"candidates"`
+	} `json:"missing"`
+	Orphans []string `json:"orphans"`
+}
+
+func TestAttachments(t *testing.T) {
+	srv, ws := newTestServer(t)
+	files := map[string]string{
+		"notes/trip.md": "# Trip\n\n![beach](../attachments/beach%20day.jpg)\n![moved](old/map.png \"Map\")\n" +
+			"![[ticket.pdf]] and ![[lost.pdf|200]]\n[other note](other.md) [site](https://example.com)\n",
+		"attachments/beach day.jpg":  "jpg",
+		"attachments/unused.png":     "png",
+		"attachments/trips/map.png":  "png",
+		"attachments/ticket.pdf":     "pdf",
+		"elsewhere/not-tracked.png":  "png",
+		"notes/assets/also-used.gif": "gif",
+		"notes/diagram.md":           "![](assets/also-used.gif)\n",
+	}
+	for p, content := range files {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := func(t *testing.T) attachmentsReport {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/attachments/report", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+		var r attachmentsReport
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r := report(t)
+	if len(r.Missing) != 2 {
+		t.Fatalf("missing = %+v", r.Missing)
+	}
+	if m := r.Missing[0]; m.Target != "old/map.png" || m.Line != 4 || !reflect.DeepEqual(m.Candidates, []string{"attachments/trips/map.png"}) {
+		t.Errorf("missing[0] = %+v", m)
+	}
+	if m := r.Missing[1]; m.Target != "lost.pdf" || len(m.Candidates) != 0 {
+		t.Errorf("missing[1] = %+v", m)
+	}
+	if want := []string{"attachments/trips/map.png", "attachments/unused.png"}; !reflect.DeepEqual(r.Orphans, want) {
+		t.Errorf("orphans = %v, want %v", r.Orphans, want)
+	}
+
+	t.Run("fix relinks and trashes", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/attachments/fix", strings.NewReader(`{"relink":true,"trash":true}`))
+		defer resp.Body.Close()
+		var fixed struct {
+			Relinked []string `json:"relinked"`
+			Trashed  []string `json:"trashed"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&fixed); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fixed.Relinked, []string{"notes/trip.md"}) ||
+			!reflect.DeepEqual(fixed.Trashed, []string{".trash/attachments/unused.png"}) {
+			t.Fatalf("fix = %+v", fixed)
+		}
+
+		note, err := ws.ReadFile("notes/trip.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(note), "![moved](../attachments/trips/map.png \"Map\")") {
+			t.Errorf("note not relinked:\n%s", note)
+		}
+		if _, err := ws.Stat(".trash/attachments/unused.png"); err != nil {
+			t.Errorf("orphan not trashed: %v", err)
+		}
+
+		r := report(t)
+		if len(r.Missing) != 1 || len(r.Orphans) != 0 {
+			t.Errorf("report after fix = %+v", r)
+		}
+	})
+}
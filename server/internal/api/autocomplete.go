@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// maxFrecencyBonus caps how far frecency can lift a weak fuzzy match.
+const maxFrecencyBonus = 50
+
+type linkSuggestion struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	// Alias is set when the query matched one of the note's aliases best.
+	Alias string `json:"alias,omitempty"`
+	Score int    `json:"score"`
+}
+
+// autocompleteHandler suggests link targets for editors. Notes are matched
+// on title, aliases and path, and ranked by fuzzy score plus frecency. An
+// empty query returns the most frecent notes.
+func autocompleteHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if t := r.URL.Query().Get("type"); t != "" && t != "link" {
+			http.Error(w, "unsupported autocomplete type", http.StatusBadRequest)
+			return
+		}
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		limit := searchPageLimits.def
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = min(n, searchPageLimits.max)
+		}
+
+		all, err := index.Notes(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		results := []linkSuggestion{}
+		for _, n := range all {
+			s, ok := matchLink(query, n)
+			if !ok {
+				continue
+			}
+			frecency := index.Frecency(n.Path, now)
+			if query == "" && frecency == 0 {
+				continue
+			}
+			s.Score += min(frecency/10, maxFrecencyBonus)
+			results = append(results, s)
+		}
+
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Score != results[j].Score {
+				return results[i].Score > results[j].Score
+			}
+			return results[i].Path < results[j].Path
+		})
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		writeJSON(w, http.StatusOK, results)
+	})
+}
+
+func matchLink(query string, n notes.Note) (linkSuggestion, bool) {
+	s := linkSuggestion{Path: n.Path, Title: n.Title}
+	if query == "" {
+		return s, true
+	}
+
+	best, matched := 0, false
+	try := func(candidate, alias string) {
+		if score, ok := FuzzyMatch(query, candidate); ok && (!matched || score > best) {
+			best, matched, s.Alias = score, true, alias
+		}
+	}
+	try(n.Title, "")
+	for _, alias := range n.Aliases {
+		try(alias, alias)
+	}
+	try(n.Path, "")
+
+	s.Score = best
+	return s, matched
+}
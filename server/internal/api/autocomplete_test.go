@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type linkSuggestion struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	Alias string `json:"alias"`
+}
+
+func TestAutocompleteLinks(t *testing.T) {
+	srv, ws := newTestServer(t)
+	files := map[string]string{
+		"people/ada.md":     "---\ntitle: Ada Lovelace\naliases: [Countess of Lovelace]\n---\n",
+		"people/babbage.md": "# Charles Babbage\n",
+		"projects/adapt.md": "# Adapter pattern\n",
+	}
+	for _, dir := range []string{"people", "projects"} {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, content := range files {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(t *testing.T, query string, want ...linkSuggestion) {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/autocomplete?type=link&q="+url.QueryEscape(query), nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+		var got []linkSuggestion
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) < len(want) {
+			t.Fatalf("q=%q: got %+v, want at least %+v", query, got, want)
+		}
+		for i, w := range want {
+			if got[i] != w {
+				t.Errorf("q=%q result %d = %+v, want %+v", query, i, got[i], w)
+			}
+		}
+	}
+
+	ada := linkSuggestion{Path: "people/ada.md", Title: "Ada Lovelace"}
+	adapt := linkSuggestion{Path: "projects/adapt.md", Title: "Adapter pattern"}
+
+	check(t, "babbage", linkSuggestion{Path: "people/babbage.md", Title: "Charles Babbage"})
+	check(t, "countess", linkSuggestion{Path: "people/ada.md", Title: "Ada Lovelace", Alias: "Countess of Lovelace"})
+	check(t, "")
+
+	t.Run("opened notes rank higher", func(t *testing.T) {
+		check(t, "ada", ada, adapt)
+		for range 3 {
+			doRequest(t, http.MethodGet, srv.URL+"/api/fs/projects/adapt.md", nil).Body.Close()
+		}
+		check(t, "ada", adapt, ada)
+		check(t, "", adapt)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/autocomplete?type=tag&q=a", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/backup"
+	"github.com/shrik450/wisdom/internal/wlog"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func mapBackupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, backup.ErrInvalidArchive):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, backup.ErrIncompatible):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		mapError(w, err)
+	}
+}
+
+// exportHandler streams the whole workspace as a zip archive. Once the
+// archive has started, an error can only cut it short, so clients should
+// check it opens before relying on it.
+func exportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// A whole workspace takes longer to send than the server's write
+		// timeout allows.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		snapshots, _ := strconv.ParseBool(r.URL.Query().Get("snapshots"))
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="wisdom-`+now.Format("2006-01-02")+`.zip"`)
+		ws := workspace.FromContext(r.Context())
+		if err := backup.Export(ws, w, backup.Options{Snapshots: snapshots}, now); err != nil {
+			wlog.FromContext(r.Context()).Warn("export", "err", err)
+		}
+	})
+}
+
+// importDir stages archives being imported.
+const importDir = workspace.ScratchDir + "/imports"
+
+// importHandler restores an archive made by exportHandler. The body is
+// spooled to importDir first, as reading a zip needs random access.
+func importHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// A whole workspace takes longer to receive, and to restore before
+		// answering, than the server's timeouts allow.
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		scratch := ws.Full()
+		if err := scratch.MkdirAll(importDir, 0o755); err != nil {
+			mapError(w, err)
+			return
+		}
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		staged := importDir + "/" + hex.EncodeToString(buf) + ".zip"
+		defer scratch.Remove(staged)
+		if err := scratch.WriteStream(staged, r.Body, 0o600); err != nil {
+			http.Error(w, "reading archive: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		info, err := scratch.Stat(staged)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		f, err := scratch.Open(staged)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		defer f.Close()
+
+		m, err := backup.Restore(ws, readerAt(f), info.Size())
+		if err != nil {
+			mapBackupError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	})
+}
+
+// readerAt reads at offsets from a workspace file. Plain files can do it
+// themselves; encrypted ones can only seek.
+func readerAt(f io.ReadSeeker) io.ReaderAt {
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra
+	}
+	return &seekReaderAt{r: f}
+}
+
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/api"
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/watch"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestImportIntoEncryptedWorkspace(t *testing.T) {
+	src, ws := newTestServer(t)
+	if err := ws.MkdirAll("notes", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("notes/plan.md", []byte("Plan.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resp := doRequest(t, http.MethodGet, src.URL+"/api/export", nil)
+	archive, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("export = %d", resp.StatusCode)
+	}
+
+	encrypted, err := workspace.NewEncrypted(t.TempDir(), bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders := protect.New()
+	handler := middleware.WithWorkspace(middleware.LockFolders(api.APIHandler(schedule.New(encrypted, slog.Default()), notes.NewIndex(), watch.New(encrypted, slog.Default()), folders), folders), encrypted)
+	dst := httptest.NewServer(handler)
+	t.Cleanup(dst.Close)
+
+	resp = doRequest(t, http.MethodPost, dst.URL+"/api/import", bytes.NewReader(archive))
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import = %d %s", resp.StatusCode, body)
+	}
+	if data, err := encrypted.ReadFile("notes/plan.md"); err != nil || string(data) != "Plan.\n" {
+		t.Errorf("restored plan.md = %q, %v", data, err)
+	}
+	if entries, _ := encrypted.Full().ReadDir(workspace.ScratchDir + "/imports"); len(entries) != 0 {
+		t.Errorf("staged archive left behind: %d files", len(entries))
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/shrik450/wisdom/internal/kanban"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// boardsMu serializes edits, which rewrite the whole board file.
+var boardsMu sync.Mutex
+
+// boardHandler reads the markdown file at the path as a kanban board (GET)
+// and applies a card operation to it (POST), returning the updated board.
+func boardHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		if !notes.IsNote(p) {
+			http.Error(w, "boards are markdown files", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := ws.ReadFile(p)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, kanban.Parse(string(data)))
+		case http.MethodPost:
+			var req struct {
+				Op      string `json:"op"`
+				Column  string `json:"column"`
+				Index   int    `json:"index"`
+				To      string `json:"to"`
+				ToIndex int    `json:"toIndex"`
+				Text    string `json:"text"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+
+			boardsMu.Lock()
+			defer boardsMu.Unlock()
+			data, err := ws.ReadFile(p)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			board := kanban.Parse(string(data))
+			switch req.Op {
+			case "add":
+				err = board.Add(req.Column, req.Text)
+			case "move":
+				err = board.Move(req.Column, req.Index, req.To, req.ToIndex)
+			case "archive":
+				err = board.Archive(req.Column, req.Index)
+			default:
+				http.Error(w, "op must be add, move or archive", http.StatusBadRequest)
+				return
+			}
+			switch {
+			case errors.Is(err, kanban.ErrNoColumn), errors.Is(err, kanban.ErrNoCard):
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := ws.WriteFile(p, []byte(board.Render()), 0o644); err != nil {
+				mapError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, board)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBoards(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("board.md", []byte("## Todo\n- [ ] Draft\n## Done\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(t *testing.T, body string) *http.Response {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/boards/board.md", strings.NewReader(body))
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, body := range []string{
+		`{"op":"add","column":"Todo","text":"Review"}`,
+		`{"op":"move","column":"Todo","index":0,"to":"Done","toIndex":0}`,
+		`{"op":"archive","column":"Done","index":0}`,
+	} {
+		if resp := post(t, body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status=%d", body, resp.StatusCode)
+		}
+	}
+	data, err := ws.ReadFile("board.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Todo\n- [ ] Review\n## Done\n## Archive\n- [ ] Draft\n"; string(data) != want {
+		t.Errorf("board.md = %q, want %q", data, want)
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/boards/board.md", nil)
+	defer resp.Body.Close()
+	var board struct {
+		Columns []struct {
+			Title string `json:"title"`
+			Cards []struct {
+				Text string `json:"text"`
+			} `json:"cards"`
+		} `json:"columns"`
+		Archive []struct {
+			Text string `json:"text"`
+		} `json:"archive"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&board); err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Columns) != 2 || board.Columns[0].Cards[0].Text != "Review" || len(board.Archive) != 1 {
+		t.Errorf("board = %+v", board)
+	}
+
+	for body, status := range map[string]int{
+		`{"op":"move","column":"Todo","index":3,"to":"Done"}`: http.StatusNotFound,
+		`{"op":"add","column":"Later","text":"x"}`:            http.StatusNotFound,
+		`{"op":"add","column":"Todo"}`:                        http.StatusBadRequest,
+		`{"op":"delete","column":"Todo"}`:                     http.StatusBadRequest,
+	} {
+		if resp := post(t, body); resp.StatusCode != status {
+			t.Errorf("%s: status = %d, want %d", body, resp.StatusCode, status)
+		}
+	}
+}
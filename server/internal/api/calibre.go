@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/calibre"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const defaultCalibreDestination = "books"
+
+const calibreImportTimeout = time.Hour
+
+// calibreImportHandler reports the last import of the Calibre library at
+// library, the one the server is configured with, on GET, and starts one
+// into the workspace in the background on POST.
+func calibreImportHandler(library string, jobs *jobManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if library == "" {
+			http.Error(w, calibre.ErrDisabled.Error()+"; set WISDOM_CALIBRE_LIBRARY", http.StatusServiceUnavailable)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+
+		if r.Method == http.MethodGet {
+			report, err := calibre.LoadReport(ws)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if report == nil {
+				http.Error(w, "the library hasn't been imported yet", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, report)
+			return
+		}
+
+		var req struct {
+			Destination string `json:"destination"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		dest := defaultCalibreDestination
+		if req.Destination != "" {
+			dest = normalizePath(req.Destination)
+		}
+		if _, err := ws.Resolve(dest); err != nil {
+			mapError(w, err)
+			return
+		}
+
+		job := jobs.start(dest, calibre.ReportPath, func(ctx context.Context) error {
+			_, err := calibre.Import(ws, library, dest, time.Now())
+			return err
+		})
+		w.Header().Set("Location", "/api/imports/calibre/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	})
+}
@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCalibreImport(t *testing.T) {
+	t.Run("off without a library", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/imports/calibre", strings.NewReader(`{}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("status=%d, want 503", resp.StatusCode)
+		}
+	})
+
+	t.Run("reads the configured library only", func(t *testing.T) {
+		library := t.TempDir()
+		bookDir := filepath.Join(library, "Ursula K. Le Guin", "Lathe of Heaven (3)")
+		if err := os.MkdirAll(bookDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		opf := `<package><metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>The Lathe of Heaven</dc:title></metadata></package>`
+		if err := os.WriteFile(filepath.Join(bookDir, "metadata.opf"), []byte(opf), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("WISDOM_CALIBRE_LIBRARY", library)
+		srv, ws := newTestServer(t)
+
+		body := `{"library":"` + t.TempDir() + `","destination":"shelf"}`
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/imports/calibre", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("status=%d, want 202", resp.StatusCode)
+		}
+		if job := waitForJob(t, srv.URL+resp.Header.Get("Location")); job.Status != "completed" {
+			t.Fatalf("job = %+v", job)
+		}
+		resp = doRequest(t, http.MethodGet, srv.URL+"/api/imports/calibre", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("last import: status=%d, want 200", resp.StatusCode)
+		}
+		if _, err := ws.Stat("shelf/Ursula K. Le Guin/Lathe of Heaven/Lathe of Heaven.md"); err != nil {
+			t.Fatalf("book from the configured library not imported: %v", err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/shrik450/wisdom/internal/canvas"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// maxCanvasSize bounds the body of a canvas PUT, which is read whole to be
+// validated.
+const maxCanvasSize = 16 << 20
+
+// canvasHandler reads (GET), validates and writes (PUT) and deletes
+// (DELETE) .canvas files. Canvases are stored exactly as sent, so fields
+// other tools add survive.
+func canvasHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		if !canvas.IsCanvas(p) {
+			http.Error(w, "canvases must have the "+canvas.Ext+" extension", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := ws.ReadFile(p)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		case http.MethodPut:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCanvasSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if _, err := canvas.Parse(data); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_, err = ws.Stat(p)
+			isNew := errors.Is(err, os.ErrNotExist)
+			if err != nil && !isNew {
+				mapError(w, err)
+				return
+			}
+			if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+				mapError(w, err)
+				return
+			}
+			if err := ws.WriteFile(p, data, 0o644); err != nil {
+				mapError(w, err)
+				return
+			}
+			if isNew {
+				w.WriteHeader(http.StatusCreated)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case http.MethodDelete:
+			if err := ws.Remove(p); err != nil {
+				mapError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCanvas(t *testing.T) {
+	srv, ws := newTestServer(t)
+	url := srv.URL + "/api/canvas/boards/dune.canvas"
+	board := `{"nodes":[{"id":"a","type":"file","x":0,"y":0,"width":400,"height":400,"file":"attachments/map.png"}],"edges":[],"viewport":{"zoom":1}}`
+
+	resp := doRequest(t, http.MethodPut, url, strings.NewReader(board))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status=%d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodGet, url, nil)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != board {
+		t.Errorf("GET = %s, want the canvas as stored", data)
+	}
+
+	t.Run("embedded files aren't orphans", func(t *testing.T) {
+		if err := ws.MkdirAll("attachments", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile("attachments/map.png", []byte("png"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/attachments/report", nil)
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(data), `"orphans":[]`) {
+			t.Errorf("report = %s", data)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for target, body := range map[string]string{
+			url:                                `{"nodes":[{"id":"a","type":"text"}]}`,
+			srv.URL + "/api/canvas/board.json": board,
+		} {
+			resp := doRequest(t, http.MethodPut, target, strings.NewReader(body))
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("PUT %s status = %d, want 400", target, resp.StatusCode)
+			}
+		}
+	})
+
+	resp = doRequest(t, http.MethodDelete, url, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete status=%d", resp.StatusCode)
+	}
+	if _, err := ws.Stat("boards/dune.canvas"); err == nil {
+		t.Error("canvas still exists")
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/shrik450/wisdom/internal/backup"
+)
+
+// capabilities tells clients what the fs API can do, so they can detect
+// features instead of probing with requests that fail. Operations sync
+// clients commonly look for are listed even when not offered.
+type capabilities struct {
+	// Conditional is PUT with If-Unmodified-Since.
+	Conditional bool `json:"conditional"`
+	// ConflictCopy is PUT ?onConflict=copy.
+	ConflictCopy bool `json:"conflictCopy"`
+	// ModTime is PUT with X-Wisdom-Mtime.
+	ModTime bool `json:"modTime"`
+	// Copy is PATCH with "copy" and "preserve".
+	Copy bool `json:"copy"`
+	// RangeReads is GET with Range.
+	RangeReads bool `json:"rangeReads"`
+	// ResumableUploads is /api/uploads.
+	ResumableUploads bool `json:"resumableUploads"`
+	// Batch is /api/sync/pull and /api/sync/push.
+	Batch bool `json:"batch"`
+	// MaxBatchBytes bounds the content in one batch.
+	MaxBatchBytes int64 `json:"maxBatchBytes"`
+	// BatchStat is /api/fs:stat, for up to MaxStatPaths paths.
+	BatchStat    bool `json:"batchStat"`
+	MaxStatPaths int  `json:"maxStatPaths"`
+	// Changes is /api/changes.
+	Changes bool `json:"changes"`
+	// Events is /api/events.
+	Events bool `json:"events"`
+	// Not offered.
+	Append      bool `json:"append"`
+	Locks       bool `json:"locks"`
+	RangeWrites bool `json:"rangeWrites"`
+
+	// ArchiveFormats are those /api/export can write.
+	ArchiveFormats []string `json:"archiveFormats"`
+	// ExportFormat is the archive format version /api/export writes and the
+	// newest /api/import reads.
+	ExportFormat int `json:"exportFormat"`
+}
+
+// capabilitiesHandler answers GET /api/capabilities, and OPTIONS /api/ as
+// sync clients conventionally ask.
+func capabilitiesHandler() http.Handler {
+	caps := capabilities{
+		Conditional:      true,
+		ConflictCopy:     true,
+		ModTime:          true,
+		Copy:             true,
+		RangeReads:       true,
+		ResumableUploads: true,
+		Batch:            true,
+		MaxBatchBytes:    maxSyncBatch,
+		BatchStat:        true,
+		MaxStatPaths:     maxStatBatch,
+		Changes:          true,
+		Events:           true,
+		ArchiveFormats:   []string{"zip"},
+		ExportFormat:     backup.FormatVersion,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodOptions {
+			w.Header().Set("Allow", "GET, OPTIONS")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", "GET, OPTIONS")
+		}
+		writeJSON(w, http.StatusOK, caps)
+	})
+}
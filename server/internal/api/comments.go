@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/comments"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func mapCommentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, comments.ErrUnknownComment):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, comments.ErrUnknownParent), errors.Is(err, comments.ErrEmpty):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		mapError(w, err)
+	}
+}
+
+// commentsHandler lists the comments on the note in ?path= (GET), oldest
+// first, and adds one (POST).
+func commentsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			p := r.URL.Query().Get("path")
+			if p == "" {
+				http.Error(w, "path is required", http.StatusBadRequest)
+				return
+			}
+			list, err := comments.On(ws, normalizePath(p))
+			if err != nil {
+				mapCommentError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var c comments.Comment
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			if c.Path == "" {
+				http.Error(w, "path is required", http.StatusBadRequest)
+				return
+			}
+			c.Path = normalizePath(c.Path)
+			if _, err := ws.Stat(c.Path); err != nil {
+				mapError(w, err)
+				return
+			}
+			c, err := comments.Add(ws, c, time.Now())
+			if err != nil {
+				mapCommentError(w, err)
+				return
+			}
+			w.Header().Set("Location", "/api/comments/"+c.ID)
+			writeJSON(w, http.StatusCreated, c)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// commentHandler edits the body of a comment (PUT) or deletes it with its
+// replies (DELETE).
+func commentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodPut:
+			var req struct {
+				Body string `json:"body"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			c, err := comments.Edit(ws, r.PathValue("id"), req.Body, time.Now())
+			if err != nil {
+				mapCommentError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, c)
+		case http.MethodDelete:
+			if err := comments.Delete(ws, r.PathValue("id")); err != nil {
+				mapCommentError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
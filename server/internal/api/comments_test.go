@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("draft.md", []byte("# Draft\n\nFirst point. ^intro\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	type comment struct {
+		ID     string `json:"id"`
+		Block  string `json:"block"`
+		Parent string `json:"parent"`
+		Body   string `json:"body"`
+		Edited string `json:"edited"`
+	}
+	add := func(t *testing.T, body string, status int) comment {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/comments", strings.NewReader(body))
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("POST %s: status = %d, want %d", body, resp.StatusCode, status)
+		}
+		var c comment
+		json.NewDecoder(resp.Body).Decode(&c)
+		return c
+	}
+	list := func(t *testing.T) []comment {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/comments?path=draft.md", nil)
+		defer resp.Body.Close()
+		var cs []comment
+		if err := json.NewDecoder(resp.Body).Decode(&cs); err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+
+	root := add(t, `{"path":"draft.md","block":"^intro","body":"Say more?"}`, http.StatusCreated)
+	reply := add(t, `{"path":"draft.md","parent":"`+root.ID+`","body":"Done."}`, http.StatusCreated)
+	other := add(t, `{"path":"/draft.md","body":"Looks good overall."}`, http.StatusCreated)
+	if reply.Block != "^intro" || reply.Parent != root.ID {
+		t.Errorf("reply = %+v, want it in the thread on ^intro", reply)
+	}
+	if cs := list(t); len(cs) != 3 || cs[0].ID != root.ID || cs[2].ID != other.ID {
+		t.Errorf("comments = %+v", cs)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		add(t, `{"path":"draft.md","body":"  "}`, http.StatusBadRequest)
+		add(t, `{"path":"draft.md","parent":"nope","body":"Hi"}`, http.StatusBadRequest)
+		add(t, `{"path":"missing.md","body":"Hi"}`, http.StatusNotFound)
+	})
+
+	resp := doRequest(t, http.MethodPut, srv.URL+"/api/comments/"+other.ID, strings.NewReader(`{"body":"Looks great."}`))
+	var edited comment
+	json.NewDecoder(resp.Body).Decode(&edited)
+	resp.Body.Close()
+	if edited.Body != "Looks great." || edited.Edited == "" {
+		t.Errorf("edited = %+v", edited)
+	}
+
+	resp = doRequest(t, http.MethodDelete, srv.URL+"/api/comments/"+root.ID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", resp.StatusCode)
+	}
+	if cs := list(t); len(cs) != 1 || cs[0].ID != other.ID {
+		t.Errorf("after deleting the thread, comments = %+v", cs)
+	}
+	resp = doRequest(t, http.MethodDelete, srv.URL+"/api/comments/"+reply.ID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleting a deleted reply: status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func coversHandler(cache *covers.Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		size := r.URL.Query().Get("size")
+		if size == "" {
+			size = "medium"
+		}
+		if _, ok := covers.Sizes[size]; !ok && size != covers.Original {
+			http.Error(w, "unknown size", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		info, err := ws.Stat(p)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+
+		data, err := cache.Get(ws, p, size)
+		if errors.Is(err, covers.ErrNoCover) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, covers.ErrCoverTooLarge) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+		http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(data))
+	})
+}
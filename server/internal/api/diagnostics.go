@@ -0,0 +1,125 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Soft limits: going over one is worth a look, not an error.
+const (
+	snapshotsSoftLimit = 5 << 30
+	coversSoftLimit    = 1 << 30
+)
+
+const (
+	levelOK   = "ok"
+	levelWarn = "warn"
+	// levelError is for a check that couldn't run.
+	levelError = "error"
+)
+
+type finding struct {
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message,omitempty"`
+	// Value and Limit are set for checks against a soft limit.
+	Value int64 `json:"value,omitempty"`
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// diagnostic checks something that grows or goes stale, so it can be dealt
+// with before it causes errors.
+type diagnostic struct {
+	name string
+	run  func(ws *workspace.Workspace) (finding, error)
+}
+
+func diagnostics(index *notes.Index, scheduler *schedule.Scheduler, uploads *uploadStore, coverCache *covers.Cache) []diagnostic {
+	return []diagnostic{
+		{"snapshots", func(ws *workspace.Workspace) (finding, error) {
+			usage, err := snapshot.Usage(ws)
+			return softLimit(usage, snapshotsSoftLimit, "snapshots take more space than expected; delete old ones or run maintenance with keepSnapshots"), err
+		}},
+		{"covers", func(ws *workspace.Workspace) (finding, error) {
+			usage, err := coverCache.Usage(ws)
+			return softLimit(usage, coversSoftLimit, "the cover cache is large; run maintenance to drop unused covers"), err
+		}},
+		{"uploads", func(ws *workspace.Workspace) (finding, error) {
+			stale, err := uploads.staleSessions(ws, uploadSessionMaxAge)
+			if err != nil || len(stale) == 0 {
+				return finding{Level: levelOK}, err
+			}
+			return finding{
+				Level:   levelWarn,
+				Message: fmt.Sprintf("%d abandoned upload sessions; run maintenance to remove them", len(stale)),
+				Value:   int64(len(stale)),
+			}, nil
+		}},
+		{"schedules", func(ws *workspace.Workspace) (finding, error) {
+			all, err := schedule.Load(ws)
+			if err != nil {
+				return finding{}, err
+			}
+			var failed []string
+			for _, sched := range all {
+				if scheduler.Status(sched.ID).Error != "" {
+					failed = append(failed, cmp.Or(sched.Name, sched.ID))
+				}
+			}
+			if len(failed) == 0 {
+				return finding{Level: levelOK}, nil
+			}
+			return finding{Level: levelWarn, Message: "last run failed: " + strings.Join(failed, ", "), Value: int64(len(failed))}, nil
+		}},
+		{"noteIndex", func(ws *workspace.Workspace) (finding, error) {
+			p := index.Progress()
+			if p.Ready {
+				return finding{Level: levelOK}, nil
+			}
+			return finding{Level: levelWarn, Message: fmt.Sprintf("still building: %d of %d notes read", p.Indexed, p.Total)}, nil
+		}},
+	}
+}
+
+func softLimit(value, limit int64, advice string) finding {
+	f := finding{Level: levelOK, Value: value, Limit: limit}
+	if value > limit {
+		f.Level, f.Message = levelWarn, advice
+	}
+	return f
+}
+
+// diagnosticsHandler runs every check. The overall level is the worst of
+// the checks'.
+func diagnosticsHandler(checks []diagnostic) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		level := levelOK
+		findings := make([]finding, 0, len(checks))
+		for _, c := range checks {
+			f, err := c.run(ws)
+			if err != nil {
+				f = finding{Level: levelError, Message: err.Error()}
+			}
+			f.Check = c.name
+			if f.Level == levelError || (f.Level == levelWarn && level == levelOK) {
+				level = f.Level
+			}
+			findings = append(findings, f)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"level": level, "checks": findings})
+	})
+}
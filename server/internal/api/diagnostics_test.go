@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	type report struct {
+		Level  string `json:"level"`
+		Checks []struct {
+			Check   string `json:"check"`
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		} `json:"checks"`
+	}
+	get := func() (report, map[string]string) {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/diagnostics", nil)
+		defer resp.Body.Close()
+		var r report
+		json.NewDecoder(resp.Body).Decode(&r)
+		levels := map[string]string{}
+		for _, c := range r.Checks {
+			levels[c.Check] = c.Level
+		}
+		return r, levels
+	}
+
+	// The note index is built on first use in tests.
+	if _, levels := get(); levels["noteIndex"] != "warn" {
+		t.Errorf("noteIndex before use = %q, want warn", levels["noteIndex"])
+	}
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/autocomplete?q=", nil)
+	resp.Body.Close()
+	if r, _ := get(); r.Level != "ok" {
+		t.Errorf("level = %q, want ok: %+v", r.Level, r.Checks)
+	}
+
+	// Tools aren't configured, so this schedule fails.
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/schedules",
+		strings.NewReader(`{"name":"Broken","cron":"@daily","action":"tool"}`))
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/schedules/"+created.ID+"/run", nil)
+	resp.Body.Close()
+
+	r, levels := get()
+	if r.Level != "warn" || levels["schedules"] != "warn" {
+		t.Errorf("after a failed run: %+v", r)
+	}
+	for _, c := range r.Checks {
+		if c.Check == "schedules" && !strings.Contains(c.Message, "Broken") {
+			t.Errorf("schedules message = %q", c.Message)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/activity"
+	"github.com/shrik450/wisdom/internal/digest"
+	"github.com/shrik450/wisdom/internal/filename"
+	"github.com/shrik450/wisdom/internal/mail"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const defaultDigestFolder = "Digests"
+
+// digestDays reads the days arg of digest and activity, 7 by default.
+func digestDays(args map[string]string) (int, error) {
+	if args["days"] == "" {
+		return 7, nil
+	}
+	n, err := strconv.Atoi(args["days"])
+	if err != nil || n < 1 {
+		return 0, errors.New("days must be a positive number")
+	}
+	return n, nil
+}
+
+// recipients splits a comma-separated list of addresses.
+func recipients(s string) []string {
+	var to []string
+	for addr := range strings.SplitSeq(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// digestAction compiles the notes changed in the last days into a note in
+// output, named after the title and date, and mails it to email if set.
+// Nothing is written or sent when no notes changed.
+func digestAction(sender *mail.Sender) schedule.Action {
+	return func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		days, err := digestDays(args)
+		if err != nil {
+			return err
+		}
+		to := recipients(args["email"])
+		if len(to) > 0 && sender == nil {
+			return mail.ErrDisabled
+		}
+		now := time.Now()
+		output := normalizePath(cmp.Or(args["output"], defaultDigestFolder))
+		title := cmp.Or(args["title"], "Digest") + " " + now.Format("2006-01-02")
+		content, included, err := digest.Build(ws, digest.Options{
+			Title:   title,
+			Folder:  normalizePath(args["folder"]),
+			Tag:     args["tag"],
+			Since:   now.AddDate(0, 0, -days),
+			Exclude: output,
+		})
+		if err != nil || len(included) == 0 {
+			return err
+		}
+
+		policy, err := filename.Load(ws)
+		if err != nil {
+			return err
+		}
+		p, err := policy.Apply(ws, path.Join(output, title+".md"))
+		if err != nil {
+			return err
+		}
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			return err
+		}
+		if len(to) == 0 {
+			return nil
+		}
+		return sender.Send(to, title, content, now)
+	}
+}
+
+// activityAction mails a summary of the last days to the addresses in to.
+func activityAction(sender *mail.Sender) schedule.Action {
+	return func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		if sender == nil {
+			return mail.ErrDisabled
+		}
+		to := recipients(args["to"])
+		if len(to) == 0 {
+			return errors.New("to must list at least one address")
+		}
+		days, err := digestDays(args)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		summary, err := activity.Summarize(ws, now.AddDate(0, 0, -days), now)
+		if err != nil {
+			return err
+		}
+		return sender.Send(to, "Wisdom: the week in your workspace", summary.Text(), now)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/shrik450/wisdom/internal/document"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/query"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// documentHandler compiles notes into one markdown document: those listed
+// in paths, then those a table query selects, in its order. With format
+// html, it is rendered as a page. With output, the document is saved in the
+// workspace; otherwise it is downloaded.
+func documentHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Paths  []string     `json:"paths"`
+			Query  *query.Query `json:"query"`
+			Title  string       `json:"title"`
+			Format string       `json:"format"`
+			Output string       `json:"output"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		contentType, ext := "text/markdown; charset=utf-8", ".md"
+		switch req.Format {
+		case "", "markdown":
+		case "html":
+			contentType, ext = "text/html; charset=utf-8", ".html"
+		default:
+			http.Error(w, "format must be markdown or html; the server renders no PDF", http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			req.Title = "Document"
+		}
+
+		ws := workspace.FromContext(r.Context())
+		paths := make([]string, 0, len(req.Paths))
+		for _, p := range req.Paths {
+			paths = append(paths, normalizePath(p))
+		}
+		if req.Query != nil {
+			q := *req.Query
+			q.Select, q.GroupBy = []string{query.FilePath}, ""
+			result, err := query.Run(ws, q)
+			if errors.Is(err, query.ErrInvalidQuery) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			for _, row := range result.Rows {
+				if p, ok := row[0].(string); ok {
+					paths = append(paths, p)
+				}
+			}
+		}
+
+		opts := document.Options{Title: req.Title}
+		if req.Output != "" {
+			opts.Output = normalizePath(req.Output)
+			if _, err := ws.Stat(opts.Output); err == nil {
+				http.Error(w, "output exists", http.StatusConflict)
+				return
+			} else if !errors.Is(err, os.ErrNotExist) {
+				mapError(w, err)
+				return
+			}
+		}
+		all, err := index.Notes(ws)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		doc, err := document.Build(ws, all, paths, opts)
+		if errors.Is(err, document.ErrNoNotes) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		if req.Format == "html" {
+			doc = document.HTML(req.Title, doc)
+		}
+
+		if opts.Output == "" {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": req.Title + ext}))
+			w.Write([]byte(doc))
+			return
+		}
+		if err := ws.MkdirAll(path.Dir(opts.Output), 0o755); err != nil {
+			mapError(w, err)
+			return
+		}
+		if err := ws.WriteFile(opts.Output, []byte(doc), 0o644); err != nil {
+			mapError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/fs/"+opts.Output)
+		writeJSON(w, http.StatusCreated, map[string]any{"path": opts.Output, "notes": len(paths)})
+	})
+}
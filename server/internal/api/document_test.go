@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportDocument(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("papers", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"papers/a.md": "---\ntitle: Alpha\nyear: 2024\n---\nFirst.\n",
+		"papers/b.md": "---\ntitle: Beta\nyear: 2021\n---\nSecond.\n",
+		"inbox.md":    "# Inbox\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `{"title": "Reading", "paths": ["inbox.md"], "query": {"from": "papers", "sort": [{"field": "year"}]}}`
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/export/document", strings.NewReader(body))
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/markdown; charset=utf-8" {
+		t.Fatalf("export = %d %s", resp.StatusCode, data)
+	}
+	if want := "- [Inbox](#inbox)\n- [Beta](#beta)\n- [Alpha](#alpha)\n"; !strings.Contains(string(data), want) {
+		t.Errorf("contents of\n%s\nwant\n%s", data, want)
+	}
+
+	body = `{"title": "Reading", "paths": ["inbox.md"], "output": "shared/reading.md"}`
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/export/document", strings.NewReader(body))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/fs/shared/reading.md" {
+		t.Fatalf("saving = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/export/document", strings.NewReader(body))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("saving over it = %d, want 409", resp.StatusCode)
+	}
+
+	body = `{"title": "Reading", "paths": ["inbox.md"], "format": "html"}`
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/export/document", strings.NewReader(body))
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("html export = %d %s", resp.StatusCode, data)
+	}
+	if want := `<li><a href="#inbox">Inbox</a></li>`; !strings.Contains(string(data), want) {
+		t.Errorf("html contents of\n%s\nwant %s", data, want)
+	}
+
+	for _, body := range []string{`{"paths": ["inbox.md"], "format": "pdf"}`, `{"paths": []}`} {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/export/document", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
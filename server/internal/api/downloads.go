@@ -0,0 +1,408 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	maxDownloadSize  = 1 << 30
+	downloadTimeout  = 30 * time.Minute
+	maxDownloadJobs  = 100
+	defaultInboxPath = "inbox"
+)
+
+var allowedDownloadTypes = []string{
+	"application/pdf",
+	"application/epub+zip",
+	"text/html",
+	"text/plain",
+	"text/markdown",
+}
+
+var (
+	errDownloadTooLarge = fmt.Errorf("download exceeds %d bytes", maxDownloadSize)
+	errPrivateAddress   = errors.New("downloads from private addresses are off; set WISDOM_DOWNLOAD_PRIVATE=1")
+	errDownloadExists   = errors.New("path exists; set force=true to overwrite")
+)
+
+type downloadStatus string
+
+const (
+	downloadRunning   downloadStatus = "running"
+	downloadCompleted downloadStatus = "completed"
+	downloadFailed    downloadStatus = "failed"
+)
+
+type downloadJob struct {
+	ID       string         `json:"id"`
+	URL      string         `json:"url"`
+	Path     string         `json:"path,omitempty"`
+	Status   downloadStatus `json:"status"`
+	Received int64          `json:"received"`
+	// Total is -1 when the server didn't send a Content-Length.
+	Total    int64     `json:"total"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	// The import pipeline's jobs on the finished file, as /api/uploads
+	// reports them in headers.
+	TranscriptionJob string `json:"transcriptionJob,omitempty"`
+	OCRJob           string `json:"ocrJob,omitempty"`
+
+	// force lets the download replace an existing file at Path.
+	force bool
+}
+
+// downloadManager fetches remote documents into the workspace in the
+// background, and runs the import pipeline on them like on uploads. Jobs
+// are kept in memory only; they are a progress report, not a record.
+type downloadManager struct {
+	client   *http.Client
+	pipeline *importPipeline
+
+	mu   sync.Mutex
+	jobs []*downloadJob
+}
+
+// newDownloadManager returns a manager whose client only connects to
+// public addresses, so the server can't be made to fetch from itself or
+// its network, unless WISDOM_DOWNLOAD_PRIVATE is 1.
+func newDownloadManager(pipeline *importPipeline) *downloadManager {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if os.Getenv("WISDOM_DOWNLOAD_PRIVATE") != "1" {
+		dialer.Control = publicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &downloadManager{client: &http.Client{Timeout: downloadTimeout, Transport: transport}, pipeline: pipeline}
+}
+
+// publicOnly refuses connections to loopback, private, link-local and
+// unspecified addresses. It runs on the address actually dialed, after
+// DNS and for every redirect, so neither can be used to get around it.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, ip)
+	}
+	return nil
+}
+
+func (m *downloadManager) start(ws *workspace.Workspace, rawURL, dest string, force bool) *downloadJob {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	job := &downloadJob{
+		ID:      hex.EncodeToString(buf),
+		URL:     rawURL,
+		Path:    dest,
+		Status:  downloadRunning,
+		Total:   -1,
+		Started: time.Now().UTC(),
+		force:   force,
+	}
+
+	m.mu.Lock()
+	m.jobs = append(m.jobs, job)
+	m.pruneLocked()
+	snapshot := *job
+	m.mu.Unlock()
+
+	go m.run(ws, job)
+	return &snapshot
+}
+
+// pruneLocked drops the oldest finished jobs once more than maxDownloadJobs
+// are kept.
+func (m *downloadManager) pruneLocked() {
+	excess := len(m.jobs) - maxDownloadJobs
+	m.jobs = slices.DeleteFunc(m.jobs, func(j *downloadJob) bool {
+		if excess > 0 && j.Status != downloadRunning {
+			excess--
+			return true
+		}
+		return false
+	})
+}
+
+func (m *downloadManager) get(id string) (downloadJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return *j, true
+		}
+	}
+	return downloadJob{}, false
+}
+
+func (m *downloadManager) list() []downloadJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]downloadJob, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *m.jobs[i])
+	}
+	return jobs
+}
+
+func (m *downloadManager) update(job *downloadJob, fn func(j *downloadJob)) {
+	m.mu.Lock()
+	fn(job)
+	m.mu.Unlock()
+}
+
+func (m *downloadManager) run(ws *workspace.Workspace, job *downloadJob) {
+	dest, err := m.fetch(ws, job)
+	var transcription, ocr string
+	if err == nil {
+		transcription, ocr = m.pipeline.start(ws, dest)
+	}
+	m.update(job, func(j *downloadJob) {
+		j.Finished = time.Now().UTC()
+		if err != nil {
+			j.Status = downloadFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = downloadCompleted
+		j.Path = dest
+		j.TranscriptionJob, j.OCRJob = transcription, ocr
+	})
+}
+
+func (m *downloadManager) fetch(ws *workspace.Workspace, job *downloadJob) (_ string, err error) {
+	resp, err := m.client.Get(job.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("remote server returned %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !slices.Contains(allowedDownloadTypes, mediaType) {
+		return "", fmt.Errorf("content type %q is not allowed", mediaType)
+	}
+	if resp.ContentLength > maxDownloadSize {
+		return "", errDownloadTooLarge
+	}
+	m.update(job, func(j *downloadJob) { j.Total = resp.ContentLength })
+
+	dest := job.Path
+	claimed := false
+	if dest == "" {
+		dest, err = uniqueInboxPath(ws, downloadFilename(resp, job.URL))
+		if err != nil {
+			return "", err
+		}
+		claimed = true
+	} else {
+		if parent := filepath.Dir(dest); parent != "." {
+			if err := ws.MkdirAll(parent, 0o755); err != nil {
+				return "", err
+			}
+		}
+		if !job.force {
+			// Claimed like an inbox name, in case the file was made since
+			// the download was started.
+			f, err := ws.CreateNew(dest)
+			if errors.Is(err, fs.ErrExist) {
+				return "", errDownloadExists
+			}
+			if err != nil {
+				return "", err
+			}
+			if err := f.Close(); err != nil {
+				return "", err
+			}
+			claimed = true
+		}
+	}
+	if claimed {
+		// The claim mustn't outlive a failed download.
+		defer func() {
+			if err != nil {
+				ws.Remove(dest)
+			}
+		}()
+	}
+
+	body := &progressReader{r: resp.Body, onRead: func(n int64) {
+		m.update(job, func(j *downloadJob) { j.Received += n })
+	}}
+	if err = ws.WriteStream(dest, body, 0o644); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+type progressReader struct {
+	r      io.Reader
+	read   int64
+	onRead func(n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.read > maxDownloadSize {
+		return n, errDownloadTooLarge
+	}
+	if n > 0 {
+		p.onRead(int64(n))
+	}
+	return n, err
+}
+
+func downloadFilename(resp *http.Response, rawURL string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := sanitizeFilename(params["filename"]); name != "" {
+			return name
+		}
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if name := sanitizeFilename(path.Base(u.Path)); name != "" {
+			return name
+		}
+	}
+
+	name := "download"
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		name += exts[0]
+	}
+	return name
+}
+
+func sanitizeFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(filepath.FromSlash(name)))
+	if name == "." || name == "/" || name == ".." || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// uniqueInboxPath claims a path in the inbox that doesn't exist yet, adding
+// a numeric suffix to the name if needed, by creating it empty; two
+// downloads of the same name can't both pick it.
+func uniqueInboxPath(ws *workspace.Workspace, name string) (string, error) {
+	if err := ws.MkdirAll(defaultInboxPath, 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = stem + "-" + strconv.Itoa(i) + ext
+		}
+		p := defaultInboxPath + "/" + candidate
+		f, err := ws.CreateNew(p)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return p, f.Close()
+	}
+}
+
+func downloadsHandler(m *downloadManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, m.list())
+		case http.MethodPost:
+			handleStartDownload(w, r, m)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func handleStartDownload(w http.ResponseWriter, r *http.Request, m *downloadManager) {
+	var req struct {
+		URL   string `json:"url"`
+		Path  string `json:"path"`
+		Force bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	ws := workspace.FromContext(r.Context())
+	var dest string
+	if req.Path != "" {
+		dest = normalizePath(req.Path)
+		if dest == "." {
+			http.Error(w, "path must name a file", http.StatusBadRequest)
+			return
+		}
+		if _, err := ws.Stat(dest); err == nil && !req.Force {
+			http.Error(w, errDownloadExists.Error(), http.StatusConflict)
+			return
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			mapError(w, err)
+			return
+		}
+	}
+
+	job := m.start(ws, u.String(), dest, req.Force)
+	w.Header().Set("Location", "/api/downloads/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func downloadHandler(m *downloadManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		job, ok := m.get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "download not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+}
@@ -0,0 +1,221 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type downloadJob struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Received int64  `json:"received"`
+	Total    int64  `json:"total"`
+	Error    string `json:"error"`
+}
+
+func TestDownloads(t *testing.T) {
+	// The remote server is on loopback.
+	t.Setenv("WISDOM_DOWNLOAD_PRIVATE", "1")
+	srv, ws := newTestServer(t)
+
+	remote := http.NewServeMux()
+	remote.HandleFunc("/papers/attention.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7 paper"))
+	})
+	remote.HandleFunc("/papers/slow.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("%PDF-1.7 slow"))
+	})
+	remote.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="../../Novel.epub"`)
+		w.Write([]byte("epub"))
+	})
+	remote.HandleFunc("/tool.exe", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("MZ"))
+	})
+	remoteSrv := httptest.NewServer(remote)
+	t.Cleanup(remoteSrv.Close)
+
+	start := func(t *testing.T, body string) downloadJob {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/downloads", strings.NewReader(body))
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			data, _ := io.ReadAll(resp.Body)
+			t.Fatalf("status=%d body=%s", resp.StatusCode, data)
+		}
+		var job downloadJob
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+	wait := func(t *testing.T, job downloadJob) downloadJob {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for job.Status == "running" {
+			if time.Now().After(deadline) {
+				t.Fatal("download did not finish")
+			}
+			time.Sleep(5 * time.Millisecond)
+			resp := doRequest(t, http.MethodGet, srv.URL+"/api/downloads/"+job.ID, nil)
+			err := json.NewDecoder(resp.Body).Decode(&job)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return job
+	}
+	check := func(t *testing.T, body string) downloadJob {
+		t.Helper()
+		return wait(t, start(t, body))
+	}
+
+	t.Run("files into the inbox by URL name", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/papers/attention.pdf"}`)
+		if job.Status != "completed" || job.Path != "inbox/attention.pdf" {
+			t.Fatalf("unexpected job: %+v", job)
+		}
+		if job.Received != 14 || job.Total != 14 {
+			t.Fatalf("progress = %d/%d, want 14/14", job.Received, job.Total)
+		}
+		got, err := ws.ReadFile("inbox/attention.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "%PDF-1.7 paper" {
+			t.Fatalf("got %q", got)
+		}
+	})
+
+	t.Run("does not overwrite existing inbox files", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/papers/attention.pdf"}`)
+		if job.Path != "inbox/attention-1.pdf" {
+			t.Fatalf("path = %q", job.Path)
+		}
+	})
+
+	t.Run("concurrent downloads of one name get their own files", func(t *testing.T) {
+		body := `{"url":"` + remoteSrv.URL + `/papers/slow.pdf"}`
+		a, b := start(t, body), start(t, body)
+		a, b = wait(t, a), wait(t, b)
+		if a.Status != "completed" || b.Status != "completed" || a.Path == b.Path {
+			t.Fatalf("jobs = %+v and %+v, want two files", a, b)
+		}
+	})
+
+	t.Run("uses sanitized Content-Disposition name", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/download"}`)
+		if job.Path != "inbox/Novel.epub" {
+			t.Fatalf("path = %q", job.Path)
+		}
+	})
+
+	t.Run("explicit destination", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/papers/attention.pdf","path":"books/paper.pdf"}`)
+		if job.Status != "completed" || job.Path != "books/paper.pdf" {
+			t.Fatalf("unexpected job: %+v", job)
+		}
+		if _, err := ws.Stat("books/paper.pdf"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("disallowed content type fails", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/tool.exe"}`)
+		if job.Status != "failed" || !strings.Contains(job.Error, "not allowed") {
+			t.Fatalf("unexpected job: %+v", job)
+		}
+		if _, err := ws.Stat("inbox/tool.exe"); err == nil {
+			t.Fatal("disallowed download was written")
+		}
+	})
+
+	t.Run("remote errors fail the job", func(t *testing.T) {
+		job := check(t, `{"url":"`+remoteSrv.URL+`/missing.pdf"}`)
+		if job.Status != "failed" || !strings.Contains(job.Error, "404") {
+			t.Fatalf("unexpected job: %+v", job)
+		}
+	})
+
+	t.Run("rejects non-http URLs", func(t *testing.T) {
+		for _, body := range []string{`{"url":"file:///etc/passwd"}`, `{"url":"not a url"}`} {
+			resp := doRequest(t, http.MethodPost, srv.URL+"/api/downloads", strings.NewReader(body))
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("%s: status=%d, want 400", body, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("rejects destination outside workspace", func(t *testing.T) {
+		body := `{"url":"` + remoteSrv.URL + `/papers/attention.pdf","path":"../../x.pdf"}`
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/downloads", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("status=%d, want 403", resp.StatusCode)
+		}
+	})
+
+	t.Run("lists jobs newest first", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/downloads", nil)
+		defer resp.Body.Close()
+		var jobs []downloadJob
+		if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 8 || jobs[len(jobs)-1].Path != "inbox/attention.pdf" {
+			t.Fatalf("unexpected jobs: %+v", jobs)
+		}
+	})
+
+	t.Run("existing destination needs force", func(t *testing.T) {
+		body := `{"url":"` + remoteSrv.URL + `/download","path":"books/paper.pdf"}`
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/downloads", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("status=%d, want 409", resp.StatusCode)
+		}
+		job := check(t, `{"url":"`+remoteSrv.URL+`/download","path":"books/paper.pdf","force":true}`)
+		if got, _ := ws.ReadFile("books/paper.pdf"); job.Status != "completed" || string(got) != "epub" {
+			t.Fatalf("job = %+v, file = %q", job, got)
+		}
+	})
+}
+
+func TestDownloadsRefusePrivateAddresses(t *testing.T) {
+	srv, ws := newTestServer(t)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7 internal"))
+	}))
+	t.Cleanup(remote.Close)
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/downloads", strings.NewReader(`{"url":"`+remote.URL+`/admin.pdf"}`))
+	var job downloadJob
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	for deadline := time.Now().Add(5 * time.Second); job.Status == "running" && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/downloads/"+job.ID, nil)
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+	}
+	if job.Status != "failed" || !strings.Contains(job.Error, "private") {
+		t.Fatalf("job = %+v, want it refused", job)
+	}
+	if _, err := ws.Stat("inbox/admin.pdf"); err == nil {
+		t.Error("download from loopback was written")
+	}
+}
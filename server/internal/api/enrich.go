@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/enrich"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type enrichResponse struct {
+	Path    string          `json:"path"`
+	Changes []enrich.Change `json:"changes"`
+}
+
+// enrichHandler proposes metadata for a book note on GET and applies the
+// reviewed subset of the proposal on POST.
+func enrichHandler(provider enrich.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleEnrichProposal(w, r, provider)
+		case http.MethodPost:
+			handleEnrichApply(w, r, provider)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func readBookNote(w http.ResponseWriter, r *http.Request) (string, *frontmatter.Document, bool) {
+	p := fsPath(r)
+	if !strings.EqualFold(path.Ext(p), ".md") {
+		http.Error(w, "enrichment works on markdown notes", http.StatusBadRequest)
+		return "", nil, false
+	}
+	data, err := workspace.FromContext(r.Context()).ReadFile(p)
+	if err != nil {
+		mapError(w, err)
+		return "", nil, false
+	}
+	return p, frontmatter.Parse(string(data)), true
+}
+
+func mapLookupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, enrich.ErrDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, enrich.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+func handleEnrichProposal(w http.ResponseWriter, r *http.Request, provider enrich.Provider) {
+	p, doc, ok := readBookNote(w, r)
+	if !ok {
+		return
+	}
+
+	md, err := provider.Lookup(r.Context(), enrich.QueryFor(doc))
+	if err != nil {
+		mapLookupError(w, err)
+		return
+	}
+
+	_, err = covers.Find(workspace.FromContext(r.Context()), p)
+	writeJSON(w, http.StatusOK, enrichResponse{
+		Path:    p,
+		Changes: enrich.Propose(doc, md, err == nil),
+	})
+}
+
+func handleEnrichApply(w http.ResponseWriter, r *http.Request, provider enrich.Provider) {
+	p, doc, ok := readBookNote(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Changes []enrich.Change `json:"changes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	ws := workspace.FromContext(r.Context())
+	var cover []byte
+	var coverPath string
+	if i := slices.IndexFunc(req.Changes, func(c enrich.Change) bool { return c.Field == enrich.FieldCover }); i >= 0 {
+		// Covers are never overwritten.
+		if _, err := covers.Find(ws, p); err != nil {
+			// Only the cover the provider proposes is fetched, so a client
+			// can't have the server download anything else.
+			md, err := provider.Lookup(r.Context(), enrich.QueryFor(doc))
+			if err != nil {
+				mapLookupError(w, err)
+				return
+			}
+			if coverURL, _ := req.Changes[i].Value.(string); md.CoverURL == "" || coverURL != md.CoverURL {
+				http.Error(w, "cover is not the one proposed for this book", http.StatusBadRequest)
+				return
+			}
+			data, ext, err := provider.Cover(r.Context(), md.CoverURL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			cover, coverPath = data, path.Join(path.Dir(p), "cover"+ext)
+		}
+	}
+
+	if err := enrich.Apply(doc, req.Changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cover != nil {
+		if err := ws.WriteFile(coverPath, cover, 0o644); err != nil {
+			mapError(w, err)
+			return
+		}
+	}
+	if err := ws.WriteFile(p, []byte(doc.Render()), 0o644); err != nil {
+		mapError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, enrichResponse{Path: p, Changes: req.Changes})
+}
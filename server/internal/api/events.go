@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/watch"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Proxies tend to drop connections that are quiet for a minute or so.
+const eventsKeepAlive = 30 * time.Second
+
+// eventFilter picks the events a subscriber wants. Each kind of condition
+// matches if any of its values do, and an event is sent if every kind that
+// was given matches.
+type eventFilter struct {
+	prefixes []string
+	ops      []string
+	globs    []string
+}
+
+// parseEventFilter reads ?prefix= (a folder or file), ?op= (put or delete)
+// and ?glob= (matched against the path and the file name, as in
+// watchignore), each of which may be repeated.
+func parseEventFilter(q url.Values) (eventFilter, error) {
+	var f eventFilter
+	for _, p := range q["prefix"] {
+		if p = normalizePath(p); p != "." {
+			f.prefixes = append(f.prefixes, p)
+		}
+	}
+	for _, op := range q["op"] {
+		if op != watch.OpPut && op != watch.OpDelete {
+			return f, fmt.Errorf("unknown op %q", op)
+		}
+		f.ops = append(f.ops, op)
+	}
+	for _, g := range q["glob"] {
+		if _, err := path.Match(g, ""); err != nil {
+			return f, fmt.Errorf("invalid glob %q", g)
+		}
+		f.globs = append(f.globs, g)
+	}
+	return f, nil
+}
+
+func (f eventFilter) match(e watch.Event) bool {
+	if len(f.prefixes) > 0 && !slices.ContainsFunc(f.prefixes, func(p string) bool {
+		return e.Path == p || strings.HasPrefix(e.Path, p+"/")
+	}) {
+		return false
+	}
+	if len(f.ops) > 0 && !slices.Contains(f.ops, e.Op) {
+		return false
+	}
+	if len(f.globs) > 0 && !slices.ContainsFunc(f.globs, func(g string) bool {
+		full, _ := path.Match(g, e.Path)
+		base, _ := path.Match(g, path.Base(e.Path))
+		return full || base
+	}) {
+		return false
+	}
+	return true
+}
+
+// eventsHandler streams the watcher's changes as server-sent events, one
+// "change" event per batch, so open views can reload what changed on disk.
+// The filter in the query (see parseEventFilter) is applied before
+// sending, so a client watching one folder isn't sent a bulk import
+// elsewhere.
+func eventsHandler(watcher *watch.Watcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		filter, err := parseEventFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rc := http.NewResponseController(w)
+		// The stream outlives the server's write timeout.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Changes in folders locked for this client aren't sent, as of when
+		// it connected.
+		view := workspace.FromContext(r.Context())
+		batches := make(chan []watch.Event, 16)
+		cancel := watcher.Subscribe(func(_ *workspace.Workspace, events []watch.Event) {
+			events = slices.DeleteFunc(slices.Clone(events), func(e watch.Event) bool {
+				return view.IsLocked(e.Path) || !filter.match(e)
+			})
+			if len(events) == 0 {
+				return
+			}
+			select {
+			case batches <- events:
+			default:
+				// A client this far behind should reload everything anyway.
+			}
+		})
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+					return
+				}
+			case events := <-batches:
+				data, err := json.Marshal(events)
+				if err != nil {
+					return
+				}
+				if _, err := w.Write([]byte("event: change\ndata: " + string(data) + "\n\n")); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
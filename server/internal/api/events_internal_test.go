@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/watch"
+)
+
+func TestEventFilter(t *testing.T) {
+	events := []watch.Event{
+		{Op: watch.OpPut, Path: "projects/plan.md"},
+		{Op: watch.OpDelete, Path: "projects/old.md"},
+		{Op: watch.OpPut, Path: "projects-archive/plan.md"},
+		{Op: watch.OpPut, Path: "imports/scan.pdf"},
+		{Op: watch.OpPut, Path: "projects/cover.png"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"projects/plan.md", "projects/old.md", "projects-archive/plan.md", "imports/scan.pdf", "projects/cover.png"}},
+		{"prefix=projects", []string{"projects/plan.md", "projects/old.md", "projects/cover.png"}},
+		{"prefix=/projects/&op=delete", []string{"projects/old.md"}},
+		{"glob=*.md", []string{"projects/plan.md", "projects/old.md", "projects-archive/plan.md"}},
+		{"glob=projects/*.md&glob=*.pdf", []string{"projects/plan.md", "projects/old.md", "imports/scan.pdf"}},
+		{"prefix=projects&glob=*.png&op=put", []string{"projects/cover.png"}},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		f, err := parseEventFilter(q)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		var got []string
+		for _, e := range events {
+			if f.match(e) {
+				got = append(got, e.Path)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"op=rename", "glob=[a-"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseEventFilter(q); err == nil {
+			t.Errorf("%q: want an error", query)
+		}
+	}
+}
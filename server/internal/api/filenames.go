@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/shrik450/wisdom/internal/filename"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// applyFilenamePolicy checks the name of a file or folder about to be
+// created at p and returns the path to create it at. If the policy changed
+// the name, the new path is sent back in Content-Location.
+func applyFilenamePolicy(w http.ResponseWriter, ws *workspace.Workspace, p string) (string, error) {
+	policy, err := filename.Load(ws)
+	if err != nil {
+		return "", err
+	}
+	applied, err := policy.Apply(ws, p)
+	if err != nil {
+		return "", err
+	}
+	if applied != p {
+		w.Header().Set("Content-Location", (&url.URL{Path: "/api/fs/" + applied}).String())
+	}
+	return applied, nil
+}
+
+func mapFilenameError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, filename.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, filename.ErrCaseCollision):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		mapError(w, err)
+	}
+}
+
+// filenameViolationsHandler lists the names in the workspace that break
+// the filename policy, to fix before syncing it somewhere stricter.
+func filenameViolationsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		policy, err := filename.Load(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		violations, err := policy.Check(ws)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"violations": violations})
+	})
+}
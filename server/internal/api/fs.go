@@ -1,15 +1,24 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/wlog"
 	"github.com/shrik450/wisdom/internal/workspace"
 )
 
@@ -26,24 +35,28 @@ func mapError(w http.ResponseWriter, err error) {
 		http.Error(w, err.Error(), http.StatusForbidden)
 	case errors.Is(err, os.ErrNotExist):
 		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, workspace.ErrReservedName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, workspace.ErrLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
 	default:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func fsHandler() http.Handler {
+func fsHandler(index *notes.Index, unlocks *sealed.Unlocks) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			handleGet(w, r)
+			handleGet(w, r, index)
 		case http.MethodHead:
 			handleHead(w, r)
 		case http.MethodPut:
-			handlePut(w, r)
+			handlePut(w, r, index, unlocks)
 		case http.MethodDelete:
-			handleDelete(w, r)
+			handleDelete(w, r, index, unlocks)
 		case http.MethodPatch:
-			handlePatch(w, r)
+			handlePatch(w, r, index, unlocks)
 		default:
 			w.Header().Set("Allow", "GET, HEAD, PUT, DELETE, PATCH")
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -71,7 +84,7 @@ func isProtectedPath(p string) bool {
 	return p == "." || p == "ui"
 }
 
-func handleGet(w http.ResponseWriter, r *http.Request) {
+func handleGet(w http.ResponseWriter, r *http.Request, index *notes.Index) {
 	ws := workspace.FromContext(r.Context())
 	p := fsPath(r)
 
@@ -82,7 +95,7 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if info.IsDir() {
-		if err := writeDirectoryResponse(w, ws, p, info); err != nil {
+		if err := writeDirectoryResponse(w, r, ws, p, info); err != nil {
 			mapError(w, err)
 		}
 		return
@@ -95,6 +108,10 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	}
 	defer f.Close()
 
+	if notes.IsNote(p) {
+		index.Visit(p, time.Now())
+	}
+	setContentHeaders(w, ws, p)
 	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
 }
 
@@ -121,6 +138,7 @@ func handleHead(w http.ResponseWriter, r *http.Request) {
 	}
 	defer f.Close()
 
+	setContentHeaders(w, ws, p)
 	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
 }
 
@@ -132,8 +150,54 @@ func writeDirectoryHeaders(w http.ResponseWriter, info os.FileInfo) {
 	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
 }
 
+// contentHeader tells clients whether a file is text they can open:
+// "text", "binary" or "too-large".
+const contentHeader = "Wisdom-Content"
+
+// setContentHeaders sets contentHeader for the file at p and, if it is text
+// but not UTF-8, declares it Latin-1, the way textfile reads it, so clients
+// decode it right.
+func setContentHeaders(w http.ResponseWriter, ws *workspace.Workspace, p string) {
+	kind, isUTF8, err := textfile.Sniff(ws, p)
+	if err != nil {
+		return
+	}
+	w.Header().Set(contentHeader, string(kind))
+	if kind == textfile.Binary || isUTF8 {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(p)))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		w.Header().Set("Content-Type", mediaType+"; charset=iso-8859-1")
+	}
+}
+
+// directoryIndexNames are the files shown as a directory's description, in
+// order of preference. Matching is case-insensitive.
+var directoryIndexNames = []string{"index.md", "readme.md"}
+
+type directoryIndex struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// Truncated is set if the file is over textfile.MaxSize and Content
+	// holds only its start.
+	Truncated bool `json:"truncated,omitzero"`
+}
+
+// slimDirEntryFields are the fields of a directory listing's ?view=slim.
+var slimDirEntryFields = []string{"name", "isDir"}
+
+// writeDirectoryResponse writes the directory listing, shaped by
+// requestedFields.
+// With ?withIndex, the listing is wrapped in an object that also carries the
+// markdown source of the directory's index or README, if it has one, for the
+// UI to render.
 func writeDirectoryResponse(
 	w http.ResponseWriter,
+	r *http.Request,
 	ws *workspace.Workspace,
 	path string,
 	info os.FileInfo,
@@ -143,36 +207,121 @@ func writeDirectoryResponse(
 		return err
 	}
 
+	// A directory's modification time doesn't change when a file in it is
+	// edited, so the ETag is taken from what the listing is made of, hashed
+	// as it is read, and from the query that shapes it. Clients polling an
+	// unchanged directory get a 304 instead of the whole listing.
+	hash := sha256.New()
 	result := make([]dirEntry, 0, len(entries))
 	for _, e := range entries {
 		eInfo, err := e.Info()
 		if err != nil {
 			return err
 		}
-		result = append(result, dirEntry{
+		entry := dirEntry{
 			Name:    e.Name(),
 			Size:    eInfo.Size(),
 			ModTime: eInfo.ModTime(),
 			IsDir:   e.IsDir(),
-		})
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00%t\x00", entry.Name, entry.Size, entry.ModTime.UnixNano(), entry.IsDir)
+		result = append(result, entry)
 	}
+	io.WriteString(hash, r.URL.RawQuery)
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
 
-	data, err := json.Marshal(result)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	page, ok := paginate(w, r, result, defaultPageLimits)
+	if !ok {
+		return nil
+	}
+	withIndex, _ := strconv.ParseBool(r.URL.Query().Get("withIndex"))
+	if withIndex {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	} else {
+		writeDirectoryHeaders(w, info)
+	}
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return nil
 	}
 
-	writeDirectoryHeaders(w, info)
-	w.Write(data)
+	var index *directoryIndex
+	if withIndex {
+		if index, err = readDirectoryIndex(ws, path, entries); err != nil {
+			return err
+		}
+	}
+	// The listing is streamed, so a long one isn't held in memory whole.
+	// With ?withIndex, it is wrapped in an object with the index.
+	write := func(out io.Writer, flush func() error) error {
+		if !withIndex {
+			return encodeList(out, r, page, slimDirEntryFields, flush)
+		}
+		if _, err := io.WriteString(out, `{"entries":`); err != nil {
+			return err
+		}
+		if err := encodeList(out, r, page, slimDirEntryFields, flush); err != nil {
+			return err
+		}
+		data, err := json.Marshal(index)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, `,"index":%s}`, data)
+		return err
+	}
+
+	if err := write(w, flusher(w)); err != nil {
+		wlog.FromContext(r.Context()).Warn("encoding directory listing", "err", err)
+	}
 	return nil
 }
 
-func handlePut(w http.ResponseWriter, r *http.Request) {
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison the header calls for.
+func etagMatches(header, etag string) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func readDirectoryIndex(ws *workspace.Workspace, dir string, entries []os.DirEntry) (*directoryIndex, error) {
+	for _, want := range directoryIndexNames {
+		for _, e := range entries {
+			if e.IsDir() || !strings.EqualFold(e.Name(), want) {
+				continue
+			}
+			p := filepath.ToSlash(filepath.Join(dir, e.Name()))
+			content, kind, err := textfile.Read(ws, p)
+			if err != nil {
+				return nil, err
+			}
+			if kind == textfile.Binary {
+				continue
+			}
+			return &directoryIndex{Path: p, Content: content, Truncated: kind == textfile.TooLarge}, nil
+		}
+	}
+	return nil, nil
+}
+
+func handlePut(w http.ResponseWriter, r *http.Request, index *notes.Index, unlocks *sealed.Unlocks) {
 	ws := workspace.FromContext(r.Context())
 	p := fsPath(r)
 
 	if r.URL.Query().Has("mkdir") {
+		if _, err := ws.Stat(p); errors.Is(err, os.ErrNotExist) {
+			if p, err = applyFilenamePolicy(w, ws, p); err != nil {
+				mapFilenameError(w, err)
+				return
+			}
+		}
 		if err := ws.MkdirAll(p, 0o755); err != nil {
 			mapError(w, err)
 			return
@@ -181,12 +330,38 @@ func handlePut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := ws.Stat(p)
+	modTime, err := requestModTime(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := ws.Stat(p)
 	isNew := errors.Is(err, os.ErrNotExist)
 	if err != nil && !isNew {
 		mapError(w, err)
 		return
 	}
+	if isNew {
+		if p, err = applyFilenamePolicy(w, ws, p); err != nil {
+			mapFilenameError(w, err)
+			return
+		}
+	} else if !checkUnsealed(w, r, unlocks, p) {
+		return
+	}
+	if !isNew && modifiedSince(r, info) {
+		if r.URL.Query().Get("onConflict") != "copy" {
+			http.Error(w, "file was modified since it was read", http.StatusPreconditionFailed)
+			return
+		}
+		if p, err = conflictPath(ws, p, time.Now()); err != nil {
+			mapError(w, err)
+			return
+		}
+		isNew = true
+		w.Header().Set(conflictHeader, p)
+	}
 
 	parent := filepath.Dir(p)
 	if parent != "." {
@@ -200,11 +375,18 @@ func handlePut(w http.ResponseWriter, r *http.Request) {
 		mapError(w, err)
 		return
 	}
+	if !modTime.IsZero() {
+		if err := ws.Chtimes(p, modTime); err != nil {
+			mapError(w, err)
+			return
+		}
+	}
+	index.Update(ws, p)
 
-	info, err := ws.Stat(p)
-	if err == nil {
+	if info, err := ws.Stat(p); err == nil {
 		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
 	}
+	setSchemaViolations(w, ws, p)
 
 	if isNew {
 		w.WriteHeader(http.StatusCreated)
@@ -213,7 +395,59 @@ func handlePut(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func handleDelete(w http.ResponseWriter, r *http.Request) {
+// modTimeHeader sets the modification time of a written file, as RFC 3339,
+// so sync clients can keep the times files had elsewhere. Last-Modified
+// can't carry it: HTTP dates only have whole seconds.
+const modTimeHeader = "X-Wisdom-Mtime"
+
+func requestModTime(r *http.Request) (time.Time, error) {
+	s := r.Header.Get(modTimeHeader)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", modTimeHeader)
+	}
+	return t, nil
+}
+
+// conflictHeader names the copy a conflicting write was saved to.
+const conflictHeader = "Wisdom-Conflict"
+
+// modifiedSince reports whether the file changed after the client's
+// If-Unmodified-Since, which clients set to the Last-Modified they read.
+func modifiedSince(r *http.Request, info os.FileInfo) bool {
+	since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	if err != nil {
+		return false
+	}
+	// HTTP dates only have whole seconds.
+	return info.ModTime().Truncate(time.Second).After(since)
+}
+
+// conflictPath picks a name next to p for a write that would have
+// overwritten someone else's changes, the way sync tools do:
+// "plan (conflict 2024-06-01).md", then "plan (conflict 2024-06-01 2).md".
+func conflictPath(ws *workspace.Workspace, p string, now time.Time) (string, error) {
+	ext := filepath.Ext(p)
+	stem := strings.TrimSuffix(p, ext) + " (conflict " + now.Format(time.DateOnly)
+	for i := 1; ; i++ {
+		candidate := stem + ")" + ext
+		if i > 1 {
+			candidate = stem + " " + strconv.Itoa(i) + ")" + ext
+		}
+		_, err := ws.Stat(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func handleDelete(w http.ResponseWriter, r *http.Request, index *notes.Index, unlocks *sealed.Unlocks) {
 	ws := workspace.FromContext(r.Context())
 	p := fsPath(r)
 
@@ -229,6 +463,9 @@ func handleDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "path is protected; set force=true to delete", http.StatusBadRequest)
 		return
 	}
+	if !checkUnsealed(w, r, unlocks, p) {
+		return
+	}
 
 	if err := ws.Remove(p); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -240,16 +477,21 @@ func handleDelete(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	index.Update(ws, p)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func handlePatch(w http.ResponseWriter, r *http.Request) {
+func handlePatch(w http.ResponseWriter, r *http.Request, index *notes.Index, unlocks *sealed.Unlocks) {
 	ws := workspace.FromContext(r.Context())
 	p := fsPath(r)
 
 	var req struct {
 		Destination string `json:"destination"`
 		Force       bool   `json:"force"`
+		// Copy leaves the source in place. A move keeps the file's
+		// modification time and attributes; a copy only does with Preserve.
+		Copy     bool `json:"copy"`
+		Preserve bool `json:"preserve"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid JSON body", http.StatusBadRequest)
@@ -264,26 +506,39 @@ func handlePatch(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "path is protected; set force=true to move", http.StatusBadRequest)
 		return
 	}
-
-	dstPath, err := ws.Resolve(dst)
-	if err != nil {
-		mapError(w, err)
+	if !req.Copy && !checkUnsealed(w, r, unlocks, p) {
 		return
 	}
-	if _, err := os.Lstat(dstPath); err == nil && !req.Force {
+
+	if _, err := ws.Lstat(dst); err == nil && !req.Force {
 		http.Error(w, "destination exists; set force=true to overwrite", http.StatusBadRequest)
 		return
-	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+	} else if err == nil && !checkUnsealed(w, r, unlocks, dst) {
+		return
+	} else if errors.Is(err, os.ErrNotExist) {
+		if dst, err = applyFilenamePolicy(w, ws, dst); err != nil {
+			mapFilenameError(w, err)
+			return
+		}
+	} else if err != nil {
 		mapError(w, err)
 		return
 	}
 
-	if err := ws.Move(p, dst); err != nil {
+	var err error
+	if req.Copy {
+		err = ws.Copy(p, dst, req.Preserve)
+	} else {
+		err = ws.Move(p, dst)
+	}
+	if err != nil {
 		mapError(w, err)
 		return
 	}
+	index.Update(ws, p, dst)
 
-	info, err := os.Lstat(dstPath)
+	// The workspace reports the size of the content, not of its encryption.
+	info, err := ws.Lstat(dst)
 	if err != nil {
 		mapError(w, err)
 		return
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log/slog"
 	"mime"
 	"net/http"
 	"net/http/httptest"
@@ -15,7 +16,12 @@ import (
 	"time"
 
 	"github.com/shrik450/wisdom/internal/api"
+	"github.com/shrik450/wisdom/internal/filename"
 	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/watch"
 	"github.com/shrik450/wisdom/internal/workspace"
 )
 
@@ -32,7 +38,8 @@ func newTestServer(t *testing.T) (*httptest.Server, *workspace.Workspace) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	handler := middleware.WithWorkspace(api.APIHandler(), ws)
+	folders := protect.New()
+	handler := middleware.WithWorkspace(middleware.LockFolders(api.APIHandler(schedule.New(ws, slog.Default()), notes.NewIndex(), watch.New(ws, slog.Default()), folders), folders), ws)
 	srv := httptest.NewServer(handler)
 	t.Cleanup(srv.Close)
 	return srv, ws
@@ -134,6 +141,50 @@ func TestGet(t *testing.T) {
 		}
 	})
 
+	t.Run("list directory with index", func(t *testing.T) {
+		check := func(t *testing.T, url string, wantIndex string) {
+			t.Helper()
+			resp := doRequest(t, "GET", url, nil)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != 200 {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			var listing struct {
+				Entries []dirEntry `json:"entries"`
+				Index   *struct {
+					Path    string `json:"path"`
+					Content string `json:"content"`
+				} `json:"index"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+				t.Fatal(err)
+			}
+			if len(listing.Entries) == 0 {
+				t.Fatalf("no entries in listing")
+			}
+			got := ""
+			if listing.Index != nil {
+				got = listing.Index.Path + ": " + listing.Index.Content
+			}
+			if got != wantIndex {
+				t.Fatalf("index = %q, want %q", got, wantIndex)
+			}
+		}
+
+		check(t, srv.URL+"/api/fs/notes?withIndex=1", "")
+
+		if err := ws.WriteFile("notes/README.md", []byte("About notes"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		check(t, srv.URL+"/api/fs/notes?withIndex=1", "notes/README.md: About notes")
+
+		if err := ws.WriteFile("notes/index.md", []byte("Index first"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		check(t, srv.URL+"/api/fs/notes?withIndex=true", "notes/index.md: Index first")
+	})
+
 	t.Run("404 for missing file", func(t *testing.T) {
 		resp := doRequest(t, "GET", srv.URL+"/api/fs/nope.txt", nil)
 		defer resp.Body.Close()
@@ -156,6 +207,25 @@ func TestGet(t *testing.T) {
 		if !bytes.Equal(got, data) {
 			t.Fatalf("binary content mismatch")
 		}
+		if kind := resp.Header.Get("Wisdom-Content"); kind != "binary" {
+			t.Fatalf("Wisdom-Content = %q, want binary", kind)
+		}
+	})
+
+	t.Run("latin-1 text", func(t *testing.T) {
+		if err := ws.WriteFile("latin1.txt", []byte("caf\xe9"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := doRequest(t, "GET", srv.URL+"/api/fs/latin1.txt", nil)
+		defer resp.Body.Close()
+
+		if kind := resp.Header.Get("Wisdom-Content"); kind != "text" {
+			t.Fatalf("Wisdom-Content = %q, want text", kind)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=iso-8859-1" {
+			t.Fatalf("Content-Type = %q, want Latin-1 text", ct)
+		}
 	})
 }
 
@@ -272,6 +342,105 @@ func TestPutFile(t *testing.T) {
 			t.Fatal("expected Last-Modified header")
 		}
 	})
+
+	t.Run("modification time header", func(t *testing.T) {
+		req, err := http.NewRequest("PUT", srv.URL+"/api/fs/synced.txt", strings.NewReader("x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Wisdom-Mtime", "2021-03-04T05:06:07.123456789Z")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		info, err := ws.Stat("synced.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC); !info.ModTime().Equal(want) {
+			t.Fatalf("modified at %v, want %v", info.ModTime(), want)
+		}
+	})
+
+	t.Run("stale precondition", func(t *testing.T) {
+		if err := ws.WriteFile("plan.md", []byte("theirs"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		read := time.Now().Add(-time.Hour)
+		abs, _ := ws.Resolve("plan.md")
+		if err := os.Chtimes(abs, read, read); err != nil {
+			t.Fatal(err)
+		}
+		put := func(t *testing.T, url, since string) *http.Response {
+			t.Helper()
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+url, strings.NewReader("mine"))
+			req.Header.Set("If-Unmodified-Since", since)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp
+		}
+		stale := read.Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+		if resp := put(t, "/api/fs/plan.md", stale); resp.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("expected 412, got %d", resp.StatusCode)
+		}
+
+		day := time.Now().Format(time.DateOnly)
+		for _, want := range []string{"plan (conflict " + day + ").md", "plan (conflict " + day + " 2).md"} {
+			resp := put(t, "/api/fs/plan.md?onConflict=copy", stale)
+			if resp.StatusCode != http.StatusCreated || resp.Header.Get("Wisdom-Conflict") != want {
+				t.Errorf("expected 201 with conflict %q, got %d %q", want, resp.StatusCode, resp.Header.Get("Wisdom-Conflict"))
+			}
+			if got, _ := ws.ReadFile(want); string(got) != "mine" {
+				t.Errorf("conflict copy = %q", got)
+			}
+		}
+		if got, _ := ws.ReadFile("plan.md"); string(got) != "theirs" {
+			t.Errorf("original overwritten: %q", got)
+		}
+
+		if resp := put(t, "/api/fs/plan.md?onConflict=copy", read.UTC().Format(http.TimeFormat)); resp.StatusCode != http.StatusNoContent || resp.Header.Get("Wisdom-Conflict") != "" {
+			t.Errorf("expected a fresh write to overwrite, got %d", resp.StatusCode)
+		}
+		if got, _ := ws.ReadFile("plan.md"); string(got) != "mine" {
+			t.Errorf("plan.md = %q", got)
+		}
+	})
+}
+
+func TestPutFilenamePolicy(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile(filename.PolicyPath, []byte(`{"normalize": true, "forbidden": ":"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doRequest(t, "PUT", srv.URL+"/api/fs/Cafe%CC%81.md", strings.NewReader("hi"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Content-Location"); loc != "/api/fs/Caf%C3%A9.md" {
+		t.Fatalf("Content-Location = %q, want the normalized name", loc)
+	}
+	if _, err := ws.Stat("Caf\u00e9.md"); err != nil {
+		t.Fatalf("normalized file not written: %v", err)
+	}
+
+	resp = doRequest(t, "PUT", srv.URL+"/api/fs/a:b.md", strings.NewReader("hi"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("forbidden name: expected 400, got %d", resp.StatusCode)
+	}
 }
 
 func TestPutMkdir(t *testing.T) {
@@ -462,6 +631,34 @@ func TestPatch(t *testing.T) {
 		}
 	})
 
+	t.Run("copy file", func(t *testing.T) {
+		if err := ws.WriteFile("original.txt", []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := ws.Chtimes("original.txt", old); err != nil {
+			t.Fatal(err)
+		}
+
+		body := `{"destination": "copied.txt", "copy": true, "preserve": true}`
+		resp := doRequest(t, "PATCH", srv.URL+"/api/fs/original.txt", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+
+		if _, err := ws.Stat("original.txt"); err != nil {
+			t.Fatalf("source gone after copy: %v", err)
+		}
+		info, err := ws.Stat("copied.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(old) {
+			t.Fatalf("copy modified at %v, want %v", info.ModTime(), old)
+		}
+	})
+
 	t.Run("rename over existing requires force", func(t *testing.T) {
 		if err := ws.WriteFile("source.txt", []byte("new"), 0o644); err != nil {
 			t.Fatal(err)
@@ -671,6 +868,131 @@ func TestDirectoryEntryFields(t *testing.T) {
 	}
 }
 
+func TestDirectoryListingShape(t *testing.T) {
+	srv, ws := newTestServer(t)
+
+	if err := ws.MkdirAll("sub", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("sub/file.txt", []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) []map[string]any {
+		t.Helper()
+		resp := doRequest(t, "GET", srv.URL+"/api/fs/sub?"+query, nil)
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/vnd.wisdom.dirlist+json" {
+			t.Fatalf("%s: Content-Type = %q", query, ct)
+		}
+		var entries []map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%s: expected 1 entry, got %d", query, len(entries))
+		}
+		return entries
+	}
+
+	if e := get("view=slim")[0]; len(e) != 2 || e["name"] != "file.txt" || e["isDir"] != false {
+		t.Errorf("slim entry = %v, want only name and isDir", e)
+	}
+	if e := get("fields=name,size,bogus")[0]; len(e) != 2 || e["size"] != float64(len("contents")) {
+		t.Errorf("entry with fields = %v, want only name and size", e)
+	}
+	// fields wins over view.
+	if e := get("view=slim&fields=modTime")[0]; len(e) != 1 || e["modTime"] == nil {
+		t.Errorf("entry with fields and view = %v, want only modTime", e)
+	}
+}
+
+func TestDirectoryListingPages(t *testing.T) {
+	srv, ws := newTestServer(t)
+
+	for _, name := range []string{"a.md", "b.md", "c.md", "d.md", "e.md"} {
+		if err := ws.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	cursor, pages := "", 0
+	for {
+		resp := doRequest(t, "GET", srv.URL+"/api/fs/?limit=2&cursor="+cursor, nil)
+		var entries []dirEntry
+		if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if total := resp.Header.Get("Wisdom-Total"); total != "5" {
+			t.Fatalf("Wisdom-Total = %q, want 5", total)
+		}
+		for _, e := range entries {
+			names = append(names, e.Name)
+		}
+		pages++
+		cursor = resp.Header.Get("Wisdom-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+	}
+	if pages != 3 || strings.Join(names, ",") != "a.md,b.md,c.md,d.md,e.md" {
+		t.Errorf("got %v in %d pages", names, pages)
+	}
+
+	resp := doRequest(t, "GET", srv.URL+"/api/fs/?cursor=bogus", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid cursor: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestDirectoryListingETag(t *testing.T) {
+	srv, ws := newTestServer(t)
+
+	if err := ws.WriteFile("a.md", []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	get := func(query, etag string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("GET", srv.URL+"/api/fs/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	etag := get("", "").Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if resp := get("", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("unchanged listing: status = %d, want 304", resp.StatusCode)
+	}
+	// The query shapes the listing, so another shape is another ETag.
+	if resp := get("?view=slim", etag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("slim listing: status = %d, want 200", resp.StatusCode)
+	}
+
+	// Editing a file doesn't touch the directory, but changes the listing.
+	if err := ws.WriteFile("a.md", []byte("one, edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resp := get("", etag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("changed listing: status = %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+}
+
 func TestPutPathTraversal(t *testing.T) {
 	srv, _ := newTestServer(t)
 
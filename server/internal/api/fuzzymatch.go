@@ -140,7 +140,7 @@ func FuzzySearch(query string, entries []workspace.WalkEntry, limit int) []Fuzzy
 		})
 	}
 
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].Score > results[j].Score
 	})
 
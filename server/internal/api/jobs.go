@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const maxBackgroundJobs = 100
+
+type jobStatus string
+
+const (
+	jobRunning   jobStatus = "running"
+	jobCompleted jobStatus = "completed"
+	jobFailed    jobStatus = "failed"
+)
+
+// backgroundJob turns the file at Path into the file at Output.
+type backgroundJob struct {
+	ID       string    `json:"id"`
+	Path     string    `json:"path"`
+	Output   string    `json:"output"`
+	Status   jobStatus `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+// jobManager runs the slow steps of importing a file, such as transcription
+// and OCR, in the background. Like downloads, jobs are only kept in memory.
+type jobManager struct {
+	timeout time.Duration
+
+	mu   sync.Mutex
+	jobs []*backgroundJob
+}
+
+func newJobManager(timeout time.Duration) *jobManager {
+	return &jobManager{timeout: timeout}
+}
+
+func (m *jobManager) start(p, output string, fn func(ctx context.Context) error) *backgroundJob {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	job := &backgroundJob{
+		ID:      hex.EncodeToString(buf),
+		Path:    p,
+		Output:  output,
+		Status:  jobRunning,
+		Started: time.Now().UTC(),
+	}
+
+	m.mu.Lock()
+	m.jobs = append(m.jobs, job)
+	excess := len(m.jobs) - maxBackgroundJobs
+	m.jobs = slices.DeleteFunc(m.jobs, func(j *backgroundJob) bool {
+		if excess > 0 && j.Status != jobRunning {
+			excess--
+			return true
+		}
+		return false
+	})
+	snapshot := *job
+	m.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		err := fn(ctx)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		job.Finished = time.Now().UTC()
+		if err != nil {
+			job.Status = jobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = jobCompleted
+	}()
+	return &snapshot
+}
+
+func (m *jobManager) get(id string) (backgroundJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return *j, true
+		}
+	}
+	return backgroundJob{}, false
+}
+
+func (m *jobManager) list() []backgroundJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]backgroundJob, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *m.jobs[i])
+	}
+	return jobs
+}
+
+func jobHandler(m *jobManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		job, ok := m.get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/library"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// slimBookFields are the fields of a book's ?view=slim, enough for a list.
+var slimBookFields = []string{"path", "title", "authors"}
+
+func scanLibrary(w http.ResponseWriter, r *http.Request) ([]library.Book, bool) {
+	books, err := library.Scan(workspace.FromContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return books, true
+}
+
+func libraryBooksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		books, ok := scanLibrary(w, r)
+		if !ok {
+			return
+		}
+		if page, ok := paginate(w, r, books, defaultPageLimits); ok {
+			writeList(w, r, http.StatusOK, page, slimBookFields)
+		}
+	})
+}
+
+// libraryGroupsHandler lists the authors, series or collections of the
+// library.
+func libraryGroupsHandler(groups func([]library.Book) []library.Group) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		books, ok := scanLibrary(w, r)
+		if !ok {
+			return
+		}
+		if page, ok := paginate(w, r, groups(books), defaultPageLimits); ok {
+			writeList(w, r, http.StatusOK, page, nil)
+		}
+	})
+}
+
+// libraryGroupHandler lists the books of one author or series.
+func libraryGroupHandler(members func([]library.Book, string) []library.Book) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		books, ok := scanLibrary(w, r)
+		if !ok {
+			return
+		}
+		result := members(books, r.PathValue("name"))
+		if len(result) == 0 {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if page, ok := paginate(w, r, result, defaultPageLimits); ok {
+			writeList(w, r, http.StatusOK, page, slimBookFields)
+		}
+	})
+}
+
+// libraryCollectionHandler lists a collection's books on GET, adds the book
+// note given as {"path": ...} on POST and removes the one in ?path= on
+// DELETE. Collections exist as long as a book is in them, so listing an
+// empty one isn't an error.
+func libraryCollectionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		ws := workspace.FromContext(r.Context())
+
+		switch r.Method {
+		case http.MethodGet:
+			books, ok := scanLibrary(w, r)
+			if !ok {
+				return
+			}
+			if page, ok := paginate(w, r, library.ByCollection(books, name), defaultPageLimits); ok {
+				writeList(w, r, http.StatusOK, page, slimBookFields)
+			}
+		case http.MethodPost:
+			var req struct {
+				Path string `json:"path"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+				http.Error(w, "path is required", http.StatusBadRequest)
+				return
+			}
+			if err := library.AddToCollection(ws, normalizePath(req.Path), name); err != nil {
+				mapLibraryError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			p := r.URL.Query().Get("path")
+			if p == "" {
+				http.Error(w, "path is required", http.StatusBadRequest)
+				return
+			}
+			if err := library.RemoveFromCollection(ws, normalizePath(p), name); err != nil {
+				mapLibraryError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func mapLibraryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, library.ErrNotABook) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mapError(w, err)
+}
+
+const reconcileTimeout = time.Hour
+
+// reconcileHandler reports the last reconciliation of the library with the
+// book files on GET, and starts one in the background on POST with
+// {"root": ..., "add": ...}. root defaults to where Calibre imports go.
+func reconcileHandler(jobs *jobManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			report, err := library.LoadReconciliation(ws)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if report == nil {
+				http.Error(w, "the library hasn't been reconciled yet", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, report)
+		case http.MethodPost:
+			var req struct {
+				Root string `json:"root"`
+				Add  bool   `json:"add"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			root := defaultCalibreDestination
+			if req.Root != "" {
+				root = normalizePath(req.Root)
+			}
+			if _, err := ws.Resolve(root); err != nil {
+				mapError(w, err)
+				return
+			}
+			job := jobs.start(root, library.ReconcilePath, func(ctx context.Context) error {
+				_, err := library.Reconcile(ws, root, req.Add, time.Now())
+				return err
+			})
+			w.Header().Set("Location", "/api/library/reconcile/jobs/"+job.ID)
+			writeJSON(w, http.StatusAccepted, job)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// reconcileAction reconciles the library on a schedule; args: root, add
+// ("true" to write notes for unlisted books).
+func reconcileAction(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+	root := defaultCalibreDestination
+	if args["root"] != "" {
+		root = normalizePath(args["root"])
+	}
+	_, err := library.Reconcile(ws, root, args["add"] == "true", time.Now())
+	return err
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// limiter bounds how many requests to a group of expensive endpoints run at
+// once, so parallel walks or archives can't wedge a small host. Requests
+// over the limit queue briefly; when the queue is full too, or the wait
+// runs out, they get 503 with Retry-After.
+type limiter struct {
+	running chan struct{}
+	waiting chan struct{}
+	maxWait time.Duration
+}
+
+func newLimiter(concurrent, queued int, maxWait time.Duration) *limiter {
+	return &limiter{
+		running: make(chan struct{}, concurrent),
+		waiting: make(chan struct{}, concurrent+queued),
+		maxWait: maxWait,
+	}
+}
+
+// Limits for workspace walks, which are mostly disk-bound, and for whole
+// workspace archives, which also hold a core busy compressing. Walks wait
+// well under the server's write timeout, which also covers the wait, so a
+// request admitted late still has time to answer; archives lift it.
+func newWalkLimiter() *limiter {
+	return newLimiter(max(2, runtime.NumCPU()), 16, 2*time.Second)
+}
+
+func newArchiveLimiter() *limiter {
+	return newLimiter(1, 1, 30*time.Second)
+}
+
+// Deriving a key from a password takes a core for a fraction of a second
+// by design, which also slows down guessing. Like walks, the wait stays
+// well under the write timeout.
+func newPasswordLimiter() *limiter {
+	return newLimiter(2, 8, 2*time.Second)
+}
+
+func (l *limiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.waiting <- struct{}{}:
+		default:
+			l.reject(w)
+			return
+		}
+		defer func() { <-l.waiting }()
+
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		select {
+		case l.running <- struct{}{}:
+		case <-timer.C:
+			l.reject(w)
+			return
+		case <-r.Context().Done():
+			return
+		}
+		defer func() { <-l.running }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *limiter) reject(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(l.maxWait.Seconds()))))
+	http.Error(w, "server busy, retry later", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	handler := newLimiter(1, 1, time.Second).limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	results := make(chan int, 2)
+	go func() { results <- do().Code }()
+	<-started
+	go func() { results <- do().Code }()
+	// Let the second request queue, then the third finds the queue full.
+	time.Sleep(50 * time.Millisecond)
+	if w := do(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("over the queue = %d, Retry-After %q; want 503", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	for range 2 {
+		if code := <-results; code != http.StatusOK {
+			t.Errorf("queued request = %d, want 200", code)
+		}
+	}
+
+	t.Run("wait runs out", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		handler := newLimiter(1, 1, 10*time.Millisecond).limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		time.Sleep(20 * time.Millisecond)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("after waiting = %d, want 503", w.Code)
+		}
+	})
+}
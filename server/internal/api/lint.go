@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/lint"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func lintAction(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+	_, err := lint.Run(ws, time.Now())
+	return err
+}
+
+// lintHandler serves the report of the last lint run (GET /api/lint) and
+// runs the rules again (POST).
+func lintHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		var report lint.Report
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			report, err = lint.Last(ws)
+		case http.MethodPost:
+			report, err = lint.Run(ws, time.Now())
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, lint.ErrNoReport):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, lint.ErrInvalidConfig):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		case err != nil:
+			mapError(w, err)
+		default:
+			writeJSON(w, http.StatusOK, report)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const defaultCoverMaxAgeDays = 90
+
+type maintenanceOptions struct {
+	// KeepSnapshots deletes all but the newest snapshots if set.
+	KeepSnapshots int `json:"keepSnapshots"`
+	// CoverMaxAgeDays is how long an unused cover stays cached.
+	CoverMaxAgeDays int `json:"coverMaxAgeDays"`
+}
+
+// reclaimed is how many files a cleanup removed and their total size.
+type reclaimed struct {
+	Removed int   `json:"removed"`
+	Bytes   int64 `json:"bytes"`
+}
+
+type maintenanceReport struct {
+	Uploads   reclaimed `json:"uploads"`
+	Covers    reclaimed `json:"covers"`
+	Snapshots reclaimed `json:"snapshots"`
+}
+
+type maintainer struct {
+	uploads *uploadStore
+	covers  *covers.Cache
+}
+
+// run removes abandoned upload sessions, covers that are no longer used
+// and, if asked, old snapshots. Every cleanup is attempted even if another
+// fails.
+func (m *maintainer) run(ws *workspace.Workspace, opts maintenanceOptions) (maintenanceReport, error) {
+	var report maintenanceReport
+	var errs []error
+
+	uploads, err := m.uploads.collectGarbage(ws, uploadSessionMaxAge)
+	report.Uploads = uploads
+	errs = append(errs, err)
+
+	days := opts.CoverMaxAgeDays
+	if days == 0 {
+		days = defaultCoverMaxAgeDays
+	}
+	report.Covers.Removed, report.Covers.Bytes, err = m.covers.Prune(ws, time.Duration(days)*24*time.Hour, time.Now())
+	errs = append(errs, err)
+
+	if opts.KeepSnapshots > 0 {
+		before, _ := snapshot.List(ws)
+		usage, err := snapshot.Usage(ws)
+		errs = append(errs, err)
+		errs = append(errs, snapshot.Keep(ws, opts.KeepSnapshots))
+		after, _ := snapshot.List(ws)
+		remaining, err := snapshot.Usage(ws)
+		errs = append(errs, err)
+		report.Snapshots = reclaimed{Removed: len(before) - len(after), Bytes: usage - remaining}
+	}
+	return report, errors.Join(errs...)
+}
+
+// action runs maintenance on a schedule; args: keepSnapshots,
+// coverMaxAgeDays.
+func (m *maintainer) action(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+	var opts maintenanceOptions
+	for name, field := range map[string]*int{"keepSnapshots": &opts.KeepSnapshots, "coverMaxAgeDays": &opts.CoverMaxAgeDays} {
+		if args[name] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(args[name])
+		if err != nil || n < 1 {
+			return errors.New(name + " must be a positive number")
+		}
+		*field = n
+	}
+	_, err := m.run(ws, opts)
+	return err
+}
+
+// maintenanceHandler runs maintenance with the options in the body, if any,
+// and reports what it reclaimed.
+func maintenanceHandler(m *maintainer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var opts maintenanceOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if opts.KeepSnapshots < 0 || opts.CoverMaxAgeDays < 0 {
+			http.Error(w, "options must not be negative", http.StatusBadRequest)
+			return
+		}
+		report, err := m.run(workspace.FromContext(r.Context()), opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+}
@@ -0,0 +1,55 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	srv, ws := newTestServer(t)
+
+	for i, content := range []string{"one", "two", "three"} {
+		if err := ws.WriteFile("plan.md", []byte(strings.Repeat(content, i+1)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/snapshots", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("snapshot: status = %d", resp.StatusCode)
+		}
+	}
+
+	type report struct {
+		Snapshots struct {
+			Removed int   `json:"removed"`
+			Bytes   int64 `json:"bytes"`
+		} `json:"snapshots"`
+	}
+	run := func(body string) report {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/maintenance", strings.NewReader(body))
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("maintenance %q: status = %d", body, resp.StatusCode)
+		}
+		var r report
+		json.NewDecoder(resp.Body).Decode(&r)
+		return r
+	}
+
+	// Snapshots are only deleted when asked.
+	if r := run(""); r.Snapshots.Removed != 0 {
+		t.Errorf("default maintenance removed %d snapshots", r.Snapshots.Removed)
+	}
+	if r := run(`{"keepSnapshots": 1}`); r.Snapshots.Removed != 2 || r.Snapshots.Bytes <= 0 {
+		t.Errorf("snapshots reclaimed = %+v, want 2 with their space", r.Snapshots)
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/maintenance", strings.NewReader(`{"keepSnapshots": -1}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("negative option: status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/shrik450/wisdom/internal/covers"
+)
+
+// cacheMetricsHandler reports the hit rate and size of the in-memory
+// caches, to tell whether they are big enough.
+func cacheMetricsHandler(coverCache *covers.Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"covers": coverCache.MemoryStats()})
+	})
+}
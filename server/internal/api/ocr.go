@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/ocr"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	ocrTimeout   = 30 * time.Minute
+	ocrJobHeader = "Wisdom-OCR-Job"
+)
+
+// ocrScanner writes the text of scanned documents to sidecar files. It is
+// disabled when there is no engine.
+type ocrScanner struct {
+	engine *ocr.Engine
+	jobs   *jobManager
+}
+
+func newOCRScanner(engine *ocr.Engine) *ocrScanner {
+	return &ocrScanner{engine: engine, jobs: newJobManager(ocrTimeout)}
+}
+
+func (s *ocrScanner) enabled() bool {
+	return s.engine != nil
+}
+
+// needsScan reports whether the file at p is an image or a PDF without a
+// text layer.
+func (s *ocrScanner) needsScan(ws *workspace.Workspace, p string) bool {
+	if !ocr.IsScannable(p) {
+		return false
+	}
+	if !strings.EqualFold(path.Ext(p), ".pdf") {
+		return true
+	}
+	data, err := ws.ReadFile(p)
+	return err == nil && !ocr.HasText(data)
+}
+
+func (s *ocrScanner) start(ws *workspace.Workspace, p string) *backgroundJob {
+	sidecar := ocr.SidecarPath(p)
+	return s.jobs.start(p, sidecar, func(ctx context.Context) error {
+		f, err := ws.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		text, err := s.engine.Extract(ctx, p, f)
+		if err != nil {
+			return err
+		}
+		return ws.WriteFile(sidecar, []byte(text), 0o644)
+	})
+}
+
+func ocrHandler(s *ocrScanner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.jobs.list())
+		case http.MethodPost:
+			handleStartOCR(w, r, s)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func handleStartOCR(w http.ResponseWriter, r *http.Request, s *ocrScanner) {
+	if !s.enabled() {
+		http.Error(w, "OCR is disabled; set WISDOM_TESSERACT", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	p := normalizePath(req.Path)
+	if !ocr.IsScannable(p) {
+		http.Error(w, "path must be an image or a PDF", http.StatusBadRequest)
+		return
+	}
+
+	ws := workspace.FromContext(r.Context())
+	if _, err := ws.Stat(p); err != nil {
+		mapError(w, err)
+		return
+	}
+	job := s.start(ws, p)
+	w.Header().Set("Location", "/api/ocr/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,48 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOCR(t *testing.T) {
+	tesseract := filepath.Join(t.TempDir(), "tesseract")
+	if err := os.WriteFile(tesseract, []byte("#!/bin/sh\necho 'TOTAL 12.50'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WISDOM_TESSERACT", tesseract)
+	t.Setenv("TMPDIR", t.TempDir())
+	srv, ws := newTestServer(t)
+
+	upload := func(t *testing.T, p, content string) *http.Response {
+		t.Helper()
+		sess := createUpload(t, srv.URL, p, len(content))
+		sendChunk(t, srv.URL, sess.ID, 0, content)
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/uploads/"+sess.ID+"/finalize", nil)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("uploaded scans get a text sidecar", func(t *testing.T) {
+		id := upload(t, "receipts/lunch.png", "png").Header.Get("Wisdom-OCR-Job")
+		if id == "" {
+			t.Fatal("no OCR job started")
+		}
+		job := waitForJob(t, srv.URL+"/api/ocr/"+id)
+		if job.Status != "completed" || job.Output != "receipts/lunch.png.txt" {
+			t.Fatalf("job = %+v", job)
+		}
+		if text, err := ws.ReadFile(job.Output); err != nil || string(text) != "TOTAL 12.50\n" {
+			t.Errorf("sidecar = %q, %v", text, err)
+		}
+	})
+
+	t.Run("PDFs with text are skipped", func(t *testing.T) {
+		resp := upload(t, "papers/typed.pdf", "%PDF-1.4 /Font /F1")
+		if id := resp.Header.Get("Wisdom-OCR-Job"); id != "" {
+			t.Errorf("OCR job %s started for a PDF with text", id)
+		}
+	})
+}
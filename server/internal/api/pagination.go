@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Pagination is reported in headers, so list bodies keep their shape and
+// clients that don't page still work on lists shorter than the default.
+const (
+	// nextCursorHeader holds the ?cursor= for the next page, if there is one.
+	nextCursorHeader = "Wisdom-Next-Cursor"
+	totalHeader      = "Wisdom-Total"
+)
+
+type pageLimits struct {
+	def, max int
+}
+
+var defaultPageLimits = pageLimits{def: 1000, max: 5000}
+
+// paginate returns the page of items selected by ?cursor= and ?limit=, so
+// no list response grows without bound. A missing or invalid limit gets the
+// default and larger ones are capped. Cursors are opaque to clients; they
+// are offsets, so a list changing between requests can shift items across
+// pages. items must be in a stable order. It reports false if the cursor
+// was invalid, after responding.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T, limits pageLimits) ([]T, bool) {
+	offset := 0
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return nil, false
+		}
+		offset = n
+	}
+	limit := limits.def
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, limits.max)
+	}
+
+	w.Header().Set(totalHeader, strconv.Itoa(len(items)))
+	if offset >= len(items) {
+		return []T{}, true
+	}
+	end := min(offset+limit, len(items))
+	if end < len(items) {
+		w.Header().Set(nextCursorHeader, strconv.Itoa(end))
+	}
+	return items[offset:end], true
+}
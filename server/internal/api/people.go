@@ -0,0 +1,86 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/people"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type personSummary struct {
+	notes.Note
+	Mentions int `json:"mentions"`
+	// LastMentioned is the date of the newest mention, if any.
+	LastMentioned string `json:"lastMentioned,omitempty"`
+}
+
+// peopleHandler lists the person notes with how often they are mentioned,
+// sorted by title.
+func peopleHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		all, err := index.Notes(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mentions := people.Mentions(ws, all)
+
+		result := []personSummary{}
+		for _, p := range people.People(all) {
+			s := personSummary{Note: p, Mentions: len(mentions[p.Path])}
+			if s.Mentions > 0 {
+				s.LastMentioned = mentions[p.Path][0].Date
+			}
+			result = append(result, s)
+		}
+		slices.SortFunc(result, func(a, b personSummary) int {
+			return cmp.Or(strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), strings.Compare(a.Path, b.Path))
+		})
+		if page, ok := paginate(w, r, result, defaultPageLimits); ok {
+			writeList(w, r, http.StatusOK, page, nil)
+		}
+	})
+}
+
+// personHandler returns a person note and the timeline of its mentions,
+// newest first.
+func personHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		all, err := index.Notes(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		persons := people.People(all)
+		i := slices.IndexFunc(persons, func(n notes.Note) bool { return n.Path == p })
+		if i < 0 {
+			http.Error(w, p+" is not a person note", http.StatusNotFound)
+			return
+		}
+
+		mentions := people.Mentions(ws, all)[p]
+		if mentions == nil {
+			mentions = []people.Mention{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"person": persons[i], "mentions": mentions})
+	})
+}
@@ -0,0 +1,55 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPeople(t *testing.T) {
+	srv, ws := newTestServer(t)
+	for p, content := range map[string]string{
+		"ada.md":        "---\ntitle: Ada Lovelace\ntype: person\n---\n",
+		"grace.md":      "---\ntitle: Grace Hopper\ntype: person\n---\n",
+		"2024-01-02.md": "Met @ada-lovelace.\n",
+		"2024-02-03.md": "More on [[Ada Lovelace]].\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/people", nil)
+	defer resp.Body.Close()
+	var list []struct {
+		Path          string `json:"path"`
+		Mentions      int    `json:"mentions"`
+		LastMentioned string `json:"lastMentioned"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Path != "ada.md" || list[0].Mentions != 2 || list[0].LastMentioned != "2024-02-03" || list[1].Mentions != 0 {
+		t.Errorf("people = %+v", list)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/people/ada.md", nil)
+	defer resp.Body.Close()
+	var timeline struct {
+		Mentions []struct {
+			Path string `json:"path"`
+		} `json:"mentions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&timeline); err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline.Mentions) != 2 || timeline.Mentions[0].Path != "2024-02-03.md" {
+		t.Errorf("timeline = %+v", timeline)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/people/2024-01-02.md", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("non-person status = %d, want 404", resp.StatusCode)
+	}
+}
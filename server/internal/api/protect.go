@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func mapProtectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, protect.ErrWrongPassphrase):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, protect.ErrNoPassphrase):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, protect.ErrNotProtected):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, protect.ErrAlreadyProtected):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		mapError(w, err)
+	}
+}
+
+type protectRequest struct {
+	Folder     string `json:"folder"`
+	Passphrase string `json:"passphrase"`
+}
+
+func decodeProtectRequest(w http.ResponseWriter, r *http.Request) (protectRequest, bool) {
+	var req protectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Folder == "" {
+		http.Error(w, "folder is required", http.StatusBadRequest)
+		return req, false
+	}
+	req.Folder = normalizePath(req.Folder)
+	return req, true
+}
+
+// protectedHandler lists the protected folders and whether this session
+// has them unlocked (GET), protects a folder (POST) and lifts the
+// protection of an unlocked one (DELETE ?folder=).
+func protectedHandler(folders *protect.Folders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			names, err := folders.List(ws)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			type protected struct {
+				Folder   string `json:"folder"`
+				Unlocked bool   `json:"unlocked"`
+			}
+			list := make([]protected, len(names))
+			for i, name := range names {
+				list[i] = protected{Folder: name, Unlocked: !ws.IsLocked(name)}
+			}
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			req, ok := decodeProtectRequest(w, r)
+			if !ok {
+				return
+			}
+			// Stat in this view, so a folder inside a locked one can't be
+			// protected by someone who can't open it.
+			info, err := ws.Stat(req.Folder)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			if !info.IsDir() || req.Folder == "." {
+				http.Error(w, "only folders below the root can be protected", http.StatusBadRequest)
+				return
+			}
+			if err := folders.Protect(ws, req.Folder, req.Passphrase); err != nil {
+				mapProtectError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			p := normalizePath(r.URL.Query().Get("folder"))
+			if ws.IsLocked(p) {
+				http.Error(w, "unlock the folder first", http.StatusLocked)
+				return
+			}
+			if err := folders.Unprotect(ws, p); err != nil {
+				mapProtectError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// unlockFolderHandler unlocks a protected folder for the client's session,
+// starting one if needed, and answers with the session token, which is
+// also set as a cookie for browsers.
+func unlockFolderHandler(folders *protect.Folders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req, ok := decodeProtectRequest(w, r)
+		if !ok {
+			return
+		}
+		token, expires, err := folders.Unlock(workspace.FromContext(r.Context()), protect.SessionToken(r), req.Folder, req.Passphrase, time.Now())
+		if err != nil {
+			mapProtectError(w, err)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     protect.Cookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		writeJSON(w, http.StatusOK, map[string]any{"token": token, "expires": expires})
+	})
+}
+
+// lockFoldersHandler ends the client's session, locking every folder it
+// unlocked.
+func lockFoldersHandler(folders *protect.Folders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		folders.End(protect.SessionToken(r))
+		http.SetCookie(w, &http.Cookie{Name: protect.Cookie, Path: "/", MaxAge: -1})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
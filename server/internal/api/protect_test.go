@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProtectedFolders(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("journal/private", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("journal/private/secret.md", []byte("# Secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	do := func(t *testing.T, method, url, token, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Wisdom-Folder-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	expect := func(t *testing.T, resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Errorf("%s %s: status = %d, want %d", resp.Request.Method, resp.Request.URL, resp.StatusCode, status)
+		}
+	}
+
+	expect(t, do(t, http.MethodPost, "/api/protected", "", `{"folder":"journal/private","passphrase":"open sesame"}`), http.StatusNoContent)
+	expect(t, do(t, http.MethodPost, "/api/protected", "", `{"folder":"journal/private","passphrase":"again"}`), http.StatusLocked)
+	expect(t, do(t, http.MethodGet, "/api/fs/journal/private/secret.md", "", ""), http.StatusLocked)
+	expect(t, do(t, http.MethodGet, "/api/fs/journal/private", "", ""), http.StatusLocked)
+	expect(t, do(t, http.MethodPut, "/api/fs/.wisdom/protected.json", "", "[]"), http.StatusLocked)
+	expect(t, do(t, http.MethodDelete, "/api/protected?folder=journal/private", "", ""), http.StatusLocked)
+	expect(t, do(t, http.MethodPost, "/api/protected/unlock", "", `{"folder":"journal/private","passphrase":"wrong"}`), http.StatusForbidden)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/protected/unlock", strings.NewReader(`{"folder":"journal/private","passphrase":"open sesame"}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unlocked struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&unlocked)
+	resp.Body.Close()
+	if unlocked.Token == "" || len(resp.Cookies()) != 1 || resp.Cookies()[0].Value != unlocked.Token {
+		t.Fatalf("unlock: token %q, cookies %v", unlocked.Token, resp.Cookies())
+	}
+
+	expect(t, do(t, http.MethodGet, "/api/fs/journal/private/secret.md", unlocked.Token, ""), http.StatusOK)
+	expect(t, do(t, http.MethodGet, "/api/fs/journal/private/secret.md", "", ""), http.StatusLocked)
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api/protected", nil)
+	req.Header.Set("Wisdom-Folder-Token", unlocked.Token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []struct {
+		Folder   string `json:"folder"`
+		Unlocked bool   `json:"unlocked"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list) != 1 || list[0].Folder != "journal/private" || !list[0].Unlocked {
+		t.Errorf("protected = %+v", list)
+	}
+
+	expect(t, do(t, http.MethodPost, "/api/protected/lock", unlocked.Token, ""), http.StatusNoContent)
+	expect(t, do(t, http.MethodGet, "/api/fs/journal/private/secret.md", unlocked.Token, ""), http.StatusLocked)
+
+	t.Run("unprotect", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/protected/unlock", strings.NewReader(`{"folder":"journal/private","passphrase":"open sesame"}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&unlocked)
+		resp.Body.Close()
+		expect(t, do(t, http.MethodDelete, "/api/protected?folder=journal/private", unlocked.Token, ""), http.StatusNoContent)
+		expect(t, do(t, http.MethodGet, "/api/fs/journal/private/secret.md", "", ""), http.StatusOK)
+	})
+}
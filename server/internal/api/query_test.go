@@ -0,0 +1,48 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("books", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"books/dune.md": "---\ntitle: Dune\nrating: 5\n---\n",
+		"books/emma.md": "---\ntitle: Emma\nrating: 3\n---\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `{"from":"books","select":["title","file.size"],"where":[{"field":"rating","op":">=","value":"4"}]}`
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/query", strings.NewReader(body))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d", resp.StatusCode)
+	}
+	var result struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]any{{"Dune", float64(len("---\ntitle: Dune\nrating: 5\n---\n"))}}
+	if !reflect.DeepEqual(result.Columns, []string{"title", "file.size"}) || !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("result = %+v", result)
+	}
+
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/query", strings.NewReader(`{"where":[{"field":"rating","op":"like"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid op status = %d", resp.StatusCode)
+	}
+}
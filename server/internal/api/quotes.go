@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/quotes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// dailyQuoteHandler returns the quote of the day, or of ?date=YYYY-MM-DD.
+func dailyQuoteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		date := r.URL.Query().Get("date")
+		if date == "" {
+			date = time.Now().Format(time.DateOnly)
+		} else if _, err := time.Parse(time.DateOnly, date); err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+
+		all, err := quotes.Collect(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		quote, ok := quotes.Daily(all, date)
+		if !ok {
+			http.Error(w, "no quotes in the workspace", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, quote)
+	})
+}
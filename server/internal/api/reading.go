@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reading"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func readingProgressHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Path  string `json:"path"`
+			Page  int    `json:"page"`
+			Pages int    `json:"pages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.Path == "" || req.Page < 0 || req.Pages < 0 {
+			http.Error(w, "path and a non-negative page are required", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		p := normalizePath(req.Path)
+		if _, err := ws.Stat(p); err != nil {
+			mapError(w, err)
+			return
+		}
+		err := reading.Append(ws, reading.Entry{
+			Kind:  reading.KindProgress,
+			Time:  time.Now(),
+			Path:  p,
+			Page:  req.Page,
+			Pages: req.Pages,
+		})
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func readingGoalHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.Header().Set("Allow", "PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Year  int `json:"year"`
+			Books int `json:"books"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.Year == 0 {
+			req.Year = time.Now().Year()
+		}
+		if req.Books < 0 {
+			http.Error(w, "books must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		err := reading.Append(workspace.FromContext(r.Context()), reading.Entry{
+			Kind:  reading.KindGoal,
+			Time:  time.Now(),
+			Year:  req.Year,
+			Books: req.Books,
+		})
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func readingStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		now := time.Now()
+		year := now.Year()
+		if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+			n, err := strconv.Atoi(yearStr)
+			if err != nil {
+				http.Error(w, "invalid year", http.StatusBadRequest)
+				return
+			}
+			year = n
+		}
+
+		entries, err := reading.ReadLog(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, reading.Compute(entries, year, now))
+	})
+}
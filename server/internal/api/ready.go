@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/shrik450/wisdom/internal/notes"
+)
+
+// ReadyHandler reports whether the note index has been built, with its
+// progress, for health checks and the UI. Until then lookups by title are
+// degraded to file names.
+func ReadyHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		progress := index.Progress()
+		status := http.StatusOK
+		if !progress.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, progress)
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reorganize"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// reorganizeHandler moves files and folders in bulk, keeping the links
+// between notes working. POST /api/reorganize with {"rules": [...],
+// "dryRun": true} returns the plan; without dryRun it is carried out and
+// logged. GET lists the reorganizations carried out, newest first.
+func reorganizeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			entries, err := reorganize.Log(ws)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, entries)
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Rules  []reorganize.Rule `json:"rules"`
+			DryRun bool              `json:"dryRun"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		for _, rule := range req.Rules {
+			if (rule.Tag == "" && isProtectedPath(normalizePath(rule.From))) || isProtectedPath(normalizePath(rule.To)) {
+				http.Error(w, "path is protected", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.DryRun {
+			plan, err := reorganize.NewPlan(ws, req.Rules)
+			if err != nil {
+				mapReorganizeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, plan)
+			return
+		}
+		plan, entry, err := reorganize.Execute(ws, req.Rules, time.Now())
+		if errors.Is(err, reorganize.ErrConflict) {
+			writeJSON(w, http.StatusConflict, plan)
+			return
+		}
+		if err != nil {
+			mapReorganizeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			*reorganize.Plan
+			Snapshot string `json:"snapshot"`
+		}{plan, entry.Snapshot})
+	})
+}
+
+func mapReorganizeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, reorganize.ErrInvalidRule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mapError(w, err)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type resolveResponse struct {
+	Name string `json:"name"`
+	// Path is set only when the name resolves to exactly one note.
+	Path      string        `json:"path,omitempty"`
+	Ambiguous bool          `json:"ambiguous"`
+	Matches   []notes.Match `json:"matches"`
+}
+
+func resolveHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		if name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		all, err := index.Notes(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		matches := notes.Resolve(all, name)
+		if len(matches) == 0 {
+			http.Error(w, "no note matches "+name, http.StatusNotFound)
+			return
+		}
+
+		resp := resolveResponse{Name: name, Ambiguous: len(matches) > 1, Matches: matches}
+		if len(matches) == 1 {
+			resp.Path = matches[0].Path
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
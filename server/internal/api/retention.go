@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reorganize"
+	"github.com/shrik450/wisdom/internal/retention"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func retentionAction(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+	_, err := retention.Apply(ws, time.Now())
+	return err
+}
+
+func mapRetentionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, retention.ErrInvalidPolicy):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case errors.Is(err, reorganize.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		mapError(w, err)
+	}
+}
+
+// retentionPreviewHandler lists the notes the retention policies would
+// archive or flag now.
+func retentionPreviewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		policies, err := retention.Load(ws)
+		if err != nil {
+			mapRetentionError(w, err)
+			return
+		}
+		candidates, err := retention.Preview(ws, policies, time.Now())
+		if err != nil {
+			mapRetentionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"notes": candidates})
+	})
+}
+
+// retentionApplyHandler archives and flags the notes the preview lists.
+func retentionApplyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		applied, err := retention.Apply(workspace.FromContext(r.Context()), time.Now())
+		if err != nil {
+			mapRetentionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"notes": applied})
+	})
+}
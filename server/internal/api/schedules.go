@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/assist"
+	"github.com/shrik450/wisdom/internal/mail"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/tools"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// registerActions makes the server's tasks available to schedules:
+//
+//   - reindex refreshes the note index.
+//   - suggest queues title and tag suggestions; args: model ("true" to ask
+//     the model too).
+//   - tool runs a tool and overwrites its output; args: tool, path, output.
+//   - snapshot snapshots the workspace; args: keep (how many to keep).
+//   - maintenance cleans up caches and old data; args: keepSnapshots,
+//     coverMaxAgeDays.
+//   - reconcile compares the library with the book files; args: root, add.
+//   - digest compiles recently changed notes into one; args: folder, tag,
+//     days, output, title, email (addresses to also mail it to).
+//   - activity mails a summary of recent activity; args: to, days.
+//   - lint checks the workspace and keeps the report.
+//   - retention archives and flags notes by the retention policies.
+func registerActions(s *schedule.Scheduler, index *notes.Index, model assist.Model, registry *tools.Registry, maint *maintainer, sender *mail.Sender) {
+	s.Register("reindex", func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		return index.Refresh(ws)
+	})
+	s.Register("suggest", func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		var m assist.Model
+		if args["model"] == "true" {
+			if model == nil {
+				return assist.ErrDisabled
+			}
+			m = model
+		}
+		_, err := assist.Scan(ctx, ws, m)
+		return err
+	})
+	s.Register("tool", func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		if registry == nil {
+			return tools.ErrDisabled
+		}
+		tool, err := registry.Get(args["tool"])
+		if err != nil {
+			return err
+		}
+		p := normalizePath(args["path"])
+		output := strings.TrimSuffix(p, path.Ext(p)) + tool.Output
+		if args["output"] != "" {
+			output = normalizePath(args["output"])
+		}
+		return runTool(ctx, ws, tool, p, output)
+	})
+	s.Register("snapshot", snapshotAction)
+	s.Register("maintenance", maint.action)
+	s.Register("reconcile", reconcileAction)
+	s.Register("digest", digestAction(sender))
+	s.Register("activity", activityAction(sender))
+	s.Register("lint", lintAction)
+	s.Register("retention", retentionAction)
+}
+
+type scheduleView struct {
+	schedule.Schedule
+	// Next is when the schedule runs next, unless it is paused.
+	Next   time.Time       `json:"next,omitzero"`
+	Status schedule.Status `json:"status"`
+}
+
+func viewSchedule(s *schedule.Scheduler, sched schedule.Schedule) scheduleView {
+	v := scheduleView{Schedule: sched, Status: s.Status(sched.ID)}
+	if spec, err := schedule.Parse(sched.Cron); err == nil && !sched.Paused {
+		v.Next = spec.Next(time.Now())
+	}
+	return v
+}
+
+func mapScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrUnknownSchedule):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, schedule.ErrInvalidCron), errors.Is(err, schedule.ErrUnknownAction):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, schedule.ErrRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		mapError(w, err)
+	}
+}
+
+func decodeSchedule(w http.ResponseWriter, r *http.Request, s *schedule.Scheduler) (schedule.Schedule, bool) {
+	var sched schedule.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&sched); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return sched, false
+	}
+	if err := s.Validate(sched); err != nil {
+		mapScheduleError(w, err)
+		return sched, false
+	}
+	return sched, true
+}
+
+// findSchedule loads the schedule with the id in the request path.
+func findSchedule(w http.ResponseWriter, r *http.Request) (schedule.Schedule, bool) {
+	ws := workspace.FromContext(r.Context())
+	all, err := schedule.Load(ws)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return schedule.Schedule{}, false
+	}
+	i := slices.IndexFunc(all, func(sched schedule.Schedule) bool { return sched.ID == r.PathValue("id") })
+	if i < 0 {
+		mapScheduleError(w, schedule.ErrUnknownSchedule)
+		return schedule.Schedule{}, false
+	}
+	return all[i], true
+}
+
+func schedulesHandler(s *schedule.Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			all, err := schedule.Load(ws)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			views := make([]scheduleView, len(all))
+			for i, sched := range all {
+				views[i] = viewSchedule(s, sched)
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"schedules": views, "actions": s.Actions()})
+		case http.MethodPost:
+			sched, ok := decodeSchedule(w, r, s)
+			if !ok {
+				return
+			}
+			sched.ID = ""
+			sched, err := schedule.Put(ws, sched)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			w.Header().Set("Location", "/api/schedules/"+sched.ID)
+			writeJSON(w, http.StatusCreated, viewSchedule(s, sched))
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func scheduleHandler(s *schedule.Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			if sched, ok := findSchedule(w, r); ok {
+				writeJSON(w, http.StatusOK, viewSchedule(s, sched))
+			}
+		case http.MethodPut:
+			if _, ok := findSchedule(w, r); !ok {
+				return
+			}
+			sched, ok := decodeSchedule(w, r, s)
+			if !ok {
+				return
+			}
+			sched.ID = r.PathValue("id")
+			if _, err := schedule.Put(ws, sched); err != nil {
+				mapError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, viewSchedule(s, sched))
+		case http.MethodDelete:
+			if err := schedule.Delete(ws, r.PathValue("id")); err != nil {
+				mapScheduleError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// scheduleRunHandler runs a schedule right away, even if it is paused, and
+// responds with how it went once it has finished.
+func scheduleRunHandler(s *schedule.Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		sched, ok := findSchedule(w, r)
+		if !ok {
+			return
+		}
+		status, err := s.RunNow(r.Context(), sched)
+		if errors.Is(err, schedule.ErrRunning) || errors.Is(err, schedule.ErrUnknownAction) {
+			mapScheduleError(w, err)
+			return
+		}
+		// A failed action is reported in the status.
+		writeJSON(w, http.StatusOK, status)
+	})
+}
@@ -0,0 +1,82 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSchedules(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("Untitled.md", []byte("Groceries for the week\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/schedules", strings.NewReader(`{"name":"Nightly","cron":"0 3 * * *","action":"suggest"}`))
+	var created struct {
+		ID   string `json:"id"`
+		Next string `json:"next"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.ID == "" || created.Next == "" {
+		t.Fatalf("status = %d, created = %+v", resp.StatusCode, created)
+	}
+
+	for _, body := range []string{`{"cron":"every day","action":"suggest"}`, `{"cron":"@daily","action":"backup"}`} {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/schedules", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/schedules/"+created.ID+"/run", nil)
+	var status struct {
+		Finished string `json:"finished"`
+		Error    string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || status.Finished == "" || status.Error != "" {
+		t.Fatalf("run: status = %d, %+v", resp.StatusCode, status)
+	}
+	if data, _ := ws.ReadFile(".wisdom/suggestions.json"); !strings.Contains(string(data), "Groceries for the week") {
+		t.Errorf("suggestions = %s", data)
+	}
+
+	resp = doRequest(t, http.MethodPut, srv.URL+"/api/schedules/"+created.ID, strings.NewReader(`{"name":"Nightly","cron":"0 3 * * *","action":"reindex","paused":true}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put: status = %d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/schedules", nil)
+	var list struct {
+		Schedules []struct {
+			Action string `json:"action"`
+			Next   string `json:"next"`
+			Status struct {
+				Finished string `json:"finished"`
+			} `json:"status"`
+		} `json:"schedules"`
+		Actions []string `json:"actions"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Schedules) != 1 || list.Schedules[0].Action != "reindex" || list.Schedules[0].Next != "" || list.Schedules[0].Status.Finished == "" {
+		t.Errorf("schedules = %+v", list.Schedules)
+	}
+	if strings.Join(list.Actions, ",") != "activity,digest,lint,maintenance,reconcile,reindex,retention,snapshot,suggest,tool" {
+		t.Errorf("actions = %v", list.Actions)
+	}
+
+	resp = doRequest(t, http.MethodDelete, srv.URL+"/api/schedules/"+created.ID, nil)
+	resp.Body.Close()
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/schedules/"+created.ID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted schedule: status = %d, want 404", resp.StatusCode)
+	}
+}
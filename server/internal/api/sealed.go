@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// unlockTokenHeader carries the token from /api/unlock on requests for a
+// sealed note's content.
+const unlockTokenHeader = "Wisdom-Unlock-Token"
+
+func mapSealedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sealed.ErrNotSealed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sealed.ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, sealed.ErrNoPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sealed.ErrSealed):
+		http.Error(w, err.Error(), http.StatusLocked)
+	default:
+		mapError(w, err)
+	}
+}
+
+// sealedNotes returns the sealed notes at p: p itself if it is one, or
+// those anywhere under it if it is a folder.
+func sealedNotes(ws *workspace.Workspace, p string) ([]string, error) {
+	info, err := ws.Lstat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() || !notes.IsNote(p) {
+			return nil, nil
+		}
+		f, err := ws.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if ok, err := sealed.Sniff(f); err != nil || !ok {
+			return nil, err
+		}
+		return []string{p}, nil
+	}
+	entries, err := ws.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, e := range entries {
+		under, err := sealedNotes(ws, path.Join(p, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, under...)
+	}
+	return found, nil
+}
+
+// checkUnsealed reports whether the request may replace, move or delete
+// what is at p. A sealed note can only be changed with an unlock token for
+// it, and a folder holding sealed notes not at all; otherwise it answers
+// 423 Locked.
+func checkUnsealed(w http.ResponseWriter, r *http.Request, unlocks *sealed.Unlocks, p string) bool {
+	if err := unsealed(r, unlocks, p); err != nil {
+		mapSealedError(w, err)
+		return false
+	}
+	return true
+}
+
+// unsealed returns sealed.ErrSealed if p is, or holds, a sealed note the
+// request has no unlock token for.
+func unsealed(r *http.Request, unlocks *sealed.Unlocks, p string) error {
+	found, err := sealedNotes(workspace.FromContext(r.Context()), p)
+	if err != nil {
+		return err
+	}
+	for _, note := range found {
+		if _, ok := unlocks.Key(r.Header.Get(unlockTokenHeader), note, time.Now()); !ok || note != p {
+			return sealed.ErrSealed
+		}
+	}
+	return nil
+}
+
+func decodePassword(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return "", false
+	}
+	return req.Password, true
+}
+
+// sealedHandler seals a note with a password (POST), dropping it from
+// snapshots, and reads (GET), replaces (PUT) and unseals (DELETE) a sealed
+// one given an unlock token. Without a valid token it answers 423 Locked.
+func sealedHandler(unlocks *sealed.Unlocks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		if !notes.IsNote(p) {
+			http.Error(w, "only notes can be sealed", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost, http.MethodGet, http.MethodPut, http.MethodDelete:
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ws.ReadFile(p)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			password, ok := decodePassword(w, r)
+			if !ok {
+				return
+			}
+			if sealed.IsSealed(data) {
+				http.Error(w, "note is already sealed", http.StatusConflict)
+				return
+			}
+			key, err := sealed.NewKey(password)
+			if err != nil {
+				mapSealedError(w, err)
+				return
+			}
+			if err := ws.WriteFile(p, key.Seal(data), 0o644); err != nil {
+				mapError(w, err)
+				return
+			}
+			// Older snapshots still hold the plaintext.
+			if err := snapshot.Forget(ws, p); err != nil {
+				mapError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !sealed.IsSealed(data) {
+			mapSealedError(w, sealed.ErrNotSealed)
+			return
+		}
+		key, ok := unlocks.Key(r.Header.Get(unlockTokenHeader), p, time.Now())
+		if !ok {
+			http.Error(w, "note is locked; unlock it with its password", http.StatusLocked)
+			return
+		}
+		plain, err := key.Open(data)
+		if err != nil {
+			// Sealed again under another password since the token was given.
+			unlocks.Revoke(p)
+			http.Error(w, "note is locked; unlock it with its password", http.StatusLocked)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(plain)
+		case http.MethodPut:
+			content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, textfile.MaxSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err := ws.WriteFile(p, key.Seal(content), 0o644); err != nil {
+				mapError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			if err := ws.WriteFile(p, plain, 0o644); err != nil {
+				mapError(w, err)
+				return
+			}
+			unlocks.Revoke(p)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// unlockHandler checks a sealed note's password and answers with a token
+// for its content, valid for sealed.UnlockTTL.
+func unlockHandler(unlocks *sealed.Unlocks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		password, ok := decodePassword(w, r)
+		if !ok {
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+		data, err := ws.ReadFile(p)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		key, _, err := sealed.Unlock(data, password)
+		if err != nil {
+			mapSealedError(w, err)
+			return
+		}
+		token, expires := unlocks.Grant(p, key, time.Now())
+		writeJSON(w, http.StatusOK, map[string]any{"token": token, "expires": expires})
+	})
+}
@@ -0,0 +1,90 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSealedNotes(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("diary.md", []byte("Dear diary.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	do := func(t *testing.T, method, url, token string, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Wisdom-Unlock-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	expect := func(t *testing.T, resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("%s %s: status = %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, body)
+		}
+	}
+
+	resp := do(t, http.MethodPost, "/api/snapshots", "", "")
+	expect(t, resp, http.StatusCreated)
+	var snap struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, do(t, http.MethodPost, "/api/sealed/diary.md", "", `{"password":"hunter2"}`), http.StatusNoContent)
+	if data, _ := ws.ReadFile("diary.md"); strings.Contains(string(data), "diary") {
+		t.Errorf("sealed note on disk = %q", data)
+	}
+	expect(t, do(t, http.MethodGet, "/api/snapshots/"+snap.ID+"/fs/diary.md", "", ""), http.StatusNotFound)
+
+	expect(t, do(t, http.MethodPut, "/api/fs/diary.md", "", "overwritten"), http.StatusLocked)
+	expect(t, do(t, http.MethodPatch, "/api/fs/diary.md", "", `{"destination":"moved.md"}`), http.StatusLocked)
+	expect(t, do(t, http.MethodDelete, "/api/fs/diary.md", "", ""), http.StatusLocked)
+	resp = do(t, http.MethodPost, "/api/sync/push", "", `{"files":[{"path":"diary.md","delete":true}]}`)
+	expect(t, resp, http.StatusOK)
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), `"status":"error"`) {
+		t.Errorf("sync push deleting a sealed note = %s", body)
+	}
+	if _, err := ws.Stat("diary.md"); err != nil {
+		t.Fatalf("sealed note changed without a token: %v", err)
+	}
+	expect(t, do(t, http.MethodPost, "/api/sealed/diary.md", "", `{"password":"hunter2"}`), http.StatusConflict)
+	expect(t, do(t, http.MethodGet, "/api/sealed/diary.md", "", ""), http.StatusLocked)
+	expect(t, do(t, http.MethodPost, "/api/unlock/diary.md", "", `{"password":"wrong"}`), http.StatusForbidden)
+
+	resp = do(t, http.MethodPost, "/api/unlock/diary.md", "", `{"password":"hunter2"}`)
+	expect(t, resp, http.StatusOK)
+	var unlocked struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&unlocked); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = do(t, http.MethodGet, "/api/sealed/diary.md", unlocked.Token, "")
+	expect(t, resp, http.StatusOK)
+	if body, _ := io.ReadAll(resp.Body); string(body) != "Dear diary.\n" {
+		t.Errorf("content = %q", body)
+	}
+	expect(t, do(t, http.MethodPut, "/api/sealed/diary.md", unlocked.Token, "Dear diary, again.\n"), http.StatusNoContent)
+	expect(t, do(t, http.MethodDelete, "/api/sealed/diary.md", unlocked.Token, ""), http.StatusNoContent)
+	if data, _ := ws.ReadFile("diary.md"); string(data) != "Dear diary, again.\n" {
+		t.Errorf("unsealed note = %q", data)
+	}
+	expect(t, do(t, http.MethodGet, "/api/sealed/diary.md", unlocked.Token, ""), http.StatusConflict)
+}
@@ -1,18 +1,16 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/shrik450/wisdom/internal/workspace"
 )
 
-const (
-	defaultSearchLimit = 20
-	maxSearchLimit     = 50
-)
+// slimSearchFields are the fields of a search result's ?view=slim.
+var slimSearchFields = []string{"path", "isDir"}
+
+var searchPageLimits = pageLimits{def: 20, max: 50}
 
 func searchPathsHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -29,16 +27,6 @@ func searchPathsHandler() http.Handler {
 			return
 		}
 
-		limit := defaultSearchLimit
-		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-			if n, err := strconv.Atoi(limitStr); err == nil && n > 0 {
-				limit = n
-			}
-		}
-		if limit > maxSearchLimit {
-			limit = maxSearchLimit
-		}
-
 		ws := workspace.FromContext(r.Context())
 		// TODO: WalkFiles is called on every search request with no caching.
 		// The client debounces to limit frequency; a workspace-level cache with
@@ -49,18 +37,11 @@ func searchPathsHandler() http.Handler {
 			return
 		}
 
-		results := FuzzySearch(query, entries, limit)
-		if results == nil {
-			results = []FuzzyResult{}
-		}
-
-		data, err := json.Marshal(results)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		results, ok := paginate(w, r, FuzzySearch(query, entries, len(entries)), searchPageLimits)
+		if !ok {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(data)
+		writeList(w, r, http.StatusOK, results, slimSearchFields)
 	})
 }
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/oidc"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// sessionView is a device with access to the workspace: an API token, or
+// a browser signed in through single sign-on.
+type sessionView struct {
+	ID        string         `json:"id"`
+	Kind      string         `json:"kind"`
+	Name      string         `json:"name"`
+	Scopes    []tokens.Scope `json:"scopes,omitempty"`
+	Created   time.Time      `json:"created"`
+	Expires   time.Time      `json:"expires,omitzero"`
+	LastSeen  time.Time      `json:"lastSeen,omitzero"`
+	UserAgent string         `json:"userAgent,omitempty"`
+	Address   string         `json:"address,omitempty"`
+}
+
+// SessionsHandler lists what can access the workspace (GET /api/sessions):
+// unexpired API tokens and signed-in browsers, with when and from where
+// each was last used since the server started. DELETE /api/sessions/{id}
+// revokes a token or signs a browser out. sso is nil without single
+// sign-on. Like the tokens, it is for the admin only.
+func SessionsHandler(store *tokens.Store, sso *oidc.Provider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		list, err := store.List(workspace.FromContext(r.Context()))
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		now := time.Now()
+		views := []sessionView{}
+		for _, t := range list {
+			if !t.Expires.IsZero() && now.After(t.Expires) {
+				continue
+			}
+			v := sessionView{ID: t.ID, Kind: "token", Name: t.Name, Scopes: t.Scopes, Created: t.Created, Expires: t.Expires}
+			if u, ok := store.LastUse(t.ID); ok {
+				v.LastSeen, v.UserAgent, v.Address = u.At, u.UserAgent, u.Addr
+			}
+			views = append(views, v)
+		}
+		if sso != nil {
+			for _, s := range sso.Sessions(now) {
+				views = append(views, sessionView{
+					ID:        s.ID,
+					Kind:      "sign-in",
+					Name:      s.User,
+					Created:   s.Created,
+					Expires:   s.Expires,
+					LastSeen:  s.LastSeen,
+					UserAgent: s.UserAgent,
+					Address:   s.Addr,
+				})
+			}
+		}
+		slices.SortStableFunc(views, func(a, b sessionView) int { return b.LastSeen.Compare(a.LastSeen) })
+		writeJSON(w, http.StatusOK, views)
+	})
+	mux.HandleFunc("/api/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.PathValue("id")
+		if sso != nil && sso.End(id) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := store.Revoke(workspace.FromContext(r.Context()), id); err != nil {
+			mapTokenError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
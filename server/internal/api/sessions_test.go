@@ -0,0 +1,93 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/api"
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestSessions(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := tokens.New()
+	now := time.Now()
+	phone, secret, err := store.Create(ws, "phone", []tokens.Scope{tokens.ScopeRead}, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Create(ws, "old laptop", []tokens.Scope{tokens.ScopeRead}, now.Add(time.Millisecond), now); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/sessions", api.SessionsHandler(store, nil))
+	mux.Handle("/api/sessions/", api.SessionsHandler(store, nil))
+	mux.HandleFunc("/api/fs/", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(middleware.WithWorkspace(middleware.Authorize(mux, store, "admin", nil), ws))
+	t.Cleanup(srv.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/fs/", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	req.Header.Set("User-Agent", "WisdomMobile/1.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	list := func(t *testing.T) []map[string]any {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var sessions []map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+			t.Fatal(err)
+		}
+		return sessions
+	}
+	sessions := list(t)
+	if len(sessions) != 1 {
+		t.Fatalf("sessions = %v, want only the unexpired token", sessions)
+	}
+	if s := sessions[0]; s["id"] != phone.ID || s["kind"] != "token" || s["userAgent"] != "WisdomMobile/1.0" ||
+		s["address"] != "127.0.0.1" || s["lastSeen"] == nil {
+		t.Errorf("session = %v, want the phone's token with its last use", s)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/sessions/"+phone.ID, nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("revoke = %d, want 204", resp.StatusCode)
+	}
+	if sessions := list(t); len(sessions) != 0 {
+		t.Errorf("sessions after revoking = %v, want none", sessions)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking again = %d, want 404", resp.StatusCode)
+	}
+}
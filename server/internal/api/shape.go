@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/wlog"
+)
+
+// requestedFields returns the fields of each item the client asked for, so
+// clients on slow connections can skip the fields they don't show.
+// ?fields=a,b keeps only those keys; ?view=slim keeps the endpoint's slim
+// fields. Unknown fields are ignored, and without either parameter items
+// are encoded as they are.
+func requestedFields(r *http.Request, slim []string) []string {
+	if s := r.URL.Query().Get("fields"); s != "" {
+		var fields []string
+		for f := range strings.SplitSeq(s, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	}
+	if r.URL.Query().Get("view") == "slim" {
+		return slim
+	}
+	return nil
+}
+
+// decodeNumbers decodes data keeping numbers as written rather than going
+// through float64.
+func decodeNumbers(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded any
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func pick(v any, fields []string) any {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	picked := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if value, ok := obj[f]; ok {
+			picked[f] = value
+		}
+	}
+	return picked
+}
+
+// listFlushEvery is how many items of a list are written between flushes,
+// so clients start receiving a long list while the rest is encoded.
+const listFlushEvery = 500
+
+// encodeList writes items to w as a JSON array, trimmed to
+// requestedFields, encoding one item at a time so a long list is never held in
+// memory whole. flush, if set, is called every listFlushEvery items.
+func encodeList[T any](w io.Writer, r *http.Request, items []T, slim []string, flush func() error) error {
+	fields := requestedFields(r, slim)
+	bw := bufio.NewWriter(w)
+	// Items are encoded into one reused buffer, so each costs next to no
+	// allocation.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	bw.WriteByte('[')
+	for i, item := range items {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		buf.Reset()
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		if len(fields) > 0 {
+			decoded, err := decodeNumbers(buf.Bytes())
+			if err != nil {
+				return err
+			}
+			buf.Reset()
+			if err := enc.Encode(pick(decoded, fields)); err != nil {
+				return err
+			}
+		}
+		// Encode ends each value with a newline.
+		if _, err := bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))); err != nil {
+			return err
+		}
+		if flush != nil && (i+1)%listFlushEvery == 0 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// writeList is writeJSON for lists, streamed with encodeList. An error
+// part way through can only cut the response short.
+func writeList[T any](w http.ResponseWriter, r *http.Request, status int, items []T, slim []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := encodeList(w, r, items, slim, flusher(w)); err != nil {
+		wlog.FromContext(r.Context()).Warn("encoding list", "err", err)
+	}
+}
+
+// flusher returns a function flushing w, or nil if w can't be flushed.
+func flusher(w http.ResponseWriter) func() error {
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return rc.Flush
+}
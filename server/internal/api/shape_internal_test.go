@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func listing(n int) []dirEntry {
+	entries := make([]dirEntry, n)
+	for i := range entries {
+		entries[i] = dirEntry{Name: fmt.Sprintf("note-%06d.md", i), Size: int64(i), ModTime: time.Unix(int64(i), 0).UTC()}
+	}
+	return entries
+}
+
+func TestEncodeList(t *testing.T) {
+	entries := listing(3)
+	want, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flushes := 0
+	var buf bytes.Buffer
+	r := httptest.NewRequest("GET", "/", nil)
+	if err := encodeList(&buf, r, entries, nil, func() error { flushes++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Fatalf("encodeList = %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	r = httptest.NewRequest("GET", "/?view=slim", nil)
+	if err := encodeList(&buf, r, entries[:1], slimDirEntryFields, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != `[{"isDir":false,"name":"note-000000.md"}]` {
+		t.Fatalf("slim encodeList = %s", got)
+	}
+
+	if err := encodeList(io.Discard, r, []dirEntry{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkListEncoding compares encoding a listing whole with streaming
+// it. Both allocate in proportion to the listing, but Marshal's bytes are
+// one buffer the size of the response, live until it is written, while
+// streaming makes two small allocations per item that are garbage as soon
+// as the item is written. So with 100k entries, Marshal holds ~25 MB at
+// once and streaming a 4 KiB write buffer.
+func BenchmarkListEncoding(b *testing.B) {
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, n := range []int{1_000, 10_000, 100_000} {
+		entries := listing(n)
+		b.Run(fmt.Sprintf("marshal/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				data, err := json.Marshal(entries)
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Discard.Write(data)
+			}
+		})
+		b.Run(fmt.Sprintf("stream/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := encodeList(io.Discard, r, entries, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
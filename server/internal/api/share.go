@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/baseurl"
+	"github.com/shrik450/wisdom/internal/share"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// shareHandler makes a signed link to one file (POST /api/share): a share
+// link to read it, or a capture link to add text to a note.
+func shareHandler(signer *share.Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if signer == nil {
+			http.Error(w, share.ErrDisabled.Error()+"; set WISDOM_SHARE_KEY", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Path    string     `json:"path"`
+			Kind    share.Kind `json:"kind"`
+			Expires time.Time  `json:"expires"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.Kind == "" {
+			req.Kind = share.KindShare
+		}
+		if !req.Expires.IsZero() && !req.Expires.After(time.Now()) {
+			http.Error(w, "expires must be in the future", http.StatusBadRequest)
+			return
+		}
+		p := normalizePath(req.Path)
+		if isProtectedPath(p) {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if req.Kind == share.KindShare {
+			info, err := workspace.FromContext(r.Context()).Stat(p)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			if info.IsDir() {
+				http.Error(w, "only files can be shared", http.StatusBadRequest)
+				return
+			}
+		}
+		link, err := signer.URL(baseurl.FromRequest(r), req.Kind, p, req.Expires)
+		if errors.Is(err, share.ErrUnknownKind) || errors.Is(err, share.ErrNotNote) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			mapError(w, err)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusCreated, struct {
+			URL     string     `json:"url"`
+			Kind    share.Kind `json:"kind"`
+			Path    string     `json:"path"`
+			Expires time.Time  `json:"expires,omitzero"`
+		}{link, req.Kind, p, req.Expires})
+	})
+}
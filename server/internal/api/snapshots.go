@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func mapSnapshotError(w http.ResponseWriter, err error) {
+	if errors.Is(err, snapshot.ErrUnknownSnapshot) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	mapError(w, err)
+}
+
+// snapshotAction takes a snapshot for a schedule, then deletes all but the
+// newest keep snapshots if keep is set.
+func snapshotAction(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+	if _, err := snapshot.Create(ws, time.Now()); err != nil {
+		return err
+	}
+	if args["keep"] == "" {
+		return nil
+	}
+	keep, err := strconv.Atoi(args["keep"])
+	if err != nil || keep < 1 {
+		return errors.New("keep must be a positive number")
+	}
+	return snapshot.Keep(ws, keep)
+}
+
+func snapshotsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			all, err := snapshot.List(ws)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if page, ok := paginate(w, r, all, defaultPageLimits); ok {
+				writeList(w, r, http.StatusOK, page, nil)
+			}
+		case http.MethodPost:
+			s, err := snapshot.Create(ws, time.Now())
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			w.Header().Set("Location", "/api/snapshots/"+s.ID)
+			writeJSON(w, http.StatusCreated, s)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func snapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			m, err := snapshot.Load(ws, r.PathValue("id"))
+			if err != nil {
+				mapSnapshotError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, m.Snapshot)
+		case http.MethodDelete:
+			if err := snapshot.Delete(ws, r.PathValue("id")); err != nil {
+				mapSnapshotError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// snapshotFSHandler serves a snapshot read-only, the way /api/fs serves the
+// workspace: files as their content and directories as listings.
+func snapshotFSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		m, err := snapshot.Load(ws, r.PathValue("id"))
+		if err != nil {
+			mapSnapshotError(w, err)
+			return
+		}
+		p := fsPath(r)
+
+		f, entry, err := m.Open(ws, p)
+		if errors.Is(err, workspace.ErrLocked) {
+			mapError(w, err)
+			return
+		}
+		if err == nil {
+			defer f.Close()
+			http.ServeContent(w, r, path.Base(p), entry.ModTime, f)
+			return
+		}
+		entries, ok := m.ReadDir(ws, p)
+		if !ok {
+			http.Error(w, "not found in snapshot", http.StatusNotFound)
+			return
+		}
+		result := make([]dirEntry, len(entries))
+		for i, e := range entries {
+			result[i] = dirEntry{Name: e.Name, Size: e.Size, ModTime: e.ModTime, IsDir: e.IsDir}
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Same vendor type as /api/fs, so the UI can tell listings apart.
+		w.Header().Set("Content-Type", "application/vnd.wisdom.dirlist+json")
+		w.Header().Set("Last-Modified", m.Created.Format(http.TimeFormat))
+		w.Write(data)
+	})
+}
@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("notes", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("notes/plan.md", []byte("Version one.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/snapshots", nil)
+	var created struct {
+		ID    string `json:"id"`
+		Files int    `json:"files"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.Files != 1 {
+		t.Fatalf("status = %d, created = %+v", resp.StatusCode, created)
+	}
+	if err := ws.WriteFile("notes/plan.md", []byte("Version two.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/snapshots/"+created.ID+"/fs/notes/plan.md", nil)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "Version one.\n" {
+		t.Errorf("status = %d, body = %q", resp.StatusCode, body)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/snapshots/"+created.ID+"/fs/", nil)
+	var listing []dirEntry
+	json.NewDecoder(resp.Body).Decode(&listing)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/vnd.wisdom.dirlist+json" || len(listing) != 1 || listing[0].Name != "notes" || !listing[0].IsDir {
+		t.Errorf("listing = %+v (%s)", listing, resp.Header.Get("Content-Type"))
+	}
+
+	tests := []struct {
+		method, url string
+		want        int
+	}{
+		{http.MethodPut, "/api/snapshots/" + created.ID + "/fs/notes/plan.md", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/snapshots/" + created.ID + "/fs/notes/gone.md", http.StatusNotFound},
+		{http.MethodGet, "/api/snapshots/nope/fs/notes/plan.md", http.StatusNotFound},
+		{http.MethodDelete, "/api/snapshots/" + created.ID, http.StatusNoContent},
+		{http.MethodGet, "/api/snapshots/" + created.ID, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := doRequest(t, tt.method, srv.URL+tt.url, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.url, resp.StatusCode, tt.want)
+		}
+	}
+}
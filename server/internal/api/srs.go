@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/srs"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type srsNextResponse struct {
+	// Card is null when nothing is due.
+	Card *srs.Card `json:"card"`
+	Due  int       `json:"due"`
+}
+
+func srsNextHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		cards, err := srs.Collect(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		state, err := srs.LoadState(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		due := srs.Due(cards, state, time.Now().Format(time.DateOnly))
+		resp := srsNextResponse{Due: len(due)}
+		if len(due) > 0 {
+			resp.Card = &due[0]
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func srsAnswerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			ID      string `json:"id"`
+			Quality int    `json:"quality"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		review, err := srs.Answer(workspace.FromContext(r.Context()), req.ID, req.Quality, time.Now())
+		switch {
+		case errors.Is(err, srs.ErrQuality):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, srs.ErrUnknownCard):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			mapError(w, err)
+		default:
+			writeJSON(w, http.StatusOK, review)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type srsNext struct {
+	Card *struct {
+		ID    string `json:"id"`
+		Front string `json:"front"`
+		Back  string `json:"back"`
+	} `json:"card"`
+	Due int `json:"due"`
+}
+
+func TestSRSReview(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("cards.md", []byte("Q: 2 + 2?\nA: 4\n\nQ: Capital of France?\nA: Paris\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(t *testing.T) srsNext {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/srs/next", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("next: status=%d", resp.StatusCode)
+		}
+		var n srsNext
+		if err := json.NewDecoder(resp.Body).Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	answer := func(t *testing.T, body string, wantStatus int) {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/srs/answer", strings.NewReader(body))
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			data, _ := io.ReadAll(resp.Body)
+			t.Fatalf("answer: status=%d body=%s", resp.StatusCode, data)
+		}
+	}
+
+	first := next(t)
+	if first.Due != 2 || first.Card == nil || first.Card.Front != "2 + 2?" {
+		t.Fatalf("first = %+v", first)
+	}
+
+	answer(t, `{"id":"`+first.Card.ID+`","quality":5}`, http.StatusOK)
+	second := next(t)
+	if second.Due != 1 || second.Card.Front != "Capital of France?" {
+		t.Fatalf("second = %+v", second)
+	}
+
+	answer(t, `{"id":"`+second.Card.ID+`","quality":4}`, http.StatusOK)
+	if done := next(t); done.Due != 0 || done.Card != nil {
+		t.Fatalf("done = %+v", done)
+	}
+
+	answer(t, `{"id":"`+second.Card.ID+`","quality":9}`, http.StatusBadRequest)
+	answer(t, `{"id":"nope","quality":3}`, http.StatusNotFound)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// maxStatBatch bounds the paths in one batch stat, which may hash every
+// file.
+const maxStatBatch = 1000
+
+type statResult struct {
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"isDir,omitzero"`
+	Size    int64     `json:"size,omitzero"`
+	ModTime time.Time `json:"modTime,omitzero"`
+	// Content is what GET sends in the Wisdom-Content header.
+	Content string `json:"content,omitempty"`
+	// Hash is the SHA-256 of the file, with ?hash.
+	Hash string `json:"hash,omitempty"`
+	// Title and Aliases are the note's, as links resolve them.
+	Title   string   `json:"title,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func hashFile(ws *workspace.Workspace, p string) (string, error) {
+	f, err := ws.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// statHandler answers POST /api/fs:stat with {"paths": [...], "hash": bool}
+// with what a HEAD of each path would tell, and the title of notes, in one
+// round trip, for link renderers and sync clients checking many files.
+// Paths that can't be stat'ed are reported individually rather than
+// failing the batch.
+func statHandler(index *notes.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Paths []string `json:"paths"`
+			Hash  bool     `json:"hash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if len(req.Paths) > maxStatBatch {
+			http.Error(w, "too many paths", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		var byPath map[string]notes.Note
+		results := make([]statResult, len(req.Paths))
+		for i, p := range req.Paths {
+			res := statResult{Path: normalizePath(p)}
+			info, err := ws.Stat(res.Path)
+			if err != nil {
+				res.Error = err.Error()
+				results[i] = res
+				continue
+			}
+			res.IsDir, res.ModTime = info.IsDir(), info.ModTime().UTC()
+			if !info.IsDir() {
+				res.Size = info.Size()
+				if kind, _, err := textfile.Sniff(ws, res.Path); err == nil {
+					res.Content = string(kind)
+				}
+				if req.Hash {
+					if res.Hash, err = hashFile(ws, res.Path); err != nil {
+						res.Error = err.Error()
+					}
+				}
+			}
+			if notes.IsNote(res.Path) {
+				if byPath == nil {
+					all, err := index.Notes(ws)
+					if err != nil {
+						mapError(w, err)
+						return
+					}
+					byPath = make(map[string]notes.Note, len(all))
+					for _, n := range all {
+						byPath[n.Path] = n
+					}
+				}
+				if n, ok := byPath[res.Path]; ok {
+					res.Title, res.Aliases = n.Title, n.Aliases
+				}
+			}
+			results[i] = res
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"files": results})
+	})
+}
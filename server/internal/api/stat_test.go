@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBatchStat(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("projects", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := []byte("---\ntitle: The Plan\naliases: [plan]\n---\nSteps.\n")
+	if err := ws.WriteFile("projects/plan.md", note, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("projects/scan.bin", []byte{0, 1, 2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/fs:stat",
+		strings.NewReader(`{"paths": ["/projects/plan.md", "projects/scan.bin", "projects", "missing.md", "../etc/passwd"], "hash": true}`))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var body struct {
+		Files []struct {
+			Path    string   `json:"path"`
+			IsDir   bool     `json:"isDir"`
+			Size    int64    `json:"size"`
+			Content string   `json:"content"`
+			Hash    string   `json:"hash"`
+			Title   string   `json:"title"`
+			Aliases []string `json:"aliases"`
+			Error   string   `json:"error"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Files) != 5 {
+		t.Fatalf("files = %+v, want one per path", body.Files)
+	}
+	sum := sha256.Sum256(note)
+	if f := body.Files[0]; f.Path != "projects/plan.md" || f.Size != int64(len(note)) || f.Content != "text" ||
+		f.Hash != hex.EncodeToString(sum[:]) || f.Title != "The Plan" || len(f.Aliases) != 1 {
+		t.Errorf("note = %+v", f)
+	}
+	if f := body.Files[1]; f.Content != "binary" || f.Title != "" || f.Error != "" {
+		t.Errorf("binary file = %+v", f)
+	}
+	if f := body.Files[2]; !f.IsDir || f.Hash != "" {
+		t.Errorf("directory = %+v", f)
+	}
+	if body.Files[3].Error == "" || body.Files[4].Error == "" {
+		t.Errorf("a missing path and one outside the workspace = %+v, %+v, want errors", body.Files[3], body.Files[4])
+	}
+
+	paths, _ := json.Marshal(map[string]any{"paths": make([]string, 1001)})
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/fs:stat", strings.NewReader(string(paths)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("too many paths: status = %d, want 413", resp.StatusCode)
+	}
+}
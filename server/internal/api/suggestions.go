@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/assist"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const suggestionTimeout = 30 * time.Minute
+
+// suggester queues title and tag suggestions for notes in the background.
+// The rules work on their own; the model is only asked when configured and
+// requested.
+type suggester struct {
+	model assist.Model
+	jobs  *jobManager
+}
+
+func newSuggester(model assist.Model) *suggester {
+	return &suggester{model: model, jobs: newJobManager(suggestionTimeout)}
+}
+
+func suggestionsHandler(s *suggester) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleListSuggestions(w, r)
+		case http.MethodPost:
+			handleStartSuggestions(w, r, s)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func handleListSuggestions(w http.ResponseWriter, r *http.Request) {
+	ws := workspace.FromContext(r.Context())
+	all, err := assist.LoadSuggestions(ws)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pending := slices.DeleteFunc(all, func(s assist.Suggestion) bool { return s.Status != assist.StatusPending })
+	if page, ok := paginate(w, r, pending, defaultPageLimits); ok {
+		writeList(w, r, http.StatusOK, page, nil)
+	}
+}
+
+func handleStartSuggestions(w http.ResponseWriter, r *http.Request, s *suggester) {
+	var req struct {
+		Model bool `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	var model assist.Model
+	if req.Model {
+		if s.model == nil {
+			http.Error(w, assist.ErrDisabled.Error()+"; set WISDOM_LLM_URL", http.StatusServiceUnavailable)
+			return
+		}
+		model = s.model
+	}
+
+	ws := workspace.FromContext(r.Context())
+	job := s.jobs.start(".", assist.SuggestionsPath, func(ctx context.Context) error {
+		_, err := assist.Scan(ctx, ws, model)
+		return err
+	})
+	w.Header().Set("Location", "/api/assist/suggestions/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// suggestionHandler accepts a suggestion (POST), optionally with the title
+// and tags edited, or dismisses it (DELETE).
+func suggestionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		id := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodPost:
+		case http.MethodDelete:
+			if err := assist.Dismiss(ws, id); err != nil {
+				mapSuggestionError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Title *string   `json:"title"`
+			Tags  *[]string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		all, err := assist.LoadSuggestions(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		i := slices.IndexFunc(all, func(s assist.Suggestion) bool { return s.ID == id })
+		if i < 0 {
+			mapSuggestionError(w, assist.ErrUnknownSuggestion)
+			return
+		}
+		title, add := all[i].Title, all[i].Tags
+		if req.Title != nil {
+			title = *req.Title
+		}
+		if req.Tags != nil {
+			add = *req.Tags
+		}
+
+		p, err := assist.Accept(ws, id, title, add)
+		if err != nil {
+			mapSuggestionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"path": p})
+	})
+}
+
+func mapSuggestionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, assist.ErrUnknownSuggestion) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	mapError(w, err)
+}
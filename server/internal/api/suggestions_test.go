@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuggestions(t *testing.T) {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"Title: Sourdough starter\nTags: baking, Bread Making"}}]}`))
+	}))
+	t.Cleanup(llm.Close)
+	t.Setenv("WISDOM_LLM_URL", llm.URL)
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("untitled.md", []byte("Feed it twice a day.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/assist/suggestions", strings.NewReader(`{"model":true}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+	if job := waitForJob(t, srv.URL+resp.Header.Get("Location")); job.Status != "completed" {
+		t.Fatalf("job = %+v", job)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/assist/suggestions", nil)
+	var pending []struct {
+		ID     string   `json:"id"`
+		Path   string   `json:"path"`
+		Title  string   `json:"title"`
+		Tags   []string `json:"tags"`
+		Source string   `json:"source"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&pending)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Title != "Sourdough starter" || strings.Join(pending[0].Tags, ",") != "baking,Bread-Making" || pending[0].Source != "model" {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if data, _ := ws.ReadFile("untitled.md"); string(data) != "Feed it twice a day.\n" {
+		t.Errorf("note changed before accepting: %q", data)
+	}
+
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/assist/suggestions/"+pending[0].ID, strings.NewReader(`{"tags":["baking"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("accept status = %d", resp.StatusCode)
+	}
+	want := "---\ntags: [\"baking\"]\ntitle: \"Sourdough starter\"\n---\nFeed it twice a day.\n"
+	if data, _ := ws.ReadFile("untitled.md"); string(data) != want {
+		t.Errorf("note = %q, want %q", data, want)
+	}
+
+	resp = doRequest(t, http.MethodDelete, srv.URL+"/api/assist/suggestions/"+pending[0].ID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("dismissing an accepted suggestion: status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/changes"
+	"github.com/shrik450/wisdom/internal/filename"
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	defaultChangesLimit = 1000
+	maxChangesLimit     = 10000
+	// maxSyncBatch bounds the file content in one pull or push, so a batch
+	// stays a reasonable request on a phone. Bigger files go through /api/fs.
+	maxSyncBatch = 32 << 20
+)
+
+// changesHandler lists the changes after the since cursor. Cursors are
+// opaque strings to clients; an expired one means syncing again from
+// scratch.
+func changesHandler(feed *changes.Feed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var cursor int64
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if cursor, err = strconv.ParseInt(s, 10, 64); err != nil {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+		}
+		limit := defaultChangesLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxChangesLimit)
+		}
+
+		ws := workspace.FromContext(r.Context())
+		result, next, more, err := feed.Since(ws, cursor, limit, time.Now())
+		if errors.Is(err, changes.ErrExpired) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"changes": result,
+			"cursor":  strconv.FormatInt(next, 10),
+			"more":    more,
+		})
+	})
+}
+
+type syncFile struct {
+	Path    string    `json:"path"`
+	Content []byte    `json:"content,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime,omitzero"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// syncPullHandler returns the contents of several files at once. Files that
+// can't be read are reported individually rather than failing the batch.
+func syncPullHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		files := make([]syncFile, 0, len(req.Paths))
+		var total int64
+		for _, p := range req.Paths {
+			f := syncFile{Path: normalizePath(p)}
+			info, err := ws.Stat(f.Path)
+			switch {
+			case err != nil:
+				f.Error = err.Error()
+			case info.IsDir():
+				f.Error = "is a directory"
+			case total+info.Size() > maxSyncBatch:
+				f.Error = "batch too large; fetch separately or use /api/fs"
+			default:
+				if f.Content, err = ws.ReadFile(f.Path); err != nil {
+					f.Error = err.Error()
+					break
+				}
+				total += int64(len(f.Content))
+				f.Size, f.ModTime = info.Size(), info.ModTime().UTC()
+			}
+			files = append(files, f)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"files": files})
+	})
+}
+
+type pushResult struct {
+	// Path is where the file was written, as the filename policy may
+	// normalize the name of a new one.
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	// Conflict is the copy a stale write was saved to instead.
+	Conflict string    `json:"conflict,omitempty"`
+	ModTime  time.Time `json:"modTime,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+const (
+	pushWritten  = "written"
+	pushDeleted  = "deleted"
+	pushConflict = "conflict"
+	pushError    = "error"
+)
+
+// syncPushHandler applies a batch of writes and deletions. Each carries the
+// modification time the client last saw; a write to a file changed since
+// is saved as a conflict copy like PUT ?onConflict=copy, and a stale
+// deletion is skipped, so offline edits never silently overwrite others.
+// Sealed notes are refused like on /api/fs, unless the request carries an
+// unlock token for the one it changes.
+func syncPushHandler(unlocks *sealed.Unlocks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Files []struct {
+				Path        string    `json:"path"`
+				Content     []byte    `json:"content"`
+				Delete      bool      `json:"delete"`
+				BaseModTime time.Time `json:"baseModTime"`
+			} `json:"files"`
+		}
+		// Content is base64 in the body, hence the headroom.
+		r.Body = http.MaxBytesReader(w, r.Body, maxSyncBatch*2)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		policy, err := filename.Load(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		results := make([]pushResult, 0, len(req.Files))
+		for _, f := range req.Files {
+			res := pushResult{Path: normalizePath(f.Path)}
+			err := func() error {
+				if isProtectedPath(res.Path) {
+					return errors.New("path is protected")
+				}
+				info, err := ws.Stat(res.Path)
+				exists := err == nil
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				if exists && info.IsDir() {
+					return errors.New("is a directory")
+				}
+				stale := exists && !f.BaseModTime.IsZero() && info.ModTime().After(f.BaseModTime)
+				if exists && !stale {
+					if err := unsealed(r, unlocks, res.Path); err != nil {
+						return err
+					}
+				}
+
+				if f.Delete {
+					if stale {
+						res.Status = pushConflict
+						return nil
+					}
+					if exists {
+						if err := ws.Remove(res.Path); err != nil {
+							return err
+						}
+					}
+					res.Status = pushDeleted
+					return nil
+				}
+
+				p := res.Path
+				res.Status = pushWritten
+				if stale {
+					if p, err = conflictPath(ws, p, now); err != nil {
+						return err
+					}
+					res.Status, res.Conflict = pushConflict, p
+				} else if !exists {
+					if p, err = policy.Apply(ws, p); err != nil {
+						return err
+					}
+					res.Path = p
+				}
+				if parent := filepath.Dir(p); parent != "." {
+					if err := ws.MkdirAll(parent, 0o755); err != nil {
+						return err
+					}
+				}
+				if err := ws.WriteFile(p, f.Content, 0o644); err != nil {
+					return err
+				}
+				if info, err := ws.Stat(p); err == nil {
+					res.ModTime = info.ModTime().UTC()
+				}
+				return nil
+			}()
+			if err != nil {
+				res.Status, res.Error = pushError, err.Error()
+			}
+			results = append(results, res)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"results": results})
+	})
+}
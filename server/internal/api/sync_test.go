@@ -0,0 +1,170 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/filename"
+)
+
+func TestSync(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("plan.md", []byte("Plan.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	type changesResponse struct {
+		Changes []struct {
+			Op      string    `json:"op"`
+			Path    string    `json:"path"`
+			ModTime time.Time `json:"modTime"`
+		} `json:"changes"`
+		Cursor string `json:"cursor"`
+		More   bool   `json:"more"`
+	}
+	getChanges := func(since string) changesResponse {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/changes?since="+since, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("changes since %q: status = %d", since, resp.StatusCode)
+		}
+		var result changesResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+	first := getChanges("")
+	if len(first.Changes) != 1 || first.Changes[0].Path != "plan.md" || first.Cursor == "" {
+		t.Fatalf("first sync = %+v", first)
+	}
+	base := first.Changes[0].ModTime
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/sync/pull",
+		strings.NewReader(`{"paths": ["plan.md", "missing.md"]}`))
+	var pulled struct {
+		Files []struct {
+			Path    string `json:"path"`
+			Content []byte `json:"content"`
+			Error   string `json:"error"`
+		} `json:"files"`
+	}
+	json.NewDecoder(resp.Body).Decode(&pulled)
+	resp.Body.Close()
+	if len(pulled.Files) != 2 || string(pulled.Files[0].Content) != "Plan.\n" || pulled.Files[1].Error == "" {
+		t.Fatalf("pull = %+v", pulled)
+	}
+
+	// Someone else edits the file after the client read it.
+	if err := ws.WriteFile("plan.md", []byte("Their plan.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale := base.Add(-time.Hour).Format(time.RFC3339Nano)
+	push, _ := json.Marshal(map[string]any{"files": []map[string]any{
+		{"path": "plan.md", "content": []byte("My plan.\n"), "baseModTime": stale},
+		{"path": "new/idea.md", "content": []byte("Idea.\n")},
+	}})
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/sync/push", strings.NewReader(string(push)))
+	var pushed struct {
+		Results []struct {
+			Path     string `json:"path"`
+			Status   string `json:"status"`
+			Conflict string `json:"conflict"`
+		} `json:"results"`
+	}
+	json.NewDecoder(resp.Body).Decode(&pushed)
+	resp.Body.Close()
+	if len(pushed.Results) != 2 || pushed.Results[0].Status != "conflict" || pushed.Results[1].Status != "written" {
+		t.Fatalf("push = %+v", pushed)
+	}
+	if data, _ := ws.ReadFile("plan.md"); string(data) != "Their plan.\n" {
+		t.Errorf("plan.md = %q; the newer version was overwritten", data)
+	}
+	if data, _ := ws.ReadFile(pushed.Results[0].Conflict); string(data) != "My plan.\n" {
+		t.Errorf("conflict copy %q = %q", pushed.Results[0].Conflict, data)
+	}
+
+	// A stale deletion is skipped too.
+	resp = doRequest(t, http.MethodPost, srv.URL+"/api/sync/push",
+		strings.NewReader(`{"files": [{"path": "plan.md", "delete": true, "baseModTime": "`+stale+`"}]}`))
+	resp.Body.Close()
+	if _, err := ws.Stat("plan.md"); err != nil {
+		t.Errorf("stale delete removed plan.md: %v", err)
+	}
+
+	next := getChanges(first.Cursor)
+	got := map[string]string{}
+	for _, c := range next.Changes {
+		got[c.Path] = c.Op
+	}
+	if got["plan.md"] != "put" || got["new/idea.md"] != "put" || got[pushed.Results[0].Conflict] != "put" {
+		t.Errorf("changes since first sync = %+v", next.Changes)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/changes?since=999", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("unknown cursor: status = %d, want %d", resp.StatusCode, http.StatusGone)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	srv, _ := newTestServer(t)
+	for _, method := range []string{http.MethodOptions, http.MethodGet} {
+		url := srv.URL + "/api/"
+		if method == http.MethodGet {
+			url = srv.URL + "/api/capabilities"
+		}
+		resp := doRequest(t, method, url, nil)
+		var caps map[string]any
+		json.NewDecoder(resp.Body).Decode(&caps)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || caps["batch"] != true || caps["locks"] != false || caps["maxBatchBytes"] == nil {
+			t.Errorf("%s: status %d, capabilities %v", method, resp.StatusCode, caps)
+		}
+	}
+}
+
+func TestSyncPushFilenamePolicy(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile(filename.PolicyPath, []byte(`{"normalize": true, "forbidden": ":", "caseCollisions": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("Plan.md", []byte("Plan.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	push, _ := json.Marshal(map[string]any{"files": []map[string]any{
+		{"path": "Café.md", "content": []byte("Coffee.\n")},
+		{"path": "a:b.md", "content": []byte("Colon.\n")},
+		{"path": "plan.md", "content": []byte("Lower.\n")},
+	}})
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/sync/push", strings.NewReader(string(push)))
+	var pushed struct {
+		Results []struct {
+			Path   string `json:"path"`
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	json.NewDecoder(resp.Body).Decode(&pushed)
+	resp.Body.Close()
+	if len(pushed.Results) != 3 {
+		t.Fatalf("push = %+v", pushed)
+	}
+	if r := pushed.Results[0]; r.Status != "written" || r.Path != "Caf\u00e9.md" {
+		t.Errorf("decomposed name = %+v, want written under the NFC name", r)
+	}
+	for _, r := range pushed.Results[1:] {
+		if r.Status != "error" {
+			t.Errorf("%s: status = %s, want error", r.Path, r.Status)
+		}
+	}
+	if _, err := ws.Stat("plan.md"); err == nil {
+		t.Error("plan.md written next to Plan.md")
+	}
+}
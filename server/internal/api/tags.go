@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// tagsTreeHandler returns every tag as a tree split on "/", with the number
+// of notes under each node, for the tag sidebar.
+func tagsTreeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		byNote, err := tags.Scan(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, tags.Tree(byNote))
+	})
+}
+
+// tagNotesHandler lists the notes tagged with ?tag= or any tag nested under
+// it, so selecting project also finds project/alpha.
+func tagNotesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		tag := tags.Normalize(r.URL.Query().Get("tag"))
+		if tag == "" {
+			http.Error(w, "tag is required", http.StatusBadRequest)
+			return
+		}
+		byNote, err := tags.Scan(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if page, ok := paginate(w, r, tags.Notes(byNote, tag), defaultPageLimits); ok {
+			writeJSON(w, http.StatusOK, map[string]any{"tag": tag, "notes": page})
+		}
+	})
+}
+
+// tagsBatchHandler adds, removes or renames a tag across the notes listed
+// in paths and every note under folder ("." for the whole workspace), and
+// returns the notes it changed.
+func tagsBatchHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			tags.Op
+			Paths  []string `json:"paths"`
+			Folder string   `json:"folder"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if len(req.Paths) == 0 && req.Folder == "" {
+			http.Error(w, "paths or folder is required", http.StatusBadRequest)
+			return
+		}
+		for i, p := range req.Paths {
+			req.Paths[i] = normalizePath(p)
+		}
+		if req.Folder != "" {
+			req.Folder = normalizePath(req.Folder)
+		}
+
+		ws := workspace.FromContext(r.Context())
+		changed, err := tags.Batch(ws, req.Paths, req.Folder, req.Op)
+		if errors.Is(err, tags.ErrInvalidOp) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"changed": changed})
+	})
+}
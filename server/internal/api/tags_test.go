@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTagsBatch(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("projects", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"projects/a.md": "#todo write intro\n",
+		"projects/b.md": "---\ntags: [todo, draft]\n---\n",
+		"inbox.md":      "Nothing yet.\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch := func(t *testing.T, body string) (*http.Response, []string) {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/tags/batch", strings.NewReader(body))
+		defer resp.Body.Close()
+		var result struct {
+			Changed []string `json:"changed"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, result.Changed
+	}
+
+	t.Run("rename across a folder", func(t *testing.T) {
+		_, changed := batch(t, `{"op":"rename","tag":"todo","to":"next","folder":"projects"}`)
+		if want := []string{"projects/a.md", "projects/b.md"}; !reflect.DeepEqual(changed, want) {
+			t.Errorf("changed = %v, want %v", changed, want)
+		}
+		if data, _ := ws.ReadFile("projects/a.md"); string(data) != "#next write intro\n" {
+			t.Errorf("a.md = %q", data)
+		}
+	})
+
+	t.Run("add to paths", func(t *testing.T) {
+		_, changed := batch(t, `{"op":"add","tag":"draft","paths":["inbox.md","/projects/b.md"]}`)
+		if want := []string{"inbox.md"}; !reflect.DeepEqual(changed, want) {
+			t.Errorf("changed = %v, want %v", changed, want)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for body, status := range map[string]int{
+			`{"op":"add","tag":"x"}`:                        http.StatusBadRequest,
+			`{"op":"tag","tag":"x","paths":["inbox.md"]}`:   http.StatusBadRequest,
+			`{"op":"add","tag":"x","paths":["missing.md"]}`: http.StatusNotFound,
+		} {
+			if resp, _ := batch(t, body); resp.StatusCode != status {
+				t.Errorf("%s: status = %d, want %d", body, resp.StatusCode, status)
+			}
+		}
+	})
+}
+
+func TestTagTree(t *testing.T) {
+	srv, ws := newTestServer(t)
+	for p, content := range map[string]string{
+		"a.md": "#project/alpha and #project/beta\n",
+		"b.md": "---\ntags: [project]\n---\n",
+		"c.md": "#reading\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/tags", nil)
+	defer resp.Body.Close()
+	var tree []struct {
+		Tag      string `json:"tag"`
+		Total    int    `json:"total"`
+		Children []struct {
+			Name string `json:"name"`
+		} `json:"children"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 2 || tree[0].Tag != "project" || tree[0].Total != 2 || len(tree[0].Children) != 2 || tree[1].Tag != "reading" {
+		t.Errorf("tree = %+v", tree)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/tags/notes?tag=project", nil)
+	defer resp.Body.Close()
+	var result struct {
+		Notes []string `json:"notes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.md", "b.md"}; !reflect.DeepEqual(result.Notes, want) {
+		t.Errorf("notes = %v, want %v", result.Notes, want)
+	}
+}
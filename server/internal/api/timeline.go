@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/timeline"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// timelineHandler lists what happened in the workspace, newest first.
+// ?type= takes a comma-separated list of item types to keep.
+func timelineHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var types []string
+		if s := r.URL.Query().Get("type"); s != "" {
+			types = strings.Split(s, ",")
+			for _, t := range types {
+				if !slices.Contains(timeline.Types, t) {
+					http.Error(w, "type must be one of "+strings.Join(timeline.Types, ", "), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		items, err := timeline.Build(workspace.FromContext(r.Context()), types)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		if page, ok := paginate(w, r, items, defaultPageLimits); ok {
+			writeList(w, r, http.StatusOK, page, []string{"type", "time", "path"})
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	srv, ws := newTestServer(t)
+	for _, p := range []string{"plan.md", "dune.epub"} {
+		if err := ws.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/timeline?type=import", nil)
+	defer resp.Body.Close()
+	var items []struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Type != "import" || items[0].Path != "dune.epub" {
+		t.Errorf("items = %+v", items)
+	}
+	if total := resp.Header.Get("Wisdom-Total"); total != "1" {
+		t.Errorf("total = %q, want 1", total)
+	}
+
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/timeline?type=comment", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown type status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func mapTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tokens.ErrUnknownToken):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tokens.ErrNoScopes), errors.Is(err, tokens.ErrUnknownScope):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		mapError(w, err)
+	}
+}
+
+// tokenView is a token as it is listed: without its hash.
+type tokenView struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Scopes  []tokens.Scope `json:"scopes"`
+	Created time.Time      `json:"created"`
+	Expires time.Time      `json:"expires,omitzero"`
+	Expired bool           `json:"expired"`
+}
+
+func viewToken(t tokens.Token, now time.Time) tokenView {
+	return tokenView{
+		ID:      t.ID,
+		Name:    t.Name,
+		Scopes:  t.Scopes,
+		Created: t.Created,
+		Expires: t.Expires,
+		Expired: !t.Expires.IsZero() && now.After(t.Expires),
+	}
+}
+
+// TokensHandler lists the API tokens (GET /api/tokens), creates one (POST
+// /api/tokens), answering with its secret this once, and revokes one
+// (DELETE /api/tokens/{id}). It is for the admin only, so it is served
+// apart from the rest of the API.
+func TokensHandler(store *tokens.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tokens", func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			list, err := store.List(ws)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			now := time.Now()
+			views := make([]tokenView, len(list))
+			for i, t := range list {
+				views[i] = viewToken(t, now)
+			}
+			writeJSON(w, http.StatusOK, views)
+		case http.MethodPost:
+			var req struct {
+				Name    string         `json:"name"`
+				Scopes  []tokens.Scope `json:"scopes"`
+				Expires time.Time      `json:"expires"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			if req.Name == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			now := time.Now()
+			if !req.Expires.IsZero() && !req.Expires.After(now) {
+				http.Error(w, "expires must be in the future", http.StatusBadRequest)
+				return
+			}
+			t, secret, err := store.Create(ws, req.Name, req.Scopes, req.Expires, now)
+			if err != nil {
+				mapTokenError(w, err)
+				return
+			}
+			w.Header().Set("Location", "/api/tokens/"+t.ID)
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, http.StatusCreated, struct {
+				tokenView
+				Token string `json:"token"`
+			}{viewToken(t, now), secret})
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/tokens/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := store.Revoke(workspace.FromContext(r.Context()), r.PathValue("id")); err != nil {
+			mapTokenError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/tools"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// mapToolError maps errors from the registry and tool runs, which are about
+// the server's configuration rather than the workspace, so a missing file
+// is not a 404.
+func mapToolError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tools.ErrUnknownTool):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tools.ErrInputType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// toolTimeout bounds a run in the background, on top of the tool's own
+// timeout.
+const toolTimeout = time.Hour
+
+func toolsDisabled(w http.ResponseWriter) {
+	http.Error(w, tools.ErrDisabled.Error()+"; set WISDOM_TOOLS", http.StatusServiceUnavailable)
+}
+
+func toolsHandler(registry *tools.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if registry == nil {
+			toolsDisabled(w)
+			return
+		}
+		all, err := registry.Load()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, all)
+	})
+}
+
+// toolRunHandler runs a tool on a workspace file in the background and
+// writes what it outputs next to it, as the same name with the tool's
+// output extension unless an output path is given. Existing files are only
+// replaced with force.
+func toolRunHandler(registry *tools.Registry, jobs *jobManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if registry == nil {
+			toolsDisabled(w)
+			return
+		}
+
+		var req struct {
+			Path   string `json:"path"`
+			Output string `json:"output"`
+			Force  bool   `json:"force"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		tool, err := registry.Get(r.PathValue("name"))
+		if err != nil {
+			mapToolError(w, err)
+			return
+		}
+		p := normalizePath(req.Path)
+		if !tool.Accepts(p) {
+			mapToolError(w, tools.ErrInputType)
+			return
+		}
+		output := strings.TrimSuffix(p, path.Ext(p)) + tool.Output
+		if req.Output != "" {
+			output = normalizePath(req.Output)
+		}
+		if output == p || isProtectedPath(output) {
+			http.Error(w, "output must be a new file", http.StatusBadRequest)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		if _, err := ws.Stat(output); err == nil && !req.Force {
+			http.Error(w, "output exists; set force=true to overwrite", http.StatusConflict)
+			return
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			mapError(w, err)
+			return
+		}
+
+		if _, err := ws.Stat(p); err != nil {
+			mapError(w, err)
+			return
+		}
+		job := jobs.start(p, output, func(ctx context.Context) error {
+			return runTool(ctx, ws, tool, p, output)
+		})
+		w.Header().Set("Location", "/api/tools/"+r.PathValue("name")+"/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	})
+}
+
+func runTool(ctx context.Context, ws *workspace.Workspace, tool tools.Tool, p, output string) error {
+	f, err := ws.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	result, err := tool.Run(ctx, p, f)
+	if err != nil {
+		return err
+	}
+	if dir := path.Dir(output); dir != "." {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return ws.WriteFile(output, result, 0o644)
+}
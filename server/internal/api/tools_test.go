@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTools(t *testing.T) {
+	registry := filepath.Join(t.TempDir(), "tools.json")
+	if err := os.WriteFile(registry, []byte(`{"upper": {"command": ["tr", "a-z", "A-Z"], "inputs": [".md"], "output": ".txt"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WISDOM_TOOLS", registry)
+	srv, ws := newTestServer(t)
+	if err := ws.WriteFile("draft.md", []byte("shout\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name, body string) int {
+		t.Helper()
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/tools/"+name+"/run", strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusAccepted {
+			if job := waitForJob(t, srv.URL+resp.Header.Get("Location")); job.Status != "completed" {
+				t.Fatalf("job = %+v", job)
+			}
+		}
+		return resp.StatusCode
+	}
+	if status := run("upper", `{"path":"draft.md"}`); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", status)
+	}
+	if data, _ := ws.ReadFile("draft.txt"); string(data) != "SHOUT\n" {
+		t.Errorf("draft.txt = %q", data)
+	}
+
+	tests := []struct {
+		name, tool, body string
+		want             int
+	}{
+		{"existing output", "upper", `{"path":"draft.md"}`, http.StatusConflict},
+		{"unknown tool", "lower", `{"path":"draft.md"}`, http.StatusNotFound},
+		{"wrong input type", "upper", `{"path":"draft.txt","output":"other.txt"}`, http.StatusBadRequest},
+		{"missing input", "upper", `{"path":"gone.md"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status := run(tt.tool, tt.body); status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.want)
+		}
+	}
+	if status := run("upper", `{"path":"draft.md","output":"out/loud.txt"}`); status != http.StatusAccepted {
+		t.Errorf("custom output: status = %d", status)
+	}
+}
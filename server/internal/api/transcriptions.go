@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/transcribe"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	transcriptionTimeout   = 2 * time.Hour
+	transcriptionJobHeader = "Wisdom-Transcription-Job"
+)
+
+// transcriber writes transcripts of media files. It is disabled when there
+// is no backend.
+type transcriber struct {
+	backend transcribe.Backend
+	jobs    *jobManager
+}
+
+func newTranscriber(backend transcribe.Backend) *transcriber {
+	return &transcriber{backend: backend, jobs: newJobManager(transcriptionTimeout)}
+}
+
+func (t *transcriber) enabled() bool {
+	return t.backend != nil
+}
+
+func (t *transcriber) start(ws *workspace.Workspace, p string) *backgroundJob {
+	note := transcribe.NotePath(p)
+	return t.jobs.start(p, note, func(ctx context.Context) error {
+		// An existing transcript may have been edited, so it is never
+		// replaced.
+		if _, err := ws.Stat(note); err == nil {
+			return fmt.Errorf("%s already exists", note)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		media, err := ws.Open(p)
+		if err != nil {
+			return err
+		}
+		defer media.Close()
+		segments, err := t.backend.Transcribe(ctx, path.Base(p), media)
+		if err != nil {
+			return err
+		}
+		return ws.WriteFile(note, []byte(transcribe.Note(p, segments)), 0o644)
+	})
+}
+
+func transcriptionsHandler(t *transcriber) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, t.jobs.list())
+		case http.MethodPost:
+			handleStartTranscription(w, r, t)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func handleStartTranscription(w http.ResponseWriter, r *http.Request, t *transcriber) {
+	if !t.enabled() {
+		http.Error(w, "transcription is disabled; set WISDOM_TRANSCRIBE_URL", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	p := normalizePath(req.Path)
+	if !transcribe.IsMedia(p) {
+		http.Error(w, "path must be an audio or video file", http.StatusBadRequest)
+		return
+	}
+
+	ws := workspace.FromContext(r.Context())
+	if _, err := ws.Stat(p); err != nil {
+		mapError(w, err)
+		return
+	}
+	job := t.start(ws, p)
+	w.Header().Set("Location", "/api/transcriptions/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
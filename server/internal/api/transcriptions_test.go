@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type backgroundJob struct {
+	ID     string `json:"id"`
+	Output string `json:"output"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// waitForJob polls the job at url until it stops running.
+func waitForJob(t *testing.T, url string) backgroundJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := doRequest(t, http.MethodGet, url, nil)
+		var job backgroundJob
+		err := json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != "running" {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTranscriptions(t *testing.T) {
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"segments":[{"start":0,"end":2,"text":" Buy milk."},{"start":62,"end":64,"text":" And eggs."}]}`))
+	}))
+	t.Cleanup(whisper.Close)
+	t.Setenv("WISDOM_TRANSCRIBE_URL", whisper.URL)
+	t.Setenv("TMPDIR", t.TempDir())
+	srv, ws := newTestServer(t)
+
+	wait := func(t *testing.T, id string) backgroundJob {
+		t.Helper()
+		return waitForJob(t, srv.URL+"/api/transcriptions/"+id)
+	}
+
+	t.Run("uploaded audio is transcribed", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "memos/shopping.m4a", 5)
+		sendChunk(t, srv.URL, sess.ID, 0, "audio")
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/uploads/"+sess.ID+"/finalize", nil)
+		resp.Body.Close()
+		id := resp.Header.Get("Wisdom-Transcription-Job")
+		if id == "" {
+			t.Fatal("no transcription job started")
+		}
+
+		job := wait(t, id)
+		if job.Status != "completed" || job.Output != "memos/shopping.transcript.md" {
+			t.Fatalf("job = %+v", job)
+		}
+		note, err := ws.ReadFile(job.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(note), "[00:00:00] Buy milk.\n[00:01:02] And eggs.\n") {
+			t.Errorf("note = %s", note)
+		}
+	})
+
+	t.Run("existing transcripts are kept", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/transcriptions", strings.NewReader(`{"path":"memos/shopping.m4a"}`))
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+		var job backgroundJob
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		if job = wait(t, job.ID); job.Status != "failed" || !strings.Contains(job.Error, "already exists") {
+			t.Errorf("job = %+v", job)
+		}
+	})
+
+	t.Run("only media", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/transcriptions", strings.NewReader(`{"path":"memos/shopping.transcript.md"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestTranscriptionsDisabled(t *testing.T) {
+	t.Setenv("WISDOM_TRANSCRIBE_URL", "")
+	srv, _ := newTestServer(t)
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/transcriptions", strings.NewReader(`{"path":"a.mp3"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+}
@@ -0,0 +1,567 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/transcribe"
+	"github.com/shrik450/wisdom/internal/wlog"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Sessions that haven't received data for this long are garbage collected.
+const uploadSessionMaxAge = 24 * time.Hour
+
+var errUploadNotFound = errors.New("upload session not found")
+
+type uploadSession struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	// ModTime, if set, is given to the file when the upload is finalized.
+	ModTime time.Time `json:"modTime,omitzero"`
+}
+
+// uploadDir is where uploadStore stages sessions.
+const uploadDir = workspace.ScratchDir + "/uploads"
+
+// uploadStore keeps resumable upload sessions in the workspace's scratch
+// space, so partial uploads are encrypted like the files they become, and
+// the destination is not touched until the upload is complete. Each session
+// is a folder holding its metadata and one file per chunk received, named
+// by the chunk's offset: an encrypted file can't be appended to.
+//
+// The store goes through the full workspace, as the scratch space is locked
+// to clients.
+type uploadStore struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newUploadStore(dir string) *uploadStore {
+	return &uploadStore{dir: dir, locks: make(map[string]*sync.Mutex)}
+}
+
+func (s *uploadStore) sessionDir(id string) string {
+	return s.dir + "/" + id
+}
+
+func (s *uploadStore) metaPath(id string) string {
+	return s.sessionDir(id) + "/session.json"
+}
+
+func (s *uploadStore) chunkPath(id string, offset int64) string {
+	return s.sessionDir(id) + "/" + strconv.FormatInt(offset, 10)
+}
+
+// lock serializes operations on one session so concurrent chunks can't
+// interleave.
+func (s *uploadStore) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[id] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func (s *uploadStore) forget(id string) {
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+}
+
+func (s *uploadStore) create(ws *workspace.Workspace, path string, size int64, modTime time.Time) (*uploadSession, error) {
+	ws = ws.Full()
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	sess := &uploadSession{ID: hex.EncodeToString(buf), Path: path, Size: size, ModTime: modTime}
+
+	meta, err := json.Marshal(sess)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.MkdirAll(s.sessionDir(sess.ID), 0o700); err != nil {
+		return nil, err
+	}
+	if err := ws.WriteFile(s.metaPath(sess.ID), meta, 0o600); err != nil {
+		ws.RemoveAll(s.sessionDir(sess.ID))
+		return nil, err
+	}
+	return sess, nil
+}
+
+// chunks returns the offsets of the chunks of the session with id, in
+// order, and the offset the next one starts at.
+func (s *uploadStore) chunks(ws *workspace.Workspace, id string) ([]int64, int64, error) {
+	entries, err := ws.ReadDir(s.sessionDir(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	var offsets []int64
+	for _, e := range entries {
+		if offset, err := strconv.ParseInt(e.Name(), 10, 64); err == nil {
+			offsets = append(offsets, offset)
+		}
+	}
+	slices.Sort(offsets)
+	var end int64
+	for _, offset := range offsets {
+		// Sizes come from the workspace, which knows the plaintext's.
+		info, err := ws.Stat(s.chunkPath(id, offset))
+		if err != nil {
+			return nil, 0, err
+		}
+		end = offset + info.Size()
+	}
+	return offsets, end, nil
+}
+
+func (s *uploadStore) get(ws *workspace.Workspace, id string) (*uploadSession, error) {
+	if !isUploadID(id) {
+		return nil, errUploadNotFound
+	}
+	ws = ws.Full()
+	meta, err := ws.ReadFile(s.metaPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess uploadSession
+	if err := json.Unmarshal(meta, &sess); err != nil {
+		return nil, fmt.Errorf("reading upload session: %w", err)
+	}
+	if _, sess.Offset, err = s.chunks(ws, id); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// appendChunk writes what r yields, up to limit bytes, as the chunk at
+// offset. Whatever arrived before an error is kept.
+func (s *uploadStore) appendChunk(ws *workspace.Workspace, id string, offset int64, r io.Reader, limit int64) (int64, error) {
+	f, err := ws.Full().CreateNew(s.chunkPath(id, offset))
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, limit))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if n == 0 {
+		ws.Full().Remove(s.chunkPath(id, offset))
+	}
+	return n, err
+}
+
+// open returns the data received for the session with id.
+func (s *uploadStore) open(ws *workspace.Workspace, id string) (io.ReadCloser, error) {
+	ws = ws.Full()
+	offsets, _, err := s.chunks(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	var files multiCloser
+	readers := make([]io.Reader, len(offsets))
+	for i, offset := range offsets {
+		f, err := ws.Open(s.chunkPath(id, offset))
+		if err != nil {
+			files.Close()
+			return nil, err
+		}
+		files = append(files, f)
+		readers[i] = f
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(readers...), files}, nil
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *uploadStore) remove(ws *workspace.Workspace, id string) error {
+	err := ws.Full().RemoveAll(s.sessionDir(id))
+	s.forget(id)
+	return err
+}
+
+// staleSessions lists the sessions that haven't changed within maxAge,
+// with the space they take.
+func (s *uploadStore) staleSessions(ws *workspace.Workspace, maxAge time.Duration) (map[string]int64, error) {
+	ws = ws.Full()
+	entries, err := ws.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	stale := map[string]int64{}
+	for _, e := range entries {
+		id := e.Name()
+		if !e.IsDir() || !isUploadID(id) {
+			continue
+		}
+		files, err := ws.ReadDir(s.sessionDir(id))
+		if err != nil {
+			continue
+		}
+		var size int64
+		fresh := false
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				fresh = true
+				break
+			}
+			size += info.Size()
+		}
+		if !fresh {
+			stale[id] = size
+		}
+	}
+	return stale, nil
+}
+
+// collectGarbage removes sessions that haven't changed within maxAge.
+func (s *uploadStore) collectGarbage(ws *workspace.Workspace, maxAge time.Duration) (reclaimed, error) {
+	var result reclaimed
+	stale, err := s.staleSessions(ws, maxAge)
+	if err != nil {
+		return result, err
+	}
+	var errs []error
+	for id, size := range stale {
+		unlock := s.lock(id)
+		if err := s.remove(ws, id); err != nil {
+			errs = append(errs, err)
+		} else {
+			result.Removed++
+			result.Bytes += size
+		}
+		unlock()
+	}
+	return result, errors.Join(errs...)
+}
+
+func isUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func writeUploadSession(w http.ResponseWriter, sess *uploadSession, status int) {
+	writeUploadHeaders(w, sess)
+	writeJSON(w, status, sess)
+}
+
+func writeUploadHeaders(w http.ResponseWriter, sess *uploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(sess.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func mapUploadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUploadNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	mapError(w, err)
+}
+
+func uploadsHandler(store *uploadStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Path    string    `json:"path"`
+			Size    int64     `json:"size"`
+			ModTime time.Time `json:"modTime"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.Path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if req.Size < 0 {
+			http.Error(w, "size must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		p := normalizePath(req.Path)
+		if p == "." {
+			http.Error(w, "path must name a file", http.StatusBadRequest)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		if _, err := ws.Resolve(p); err != nil {
+			mapError(w, err)
+			return
+		}
+		if tokens.CreateOnly(r.Context()) {
+			if _, err := ws.Stat(p); !errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "the token's scopes don't allow replacing a file", http.StatusForbidden)
+				return
+			}
+		}
+
+		// Collecting on create bounds the number of abandoned sessions without
+		// needing a background goroutine.
+		if _, err := store.collectGarbage(ws, uploadSessionMaxAge); err != nil {
+			wlog.FromContext(r.Context()).Warn("upload garbage collection", "err", err)
+		}
+
+		sess, err := store.create(ws, p, req.Size, req.ModTime)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/uploads/"+sess.ID)
+		writeUploadSession(w, sess, http.StatusCreated)
+	})
+}
+
+func uploadHandler(store *uploadStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		ws := workspace.FromContext(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			sess, err := store.get(ws, id)
+			if err != nil {
+				mapUploadError(w, err)
+				return
+			}
+			writeUploadSession(w, sess, http.StatusOK)
+		case http.MethodHead:
+			sess, err := store.get(ws, id)
+			if err != nil {
+				mapUploadError(w, err)
+				return
+			}
+			writeUploadHeaders(w, sess)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodPatch:
+			handleUploadChunk(w, r, ws, store, id)
+		case http.MethodDelete:
+			unlock := store.lock(id)
+			defer unlock()
+			if _, err := store.get(ws, id); err != nil {
+				mapUploadError(w, err)
+				return
+			}
+			if err := store.remove(ws, id); err != nil {
+				mapError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PATCH, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// handleUploadChunk appends the request body at the client's Upload-Offset.
+// Whatever arrives before a dropped connection is kept, so the client can
+// resume from the offset reported by HEAD.
+func handleUploadChunk(w http.ResponseWriter, r *http.Request, ws *workspace.Workspace, store *uploadStore, id string) {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+
+	unlock := store.lock(id)
+	defer unlock()
+
+	sess, err := store.get(ws, id)
+	if err != nil {
+		mapUploadError(w, err)
+		return
+	}
+	if offset != sess.Offset {
+		writeUploadHeaders(w, sess)
+		http.Error(w, "Upload-Offset does not match the session offset", http.StatusConflict)
+		return
+	}
+
+	remaining := sess.Size - sess.Offset
+	n, err := store.appendChunk(ws, id, offset, r.Body, remaining)
+	sess.Offset += n
+	if err != nil {
+		writeUploadHeaders(w, sess)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n == remaining {
+		if extra, _ := r.Body.Read(make([]byte, 1)); extra > 0 {
+			writeUploadHeaders(w, sess)
+			http.Error(w, "chunk exceeds the declared upload size", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	writeUploadHeaders(w, sess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importPipeline holds the background steps run on a file once it has been
+// imported, each of which is off unless configured.
+type importPipeline struct {
+	transcriber *transcriber
+	ocr         *ocrScanner
+}
+
+// run starts the steps that apply to the file at p, and tells the client
+// about each job in a header.
+func (pl *importPipeline) run(w http.ResponseWriter, ws *workspace.Workspace, p string) {
+	transcription, ocr := pl.start(ws, p)
+	if transcription != "" {
+		w.Header().Set(transcriptionJobHeader, transcription)
+	}
+	if ocr != "" {
+		w.Header().Set(ocrJobHeader, ocr)
+	}
+}
+
+// start starts the steps that apply to the file at p, and returns the IDs
+// of their jobs, empty for those that don't apply.
+func (pl *importPipeline) start(ws *workspace.Workspace, p string) (transcription, ocr string) {
+	if pl.transcriber.enabled() && transcribe.IsMedia(p) {
+		transcription = pl.transcriber.start(ws, p).ID
+	}
+	if pl.ocr.enabled() && pl.ocr.needsScan(ws, p) {
+		ocr = pl.ocr.start(ws, p).ID
+	}
+	return transcription, ocr
+}
+
+// uploadFinalizeHandler moves a complete upload into the workspace and runs
+// the import pipeline on it.
+func uploadFinalizeHandler(store *uploadStore, pipeline *importPipeline) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.PathValue("id")
+		unlock := store.lock(id)
+		defer unlock()
+
+		ws := workspace.FromContext(r.Context())
+		sess, err := store.get(ws, id)
+		if err != nil {
+			mapUploadError(w, err)
+			return
+		}
+		if sess.Offset != sess.Size {
+			writeUploadHeaders(w, sess)
+			http.Error(w, "upload is incomplete", http.StatusConflict)
+			return
+		}
+
+		_, err = ws.Stat(sess.Path)
+		isNew := errors.Is(err, os.ErrNotExist)
+		if err != nil && !isNew {
+			mapError(w, err)
+			return
+		}
+		// The file may have been made since the upload started.
+		if !isNew && tokens.CreateOnly(r.Context()) {
+			http.Error(w, "the token's scopes don't allow replacing a file", http.StatusForbidden)
+			return
+		}
+		if isNew {
+			if sess.Path, err = applyFilenamePolicy(w, ws, sess.Path); err != nil {
+				mapFilenameError(w, err)
+				return
+			}
+		}
+
+		parent := filepath.Dir(sess.Path)
+		if parent != "." {
+			if err := ws.MkdirAll(parent, 0o755); err != nil {
+				mapError(w, err)
+				return
+			}
+		}
+
+		data, err := store.open(ws, id)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		err = ws.WriteStream(sess.Path, data, 0o644)
+		data.Close()
+		if err == nil && !sess.ModTime.IsZero() {
+			err = ws.Chtimes(sess.Path, sess.ModTime)
+		}
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		if err := store.remove(ws, id); err != nil {
+			wlog.FromContext(r.Context()).Warn("remove finished upload session", "id", id, "err", err)
+		}
+
+		info, err := ws.Stat(sess.Path)
+		if err == nil {
+			w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+		pipeline.run(w, ws, sess.Path)
+
+		if isNew {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
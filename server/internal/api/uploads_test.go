@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type uploadSession struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+}
+
+func createUpload(t *testing.T, baseURL, path string, size int) uploadSession {
+	t.Helper()
+	body := `{"path":"` + path + `","size":` + strconv.Itoa(size) + `}`
+	resp := doRequest(t, http.MethodPost, baseURL+"/api/uploads", strings.NewReader(body))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("create upload: status=%d body=%s", resp.StatusCode, data)
+	}
+	var sess uploadSession
+	if err := json.NewDecoder(resp.Body).Decode(&sess); err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func sendChunk(t *testing.T, baseURL, id string, offset int, chunk string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPatch, baseURL+"/api/uploads/"+id, strings.NewReader(chunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestResumableUpload(t *testing.T) {
+	srv, ws := newTestServer(t)
+
+	t.Run("chunks are assembled on finalize", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "books/big.pdf", 11)
+
+		resp := sendChunk(t, srv.URL, sess.ID, 0, "hello ")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("first chunk: status=%d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Upload-Offset"); got != "6" {
+			t.Fatalf("Upload-Offset = %q, want 6", got)
+		}
+
+		if _, err := ws.Stat("books/big.pdf"); err == nil {
+			t.Fatal("destination written before finalize")
+		}
+
+		head := doRequest(t, http.MethodHead, srv.URL+"/api/uploads/"+sess.ID, nil)
+		head.Body.Close()
+		if got := head.Header.Get("Upload-Offset"); got != "6" {
+			t.Fatalf("HEAD Upload-Offset = %q, want 6", got)
+		}
+
+		resp = sendChunk(t, srv.URL, sess.ID, 6, "world")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("second chunk: status=%d", resp.StatusCode)
+		}
+
+		fin := doRequest(t, http.MethodPost, srv.URL+"/api/uploads/"+sess.ID+"/finalize", nil)
+		fin.Body.Close()
+		if fin.StatusCode != http.StatusCreated {
+			t.Fatalf("finalize: status=%d", fin.StatusCode)
+		}
+
+		got, err := ws.ReadFile("books/big.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "hello world" {
+			t.Fatalf("got %q", got)
+		}
+
+		gone := doRequest(t, http.MethodGet, srv.URL+"/api/uploads/"+sess.ID, nil)
+		gone.Body.Close()
+		if gone.StatusCode != http.StatusNotFound {
+			t.Fatalf("session still exists after finalize: status=%d", gone.StatusCode)
+		}
+	})
+
+	t.Run("offset mismatch is rejected", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "mismatch.bin", 4)
+		resp := sendChunk(t, srv.URL, sess.ID, 2, "ab")
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("status=%d, want 409", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Upload-Offset"); got != "0" {
+			t.Fatalf("Upload-Offset = %q, want 0", got)
+		}
+	})
+
+	t.Run("chunk beyond declared size is rejected", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "small.bin", 2)
+		resp := sendChunk(t, srv.URL, sess.ID, 0, "abc")
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status=%d, want 413", resp.StatusCode)
+		}
+	})
+
+	t.Run("incomplete upload cannot be finalized", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "partial.bin", 10)
+		sendChunk(t, srv.URL, sess.ID, 0, "abc")
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/uploads/"+sess.ID+"/finalize", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("status=%d, want 409", resp.StatusCode)
+		}
+	})
+
+	t.Run("delete aborts the session", func(t *testing.T) {
+		sess := createUpload(t, srv.URL, "aborted.bin", 10)
+		resp := doRequest(t, http.MethodDelete, srv.URL+"/api/uploads/"+sess.ID, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("status=%d, want 204", resp.StatusCode)
+		}
+		resp = sendChunk(t, srv.URL, sess.ID, 0, "abc")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("status=%d, want 404", resp.StatusCode)
+		}
+	})
+
+	t.Run("destination outside workspace", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, srv.URL+"/api/uploads",
+			strings.NewReader(`{"path":"../../escape.bin","size":1}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("status=%d, want 403", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		for _, id := range []string{strings.Repeat("0", 32), "..%2Fescape"} {
+			resp := doRequest(t, http.MethodGet, srv.URL+"/api/uploads/"+id, nil)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("id %q: status=%d, want 404", id, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("orphaned sessions are collected", func(t *testing.T) {
+		stale := createUpload(t, srv.URL, "stale.bin", 10)
+		old := time.Now().Add(-48 * time.Hour)
+		staged := workspace.ScratchDir + "/uploads/" + stale.ID + "/session.json"
+		if err := ws.Full().Chtimes(staged, old); err != nil {
+			t.Fatal(err)
+		}
+
+		createUpload(t, srv.URL, "fresh.bin", 10)
+
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/uploads/"+stale.ID, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("stale session survived: status=%d", resp.StatusCode)
+		}
+	})
+}
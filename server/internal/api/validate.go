@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/schema"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// schemaViolationsHeader tells the client how many schema violations a
+// saved note has. Saves are never rejected: the details come from
+// /api/validate?path=.
+const schemaViolationsHeader = "Wisdom-Schema-Violations"
+
+// validateHandler checks notes against the workspace schema: the note at
+// ?path=, or every note the schema applies to.
+func validateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := workspace.FromContext(r.Context())
+		s, err := schema.Load(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var violations []schema.Violation
+		if r.URL.Query().Has("path") {
+			p := normalizePath(r.URL.Query().Get("path"))
+			data, err := ws.ReadFile(p)
+			if err != nil {
+				mapError(w, err)
+				return
+			}
+			violations = s.Check(p, string(data))
+		} else if violations, err = schema.Validate(ws, s); err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"violations": violations})
+	})
+}
+
+// setSchemaViolations sets schemaViolationsHeader after a note at p is
+// saved. A missing or broken schema is left to /api/validate to report.
+func setSchemaViolations(w http.ResponseWriter, ws *workspace.Workspace, p string) {
+	if !strings.EqualFold(filepath.Ext(p), ".md") {
+		return
+	}
+	s, err := schema.Load(ws)
+	if err != nil || s.For(p) == nil {
+		return
+	}
+	data, err := ws.ReadFile(p)
+	if err != nil {
+		return
+	}
+	w.Header().Set(schemaViolationsHeader, strconv.Itoa(len(s.Check(p, string(data)))))
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	schema := `{"books": {"title": {"type": "string", "required": true}, "rating": {"type": "number"}}}`
+	if err := ws.WriteFile(".wisdom/schema.json", []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(t *testing.T, p, content string) *http.Response {
+		t.Helper()
+		resp := doRequest(t, http.MethodPut, srv.URL+"/api/fs/"+p, strings.NewReader(content))
+		resp.Body.Close()
+		return resp
+	}
+	validate := func(t *testing.T, query string) []map[string]string {
+		t.Helper()
+		resp := doRequest(t, http.MethodGet, srv.URL+"/api/validate"+query, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+		var result struct {
+			Violations []map[string]string `json:"violations"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result.Violations
+	}
+
+	t.Run("save reports violations", func(t *testing.T) {
+		resp := put(t, "books/dune.md", "---\nrating: five\n---\n")
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("status=%d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Wisdom-Schema-Violations"); got != "2" {
+			t.Errorf("violations header = %q, want 2", got)
+		}
+		if v := validate(t, "?path=books/dune.md"); len(v) != 2 || v[0]["property"] != "rating" {
+			t.Errorf("violations = %v", v)
+		}
+
+		resp = put(t, "books/dune.md", "---\ntitle: Dune\nrating: 5\n---\n")
+		if got := resp.Header.Get("Wisdom-Schema-Violations"); got != "0" {
+			t.Errorf("violations header = %q, want 0", got)
+		}
+	})
+
+	t.Run("notes outside the schema", func(t *testing.T) {
+		resp := put(t, "journal.md", "Today.\n")
+		if got := resp.Header.Get("Wisdom-Schema-Violations"); got != "" {
+			t.Errorf("violations header = %q, want none", got)
+		}
+	})
+
+	t.Run("whole workspace", func(t *testing.T) {
+		put(t, "books/empty.md", "")
+		if v := validate(t, ""); len(v) != 1 || v[0]["path"] != "books/empty.md" {
+			t.Errorf("violations = %v", v)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/shrik450/wisdom/internal/buildinfo"
+)
+
+// enabledFeatures lists the optional features that are configured, by name.
+func enabledFeatures(features map[string]bool) []string {
+	enabled := []string{}
+	for _, name := range slices.Sorted(maps.Keys(features)) {
+		if features[name] {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
+// versionHandler describes the build and which optional features are
+// configured, so clients can check what to expect before relying on it.
+func versionHandler(features []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			buildinfo.Info
+			Features []string `json:"features"`
+		}{buildinfo.Get(), features})
+	})
+}
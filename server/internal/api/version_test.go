@@ -0,0 +1,31 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	t.Setenv("WISDOM_TOOLS", "tools.json")
+	t.Setenv("WISDOM_METADATA_PROVIDER", "openlibrary")
+	srv, _ := newTestServer(t)
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/version", nil)
+	defer resp.Body.Close()
+	var v struct {
+		Version   string   `json:"version"`
+		GoVersion string   `json:"goVersion"`
+		Features  []string `json:"features"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != "dev" || v.GoVersion != runtime.Version() {
+		t.Errorf("version = %q, %q", v.Version, v.GoVersion)
+	}
+	if len(v.Features) != 2 || v.Features[0] != "enrichment" || v.Features[1] != "tools" {
+		t.Errorf("features = %v, want enrichment and tools", v.Features)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// viewPrefsPath stores every folder's preferences in one file, so they sync
+// across devices with the rest of the workspace.
+const viewPrefsPath = ".wisdom/viewprefs.json"
+
+var (
+	viewSortFields = []string{"name", "size", "modTime"}
+	viewDirections = []string{"asc", "desc"}
+	viewModes      = []string{"list", "grid"}
+)
+
+// viewPrefsMu serializes writes, which rewrite the whole file.
+var viewPrefsMu sync.Mutex
+
+type viewPrefs struct {
+	Sort      string   `json:"sort"`
+	Direction string   `json:"direction"`
+	Pinned    []string `json:"pinned"`
+	View      string   `json:"view"`
+}
+
+var defaultViewPrefs = viewPrefs{Sort: "name", Direction: "asc", Pinned: []string{}, View: "list"}
+
+func (p viewPrefs) validate() error {
+	switch {
+	case !slices.Contains(viewSortFields, p.Sort):
+		return errors.New("sort must be one of name, size, modTime")
+	case !slices.Contains(viewDirections, p.Direction):
+		return errors.New("direction must be asc or desc")
+	case !slices.Contains(viewModes, p.View):
+		return errors.New("view must be list or grid")
+	}
+	return nil
+}
+
+func loadViewPrefs(ws *workspace.Workspace) (map[string]viewPrefs, error) {
+	prefs := map[string]viewPrefs{}
+	data, err := ws.ReadFile(viewPrefsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func saveViewPrefs(ws *workspace.Workspace, prefs map[string]viewPrefs) error {
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(viewPrefsPath), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(viewPrefsPath, data, 0o644)
+}
+
+// viewPrefsHandler reads (GET), replaces (PUT) and resets (DELETE) how the
+// file browser displays a folder. Folders without saved preferences get the
+// defaults.
+func viewPrefsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		p := fsPath(r)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodPut, http.MethodDelete:
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		info, err := ws.Stat(p)
+		if err != nil {
+			mapError(w, err)
+			return
+		}
+		if !info.IsDir() {
+			http.Error(w, "view preferences apply to directories", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			all, err := loadViewPrefs(ws)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			prefs, ok := all[p]
+			if !ok {
+				prefs = defaultViewPrefs
+			}
+			writeJSON(w, http.StatusOK, prefs)
+			return
+		}
+
+		prefs := defaultViewPrefs
+		if r.Method == http.MethodPut {
+			if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			if err := prefs.validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if prefs.Pinned == nil {
+				prefs.Pinned = []string{}
+			}
+		}
+
+		viewPrefsMu.Lock()
+		defer viewPrefsMu.Unlock()
+		all, err := loadViewPrefs(ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(all, p)
+		} else {
+			all[p] = prefs
+		}
+		if err := saveViewPrefs(ws, all); err != nil {
+			mapError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	})
+}
@@ -0,0 +1,48 @@
+package api_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestViewPrefs(t *testing.T) {
+	srv, ws := newTestServer(t)
+	if err := ws.MkdirAll("books", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("books/a.md", []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, method, path, body string, wantStatus int, wantBody string) {
+		t.Helper()
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		resp := doRequest(t, method, srv.URL+"/api/viewprefs/"+path, reader)
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s: status=%d body=%s", method, path, resp.StatusCode, data)
+		}
+		if wantBody != "" && strings.TrimSpace(string(data)) != wantBody {
+			t.Fatalf("%s %s: body=%s, want %s", method, path, data, wantBody)
+		}
+	}
+
+	defaults := `{"sort":"name","direction":"asc","pinned":[],"view":"list"}`
+	saved := `{"sort":"modTime","direction":"desc","pinned":["a.md"],"view":"grid"}`
+
+	check(t, http.MethodGet, "books", "", http.StatusOK, defaults)
+	check(t, http.MethodPut, "books", saved, http.StatusOK, saved)
+	check(t, http.MethodGet, "books", "", http.StatusOK, saved)
+	check(t, http.MethodGet, "", "", http.StatusOK, defaults)
+	check(t, http.MethodPut, "books", `{"sort":"colour"}`, http.StatusBadRequest, "")
+	check(t, http.MethodGet, "books/a.md", "", http.StatusBadRequest, "")
+	check(t, http.MethodGet, "missing", "", http.StatusNotFound, "")
+	check(t, http.MethodDelete, "books", "", http.StatusOK, defaults)
+	check(t, http.MethodGet, "books", "", http.StatusOK, defaults)
+}
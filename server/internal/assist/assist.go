@@ -0,0 +1,161 @@
+// Package assist asks a language model for help with notes, such as
+// summarizing them. It is opt-in: nothing leaves the server unless an
+// endpoint is configured.
+//
+// Any server with an OpenAI-compatible chat completions endpoint works,
+// which includes local ones such as llama.cpp and Ollama.
+package assist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const requestTimeout = 2 * time.Minute
+
+// PromptsDir holds the prompt templates, one markdown file per task, so
+// users can tune them. Templates are filled in by replacing {{title}},
+// {{path}}, {{question}}, {{tags}} and {{content}}.
+const PromptsDir = ".wisdom/prompts"
+
+var ErrDisabled = errors.New("assistance is disabled")
+
+type Model interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// Server is an OpenAI-compatible chat completions endpoint.
+type Server struct {
+	Client *http.Client
+	URL    string
+	APIKey string
+	Model  string
+}
+
+// FromEnv returns the endpoint at WISDOM_LLM_URL, called with the model in
+// WISDOM_LLM_MODEL and authenticated with WISDOM_LLM_API_KEY if set. It
+// returns nil without a URL.
+func FromEnv() Model {
+	u := os.Getenv("WISDOM_LLM_URL")
+	if u == "" {
+		return nil
+	}
+	return &Server{
+		Client: &http.Client{Timeout: requestTimeout},
+		URL:    u,
+		APIKey: os.Getenv("WISDOM_LLM_API_KEY"),
+		Model:  os.Getenv("WISDOM_LLM_MODEL"),
+	}
+}
+
+func (s *Server) Complete(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	body, err := json.Marshal(struct {
+		Model    string    `json:"model,omitempty"`
+		Messages []message `json:"messages"`
+	}{s.Model, []message{{"user", prompt}}})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("model returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding completion: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("model returned no completion")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+var defaultPrompts = map[string]string{
+	"summarize": "Summarize the following note, titled \"{{title}}\", in one short paragraph. " +
+		"Reply with the summary only.\n\n{{content}}\n",
+	"ask": "Answer the question using only the numbered excerpts from my notes below. " +
+		"Cite the excerpts you use like [1]. If they don't answer the question, say so.\n\n" +
+		"Question: {{question}}\n\n{{content}}",
+	"suggest": "Suggest a short title and up to five tags for the note below. Prefer tags I already use: {{tags}}.\n" +
+		"Reply with exactly two lines, \"Title: ...\" and \"Tags: tag, tag\".\n\n{{content}}\n",
+}
+
+// Prompt fills in the template for task, read from PromptsDir or else the
+// built-in default.
+func Prompt(ws *workspace.Workspace, task string, vars map[string]string) (string, error) {
+	template, ok := defaultPrompts[task]
+	if !ok {
+		return "", fmt.Errorf("unknown task %q", task)
+	}
+	data, err := ws.ReadFile(PromptsDir + "/" + task + ".md")
+	switch {
+	case err == nil:
+		template = string(data)
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template), nil
+}
+
+// Section returns the part of a markdown note under the heading named
+// heading, down to the next heading of the same or a higher level. It
+// reports false if there is no such heading.
+func Section(note, heading string) (string, bool) {
+	lines := strings.Split(note, "\n")
+	start, level := -1, 0
+	for i, line := range lines {
+		hashes := len(line) - len(strings.TrimLeft(line, "#"))
+		if hashes == 0 || hashes > 6 || !strings.HasPrefix(line[hashes:], " ") {
+			continue
+		}
+		if start >= 0 && hashes <= level {
+			return strings.Join(lines[start:i], "\n"), true
+		}
+		if start < 0 && strings.EqualFold(strings.TrimSpace(line[hashes:]), strings.TrimSpace(heading)) {
+			start, level = i, hashes
+		}
+	}
+	if start < 0 {
+		return "", false
+	}
+	return strings.Join(lines[start:], "\n"), true
+}
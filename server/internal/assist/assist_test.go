@@ -0,0 +1,189 @@
+package assist_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/assist"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "small" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]string{"role": "assistant", "content": " echo: " + req.Messages[0].Content + "\n"}}},
+		})
+	}))
+	defer srv.Close()
+
+	s := &assist.Server{Client: srv.Client(), URL: srv.URL, APIKey: "key", Model: "small"}
+	got, err := s.Complete(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "echo: hi" {
+		t.Errorf("Complete = %q", got)
+	}
+
+	s.APIKey = ""
+	if _, err := s.Complete(context.Background(), "hi"); err == nil {
+		t.Error("expected an error from a failed request")
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"title": "Dune", "content": "Spice."}
+
+	got, err := assist.Prompt(ws, "summarize", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Summarize the following note, titled \"Dune\", in one short paragraph. Reply with the summary only.\n\nSpice.\n"; got != want {
+		t.Errorf("default prompt = %q", got)
+	}
+
+	if err := ws.MkdirAll(assist.PromptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile(assist.PromptsDir+"/summarize.md", []byte("TL;DR of {{title}}: {{content}} {{unknown}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := assist.Prompt(ws, "summarize", vars); got != "TL;DR of Dune: Spice. {{unknown}}" {
+		t.Errorf("workspace prompt = %q", got)
+	}
+
+	if _, err := assist.Prompt(ws, "poem", vars); err == nil {
+		t.Error("expected an error for an unknown task")
+	}
+}
+
+func TestSection(t *testing.T) {
+	note := "# Book\nIntro\n## Chapter 1\nOne\n### Scene\nDetail\n## Chapter 2\nTwo\n"
+	tests := map[string]string{
+		"chapter 1": "## Chapter 1\nOne\n### Scene\nDetail",
+		"Scene":     "### Scene\nDetail",
+		"Chapter 2": "## Chapter 2\nTwo\n",
+	}
+	for heading, want := range tests {
+		if got, ok := assist.Section(note, heading); !ok || got != want {
+			t.Errorf("Section(%q) = %q, %v", heading, got, ok)
+		}
+	}
+	if _, ok := assist.Section(note, "Chapter 3"); ok {
+		t.Error("found a missing section")
+	}
+}
+
+func TestRetrieve(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("Filler about nothing much at all. ", 40)
+	for p, content := range map[string]string{
+		"garden.md":  "# Garden\n\nTomatoes need full sun and regular watering.\n\n" + long + "\n\nBasil grows well next to tomatoes.\n",
+		"kitchen.md": "Tomato sauce: simmer tomatoes with basil.\n",
+		"travel.md":  "Rome in spring.\n",
+		"notes.txt":  "tomatoes tomatoes tomatoes\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks, err := assist.Retrieve(ws, "How much sun do tomatoes need?", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	first := chunks[0]
+	data, _ := ws.ReadFile(first.Path)
+	if first.Path != "garden.md" || !strings.HasPrefix(string(data[first.Start:first.End]), "# Garden\n\nTomatoes need full sun") {
+		t.Errorf("best chunk = %+v", first)
+	}
+	for _, c := range chunks {
+		if c.Path == "travel.md" || c.Path == "notes.txt" {
+			t.Errorf("irrelevant chunk %+v", c)
+		}
+	}
+
+	if chunks, _ := assist.Retrieve(ws, "quantum", 3); len(chunks) != 0 {
+		t.Errorf("chunks for an unknown term = %+v", chunks)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"Untitled.md":   "Planning the garden beds for spring.\nTomatoes go by the fence.\n",
+		"20260301.md":   "# Already titled\n\nNothing about gardens here.\n",
+		"garden.md":     "---\ntags: [garden, spring]\n---\nBeds.\n",
+		"reading.md":    "#books\n",
+		"Untitled 2.md": "Will be dismissed.\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := assist.Scan(context.Background(), ws, nil); err != nil || n != 2 {
+		t.Fatalf("Scan = %d, %v; want 2", n, err)
+	}
+	all, err := assist.LoadSuggestions(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]assist.Suggestion{}
+	for _, s := range all {
+		byPath[s.Path] = s
+	}
+	s := byPath["Untitled.md"]
+	if s.Title != "Planning the garden beds for spring" || strings.Join(s.Tags, ",") != "garden,spring" || s.Source != "rules" {
+		t.Errorf("Untitled.md suggestion = %+v", s)
+	}
+	if _, ok := byPath["20260301.md"]; ok {
+		t.Error("a note with a heading but no tags got a title suggestion")
+	}
+
+	if err := assist.Dismiss(ws, byPath["Untitled 2.md"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := assist.Accept(ws, s.ID, "Spring garden", []string{"garden"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ws.ReadFile("Untitled.md")
+	if want := "---\ntags: [\"garden\"]\ntitle: \"Spring garden\"\n---\nPlanning the garden beds for spring.\nTomatoes go by the fence.\n"; string(data) != want {
+		t.Errorf("accepted note = %q, want %q", data, want)
+	}
+	if _, err := assist.Accept(ws, s.ID, "", nil); err != assist.ErrUnknownSuggestion {
+		t.Errorf("accepting twice: err = %v", err)
+	}
+
+	// Neither the dismissed nor the accepted note is suggested again.
+	if n, err := assist.Scan(context.Background(), ws, nil); err != nil || n != 0 {
+		t.Errorf("second Scan = %d, %v; want 0", n, err)
+	}
+}
@@ -0,0 +1,135 @@
+package assist
+
+import (
+	"cmp"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// chunkSize is roughly how many bytes of a note go in one chunk. Chunks
+// are built from whole paragraphs, so they can be longer.
+const chunkSize = 1200
+
+// Chunk is a passage of a note. Start and End are byte offsets into the
+// file, so a citation can point at the passage.
+type Chunk struct {
+	Path  string  `json:"path"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Text  string  `json:"-"`
+	Score float64 `json:"score"`
+}
+
+// Chunks splits a note into passages of whole paragraphs.
+func Chunks(p, note string) []Chunk {
+	var chunks []Chunk
+	start, end := -1, 0
+	flush := func() {
+		if start >= 0 {
+			chunks = append(chunks, Chunk{Path: p, Start: start, End: end, Text: note[start:end]})
+		}
+		start = -1
+	}
+	offset := 0
+	for _, para := range strings.SplitAfter(note, "\n\n") {
+		text := strings.TrimSpace(para)
+		if text != "" {
+			paraStart := offset + strings.Index(para, text)
+			if start >= 0 && paraStart-start > chunkSize {
+				flush()
+			}
+			if start < 0 {
+				start = paraStart
+			}
+			end = paraStart + len(text)
+		}
+		offset += len(para)
+	}
+	flush()
+	return chunks
+}
+
+func terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Retrieve returns the k chunks of the workspace's notes most relevant to
+// query, best first, ranked with BM25. The notes are chunked on each call:
+// there is no index to keep in sync.
+func Retrieve(ws *workspace.Workspace, query string, k int) ([]Chunk, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	var counts []map[string]int
+	var lengths []int
+	df := map[string]int{}
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		for _, c := range Chunks(e.Path, string(data)) {
+			tf := map[string]int{}
+			words := terms(c.Text)
+			for _, t := range words {
+				if tf[t] == 0 {
+					df[t]++
+				}
+				tf[t]++
+			}
+			chunks = append(chunks, c)
+			counts = append(counts, tf)
+			lengths = append(lengths, len(words))
+		}
+	}
+	if len(chunks) == 0 {
+		return []Chunk{}, nil
+	}
+
+	total := 0
+	for _, l := range lengths {
+		total += l
+	}
+	avg := float64(total) / float64(len(chunks))
+	const k1, b = 1.2, 0.75
+	queryTerms := terms(query)
+	slices.Sort(queryTerms)
+	queryTerms = slices.Compact(queryTerms)
+
+	var scored []Chunk
+	for i, c := range chunks {
+		for _, t := range queryTerms {
+			tf := float64(counts[i][t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(len(chunks)-df[t])+0.5)/(float64(df[t])+0.5))
+			c.Score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(lengths[i])/avg))
+		}
+		if c.Score > 0 {
+			scored = append(scored, c)
+		}
+	}
+	slices.SortStableFunc(scored, func(a, b Chunk) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Path, b.Path))
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	if scored == nil {
+		scored = []Chunk{}
+	}
+	return scored, nil
+}
@@ -0,0 +1,348 @@
+package assist
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// SuggestionsPath keeps the suggestions waiting for the user, and the ones
+// they dismissed so they aren't made again.
+const SuggestionsPath = ".wisdom/suggestions.json"
+
+const (
+	StatusPending   = "pending"
+	StatusDismissed = "dismissed"
+)
+
+// maxSuggestedTags caps how many tags are suggested for one note.
+const maxSuggestedTags = 5
+
+// placeholderName matches file names that don't say what a note is about,
+// such as "Untitled 3" or a timestamp.
+var placeholderName = regexp.MustCompile(`(?i)^(untitled|new note|note)?[\s\d_.:-]*$`)
+
+var ErrUnknownSuggestion = errors.New("unknown suggestion")
+
+// suggestionsMu serializes changes, which rewrite the whole file.
+var suggestionsMu sync.Mutex
+
+// Suggestion is a proposed title and tags for a note. Nothing is written to
+// the note until the user accepts it.
+type Suggestion struct {
+	ID    string   `json:"id"`
+	Path  string   `json:"path"`
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// Source is "rules" or "model".
+	Source string `json:"source"`
+	Status string `json:"status"`
+}
+
+func suggestionID(p string) string {
+	sum := sha256.Sum256([]byte(p))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Untitled reports whether a note has no title of its own: no frontmatter
+// title, no top-level heading and a placeholder file name.
+func Untitled(p, note string) bool {
+	doc := frontmatter.Parse(note)
+	if doc.String("title") != "" {
+		return false
+	}
+	for _, line := range strings.Split(doc.Body, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			return false
+		}
+	}
+	name := path.Base(p)
+	return placeholderName.MatchString(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// Suggest proposes a title for an untitled note, from its first line of
+// text, and tags for an untagged one, from the known tags it mentions. known
+// should be ordered by preference, most used first. It reports false when
+// there is nothing to suggest.
+func Suggest(p, note string, known []string) (Suggestion, bool) {
+	s := Suggestion{ID: suggestionID(p), Path: p, Source: "rules", Status: StatusPending}
+	body := frontmatter.Parse(note).Body
+	if Untitled(p, note) {
+		s.Title = firstLine(body)
+	}
+	if len(tags.Extract(note)) == 0 {
+		s.Tags = mentionedTags(body, known)
+	}
+	return s, s.Title != "" || len(s.Tags) > 0
+}
+
+// firstLine returns the first line of text in body without its markdown
+// markers, cut to a title's length.
+func firstLine(body string) string {
+	inCode := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#>-*+[] "))
+		if inCode || line == "" {
+			continue
+		}
+		words := strings.Fields(line)
+		if len(words) > 8 {
+			words = words[:8]
+		}
+		return strings.TrimRight(strings.Join(words, " "), ".,:;")
+	}
+	return ""
+}
+
+func mentionedTags(body string, known []string) []string {
+	var result []string
+	for _, tag := range known {
+		// Nested tags are mentioned by their last segment.
+		word := tag[strings.LastIndex(tag, "/")+1:]
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`).MatchString(body) {
+			result = append(result, tag)
+			if len(result) == maxSuggestedTags {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// KnownTags returns the tags used in the workspace, most used first.
+func KnownTags(byNote map[string][]string) []string {
+	counts := map[string]int{}
+	spelling := map[string]string{}
+	for _, note := range slices.Sorted(maps.Keys(byNote)) {
+		for _, tag := range byNote[note] {
+			key := strings.ToLower(tag)
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = tag
+			}
+			counts[key]++
+		}
+	}
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(counts[b]-counts[a], strings.Compare(a, b))
+	})
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = spelling[k]
+	}
+	return result
+}
+
+// SuggestWithModel asks the model for the title and tags a note is
+// missing, keeping the rules' suggestions for what the model leaves out.
+func SuggestWithModel(ctx context.Context, model Model, ws *workspace.Workspace, s Suggestion, note string, known []string) (Suggestion, error) {
+	prompt, err := Prompt(ws, "suggest", map[string]string{
+		"path":    s.Path,
+		"tags":    strings.Join(known, ", "),
+		"content": frontmatter.Parse(note).Body,
+	})
+	if err != nil {
+		return s, err
+	}
+	reply, err := model.Complete(ctx, prompt)
+	if err != nil {
+		return s, err
+	}
+
+	wantTitle, wantTags := Untitled(s.Path, note), len(tags.Extract(note)) == 0
+	for _, line := range strings.Split(reply, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.Trim(key, " *")) {
+		case "title":
+			if wantTitle && value != "" {
+				s.Title, s.Source = strings.Trim(value, `"`), "model"
+			}
+		case "tags":
+			var suggested []string
+			for _, tag := range strings.Split(value, ",") {
+				tag = strings.ReplaceAll(tags.Normalize(tag), " ", "-")
+				if tag != "" && len(suggested) < maxSuggestedTags {
+					suggested = append(suggested, tag)
+				}
+			}
+			if wantTags && len(suggested) > 0 {
+				s.Tags, s.Source = suggested, "model"
+			}
+		}
+	}
+	return s, nil
+}
+
+func LoadSuggestions(ws *workspace.Workspace) ([]Suggestion, error) {
+	suggestions := []Suggestion{}
+	data, err := ws.ReadFile(SuggestionsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return suggestions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &suggestions); err != nil {
+		return nil, fmt.Errorf("reading suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+func saveSuggestions(ws *workspace.Workspace, suggestions []Suggestion) error {
+	data, err := json.MarshalIndent(suggestions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(SuggestionsPath), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(SuggestionsPath, data, 0o644)
+}
+
+// Scan queues suggestions for the notes that lack a title or tags, asking
+// the model too if it isn't nil. Notes that already have a suggestion,
+// pending or dismissed, are skipped. It returns how many were queued.
+func Scan(ctx context.Context, ws *workspace.Workspace, model Model) (int, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return 0, err
+	}
+	byNote, err := tags.Scan(ws)
+	if err != nil {
+		return 0, err
+	}
+	known := KnownTags(byNote)
+
+	suggestionsMu.Lock()
+	existing, err := LoadSuggestions(ws)
+	suggestionsMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	queued := map[string]bool{}
+	for _, s := range existing {
+		queued[s.Path] = true
+	}
+
+	var found []Suggestion
+	for _, e := range entries {
+		if e.IsDir || !notes.IsNote(e.Path) || queued[e.Path] || strings.HasPrefix(e.Path, ".wisdom/") {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		note := string(data)
+		s, ok := Suggest(e.Path, note, known)
+		if model != nil && (Untitled(e.Path, note) || len(tags.Extract(note)) == 0) {
+			if s, err = SuggestWithModel(ctx, model, ws, s, note, known); err != nil {
+				return 0, fmt.Errorf("%s: %w", e.Path, err)
+			}
+			ok = s.Title != "" || len(s.Tags) > 0
+		}
+		if ok {
+			found = append(found, s)
+		}
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	// Reload, in case a suggestion was accepted or dismissed meanwhile.
+	suggestionsMu.Lock()
+	defer suggestionsMu.Unlock()
+	current, err := LoadSuggestions(ws)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range found {
+		if !slices.ContainsFunc(current, func(c Suggestion) bool { return c.Path == s.Path }) {
+			current = append(current, s)
+		}
+	}
+	return len(found), saveSuggestions(ws, current)
+}
+
+// Accept writes the title and tags of a suggestion to its note, which the
+// user may have edited first, and removes the suggestion.
+func Accept(ws *workspace.Workspace, id, title string, add []string) (string, error) {
+	suggestionsMu.Lock()
+	defer suggestionsMu.Unlock()
+	suggestions, err := LoadSuggestions(ws)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(suggestions, func(s Suggestion) bool { return s.ID == id && s.Status == StatusPending })
+	if i < 0 {
+		return "", ErrUnknownSuggestion
+	}
+	p := suggestions[i].Path
+
+	data, err := ws.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	note := string(data)
+	for _, tag := range add {
+		if note, err = tags.Apply(note, tags.Op{Kind: tags.OpAdd, Tag: tag}); err != nil {
+			return "", err
+		}
+	}
+	if title = strings.TrimSpace(title); title != "" {
+		doc := frontmatter.Parse(note)
+		if err := doc.Set("title", title); err != nil {
+			return "", err
+		}
+		note = doc.Render()
+	}
+	if err := ws.WriteFile(p, []byte(note), 0o644); err != nil {
+		return "", err
+	}
+	return p, saveSuggestions(ws, slices.Delete(suggestions, i, i+1))
+}
+
+// Dismiss marks a suggestion as rejected, so the note isn't suggested for
+// again.
+func Dismiss(ws *workspace.Workspace, id string) error {
+	suggestionsMu.Lock()
+	defer suggestionsMu.Unlock()
+	suggestions, err := LoadSuggestions(ws)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(suggestions, func(s Suggestion) bool { return s.ID == id && s.Status == StatusPending })
+	if i < 0 {
+		return ErrUnknownSuggestion
+	}
+	suggestions[i].Status = StatusDismissed
+	suggestions[i].Title, suggestions[i].Tags = "", nil
+	return saveSuggestions(ws, suggestions)
+}
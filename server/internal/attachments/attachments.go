@@ -0,0 +1,244 @@
+// Package attachments checks the links between notes and the files they
+// attach: references to files that don't exist, and files in attachment
+// folders that no note references.
+package attachments
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/canvas"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const TrashDir = ".trash"
+
+// DefaultFolders are the directory names treated as attachment folders,
+// wherever they are in the workspace.
+var DefaultFolders = []string{"attachments", "assets"}
+
+var (
+	// markdownLink matches the target of [text](target) and ![alt](target),
+	// ignoring an optional "title".
+	markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	// wikiEmbed matches ![[name]] and ![[name|size]].
+	wikiEmbed = regexp.MustCompile(`!\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]`)
+)
+
+// Ref is a link from a note to a file.
+type Ref struct {
+	Note string `json:"note"`
+	Line int    `json:"line"`
+	// Target is the link as written; Path is where it points in the
+	// workspace.
+	Target string `json:"target"`
+	Path   string `json:"path"`
+	// Wiki embeds are resolved by file name anywhere in the workspace.
+	Wiki bool `json:"wiki"`
+}
+
+type Missing struct {
+	Ref
+	// Candidates are existing files with the same name as the missing one.
+	Candidates []string `json:"candidates"`
+}
+
+type Report struct {
+	Missing []Missing `json:"missing"`
+	Orphans []string  `json:"orphans"`
+}
+
+// Links returns the local file links in note, which is at notePath.
+// Links to other notes, URLs and anchors are skipped.
+func Links(notePath, note string) []Ref {
+	var refs []Ref
+	dir := path.Dir(notePath)
+	for i, line := range strings.Split(note, "\n") {
+		for _, m := range markdownLink.FindAllStringSubmatch(line, -1) {
+			target := m[1]
+			if strings.Contains(target, "://") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+				continue
+			}
+			p := target
+			if unescaped, err := url.PathUnescape(p); err == nil {
+				p = unescaped
+			}
+			p, _, _ = strings.Cut(p, "#")
+			if strings.HasPrefix(p, "/") {
+				p = path.Clean(strings.TrimPrefix(p, "/"))
+			} else {
+				p = path.Join(dir, p)
+			}
+			if isNote(p) || p == "." || strings.HasPrefix(p, "../") {
+				continue
+			}
+			refs = append(refs, Ref{Note: notePath, Line: i + 1, Target: target, Path: p})
+		}
+		for _, m := range wikiEmbed.FindAllStringSubmatch(line, -1) {
+			name := strings.TrimSpace(m[1])
+			if path.Ext(name) == "" || isNote(name) {
+				continue
+			}
+			refs = append(refs, Ref{Note: notePath, Line: i + 1, Target: name, Path: name, Wiki: true})
+		}
+	}
+	return refs
+}
+
+func isNote(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".md")
+}
+
+func inFolder(p string, folders []string) bool {
+	for _, segment := range strings.Split(path.Dir(p), "/") {
+		if slices.Contains(folders, segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan builds the report for the whole workspace. folders names the
+// attachment folders checked for orphans.
+func Scan(ws *workspace.Workspace, folders []string) (*Report, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]bool{}
+	byName := map[string][]string{}
+	var notes []string
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		if isNote(e.Path) {
+			notes = append(notes, e.Path)
+			continue
+		}
+		files[e.Path] = true
+		name := path.Base(e.Path)
+		byName[name] = append(byName[name], e.Path)
+	}
+
+	report := &Report{Missing: []Missing{}, Orphans: []string{}}
+	referenced := map[string]bool{}
+	// Files embedded in canvases aren't orphans. Canvases have no lines to
+	// relink, so their broken embeds aren't reported.
+	for _, e := range entries {
+		if e.IsDir || !canvas.IsCanvas(e.Path) {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		if c, err := canvas.Parse(data); err == nil {
+			for _, f := range c.Files() {
+				referenced[f] = true
+			}
+		}
+	}
+	for _, n := range notes {
+		data, err := ws.ReadFile(n)
+		if err != nil {
+			continue
+		}
+		for _, ref := range Links(n, string(data)) {
+			if ref.Wiki {
+				for _, p := range byName[path.Base(ref.Path)] {
+					referenced[p] = true
+				}
+				if len(byName[path.Base(ref.Path)]) == 0 {
+					report.Missing = append(report.Missing, Missing{Ref: ref, Candidates: []string{}})
+				}
+				continue
+			}
+			if files[ref.Path] {
+				referenced[ref.Path] = true
+				continue
+			}
+			candidates := slices.Clone(byName[path.Base(ref.Path)])
+			if candidates == nil {
+				candidates = []string{}
+			}
+			report.Missing = append(report.Missing, Missing{Ref: ref, Candidates: candidates})
+		}
+	}
+
+	for _, e := range entries {
+		if !e.IsDir && !isNote(e.Path) && !referenced[e.Path] && inFolder(e.Path, folders) {
+			report.Orphans = append(report.Orphans, e.Path)
+		}
+	}
+	return report, nil
+}
+
+// Relink points every missing link that has exactly one candidate at that
+// candidate, and returns the notes it changed. A candidate that is itself
+// referenced by another link is still used: the file was most likely moved.
+func Relink(ws *workspace.Workspace, missing []Missing) ([]string, error) {
+	byNote := map[string][]Missing{}
+	var order []string
+	for _, m := range missing {
+		if m.Wiki || len(m.Candidates) != 1 {
+			continue
+		}
+		if _, ok := byNote[m.Note]; !ok {
+			order = append(order, m.Note)
+		}
+		byNote[m.Note] = append(byNote[m.Note], m)
+	}
+
+	changed := []string{}
+	for _, note := range order {
+		data, err := ws.ReadFile(note)
+		if err != nil {
+			return changed, err
+		}
+		lines := strings.Split(string(data), "\n")
+		for _, m := range byNote[note] {
+			if m.Line > len(lines) {
+				continue
+			}
+			rel, err := filepath.Rel(path.Dir(note), m.Candidates[0])
+			if err != nil {
+				continue
+			}
+			target := (&url.URL{Path: filepath.ToSlash(rel)}).EscapedPath()
+			line := strings.ReplaceAll(lines[m.Line-1], "]("+m.Target, "]("+target)
+			lines[m.Line-1] = strings.ReplaceAll(line, "](<"+m.Target, "](<"+target)
+		}
+		updated := strings.Join(lines, "\n")
+		if updated == string(data) {
+			continue
+		}
+		if err := ws.WriteFile(note, []byte(updated), 0o644); err != nil {
+			return changed, err
+		}
+		changed = append(changed, note)
+	}
+	return changed, nil
+}
+
+// Trash moves the given files into TrashDir at the workspace root, keeping
+// their paths, and returns where each ended up.
+func Trash(ws *workspace.Workspace, paths []string) ([]string, error) {
+	moved := []string{}
+	for _, p := range paths {
+		dst := path.Join(TrashDir, p)
+		if err := ws.MkdirAll(path.Dir(dst), 0o755); err != nil {
+			return moved, err
+		}
+		if err := ws.Move(p, dst); err != nil {
+			return moved, err
+		}
+		moved = append(moved, dst)
+	}
+	return moved, nil
+}
@@ -0,0 +1,223 @@
+// Package backup exports the whole of Wisdom's state as one zip archive and
+// restores it, to move a workspace to another machine.
+//
+// An archive holds manifest.json and, under files/, every workspace file
+// with its modification time, including the app data in .wisdom. Other
+// hidden folders at the root, such as .git, have their own ways of moving
+// and are left out, as are snapshots unless asked for and caches that are
+// rebuilt on use. Files are read through the workspace, so the archive is
+// never encrypted, even when the workspace is, and what is locked in it
+// (see Workspace.Lock) is left out; an archive that would write there is
+// refused.
+package backup
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// FormatVersion is bumped when the layout of archives changes in a way
+// older servers can't restore.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	filesDir     = "files/"
+	appDataDir   = ".wisdom"
+)
+
+var (
+	ErrInvalidArchive = errors.New("invalid export archive")
+	ErrIncompatible   = errors.New("export archive is from a newer version")
+)
+
+type Manifest struct {
+	Format    int       `json:"format"`
+	Created   time.Time `json:"created"`
+	Files     int       `json:"files"`
+	Snapshots bool      `json:"snapshots"`
+}
+
+type Options struct {
+	// Snapshots includes the snapshot store, which can be far bigger than
+	// the workspace itself.
+	Snapshots bool
+}
+
+// Export writes the workspace to w as a zip archive.
+func Export(ws *workspace.Workspace, w io.Writer, opts Options, now time.Time) error {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return err
+	}
+	appData, err := walkAppData(ws, appDataDir, opts)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, appData...)
+
+	zw := zip.NewWriter(w)
+	m := Manifest{Format: FormatVersion, Created: now.UTC(), Snapshots: opts.Snapshots}
+	for _, e := range entries {
+		if e.IsDir {
+			if _, err := zw.CreateHeader(&zip.FileHeader{Name: filesDir + e.Path + "/"}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := addFile(ws, zw, e.Path); err != nil {
+			// A file deleted since the walk is simply not exported.
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("exporting %s: %w", e.Path, err)
+		}
+		m.Files++
+	}
+	// The manifest goes last so it can count the files; readers find it
+	// through the central directory anyway.
+	mw, err := zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(mw).Encode(m); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func walkAppData(ws *workspace.Workspace, dir string, opts Options) ([]workspace.WalkEntry, error) {
+	entries, err := ws.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []workspace.WalkEntry
+	for _, e := range entries {
+		p := dir + "/" + e.Name()
+		if p == notes.CachePath || p == workspace.ScratchDir || (p == snapshot.Dir && !opts.Snapshots) || ws.IsLocked(p) {
+			continue
+		}
+		result = append(result, workspace.WalkEntry{Path: p, IsDir: e.IsDir()})
+		if e.IsDir() {
+			sub, err := walkAppData(ws, p, opts)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, sub...)
+		}
+	}
+	return result, nil
+}
+
+func addFile(ws *workspace.Workspace, zw *zip.Writer, p string) error {
+	info, err := ws.Stat(p)
+	if err != nil {
+		return err
+	}
+	f, err := ws.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     filesDir + p,
+		Method:   zip.Deflate,
+		Modified: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+// Restore writes the files in the archive r into the workspace, replacing
+// files at the same paths and keeping their modification times. Files not
+// in the archive are left alone. The whole archive is checked before
+// anything is written, so a bad one changes nothing.
+func Restore(ws *workspace.Workspace, r io.ReaderAt, size int64) (Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	m, err := readManifest(zr)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var files []*zip.File
+	for _, f := range zr.File {
+		if f.Name == manifestName {
+			continue
+		}
+		name, ok := strings.CutPrefix(f.Name, filesDir)
+		name = strings.TrimSuffix(name, "/")
+		if !ok || name == "" || path.Clean(name) != name || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return Manifest{}, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, f.Name)
+		}
+		if ws.IsLocked(name) {
+			return Manifest{}, fmt.Errorf("%w: %s", workspace.ErrLocked, name)
+		}
+		files = append(files, f)
+	}
+
+	for _, f := range files {
+		name := strings.TrimSuffix(strings.TrimPrefix(f.Name, filesDir), "/")
+		if f.FileInfo().IsDir() {
+			if err := ws.MkdirAll(name, 0o755); err != nil {
+				return Manifest{}, err
+			}
+			continue
+		}
+		if err := restoreFile(ws, f, name); err != nil {
+			return Manifest{}, fmt.Errorf("restoring %s: %w", name, err)
+		}
+	}
+	return m, nil
+}
+
+func readManifest(zr *zip.Reader) (Manifest, error) {
+	f, err := zr.Open(manifestName)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: no manifest", ErrInvalidArchive)
+	}
+	defer f.Close()
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil || m.Format < 1 {
+		return Manifest{}, fmt.Errorf("%w: unreadable manifest", ErrInvalidArchive)
+	}
+	if m.Format > FormatVersion {
+		return Manifest{}, fmt.Errorf("%w: format %d, this server reads up to %d", ErrIncompatible, m.Format, FormatVersion)
+	}
+	return m, nil
+}
+
+func restoreFile(ws *workspace.Workspace, f *zip.File, name string) error {
+	if dir := path.Dir(name); dir != "." {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := ws.WriteStream(name, rc, 0o644); err != nil {
+		return err
+	}
+	return ws.Chtimes(name, f.Modified)
+}
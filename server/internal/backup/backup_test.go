@@ -0,0 +1,161 @@
+package backup_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/backup"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func newWorkspace(t *testing.T) *workspace.Workspace {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func write(t *testing.T, ws *workspace.Workspace, p, content string) {
+	t.Helper()
+	if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportRestore(t *testing.T) {
+	src := newWorkspace(t)
+	write(t, src, "notes/today.md", "# Today")
+	write(t, src, schedule.Path, "[]")
+	write(t, src, notes.CachePath, "{}")
+	write(t, src, ".git/HEAD", "ref: refs/heads/main")
+	if err := src.MkdirAll("empty", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshot.Create(src, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := src.Chtimes("notes/today.md", modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := backup.Export(src, &buf, backup.Options{}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	dst := newWorkspace(t)
+	write(t, dst, "notes/today.md", "old")
+	write(t, dst, "kept.md", "mine")
+	m, err := backup.Restore(dst, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != backup.FormatVersion || m.Files != 2 || m.Snapshots {
+		t.Errorf("manifest = %+v, want the note and the schedules", m)
+	}
+
+	for p, want := range map[string]string{"notes/today.md": "# Today", schedule.Path: "[]", "kept.md": "mine"} {
+		if got, err := dst.ReadFile(p); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", p, got, err, want)
+		}
+	}
+	if info, err := dst.Stat("notes/today.md"); err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("restored modification time = %v, want %v", info.ModTime(), modTime)
+	}
+	if info, err := dst.Stat("empty"); err != nil || !info.IsDir() {
+		t.Errorf("empty folder not restored: %v", err)
+	}
+	for _, p := range []string{notes.CachePath, ".git/HEAD", snapshot.Dir} {
+		if _, err := dst.Stat(p); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s was exported: %v", p, err)
+		}
+	}
+
+	t.Run("with snapshots", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := backup.Export(src, &buf, backup.Options{Snapshots: true}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		dst := newWorkspace(t)
+		if _, err := backup.Restore(dst, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+			t.Fatal(err)
+		}
+		if all, err := snapshot.List(dst); err != nil || len(all) != 1 {
+			t.Errorf("restored snapshots = %v, %v; want one", all, err)
+		}
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := backup.Export(src.Lock([]string{"notes", schedule.Path}), &buf, backup.Options{}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range zr.File {
+			if f.Name == "files/notes/today.md" || f.Name == "files/"+schedule.Path {
+				t.Errorf("locked %s exported", f.Name)
+			}
+		}
+	})
+}
+
+func archive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		lock  []string
+		want  error
+	}{
+		{"no manifest", map[string]string{"files/a.md": "a"}, nil, backup.ErrInvalidArchive},
+		{"newer format", map[string]string{"manifest.json": `{"format": 99}`, "files/a.md": "a"}, nil, backup.ErrIncompatible},
+		{"escaping path", map[string]string{"manifest.json": `{"format": 1}`, "files/a.md": "a", "files/../b.md": "b"}, nil, backup.ErrInvalidArchive},
+		{"unknown entry", map[string]string{"manifest.json": `{"format": 1}`, "other/a.md": "a"}, nil, backup.ErrInvalidArchive},
+		{"locked entry", map[string]string{"manifest.json": `{"format": 1}`, "files/a.md": "a", "files/private/b.md": "b"}, []string{"private"}, workspace.ErrLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newWorkspace(t).Lock(tt.lock)
+			data := archive(t, tt.files)
+			if _, err := backup.Restore(ws, bytes.NewReader(data), int64(len(data))); !errors.Is(err, tt.want) {
+				t.Fatalf("Restore = %v, want %v", err, tt.want)
+			}
+			// Nothing is written from a rejected archive.
+			if _, err := ws.Stat("a.md"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("a.md restored from a rejected archive: %v", err)
+			}
+		})
+	}
+}
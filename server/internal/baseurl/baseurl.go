@@ -0,0 +1,52 @@
+// Package baseurl is where the server is reached from outside: the path a
+// reverse proxy mounts it under, and the address the links it gives out
+// start with.
+package baseurl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid base URL")
+
+// Path is WISDOM_BASE_PATH, such as /wisdom, for a server mounted on a
+// subpath of a domain, or "" for one at its root.
+func Path() string {
+	return os.Getenv("WISDOM_BASE_PATH")
+}
+
+// FromRequest is the server's address as the links it gives out use it:
+// WISDOM_BASE_URL, for a server behind a reverse proxy or on its own
+// domain, including any base path, or else the address r reached it at.
+func FromRequest(r *http.Request) string {
+	if base := os.Getenv("WISDOM_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + Path()
+}
+
+// Check reports whether WISDOM_BASE_URL, if set, is an absolute http or
+// https URL without a query, and WISDOM_BASE_PATH, if set, a clean path
+// without a trailing slash.
+func Check() error {
+	if base := os.Getenv("WISDOM_BASE_URL"); base != "" {
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("WISDOM_BASE_URL: %w %q", ErrInvalid, base)
+		}
+	}
+	if p := Path(); p != "" && (p == "/" || path.Clean(p) != p || !strings.HasPrefix(p, "/")) {
+		return fmt.Errorf("WISDOM_BASE_PATH: %w %q, want a path like /wisdom", ErrInvalid, p)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Package buildinfo describes the running build, so bug reports and clients
+// can tell which server they are talking to.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X <package>.Version=v1.2.3". Commit and
+// Date default to what the Go toolchain records from git.
+var (
+	Version = "dev"
+	Commit  string
+	Date    string
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
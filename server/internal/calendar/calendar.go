@@ -0,0 +1,193 @@
+// Package calendar serves the workspace's deadlines as an iCalendar feed,
+// so a calendar app subscribed to it shows them alongside meetings.
+//
+// Two things become all-day events: open tasks with a due date, written as
+// "- [ ] Send the draft due:2026-03-01" or, as the Obsidian Tasks plugin
+// writes it, "📅 2026-03-01"; and notes with a date or due property.
+package calendar
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+var (
+	openTask = regexp.MustCompile(`^\s*[-*+] \[ \] (.*)$`)
+	dueDate  = regexp.MustCompile(`(?:^|\s)(?:due:|📅\s*)(\d{4}-\d{2}-\d{2})\b`)
+)
+
+type Event struct {
+	// UID stays the same while the task's text or the note's path does,
+	// so calendar apps update the event instead of adding another.
+	UID     string
+	Date    string
+	Summary string
+	// Path is the note the event comes from.
+	Path string
+}
+
+// Events returns the events in the workspace, by date.
+func Events(ws *workspace.Workspace) ([]Event, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, e := range entries {
+		if e.IsDir || !notes.IsNote(e.Path) {
+			continue
+		}
+		content, kind, err := textfile.Read(ws, e.Path)
+		if err != nil || kind == textfile.Binary {
+			continue
+		}
+		events = append(events, noteEvents(e.Path, content)...)
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		return cmp.Or(strings.Compare(a.Date, b.Date), strings.Compare(a.Path, b.Path), strings.Compare(a.Summary, b.Summary))
+	})
+	return events, nil
+}
+
+func noteEvents(p, content string) []Event {
+	var events []Event
+	doc := frontmatter.Parse(content)
+	title := notes.Parse(p, content).Title
+	for _, key := range []string{"date", "due"} {
+		if d := validDate(doc.String(key)); d != "" {
+			events = append(events, Event{UID: uid(p, key), Date: d, Summary: title, Path: p})
+		}
+	}
+
+	inCode := false
+	for line := range strings.Lines(doc.Body) {
+		line = strings.TrimRight(line, "\r\n")
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		m := openTask.FindStringSubmatch(line)
+		if inCode || m == nil {
+			continue
+		}
+		due := dueDate.FindStringSubmatch(m[1])
+		if due == nil || validDate(due[1]) == "" {
+			continue
+		}
+		text := strings.Join(strings.Fields(dueDate.ReplaceAllString(m[1], " ")), " ")
+		events = append(events, Event{UID: uid(p, text), Date: due[1], Summary: text, Path: p})
+	}
+	return events
+}
+
+// validDate returns the date at the start of s, or "" if there is none.
+func validDate(s string) string {
+	if len(s) < 10 {
+		return ""
+	}
+	if _, err := time.Parse(time.DateOnly, s[:10]); err != nil {
+		return ""
+	}
+	return s[:10]
+}
+
+func uid(p, key string) string {
+	sum := sha256.Sum256([]byte(p + "\x00" + key))
+	return hex.EncodeToString(sum[:16]) + "@wisdom"
+}
+
+// Write renders events as an iCalendar (RFC 5545) calendar.
+func Write(b *strings.Builder, events []Event, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	line := func(name, value string) { fold(b, name+":"+value) }
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//wisdom//calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", "Wisdom")
+	for _, ev := range events {
+		day, _ := time.Parse(time.DateOnly, ev.Date)
+		line("BEGIN", "VEVENT")
+		line("UID", ev.UID)
+		line("DTSTAMP", stamp)
+		line("DTSTART;VALUE=DATE", day.Format("20060102"))
+		line("DTEND;VALUE=DATE", day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", escape(ev.Summary))
+		line("DESCRIPTION", escape(ev.Path))
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// fold writes a content line, folded into lines of at most 75 bytes
+// without splitting a UTF-8 sequence, and ending in CRLF.
+func fold(b *strings.Builder, s string) {
+	limit := 75
+	for len(s) > limit {
+		i := limit
+		for i > 0 && !isRuneStart(s[i]) {
+			i--
+		}
+		b.WriteString(s[:i])
+		b.WriteString("\r\n ")
+		s = s[i:]
+		// Continuation lines start with the space.
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// Handler serves the feed to requests with the token in a token query
+// parameter, as calendar apps can only be given a URL, or as a bearer
+// token.
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		got := r.URL.Query().Get("token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			got = bearer
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		events, err := Events(workspace.FromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var b strings.Builder
+		Write(&b, events, time.Now())
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(b.String()))
+	})
+}
@@ -0,0 +1,114 @@
+package calendar_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/calendar"
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestEvents(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"projects/launch.md": "---\ntitle: Launch\ndue: 2026-04-01\n---\n" +
+			"- [ ] Send the draft due:2026-03-02\n" +
+			"- [x] Book the room due:2026-03-01\n" +
+			"  - [ ] Order cake 📅 2026-03-03 #party\n" +
+			"- [ ] Someday\n" +
+			"```\n- [ ] Example due:2026-03-04\n```\n",
+		"meetings/retro.md": "---\ndate: 2026-03-02T10:00\n---\n# Retro\n",
+		"journal/today.md":  "- [ ] Bad date due:2026-13-01\n",
+	} {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := calendar.Events(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Date+" "+ev.Summary+" ("+ev.Path+")")
+	}
+	want := []string{
+		"2026-03-02 Retro (meetings/retro.md)",
+		"2026-03-02 Send the draft (projects/launch.md)",
+		"2026-03-03 Order cake #party (projects/launch.md)",
+		"2026-04-01 Launch (projects/launch.md)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	t.Run("feed", func(t *testing.T) {
+		srv := httptest.NewServer(middleware.WithWorkspace(calendar.Handler("secret"), ws))
+		t.Cleanup(srv.Close)
+
+		resp, err := http.Get(srv.URL + "/calendar.ics?token=wrong")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("wrong token: status = %d, want 401", resp.StatusCode)
+		}
+
+		resp, err = http.Get(srv.URL + "/calendar.ics?token=secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+			t.Fatalf("status = %d, type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		ics := string(body)
+		for _, want := range []string{
+			"BEGIN:VCALENDAR\r\n",
+			"DTSTART;VALUE=DATE:20260302\r\nDTEND;VALUE=DATE:20260303\r\nSUMMARY:Send the draft\r\n",
+			"END:VCALENDAR\r\n",
+		} {
+			if !strings.Contains(ics, want) {
+				t.Errorf("feed lacks %q:\n%s", want, ics)
+			}
+		}
+		if n := strings.Count(ics, "BEGIN:VEVENT"); n != 4 {
+			t.Errorf("%d events, want 4", n)
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var b strings.Builder
+	summary := strings.Repeat("é", 50) + "; done, really"
+	calendar.Write(&b, []calendar.Event{{UID: "1@wisdom", Date: "2026-03-02", Summary: summary, Path: "a.md"}}, time.Now())
+	var unfolded strings.Builder
+	for line := range strings.SplitSeq(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d bytes: %q", len(line), line)
+		}
+		if rest, ok := strings.CutPrefix(line, " "); ok {
+			unfolded.WriteString(rest)
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	if want := "\nSUMMARY:" + strings.Repeat("é", 50) + `\; done\, really` + "\n"; !strings.Contains(unfolded.String(), want) {
+		t.Errorf("summary not escaped and folded:\n%s", unfolded.String())
+	}
+}
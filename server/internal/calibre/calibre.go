@@ -0,0 +1,393 @@
+// Package calibre imports books from a Calibre library into the workspace.
+//
+// Calibre keeps a metadata.opf file next to every book, mirroring what is in
+// its metadata.db, so the importer reads those instead of the database, which
+// would need a SQLite driver. Each
+// book becomes a folder holding its formats, its cover and a markdown note
+// whose frontmatter carries the Calibre metadata. Importing again only
+// rewrites what changed, so it doubles as a re-sync. Only the note's
+// frontmatter is owned by the importer; its body is left to the user.
+package calibre
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+var ErrDisabled = errors.New("calibre import is off")
+
+// FromEnv returns the Calibre library imported from, set on the server in
+// WISDOM_CALIBRE_LIBRARY, or "", with imports off, if it isn't set. Clients
+// can't choose another folder of the host to read.
+func FromEnv() string {
+	return os.Getenv("WISDOM_CALIBRE_LIBRARY")
+}
+
+// Formats are the extensions of the book files imported, lowercase.
+var Formats = map[string]bool{
+	".epub": true,
+	".pdf":  true,
+	".mobi": true,
+	".azw3": true,
+	".fb2":  true,
+	".cbz":  true,
+	".cbr":  true,
+	".djvu": true,
+	".txt":  true,
+}
+
+var calibreIDSuffix = regexp.MustCompile(` \(\d+\)$`)
+
+// ReportPath is where the report of the last import is kept.
+const ReportPath = ".wisdom/calibre-import.json"
+
+type Report struct {
+	Added     []string      `json:"added"`
+	Updated   []string      `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Errors    []ImportError `json:"errors"`
+	Finished  time.Time     `json:"finished"`
+}
+
+type ImportError struct {
+	Book  string `json:"book"`
+	Error string `json:"error"`
+}
+
+type Book struct {
+	CalibreID   string
+	Title       string
+	Authors     []string
+	Series      string
+	SeriesIndex string
+	// Rating is out of 5, converted from Calibre's 0-10 scale.
+	Rating      float64
+	Tags        []string
+	ISBN        string
+	Published   string
+	Description string
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string        `xml:"http://purl.org/dc/elements/1.1/ title"`
+		Creators    []opfCreator    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Description string          `xml:"http://purl.org/dc/elements/1.1/ description"`
+		Date        string          `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Subjects    []string        `xml:"http://purl.org/dc/elements/1.1/ subject"`
+		Identifiers []opfIdentifier `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+		Metas       []opfMeta       `xml:"meta"`
+	} `xml:"metadata"`
+}
+
+type opfCreator struct {
+	Role string `xml:"role,attr"`
+	Name string `xml:",chardata"`
+}
+
+type opfIdentifier struct {
+	Scheme string `xml:"scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type opfMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+}
+
+// ParseOPF reads the metadata Calibre writes for a book.
+func ParseOPF(data []byte) (*Book, error) {
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("parsing metadata.opf: %w", err)
+	}
+	md := pkg.Metadata
+
+	b := &Book{
+		Description: strings.TrimSpace(md.Description),
+		Tags:        md.Subjects,
+	}
+	if len(md.Titles) > 0 {
+		b.Title = strings.TrimSpace(md.Titles[0])
+	}
+	for _, c := range md.Creators {
+		if c.Role == "" || c.Role == "aut" {
+			b.Authors = append(b.Authors, strings.TrimSpace(c.Name))
+		}
+	}
+	// Calibre writes 0101-01-01 for books without a publication date.
+	if date, _, _ := strings.Cut(md.Date, "T"); date != "" && !strings.HasPrefix(date, "0101-") {
+		b.Published = date
+	}
+	for _, id := range md.Identifiers {
+		switch strings.ToLower(id.Scheme) {
+		case "calibre":
+			b.CalibreID = strings.TrimSpace(id.Value)
+		case "isbn":
+			b.ISBN = strings.TrimSpace(id.Value)
+		}
+	}
+	for _, m := range md.Metas {
+		switch m.Name {
+		case "calibre:series":
+			b.Series = m.Content
+		case "calibre:series_index":
+			b.SeriesIndex = m.Content
+		case "calibre:rating":
+			if r, err := strconv.ParseFloat(m.Content, 64); err == nil {
+				b.Rating = r / 2
+			}
+		}
+	}
+	if b.Title == "" {
+		return nil, errors.New("metadata.opf has no title")
+	}
+	return b, nil
+}
+
+// ReadEPUB reads the metadata of an EPUB, which uses the same package
+// format as Calibre's metadata.opf.
+func ReadEPUB(data []byte) (*Book, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading epub: %w", err)
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	data, err = readZipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return nil, fmt.Errorf("reading epub container: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub has no package file")
+	}
+	if data, err = readZipFile(zr, container.Rootfiles[0].FullPath); err != nil {
+		return nil, err
+	}
+	return ParseOPF(data)
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	rc, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading epub: %w", err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Frontmatter renders the book's metadata as a YAML frontmatter block. Values
+// are written as JSON, which is valid YAML, to avoid quoting pitfalls.
+func (b *Book) Frontmatter() string {
+	var sb strings.Builder
+	field := func(key string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(&sb, "%s: %s\n", key, data)
+	}
+
+	sb.WriteString("---\n")
+	field("title", b.Title)
+	if len(b.Authors) > 0 {
+		field("authors", b.Authors)
+	}
+	if b.Series != "" {
+		field("series", b.Series)
+		if b.SeriesIndex != "" {
+			if idx, err := strconv.ParseFloat(b.SeriesIndex, 64); err == nil {
+				field("series_index", idx)
+			}
+		}
+	}
+	if b.Rating > 0 {
+		field("rating", b.Rating)
+	}
+	if len(b.Tags) > 0 {
+		field("tags", b.Tags)
+	}
+	if b.ISBN != "" {
+		field("isbn", b.ISBN)
+	}
+	if b.Published != "" {
+		field("published", b.Published)
+	}
+	if b.Description != "" {
+		field("description", b.Description)
+	}
+	if b.CalibreID != "" {
+		field("calibre_id", b.CalibreID)
+	}
+	sb.WriteString("---\n")
+	return sb.String()
+}
+
+// Import copies every book in the Calibre library at libraryDir into destDir
+// in the workspace. Calibre's Author/Title layout is kept, minus the numeric
+// id Calibre appends to title folders. The report is also kept at
+// ReportPath.
+func Import(ws *workspace.Workspace, libraryDir, destDir string, now time.Time) (*Report, error) {
+	opfs, err := filepath.Glob(filepath.Join(libraryDir, "*", "*", "metadata.opf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(opfs) == 0 {
+		if _, err := os.Stat(libraryDir); err != nil {
+			return nil, fmt.Errorf("reading calibre library: %w", err)
+		}
+	}
+
+	report := &Report{Added: []string{}, Updated: []string{}, Errors: []ImportError{}}
+	for _, opf := range opfs {
+		bookDir := filepath.Dir(opf)
+		authorDir := filepath.Base(filepath.Dir(bookDir))
+		titleDir := calibreIDSuffix.ReplaceAllString(filepath.Base(bookDir), "")
+		dest := path.Join(destDir, authorDir, titleDir)
+
+		if err := importBook(ws, bookDir, dest, titleDir, report); err != nil {
+			rel, _ := filepath.Rel(libraryDir, bookDir)
+			report.Errors = append(report.Errors, ImportError{
+				Book:  filepath.ToSlash(rel),
+				Error: err.Error(),
+			})
+		}
+	}
+
+	report.Finished = now.UTC()
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.MkdirAll(path.Dir(ReportPath), 0o755); err != nil {
+		return nil, err
+	}
+	return report, ws.WriteFile(ReportPath, data, 0o644)
+}
+
+// LoadReport returns the report of the last import, or nil if there hasn't
+// been one.
+func LoadReport(ws *workspace.Workspace) (*Report, error) {
+	data, err := ws.ReadFile(ReportPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func importBook(ws *workspace.Workspace, srcDir, dest, title string, report *Report) error {
+	opf, err := os.ReadFile(filepath.Join(srcDir, "metadata.opf"))
+	if err != nil {
+		return err
+	}
+	book, err := ParseOPF(opf)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || (name != "cover.jpg" && !Formats[strings.ToLower(filepath.Ext(name))]) {
+			continue
+		}
+		if err := syncFile(ws, filepath.Join(srcDir, name), path.Join(dest, name), report); err != nil {
+			return err
+		}
+	}
+	return syncNote(ws, book.Frontmatter(), path.Join(dest, title+".md"), report)
+}
+
+func record(report *Report, p string, existed bool) {
+	if existed {
+		report.Updated = append(report.Updated, p)
+	} else {
+		report.Added = append(report.Added, p)
+	}
+}
+
+// syncFile copies src to dst unless dst already has the same size and
+// modification time. The source mtime is carried over so the next import can
+// tell the file is unchanged.
+func syncFile(ws *workspace.Workspace, src, dst string, report *Report) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := ws.Stat(dst)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if existed && dstInfo.Size() == srcInfo.Size() && dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+		report.Unchanged++
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := ws.WriteStream(dst, f, 0o644); err != nil {
+		return err
+	}
+	if err := ws.Chtimes(dst, srcInfo.ModTime()); err != nil {
+		return err
+	}
+	record(report, dst, existed)
+	return nil
+}
+
+// syncNote replaces the frontmatter of the note at dst, keeping its body.
+func syncNote(ws *workspace.Workspace, header, dst string, report *Report) error {
+	current, err := ws.ReadFile(dst)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	note := header + frontmatter.Parse(string(current)).Body
+	if existed && string(current) == note {
+		report.Unchanged++
+		return nil
+	}
+	if err := ws.WriteFile(dst, []byte(note), 0o644); err != nil {
+		return err
+	}
+	record(report, dst, existed)
+	return nil
+}
@@ -0,0 +1,153 @@
+package calibre_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/calibre"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const duneOPF = `<?xml version='1.0' encoding='utf-8'?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uuid_id" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:identifier opf:scheme="calibre" id="calibre_id">42</dc:identifier>
+    <dc:identifier opf:scheme="ISBN">9780441013593</dc:identifier>
+    <dc:title>Dune</dc:title>
+    <dc:creator opf:file-as="Herbert, Frank" opf:role="aut">Frank Herbert</dc:creator>
+    <dc:creator opf:role="edt">Some Editor</dc:creator>
+    <dc:description>A "desert" planet.</dc:description>
+    <dc:date>1965-08-01T00:00:00+00:00</dc:date>
+    <dc:subject>Science Fiction</dc:subject>
+    <dc:subject>Classics</dc:subject>
+    <meta name="calibre:series" content="Dune Chronicles"/>
+    <meta name="calibre:series_index" content="1.0"/>
+    <meta name="calibre:rating" content="8"/>
+  </metadata>
+</package>`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	library := t.TempDir()
+	bookDir := filepath.Join(library, "Frank Herbert", "Dune (42)")
+	writeFile(t, filepath.Join(bookDir, "metadata.opf"), duneOPF)
+	writeFile(t, filepath.Join(bookDir, "Dune - Frank Herbert.epub"), "epub")
+	writeFile(t, filepath.Join(bookDir, "cover.jpg"), "jpeg")
+	writeFile(t, filepath.Join(bookDir, "Dune - Frank Herbert.original_epub"), "ignored")
+	writeFile(t, filepath.Join(library, "Broken", "Nothing (7)", "metadata.opf"), "<package>")
+	writeFile(t, filepath.Join(library, "metadata.db"), "sqlite")
+
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, wantAdded, wantUpdated []string, wantUnchanged int) {
+		t.Helper()
+		report, err := calibre.Import(ws, library, "books", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(report.Added)
+		slices.Sort(report.Updated)
+		if !slices.Equal(report.Added, wantAdded) {
+			t.Errorf("added = %v, want %v", report.Added, wantAdded)
+		}
+		if !slices.Equal(report.Updated, wantUpdated) {
+			t.Errorf("updated = %v, want %v", report.Updated, wantUpdated)
+		}
+		if report.Unchanged != wantUnchanged {
+			t.Errorf("unchanged = %d, want %d", report.Unchanged, wantUnchanged)
+		}
+		if len(report.Errors) != 1 || report.Errors[0].Book != "Broken/Nothing (7)" {
+			t.Errorf("errors = %+v", report.Errors)
+		}
+	}
+
+	dest := "books/Frank Herbert/Dune/"
+
+	t.Run("first import copies books and writes a note", func(t *testing.T) {
+		check(t, []string{
+			dest + "Dune - Frank Herbert.epub",
+			dest + "Dune.md",
+			dest + "cover.jpg",
+		}, []string{}, 0)
+
+		note, err := ws.ReadFile(dest + "Dune.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			`title: "Dune"`,
+			`authors: ["Frank Herbert"]`,
+			`series: "Dune Chronicles"`,
+			`series_index: 1`,
+			`rating: 4`,
+			`tags: ["Science Fiction","Classics"]`,
+			`isbn: "9780441013593"`,
+			`published: "1965-08-01"`,
+			`description: "A \"desert\" planet."`,
+			`calibre_id: "42"`,
+		} {
+			if !strings.Contains(string(note), want+"\n") {
+				t.Errorf("note missing %q:\n%s", want, note)
+			}
+		}
+	})
+
+	t.Run("re-import without changes is a no-op", func(t *testing.T) {
+		check(t, []string{}, []string{}, 3)
+	})
+
+	t.Run("re-import keeps the note body and picks up changes", func(t *testing.T) {
+		note, err := ws.ReadFile(dest + "Dune.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(dest+"Dune.md", append(note, "\nMy thoughts.\n"...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(bookDir, "metadata.opf"),
+			strings.Replace(duneOPF, `content="8"`, `content="10"`, 1))
+		writeFile(t, filepath.Join(bookDir, "Dune - Frank Herbert.epub"), "epub v2")
+
+		check(t, []string{}, []string{
+			dest + "Dune - Frank Herbert.epub",
+			dest + "Dune.md",
+		}, 1)
+
+		note, err = ws.ReadFile(dest + "Dune.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(note), "rating: 5\n") || !strings.HasSuffix(string(note), "\nMy thoughts.\n") {
+			t.Fatalf("unexpected note:\n%s", note)
+		}
+	})
+
+	t.Run("report kept", func(t *testing.T) {
+		last, err := calibre.LoadReport(ws)
+		if err != nil || last == nil || last.Finished.IsZero() {
+			t.Fatalf("LoadReport = %+v, %v", last, err)
+		}
+	})
+
+	t.Run("missing library", func(t *testing.T) {
+		if _, err := calibre.Import(ws, filepath.Join(library, "nope"), "books", time.Now()); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
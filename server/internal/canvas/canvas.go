@@ -0,0 +1,134 @@
+// Package canvas validates canvases in the JSON Canvas format
+// (https://jsoncanvas.org): boards of text, file, link and group nodes
+// joined by edges.
+package canvas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+const Ext = ".canvas"
+
+var (
+	NodeTypes = []string{"text", "file", "link", "group"}
+	Sides     = []string{"top", "right", "bottom", "left"}
+	Ends      = []string{"none", "arrow"}
+)
+
+var ErrInvalid = errors.New("invalid canvas")
+
+// Fields outside the spec are allowed, and are kept since canvases are
+// stored as they were sent.
+type Canvas struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+type Node struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Color  string `json:"color,omitempty"`
+	Text   string `json:"text,omitempty"`
+	// File is a path from the workspace root, Subpath an optional heading
+	// or block in it.
+	File    string `json:"file,omitempty"`
+	Subpath string `json:"subpath,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Label   string `json:"label,omitempty"`
+}
+
+type Edge struct {
+	ID       string `json:"id"`
+	FromNode string `json:"fromNode"`
+	FromSide string `json:"fromSide,omitempty"`
+	FromEnd  string `json:"fromEnd,omitempty"`
+	ToNode   string `json:"toNode"`
+	ToSide   string `json:"toSide,omitempty"`
+	ToEnd    string `json:"toEnd,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+func IsCanvas(p string) bool {
+	return strings.EqualFold(path.Ext(p), Ext)
+}
+
+// Parse decodes and validates a canvas.
+func Parse(data []byte) (*Canvas, error) {
+	var c Canvas
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the canvas against the spec, reporting the first
+// problem found.
+func (c *Canvas) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+	}
+
+	ids := map[string]bool{}
+	for i, n := range c.Nodes {
+		switch {
+		case n.ID == "":
+			return invalid("node %d has no id", i)
+		case ids[n.ID]:
+			return invalid("duplicate id %q", n.ID)
+		case !slices.Contains(NodeTypes, n.Type):
+			return invalid("node %q has unknown type %q", n.ID, n.Type)
+		case n.Width <= 0 || n.Height <= 0:
+			return invalid("node %q needs a positive width and height", n.ID)
+		case n.Type == "file" && n.File == "":
+			return invalid("file node %q has no file", n.ID)
+		case n.Type == "link" && n.URL == "":
+			return invalid("link node %q has no url", n.ID)
+		}
+		ids[n.ID] = true
+	}
+
+	optional := func(v string, allowed []string) bool {
+		return v == "" || slices.Contains(allowed, v)
+	}
+	edges := map[string]bool{}
+	for i, e := range c.Edges {
+		switch {
+		case e.ID == "":
+			return invalid("edge %d has no id", i)
+		case ids[e.ID] || edges[e.ID]:
+			return invalid("duplicate id %q", e.ID)
+		case !ids[e.FromNode] || !ids[e.ToNode]:
+			return invalid("edge %q connects a missing node", e.ID)
+		case !optional(e.FromSide, Sides) || !optional(e.ToSide, Sides):
+			return invalid("edge %q has an unknown side", e.ID)
+		case !optional(e.FromEnd, Ends) || !optional(e.ToEnd, Ends):
+			return invalid("edge %q has an unknown end", e.ID)
+		}
+		edges[e.ID] = true
+	}
+	return nil
+}
+
+// Files returns the workspace files the canvas embeds, in node order.
+func (c *Canvas) Files() []string {
+	var files []string
+	for _, n := range c.Nodes {
+		if n.Type == "file" && !slices.Contains(files, n.File) {
+			files = append(files, n.File)
+		}
+	}
+	return files
+}
@@ -0,0 +1,53 @@
+package canvas_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/canvas"
+)
+
+const board = `{
+	"nodes": [
+		{"id": "a", "type": "text", "x": 0, "y": 0, "width": 200, "height": 100, "text": "# Idea"},
+		{"id": "b", "type": "file", "x": 300, "y": 0, "width": 400, "height": 400, "file": "notes/dune.md", "subpath": "#Themes"},
+		{"id": "c", "type": "file", "x": 300, "y": 500, "width": 400, "height": 300, "file": "attachments/map.png"},
+		{"id": "d", "type": "link", "x": 0, "y": 200, "width": 200, "height": 100, "url": "https://example.com", "custom": true},
+		{"id": "g", "type": "group", "x": -20, "y": -20, "width": 800, "height": 900, "label": "Dune"}
+	],
+	"edges": [
+		{"id": "e1", "fromNode": "a", "fromSide": "right", "toNode": "b", "toSide": "left", "toEnd": "arrow"}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	c, err := canvas.Parse([]byte(board))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"notes/dune.md", "attachments/map.png"}; !reflect.DeepEqual(c.Files(), want) {
+		t.Errorf("Files = %v, want %v", c.Files(), want)
+	}
+
+	replace := func(old, new string) string { return strings.Replace(board, old, new, 1) }
+	invalid := map[string]string{
+		"not json":      board[:40],
+		"unknown type":  replace(`"type": "text"`, `"type": "sticky"`),
+		"duplicate id":  replace(`"id": "d"`, `"id": "a"`),
+		"no size":       replace(`"width": 200, "height": 100, "text"`, `"width": 0, "height": 100, "text"`),
+		"file w/o path": replace(`"file": "notes/dune.md", `, ``),
+		"dangling edge": replace(`"toNode": "b"`, `"toNode": "z"`),
+		"unknown side":  replace(`"fromSide": "right"`, `"fromSide": "middle"`),
+		"unknown end":   replace(`"toEnd": "arrow"`, `"toEnd": "dot"`),
+		"edge id clash": replace(`"id": "e1"`, `"id": "g"`),
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := canvas.Parse([]byte(data)); !errors.Is(err, canvas.ErrInvalid) {
+				t.Errorf("err = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package changes keeps a feed of the files changed in the workspace, so
+// clients can sync incrementally instead of walking the whole workspace.
+//
+// There is no hook on every write: files are written through many
+// endpoints and by other programs. Instead, like the note index, the feed
+// is brought up to date on use, by comparing every file's size and
+// modification time with what was last seen and numbering each difference.
+// Only the latest change to each path is kept, which is all a client
+// catching up needs, and deletions are forgotten after TombstoneTTL.
+package changes
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/renames"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Path = ".wisdom/changes.json"
+
+// TombstoneTTL is how long deletions are kept. A client that hasn't synced
+// for longer has to start over from cursor 0.
+const TombstoneTTL = 30 * 24 * time.Hour
+
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// ErrExpired is returned for cursors the feed can no longer continue from,
+// because deletions after them were forgotten or the feed was reset.
+var ErrExpired = errors.New("cursor expired; sync again from 0")
+
+type Change struct {
+	Seq     int64     `json:"seq"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime,omitzero"`
+	// Seen is when the change was noticed, not when it was made.
+	Seen time.Time `json:"seen"`
+	// From is set on the put of a file renamed outside the server, and To
+	// on its deletion, so clients can move their copy rather than
+	// download it again.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type state struct {
+	Seq int64 `json:"seq"`
+	// Floor is the newest forgotten change; cursors below it have expired.
+	Floor   int64    `json:"floor"`
+	Changes []Change `json:"changes"`
+}
+
+// Feed is the change feed of a workspace.
+type Feed struct {
+	mu     sync.Mutex
+	loaded bool
+	st     state
+	latest map[string]Change
+	// ids are the inodes of the files as last seen, to tell renames apart.
+	// They aren't saved: after a restart, renames are told by size and
+	// modification time alone until the next refresh.
+	ids map[string]uint64
+}
+
+func NewFeed() *Feed {
+	return &Feed{}
+}
+
+func (f *Feed) load(ws *workspace.Workspace) error {
+	if f.loaded {
+		return nil
+	}
+	f.st, f.latest = state{}, map[string]Change{}
+	data, err := ws.ReadFile(Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &f.st); err != nil {
+			return fmt.Errorf("reading change feed: %w", err)
+		}
+	}
+	for _, c := range f.st.Changes {
+		f.latest[c.Path] = c
+	}
+	f.ids = map[string]uint64{}
+	f.loaded = true
+	return nil
+}
+
+func (f *Feed) save(ws *workspace.Workspace) error {
+	f.st.Changes = make([]Change, 0, len(f.latest))
+	for _, c := range f.latest {
+		f.st.Changes = append(f.st.Changes, c)
+	}
+	slices.SortFunc(f.st.Changes, func(a, b Change) int { return cmp.Compare(a.Seq, b.Seq) })
+	data, err := json.Marshal(f.st)
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(Path), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(Path, data, 0o644)
+}
+
+// refresh records the differences between the workspace and the feed.
+func (f *Feed) refresh(ws *workspace.Workspace, now time.Time) error {
+	if err := f.load(ws); err != nil {
+		return err
+	}
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	record := func(c Change) {
+		f.st.Seq++
+		c.Seq, c.Seen = f.st.Seq, now.UTC()
+		f.latest[c.Path] = c
+		changed = true
+	}
+	seen := make(map[string]bool, len(entries))
+	var puts []Change
+	var appeared []renames.File
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		info, err := ws.Stat(e.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		seen[e.Path] = true
+		file := renames.FromInfo(e.Path, info)
+		file.ModTime = file.ModTime.UTC()
+		last, ok := f.latest[e.Path]
+		id := f.ids[e.Path]
+		f.ids[e.Path] = file.ID
+		if ok && last.Op == OpPut && last.Size == file.Size && last.ModTime.Equal(file.ModTime) && (id == 0 || id == file.ID) {
+			continue
+		}
+		puts = append(puts, Change{Op: OpPut, Path: e.Path, Size: file.Size, ModTime: file.ModTime})
+		if !ok || last.Op == OpDelete {
+			appeared = append(appeared, file)
+		}
+	}
+	var gone []renames.File
+	for _, p := range slices.Sorted(maps.Keys(f.latest)) {
+		if c := f.latest[p]; c.Op == OpPut && !seen[p] {
+			gone = append(gone, renames.File{Path: p, Size: c.Size, ModTime: c.ModTime, ID: f.ids[p]})
+			delete(f.ids, p)
+		}
+	}
+
+	// A rename is recorded as the deletion right before the put, so a
+	// client reading in order can move its copy.
+	moves := renames.Match(gone, appeared)
+	to := make(map[string]string, len(moves))
+	for newPath, oldPath := range moves {
+		to[oldPath] = newPath
+	}
+	for _, c := range puts {
+		if from, ok := moves[c.Path]; ok {
+			record(Change{Op: OpDelete, Path: from, To: c.Path})
+			c.From = from
+		}
+		record(c)
+	}
+	for _, g := range gone {
+		if _, moved := to[g.Path]; !moved {
+			record(Change{Op: OpDelete, Path: g.Path})
+		}
+	}
+	for p, c := range f.latest {
+		if c.Op == OpDelete && now.Sub(c.Seen) > TombstoneTTL {
+			f.st.Floor = max(f.st.Floor, c.Seq)
+			delete(f.latest, p)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return f.save(ws)
+}
+
+// Refresh records the changes made to the workspace since the last look,
+// so they are stamped close to when they happened rather than when a
+// client next asks.
+func (f *Feed) Refresh(ws *workspace.Workspace, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refresh(ws.Full(), now)
+}
+
+// Since returns up to limit changes after cursor, oldest first, and the
+// cursor to continue from. more reports whether there are more to fetch.
+// Cursor 0 lists every file, without deletions, for a first sync.
+// Files in folders locked in ws are left out.
+func (f *Feed) Since(ws *workspace.Workspace, cursor int64, limit int, now time.Time) (result []Change, next int64, more bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.refresh(ws.Full(), now); err != nil {
+		return nil, 0, false, err
+	}
+	if cursor < 0 || cursor > f.st.Seq || (cursor > 0 && cursor < f.st.Floor) {
+		return nil, 0, false, ErrExpired
+	}
+
+	result = []Change{}
+	for _, c := range f.latest {
+		if c.Seq > cursor && (cursor > 0 || c.Op == OpPut) && !ws.IsLocked(c.Path) {
+			result = append(result, c)
+		}
+	}
+	slices.SortFunc(result, func(a, b Change) int { return cmp.Compare(a.Seq, b.Seq) })
+	if len(result) > limit {
+		return result[:limit], result[limit-1].Seq, true, nil
+	}
+	return result, f.st.Seq, false, nil
+}
@@ -0,0 +1,126 @@
+package changes_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/changes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func ops(cs []changes.Change) []string {
+	result := make([]string, len(cs))
+	for i, c := range cs {
+		result[i] = c.Op + " " + c.Path
+	}
+	return result
+}
+
+func TestFeed(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(p, content string) {
+		t.Helper()
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "one")
+	write("b.md", "two")
+
+	now := time.Now()
+	feed := changes.NewFeed()
+	all, cursor, more, err := feed.Since(ws, 0, 100, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(all); len(got) != 2 || got[0] != "put a.md" || got[1] != "put b.md" || more {
+		t.Fatalf("first sync = %v, more %v", got, more)
+	}
+
+	write("a.md", "one, edited")
+	if err := ws.Remove("b.md"); err != nil {
+		t.Fatal(err)
+	}
+	write("c.md", "three")
+
+	// A fresh feed picks up where the saved one left off.
+	feed = changes.NewFeed()
+	page, next, more, err := feed.Since(ws, cursor, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || !more || next != page[1].Seq {
+		t.Fatalf("first page = %v, next %d, more %v", ops(page), next, more)
+	}
+	rest, last, more, err := feed.Since(ws, next, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 || more {
+		t.Fatalf("second page = %v, more %v", ops(rest), more)
+	}
+	got := map[string]bool{}
+	for _, op := range ops(append(page, rest...)) {
+		got[op] = true
+	}
+	for _, want := range []string{"put a.md", "delete b.md", "put c.md"} {
+		if !got[want] {
+			t.Errorf("changes missing %q: %v", want, got)
+		}
+	}
+
+	if none, same, _, err := feed.Since(ws, last, 100, now); err != nil || len(none) != 0 || same != last {
+		t.Errorf("caught up = %v, %d, %v; want nothing new at %d", ops(none), same, err, last)
+	}
+	if _, _, _, err := feed.Since(ws, last+1, 100, now); !errors.Is(err, changes.ErrExpired) {
+		t.Errorf("cursor from the future: err = %v", err)
+	}
+
+	// Once the deletion is forgotten, cursors from before it are expired.
+	later := now.Add(changes.TombstoneTTL + time.Hour)
+	if _, _, _, err := feed.Since(ws, cursor, 100, later); !errors.Is(err, changes.ErrExpired) {
+		t.Errorf("cursor before forgotten deletion: err = %v", err)
+	}
+	if _, _, _, err := feed.Since(ws, last, 100, later); err != nil {
+		t.Errorf("cursor after forgotten deletion: %v", err)
+	}
+}
+
+func TestFeedRename(t *testing.T) {
+	root := t.TempDir()
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("plan.md", []byte("the plan"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	feed := changes.NewFeed()
+	_, cursor, _, err := feed.Since(ws, 0, 100, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.MkdirAll("projects", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "plan.md"), filepath.Join(root, "projects", "plan.md")); err != nil {
+		t.Fatal(err)
+	}
+	result, _, _, err := feed.Since(ws, cursor, 100, now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 ||
+		result[0].Op != changes.OpDelete || result[0].Path != "plan.md" || result[0].To != "projects/plan.md" ||
+		result[1].Op != changes.OpPut || result[1].Path != "projects/plan.md" || result[1].From != "plan.md" {
+		t.Errorf("changes after a rename = %+v, want the deletion and the put linked", result)
+	}
+}
@@ -0,0 +1,164 @@
+// Package comments keeps threaded remarks on notes, anchored to a note and
+// optionally to a block in it, so a reviewer can leave them without
+// editing the note.
+//
+// Comments are kept in one JSON file in the workspace, apart from the
+// notes, and sync with the rest of it. They are anchored by path: a note
+// moved or renamed outside the comments' knowledge leaves its comments
+// behind.
+package comments
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Path = ".wisdom/comments.json"
+
+var (
+	ErrUnknownComment = errors.New("unknown comment")
+	ErrUnknownParent  = errors.New("parent is not a comment on this note")
+	ErrEmpty          = errors.New("comment body is required")
+)
+
+// storeMu serializes changes, which rewrite the whole file.
+var storeMu sync.Mutex
+
+type Comment struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	// Block is the block the comment is on, such as a block ID or a
+	// heading, as the client names it; empty for the whole note.
+	Block string `json:"block,omitempty"`
+	// Parent is the comment replied to. Replies share their thread's
+	// block.
+	Parent  string    `json:"parent,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Body    string    `json:"body"`
+	Created time.Time `json:"created"`
+	Edited  time.Time `json:"edited,omitzero"`
+}
+
+func Load(ws *workspace.Workspace) ([]Comment, error) {
+	comments := []Comment{}
+	data, err := ws.ReadFile(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return comments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return nil, fmt.Errorf("reading comments: %w", err)
+	}
+	return comments, nil
+}
+
+func save(ws *workspace.Workspace, comments []Comment) error {
+	data, err := json.MarshalIndent(comments, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(Path), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(Path, data, 0o644)
+}
+
+// On returns the comments on the note at p, oldest first.
+func On(ws *workspace.Workspace, p string) ([]Comment, error) {
+	all, err := Load(ws)
+	if err != nil {
+		return nil, err
+	}
+	result := []Comment{}
+	for _, c := range all {
+		if c.Path == p {
+			result = append(result, c)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b Comment) int {
+		return cmp.Or(a.Created.Compare(b.Created), strings.Compare(a.ID, b.ID))
+	})
+	return result, nil
+}
+
+// Add stores c as a new comment, giving it an ID and its creation time.
+func Add(ws *workspace.Workspace, c Comment, now time.Time) (Comment, error) {
+	c.Body = strings.TrimSpace(c.Body)
+	if c.Body == "" {
+		return c, ErrEmpty
+	}
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	all, err := Load(ws)
+	if err != nil {
+		return c, err
+	}
+	if c.Parent != "" {
+		i := slices.IndexFunc(all, func(p Comment) bool { return p.ID == c.Parent })
+		if i < 0 || all[i].Path != c.Path {
+			return c, ErrUnknownParent
+		}
+		c.Block = all[i].Block
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	c.ID = hex.EncodeToString(buf)
+	c.Created, c.Edited = now, time.Time{}
+	return c, save(ws, append(all, c))
+}
+
+// Edit replaces the body of the comment with id.
+func Edit(ws *workspace.Workspace, id, body string, now time.Time) (Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return Comment{}, ErrEmpty
+	}
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	all, err := Load(ws)
+	if err != nil {
+		return Comment{}, err
+	}
+	i := slices.IndexFunc(all, func(c Comment) bool { return c.ID == id })
+	if i < 0 {
+		return Comment{}, ErrUnknownComment
+	}
+	all[i].Body, all[i].Edited = body, now
+	return all[i], save(ws, all)
+}
+
+// Delete removes the comment with id and the replies to it.
+func Delete(ws *workspace.Workspace, id string) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	all, err := Load(ws)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(all, func(c Comment) bool { return c.ID == id }) {
+		return ErrUnknownComment
+	}
+	removed := map[string]bool{id: true}
+	// Replies are always added after their parent, so one pass in order
+	// finds the whole thread.
+	for _, c := range all {
+		if removed[c.Parent] {
+			removed[c.ID] = true
+		}
+	}
+	return save(ws, slices.DeleteFunc(all, func(c Comment) bool { return removed[c.ID] }))
+}
@@ -0,0 +1,390 @@
+// Package covers finds cover images for books in the workspace and serves
+// resized copies of them from a disk cache.
+package covers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/lru"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+var (
+	ErrNoCover       = errors.New("no cover found")
+	ErrCoverTooLarge = fmt.Errorf("cover is over %d pixels", maxCoverPixels)
+)
+
+// Widths of the generated sizes. Original serves the source image as is.
+var Sizes = map[string]int{
+	"small":  160,
+	"medium": 320,
+	"large":  640,
+}
+
+const Original = "original"
+
+// Sibling images that count as the cover of every book in their directory,
+// as Calibre lays libraries out.
+var sidecarNames = []string{"cover.jpg", "cover.jpeg", "cover.png"}
+
+// maxEPUBSize bounds how much of an EPUB is read into memory to find its
+// cover.
+const maxEPUBSize = 256 << 20
+
+// maxCoverPixels bounds the images decoded to make thumbnails, about 160
+// MiB decoded, as a small compressed file can claim a huge image.
+const maxCoverPixels = 40_000_000
+
+// Find returns the source cover image for the book at p.
+func Find(ws *workspace.Workspace, p string) ([]byte, error) {
+	src, _, err := locate(ws, p)
+	if err != nil {
+		return nil, err
+	}
+	if src == p {
+		return fromEPUB(ws, p)
+	}
+	return ws.ReadFile(src)
+}
+
+// locate returns the file holding the cover of the book at p: a sidecar
+// image, or the book itself for EPUBs.
+func locate(ws *workspace.Workspace, p string) (string, fs.FileInfo, error) {
+	dir := path.Dir(p)
+	for _, name := range sidecarNames {
+		src := path.Join(dir, name)
+		info, err := ws.Stat(src)
+		if err == nil {
+			return src, info, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, err
+		}
+	}
+
+	if !strings.EqualFold(path.Ext(p), ".epub") {
+		return "", nil, ErrNoCover
+	}
+	info, err := ws.Stat(p)
+	if err != nil {
+		return "", nil, err
+	}
+	return p, info, nil
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metas []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+func fromEPUB(ws *workspace.Workspace, p string) ([]byte, error) {
+	f, err := ws.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxEPUBSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEPUBSize {
+		return nil, ErrNoCover
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading epub: %w", err)
+	}
+
+	var c container
+	if err := readXML(zr, "META-INF/container.xml", &c); err != nil {
+		return nil, err
+	}
+	if len(c.Rootfiles) == 0 {
+		return nil, ErrNoCover
+	}
+	opfPath := c.Rootfiles[0].FullPath
+
+	var pkg opfPackage
+	if err := readXML(zr, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	// EPUB 3 marks the cover in the manifest, EPUB 2 with a meta element
+	// pointing at a manifest id. Some books only have a suggestive name.
+	var coverID string
+	for _, m := range pkg.Metas {
+		if m.Name == "cover" {
+			coverID = m.Content
+		}
+	}
+	href := ""
+	for _, item := range pkg.Items {
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") || (coverID != "" && item.ID == coverID) {
+			href = item.Href
+			break
+		}
+	}
+	if href == "" {
+		for _, item := range pkg.Items {
+			if strings.HasPrefix(item.MediaType, "image/") &&
+				(strings.Contains(strings.ToLower(item.ID), "cover") || strings.Contains(strings.ToLower(item.Href), "cover")) {
+				href = item.Href
+				break
+			}
+		}
+	}
+	if href == "" {
+		return nil, ErrNoCover
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+
+	rc, err := zr.Open(path.Join(path.Dir(opfPath), href))
+	if err != nil {
+		return nil, ErrNoCover
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func readXML(zr *zip.Reader, name string, v any) error {
+	rc, err := zr.Open(name)
+	if err != nil {
+		return ErrNoCover
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// Resize scales src down to width, keeping its aspect ratio, by averaging
+// the source pixels each destination pixel covers. Images already narrower
+// than width are returned unchanged.
+func Resize(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width {
+		return src
+	}
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		sy0 := b.Min.Y + y*b.Dy()/height
+		sy1 := max(sy0+1, b.Min.Y+(y+1)*b.Dy()/height)
+		for x := range width {
+			sx0 := b.Min.X + x*b.Dx()/width
+			sx1 := max(sx0+1, b.Min.X+(x+1)*b.Dx()/width)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// Cache stores generated covers on disk, keyed by the cover source's path,
+// size and modification time, so a changed cover is regenerated. The most
+// used are also kept in memory, as a library page shows dozens at once.
+//
+// The covers on disk are in the workspace's scratch space, so they are
+// encrypted like the books they come from; the cache goes through the full
+// workspace, as that space is locked to clients.
+type Cache struct {
+	dir string
+	mem *lru.Cache
+}
+
+// memoryCacheSize holds a few hundred thumbnails.
+const memoryCacheSize = 32 << 20
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir, mem: lru.New(memoryCacheSize)}
+}
+
+// Invalidate drops the covers made from the file at p from memory, when it
+// is known to have changed. Those on disk are keyed by its modification
+// time, so they are never served again.
+func (c *Cache) Invalidate(p string) {
+	c.mem.Invalidate(p)
+}
+
+// MemoryStats reports how well the in-memory cache is doing.
+func (c *Cache) MemoryStats() lru.Stats {
+	return c.mem.Stats()
+}
+
+// CacheDir is where covers are cached in the workspace.
+const CacheDir = workspace.ScratchDir + "/covers"
+
+// Get returns the cover of the book at p in the named size as a JPEG, or the
+// source image as is for Original.
+func (c *Cache) Get(ws *workspace.Workspace, p, size string) ([]byte, error) {
+	width, ok := Sizes[size]
+	if !ok && size != Original {
+		return nil, fmt.Errorf("unknown cover size %q", size)
+	}
+
+	info, err := ws.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNoCover
+	}
+	if size == Original {
+		return Find(ws, p)
+	}
+
+	srcPath, srcInfo, err := locate(ws, p)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(strings.Join([]string{
+		srcPath,
+		size,
+		strconv.FormatInt(srcInfo.ModTime().UnixNano(), 10),
+		strconv.FormatInt(srcInfo.Size(), 10),
+	}, "\x00")))
+	name := hex.EncodeToString(key[:])
+	if data, ok := c.mem.Get(name); ok {
+		return data, nil
+	}
+	cached := c.dir + "/" + name + ".jpg"
+	if data, err := ws.Full().ReadFile(cached); err == nil {
+		// Dated by last use, so Prune keeps the covers still shown. Covers
+		// served from memory are dated when they were loaded, which is
+		// close enough for a limit of months.
+		ws.Full().Chtimes(cached, time.Now())
+		c.mem.Add(name, srcPath, data)
+		return data, nil
+	}
+
+	src, err := Find(ws, p)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("decoding cover: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxCoverPixels {
+		return nil, ErrCoverTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("decoding cover: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, Resize(img, width), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+
+	// A failed cache write only costs a regeneration next time.
+	c.store(ws.Full(), cached, buf.Bytes())
+	c.mem.Add(name, srcPath, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// store writes atomically so concurrent readers never see a partial cover.
+func (c *Cache) store(ws *workspace.Workspace, name string, data []byte) {
+	if err := ws.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	ws.WriteStream(name, bytes.NewReader(data), 0o600)
+}
+
+// Prune removes the covers not used within maxAge, such as those of changed
+// or deleted books. It returns how many files it removed and their total
+// size.
+func (c *Cache) Prune(ws *workspace.Workspace, maxAge time.Duration, now time.Time) (int, int64, error) {
+	ws = ws.Full()
+	entries, err := ws.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	removed, freed := 0, int64(0)
+	var errs []error
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || now.Sub(info.ModTime()) <= maxAge {
+			continue
+		}
+		if err := ws.Remove(c.dir + "/" + e.Name()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+		freed += info.Size()
+	}
+	return removed, freed, errors.Join(errs...)
+}
+
+// Usage returns the total size of the cached covers.
+func (c *Cache) Usage(ws *workspace.Workspace) (int64, error) {
+	entries, err := ws.Full().ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
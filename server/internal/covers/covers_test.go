@@ -0,0 +1,229 @@
+package covers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/covers"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func pngImage(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func epub(t *testing.T, opf string, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	add("META-INF/container.xml", []byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`))
+	add("OEBPS/content.opf", []byte(opf))
+	for name, data := range files {
+		add(name, data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFind(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := pngImage(t, 4, 6, color.RGBA{255, 0, 0, 255})
+
+	check := func(t *testing.T, name string, content []byte, want []byte, wantErr error) {
+		t.Helper()
+		if err := ws.WriteFile(name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := covers.Find(ws, name)
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("err = %v, want %v", err, wantErr)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got %d bytes, want %d", len(got), len(want))
+		}
+	}
+
+	t.Run("epub3 cover-image", func(t *testing.T) {
+		check(t, "epub3.epub", epub(t, `<package xmlns="http://www.idpf.org/2007/opf"><manifest>
+  <item id="img" href="images/front%20page.png" media-type="image/png" properties="cover-image"/>
+</manifest></package>`, map[string][]byte{"OEBPS/images/front page.png": red}), red, nil)
+	})
+
+	t.Run("epub2 meta cover", func(t *testing.T) {
+		check(t, "epub2.epub", epub(t, `<package xmlns="http://www.idpf.org/2007/opf">
+<metadata><meta name="cover" content="c1"/></metadata>
+<manifest><item id="c1" href="front.png" media-type="image/png"/></manifest></package>`,
+			map[string][]byte{"OEBPS/front.png": red}), red, nil)
+	})
+
+	t.Run("epub without cover", func(t *testing.T) {
+		check(t, "bare.epub", epub(t, `<package><manifest/></package>`, nil), nil, covers.ErrNoCover)
+	})
+
+	t.Run("pdf without sidecar", func(t *testing.T) {
+		check(t, "paper.pdf", []byte("%PDF"), nil, covers.ErrNoCover)
+	})
+
+	t.Run("sidecar cover wins", func(t *testing.T) {
+		if err := ws.MkdirAll("book", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		sidecar := []byte("sidecar")
+		if err := ws.WriteFile("book/cover.jpg", sidecar, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		check(t, "book/paper.pdf", []byte("%PDF"), sidecar, nil)
+	})
+}
+
+func TestResize(t *testing.T) {
+	check := func(t *testing.T, w, h, width, wantW, wantH int) {
+		t.Helper()
+		src := image.NewRGBA(image.Rect(0, 0, w, h))
+		b := covers.Resize(src, width).Bounds()
+		if b.Dx() != wantW || b.Dy() != wantH {
+			t.Fatalf("Resize(%dx%d, %d) = %dx%d, want %dx%d", w, h, width, b.Dx(), b.Dy(), wantW, wantH)
+		}
+	}
+
+	check(t, 600, 900, 300, 300, 450)
+	check(t, 1000, 10, 160, 160, 1)
+	check(t, 100, 150, 320, 100, 150)
+}
+
+func TestCache(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := covers.NewCache(covers.CacheDir)
+	if err := ws.MkdirAll("book", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("book/novel.pdf", []byte("%PDF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, wantWidth int, wantColor color.RGBA) {
+		t.Helper()
+		data, err := cache.Get(ws, "book/novel.pdf", "small")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != wantWidth {
+			t.Fatalf("width = %d, want %d", img.Bounds().Dx(), wantWidth)
+		}
+		r, g, b, _ := img.At(0, 0).RGBA()
+		// JPEG is lossy, so only compare the dominant channel.
+		if (r>>8 > 128) != (wantColor.R > 128) || (b>>8 > 128) != (wantColor.B > 128) || g>>8 > 128 {
+			t.Fatalf("color = %d,%d,%d, want %v", r>>8, g>>8, b>>8, wantColor)
+		}
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	if err := ws.WriteFile("book/cover.png", pngImage(t, 400, 600, red), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	check(t, 160, red)
+	check(t, 160, red)
+	if stats := cache.MemoryStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("MemoryStats = %+v, want the second read from memory", stats)
+	}
+
+	t.Run("huge covers are not decoded", func(t *testing.T) {
+		if err := ws.MkdirAll("bomb", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile("bomb/book.pdf", []byte("%PDF"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// A GIF header claiming 65535x65535 pixels, with no image data.
+		gif := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00")
+		if err := ws.WriteFile("bomb/cover.jpg", gif, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cache.Get(ws, "bomb/book.pdf", "small"); !errors.Is(err, covers.ErrCoverTooLarge) {
+			t.Fatalf("err = %v, want ErrCoverTooLarge", err)
+		}
+	})
+
+	t.Run("changed cover is regenerated", func(t *testing.T) {
+		blue := color.RGBA{0, 0, 255, 255}
+		if err := ws.WriteFile("book/cover.png", pngImage(t, 400, 600, blue), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		abs, err := ws.Resolve("book/cover.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(abs, later, later); err != nil {
+			t.Fatal(err)
+		}
+		check(t, 160, blue)
+	})
+
+	t.Run("invalidated covers are dropped from memory", func(t *testing.T) {
+		cache.Invalidate("book/cover.png")
+		if stats := cache.MemoryStats(); stats.Entries != 0 || stats.Bytes != 0 {
+			t.Fatalf("MemoryStats = %+v, want empty", stats)
+		}
+		check(t, 160, color.RGBA{0, 0, 255, 255})
+	})
+
+	t.Run("unused covers are pruned", func(t *testing.T) {
+		if removed, _, err := cache.Prune(ws, time.Hour, time.Now()); err != nil || removed != 0 {
+			t.Fatalf("Prune of fresh covers = %d, %v", removed, err)
+		}
+		// The red cover was replaced and is never used again.
+		removed, freed, err := cache.Prune(ws, time.Hour, time.Now().Add(2*time.Hour))
+		if err != nil || removed != 2 || freed == 0 {
+			t.Fatalf("Prune = %d, %d, %v; want both covers", removed, freed, err)
+		}
+		check(t, 160, color.RGBA{0, 0, 255, 255})
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		if _, err := cache.Get(ws, "book/novel.pdf", "huge"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// Package digest compiles the notes changed over a period, such as a week
+// of journal entries, into one note to read in a sitting.
+//
+// A digest is markdown, like every other note, so the UI renders it and it
+// can be read anywhere the workspace is synced.
+package digest
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type Options struct {
+	Title string
+	// Folder and Tag narrow the notes included; either may be empty.
+	Folder string
+	Tag    string
+	// Since is how far back notes were changed.
+	Since time.Time
+	// Exclude is left out, so earlier digests aren't compiled again.
+	Exclude string
+}
+
+type entry struct {
+	note    notes.Note
+	body    string
+	modTime time.Time
+}
+
+// Build returns the digest and the paths of the notes in it, oldest first.
+// With no notes to include, it returns no digest.
+func Build(ws *workspace.Workspace, opts Options) (string, []string, error) {
+	all, err := ws.WalkFiles()
+	if err != nil {
+		return "", nil, err
+	}
+	var entries []entry
+	for _, e := range all {
+		if e.IsDir || !notes.IsNote(e.Path) || !within(e.Path, opts.Folder) || (opts.Exclude != "" && within(e.Path, opts.Exclude)) {
+			continue
+		}
+		info, err := ws.Stat(e.Path)
+		if err != nil || info.ModTime().Before(opts.Since) {
+			continue
+		}
+		content, kind, err := textfile.Read(ws, e.Path)
+		if err != nil || kind == textfile.Binary {
+			continue
+		}
+		if opts.Tag != "" && !slices.ContainsFunc(tags.Extract(content), func(t string) bool { return tags.Matches(t, opts.Tag) }) {
+			continue
+		}
+		entries = append(entries, entry{notes.Parse(e.Path, content), frontmatter.Parse(content).Body, info.ModTime()})
+	}
+	if len(entries) == 0 {
+		return "", nil, nil
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Or(a.modTime.Compare(b.modTime), strings.Compare(a.note.Path, b.note.Path))
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", opts.Title)
+	included := make([]string, 0, len(entries))
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n## %s\n\n_[[%s]], %s_\n\n", e.note.Title, strings.TrimSuffix(e.note.Path, path.Ext(e.note.Path)), e.modTime.Format("Mon 2 Jan 2006 15:04"))
+		b.WriteString(Nest(e.body, e.note.Title))
+		included = append(included, e.note.Path)
+	}
+	return b.String(), included, nil
+}
+
+func within(p, folder string) bool {
+	folder = strings.Trim(folder, "/")
+	return folder == "" || folder == "." || p == folder || strings.HasPrefix(p, folder+"/")
+}
+
+// Nest pushes the headings of a note's body two levels down, below the
+// digest's or document's title and the note's own heading, and drops a leading heading
+// that only repeats the note's title. Headings inside code blocks are
+// left alone.
+func Nest(body, title string) string {
+	var b strings.Builder
+	inCode, first := false, true
+	for line := range strings.Lines(body) {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		if !inCode && strings.HasPrefix(trimmed, "#") {
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			if level <= 6 && (len(trimmed) == level || trimmed[level] == ' ') {
+				if first && level == 1 && strings.TrimSpace(trimmed[1:]) == title {
+					first = false
+					continue
+				}
+				line = strings.Repeat("#", min(level+2, 6)) + line[level:]
+			}
+		}
+		if strings.TrimSpace(line) != "" {
+			first = false
+		}
+		b.WriteString(line)
+	}
+	s := strings.TrimSpace(b.String())
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
@@ -0,0 +1,75 @@
+package digest_test
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/digest"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestBuild(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for p, note := range map[string]struct {
+		content string
+		age     time.Duration
+	}{
+		"journal/monday.md":   {"---\ntitle: Monday\n---\n# Monday\n\nRan.\n\n## Later\n\n```\n# not a heading\n```\n", 3 * 24 * time.Hour},
+		"journal/tuesday.md":  {"# Tuesday\n\nRead #books/fiction.\n", 2 * 24 * time.Hour},
+		"journal/old.md":      {"# Old\n", 30 * 24 * time.Hour},
+		"work/plan.md":        {"# Plan\n\n#books\n", time.Hour},
+		"Digests/earlier.md":  {"# Digest\n", time.Hour},
+		"journal/picture.png": {"png", time.Hour},
+	} {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(note.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.Chtimes(p, now.Add(-note.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	week := now.AddDate(0, 0, -7)
+
+	got, included, err := digest.Build(ws, digest.Options{Title: "Week", Folder: "journal", Since: week})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(included, []string{"journal/monday.md", "journal/tuesday.md"}) {
+		t.Errorf("included = %v, want this week's journal, oldest first", included)
+	}
+	for _, want := range []string{"# Week\n", "\n## Monday\n\n_[[journal/monday]], ", "Ran.\n\n#### Later\n", "# not a heading", "\n## Tuesday\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("digest lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "### Monday") || strings.Count(got, "Monday") != 1 {
+		t.Errorf("repeated title heading kept:\n%s", got)
+	}
+
+	t.Run("by tag", func(t *testing.T) {
+		_, included, err := digest.Build(ws, digest.Options{Title: "Books", Tag: "books", Since: week, Exclude: "Digests"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(included, []string{"journal/tuesday.md", "work/plan.md"}) {
+			t.Errorf("included = %v", included)
+		}
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		got, included, err := digest.Build(ws, digest.Options{Title: "Empty", Folder: "journal", Since: now})
+		if err != nil || got != "" || included != nil {
+			t.Errorf("Build = %q, %v, %v; want no digest", got, included, err)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+// Package document compiles chosen notes, such as a research folder, into
+// one markdown document to share: a title, a table of contents and each
+// note under its own heading, with the notes and images it embeds written
+// out in place.
+//
+// Like a digest, the document is markdown, which HTML renders as a page of
+// its own. The server renders no PDF; a browser can print the page to one.
+package document
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/shrik450/wisdom/internal/digest"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/reorganize"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// maxEmbedDepth bounds embeds within embeds.
+const maxEmbedDepth = 3
+
+var ErrNoNotes = errors.New("no notes to compile")
+
+// embed matches ![[name]], ![[name#heading]] and ![[name|size]] on a line
+// of its own, the way a note is embedded.
+var embed = regexp.MustCompile(`^\s*!\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]\s*$`)
+
+type Options struct {
+	Title string
+	// Output is where the document will be saved, which its relative links
+	// are written from. A document that is only downloaded is written as
+	// if at the workspace root.
+	Output string
+}
+
+type compiler struct {
+	ws     *workspace.Workspace
+	all    []notes.Note
+	files  map[string][]string
+	output string
+}
+
+// Build compiles the notes at paths, in that order, using all to resolve
+// the notes they embed.
+func Build(ws *workspace.Workspace, all []notes.Note, paths []string, opts Options) (string, error) {
+	if len(paths) == 0 {
+		return "", ErrNoNotes
+	}
+	c := &compiler{ws: ws, all: all, output: opts.Output}
+	if c.output == "" {
+		c.output = "document.md"
+	}
+
+	type section struct {
+		title, anchor, body string
+	}
+	var sections []section
+	anchors := map[string]int{}
+	for _, p := range paths {
+		content, kind, err := textfile.Read(ws, p)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", p, err)
+		}
+		if kind == textfile.Binary {
+			return "", fmt.Errorf("%s: not a note", p)
+		}
+		n := notes.Parse(p, content)
+		body, err := c.resolve(p, content, map[string]bool{p: true}, 0)
+		if err != nil {
+			return "", err
+		}
+		anchor := slug(n.Title)
+		if i := anchors[anchor]; i > 0 {
+			anchors[anchor]++
+			anchor = fmt.Sprintf("%s-%d", anchor, i)
+		} else {
+			anchors[anchor] = 1
+		}
+		sections = append(sections, section{n.Title, anchor, digest.Nest(body, n.Title)})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n## Contents\n\n", opts.Title)
+	for _, s := range sections {
+		fmt.Fprintf(&b, "- [%s](#%s)\n", s.title, s.anchor)
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\n## %s\n\n%s", s.title, s.body)
+	}
+	return b.String(), nil
+}
+
+// resolve returns the body of the note at p, with its links rebased onto
+// the output and the notes and files it embeds written out. seen holds the
+// notes being embedded, so a cycle ends in a link instead.
+func (c *compiler) resolve(p, content string, seen map[string]bool, depth int) (string, error) {
+	body := reorganize.Rebase(frontmatter.Parse(content).Body, p, c.output)
+	var b strings.Builder
+	for line := range strings.Lines(body) {
+		m := embed.FindStringSubmatch(line)
+		if m == nil {
+			b.WriteString(line)
+			continue
+		}
+		name := strings.TrimSpace(m[1])
+		if path.Ext(name) != "" && !notes.IsNote(name) {
+			if file, ok := c.file(name); ok {
+				fmt.Fprintf(&b, "![%s](%s)\n", path.Base(file), link(c.output, file))
+				continue
+			}
+			b.WriteString(line)
+			continue
+		}
+		matches := notes.Resolve(c.all, name)
+		if len(matches) != 1 || seen[matches[0].Path] || depth >= maxEmbedDepth {
+			b.WriteString(line)
+			continue
+		}
+		target := matches[0].Path
+		embedded, _, err := textfile.Read(c.ws, target)
+		if err != nil {
+			b.WriteString(line)
+			continue
+		}
+		seen[target] = true
+		inner, err := c.resolve(target, embedded, seen, depth+1)
+		delete(seen, target)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(strings.TrimRight(inner, "\n") + "\n")
+	}
+	return b.String(), nil
+}
+
+// file finds an embedded file by name, the way wiki embeds resolve: a
+// workspace path, or else the one file with that name.
+func (c *compiler) file(name string) (string, bool) {
+	if _, err := c.ws.Stat(name); err == nil {
+		return name, true
+	}
+	if c.files == nil {
+		c.files = map[string][]string{}
+		entries, err := c.ws.WalkFiles()
+		if err != nil {
+			return "", false
+		}
+		for _, e := range entries {
+			if !e.IsDir {
+				c.files[path.Base(e.Path)] = append(c.files[path.Base(e.Path)], e.Path)
+			}
+		}
+	}
+	if found := c.files[path.Base(name)]; len(found) == 1 {
+		return found[0], true
+	}
+	return "", false
+}
+
+// link is the relative link from a note at from to the file at to.
+func link(from, to string) string {
+	rel, err := filepath.Rel(path.Dir(from), to)
+	if err != nil {
+		rel = "/" + to
+	}
+	return (&url.URL{Path: filepath.ToSlash(rel)}).EscapedPath()
+}
+
+// slug is the anchor markdown renderers give a heading: lower case, spaces
+// as hyphens and punctuation dropped.
+func slug(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package document_test
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/document"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestBuild(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"research/intro.md":       "---\ntitle: Intro\n---\n# Intro\n\nSee [methods](methods.md).\n\n![[Definitions]]\n",
+		"research/methods.md":     "# Methods\n\n## Setup\n\n![[chart.png]]\n",
+		"research/definitions.md": "# Definitions\n\nA *term*, as in the ![[Intro]]\n\n![[Intro]]\n",
+		"research/img/chart.png":  "png",
+	}
+	var all []notes.Note
+	for p, content := range files {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if notes.IsNote(p) {
+			all = append(all, notes.Parse(p, content))
+		}
+	}
+
+	got, err := document.Build(ws, all, []string{"research/intro.md", "research/methods.md"}, document.Options{
+		Title:  "Field Notes",
+		Output: "shared/field-notes.md",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Field Notes
+
+## Contents
+
+- [Intro](#intro)
+- [Methods](#methods)
+
+## Intro
+
+See [methods](../research/methods.md).
+
+### Definitions
+
+A *term*, as in the ![[Intro]]
+
+![[Intro]]
+
+## Methods
+
+#### Setup
+
+![chart.png](../research/img/chart.png)
+`
+	if got != want {
+		t.Errorf("document =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := document.Build(ws, all, nil, document.Options{Title: "Empty"}); !errors.Is(err, document.ErrNoNotes) {
+		t.Errorf("no notes: err = %v, want ErrNoNotes", err)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := document.HTML("Notes & Links", `# Notes & Links
+
+## Contents
+
+- [Intro](#intro)
+- [Intro](#intro-1)
+
+## Intro
+
+Some *emphasis*, **strong**, `+"`<code>`"+` and a snake_case name.
+A [link](../a%20b.md "A") and ![chart](img/chart.png).
+
+[bad](javascript:alert(1)) <https://example.com> <b>raw</b>
+
+## Intro
+
+> quoted
+> text
+
+1. one
+2. two
+   - nested
+
+`+"```go\nif a < b {}\n```"+`
+`)
+	for _, want := range []string{
+		"<title>Notes &amp; Links</title>",
+		`<h1 id="notes--links">Notes &amp; Links</h1>`,
+		"<ul>\n<li><a href=\"#intro\">Intro</a></li>\n<li><a href=\"#intro-1\">Intro</a></li>\n</ul>",
+		`<h2 id="intro">Intro</h2>`,
+		`<h2 id="intro-1">Intro</h2>`,
+		"<p>Some <em>emphasis</em>, <strong>strong</strong>, <code>&lt;code&gt;</code> and a snake_case name.\n" +
+			`A <a href="../a%20b.md" title="A">link</a> and <img src="img/chart.png" alt="chart">.</p>`,
+		`<p>bad <a href="https://example.com">https://example.com</a> &lt;b&gt;raw&lt;/b&gt;</p>`,
+		"<blockquote>\n<p>quoted\ntext</p>\n</blockquote>",
+		"<ol>\n<li>one</li>\n<li>two<ul>\n<li>nested</li>\n</ul>\n</li>\n</ol>",
+		"<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML missing\n%s\nin\n%s", want, got)
+		}
+	}
+}
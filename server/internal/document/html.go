@@ -0,0 +1,484 @@
+package document
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fence      = regexp.MustCompile("^( {0,3})(```+|~~~+)[ \t]*([^`\\s]*)")
+	rule       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listItem   = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])(?:[ \t]+|$)`)
+	autolink   = regexp.MustCompile(`^<((?:https?|mailto):[^\s<>]+)>`)
+)
+
+// escapable are the characters a backslash escapes.
+const escapable = "\\`*_{}[]()#+-.!|<>\"'~"
+
+// HTML renders a document as a page of its own. It covers the markdown
+// documents are written in: headings, whose ids are the anchors the table
+// of contents links to, paragraphs, lists, quotes, code, rules, links,
+// images and emphasis. Anything else, such as a table, is left as text,
+// and HTML in the notes is escaped rather than passed through.
+func HTML(title, markdown string) string {
+	r := &renderer{ids: map[string]int{}}
+	r.blocks(strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n"))
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>body{max-width:42rem;margin:2rem auto;padding:0 1rem;font-family:system-ui,sans-serif;line-height:1.5}img{max-width:100%%}pre{overflow-x:auto}blockquote{margin-left:0;padding-left:1rem;border-left:3px solid #ccc}</style>
+</head>
+<body>
+%s</body>
+</html>
+`, html.EscapeString(title), r.b.String())
+}
+
+type renderer struct {
+	b strings.Builder
+	// ids counts the headings given each id, so a repeated one is
+	// numbered the way markdown renderers number it.
+	ids map[string]int
+	// tight leaves the paragraphs of a list item without <p>.
+	tight bool
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indent is the width of line's leading whitespace, a tab counting as 4.
+func indent(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// dedent removes up to n columns of leading whitespace from line.
+func dedent(line string, n int) string {
+	i, col := 0, 0
+	for i < len(line) && col < n {
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			col += 4
+		default:
+			return line[i:]
+		}
+		i++
+	}
+	return line[i:]
+}
+
+func isQuote(line string) bool {
+	return indent(line) < 4 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// startsBlock reports whether line begins a block other than a paragraph,
+// which ends a paragraph before it.
+func startsBlock(line string) bool {
+	return atxHeading.MatchString(line) || fence.MatchString(line) || rule.MatchString(line) ||
+		isQuote(line) || listItem.MatchString(line)
+}
+
+func (r *renderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fence.MatchString(line):
+			i = r.code(lines, i)
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			i++
+		case rule.MatchString(line):
+			r.b.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			var inner []string
+			for ; i < len(lines) && isQuote(lines[i]); i++ {
+				rest := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+				inner = append(inner, strings.TrimPrefix(rest, " "))
+			}
+			r.b.WriteString("<blockquote>\n")
+			tight := r.tight
+			r.tight = false
+			r.blocks(inner)
+			r.tight = tight
+			r.b.WriteString("</blockquote>\n")
+		case listItem.MatchString(line):
+			i = r.list(lines, i)
+		default:
+			para := []string{strings.TrimLeft(line, " \t")}
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]); i++ {
+				para = append(para, strings.TrimLeft(lines[i], " \t"))
+			}
+			text := r.inline(strings.TrimRight(strings.Join(para, "\n"), " \t"))
+			if r.tight {
+				r.b.WriteString(text)
+			} else {
+				r.b.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// code writes the fenced code block starting at lines[i], and returns the
+// index of the line after it.
+func (r *renderer) code(lines []string, i int) int {
+	m := fence.FindStringSubmatch(lines[i])
+	open, marker, lang := len(m[1]), m[2], m[3]
+	r.b.WriteString("<pre><code")
+	if lang != "" {
+		r.b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	r.b.WriteString(">")
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if rest := strings.TrimLeft(line, " "); indent(line) < 4 && strings.HasPrefix(rest, marker) &&
+			strings.Trim(rest, marker[:1]+" \t") == "" {
+			i++
+			break
+		}
+		r.b.WriteString(html.EscapeString(dedent(line, open)) + "\n")
+	}
+	r.b.WriteString("</code></pre>\n")
+	return i
+}
+
+func (r *renderer) heading(level int, text string) {
+	id := slug(plain(text))
+	if n := r.ids[id]; n > 0 {
+		r.ids[id]++
+		id = fmt.Sprintf("%s-%d", id, n)
+	} else {
+		r.ids[id] = 1
+	}
+	fmt.Fprintf(&r.b, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), r.inline(text), level)
+}
+
+// plain is the text of inline markdown, for a heading's id.
+func plain(text string) string {
+	return strings.NewReplacer("*", "", "_", "", "`", "", "[", "", "]", "").Replace(text)
+}
+
+// list writes the list starting at lines[i], with the items that follow it
+// at the same indent and of the same kind, and returns the index of the
+// line after it. An item holds the lines indented under it, which are
+// rendered as blocks of their own, so lists nest.
+func (r *renderer) list(lines []string, i int) int {
+	first := listItem.FindStringSubmatch(lines[i])
+	base, ordered := len(first[1]), first[2][0] >= '0' && first[2][0] <= '9'
+	if !ordered {
+		r.b.WriteString("<ul>\n")
+	} else if start, _ := strconv.Atoi(first[2][:len(first[2])-1]); start != 1 {
+		fmt.Fprintf(&r.b, "<ol start=\"%d\">\n", start)
+	} else {
+		r.b.WriteString("<ol>\n")
+	}
+
+	for i < len(lines) {
+		m := listItem.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != base || (m[2][0] >= '0' && m[2][0] <= '9') != ordered {
+			break
+		}
+		width := len(m[0])
+		content := []string{lines[i][width:]}
+		loose := false
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j == len(lines) || indent(lines[j]) < width {
+					break
+				}
+				content, loose = append(content, ""), true
+				continue
+			}
+			// Lists nested under an item are often indented less than its
+			// text, such as by two spaces under "1.".
+			if indent(line) >= width || (indent(line) > base && listItem.MatchString(line)) {
+				content = append(content, dedent(line, width))
+				continue
+			}
+			if startsBlock(line) {
+				break
+			}
+			// A paragraph's lines may carry on without the indent.
+			content = append(content, strings.TrimLeft(line, " \t"))
+		}
+
+		r.b.WriteString("<li>")
+		tight := r.tight
+		r.tight = !loose
+		r.blocks(content)
+		r.tight = tight
+		r.b.WriteString("</li>\n")
+
+		// Blank lines between items don't end the list.
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j < len(lines) && listItem.MatchString(lines[j]) {
+			i = j
+		}
+	}
+
+	if ordered {
+		r.b.WriteString("</ol>\n")
+	} else {
+		r.b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// inline renders the spans of a paragraph or heading.
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+		case c == '`':
+			n := run(s[i:], '`')
+			if end := closingRun(s[i+n:], n); end >= 0 {
+				code := s[i+n : i+n+end]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(strings.ReplaceAll(code, "\n", " ")) + "</code>")
+				i += n + end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+			continue
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, title, end, ok := linkAt(s, i+1); ok {
+				if src := safeURL(dest); src != "" {
+					fmt.Fprintf(&b, `<img src="%s" alt="%s"`, html.EscapeString(src), html.EscapeString(plain(text)))
+					if title != "" {
+						fmt.Fprintf(&b, ` title="%s"`, html.EscapeString(title))
+					}
+					b.WriteString(">")
+				} else {
+					b.WriteString(html.EscapeString(plain(text)))
+				}
+				i = end
+				continue
+			}
+		case c == '[':
+			if text, dest, title, end, ok := linkAt(s, i); ok {
+				if href := safeURL(dest); href != "" {
+					fmt.Fprintf(&b, `<a href="%s"`, html.EscapeString(href))
+					if title != "" {
+						fmt.Fprintf(&b, ` title="%s"`, html.EscapeString(title))
+					}
+					b.WriteString(">" + r.inline(text) + "</a>")
+				} else {
+					b.WriteString(r.inline(text))
+				}
+				i = end
+				continue
+			}
+		case c == '<':
+			if m := autolink.FindStringSubmatch(s[i:]); m != nil {
+				fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(m[1]), html.EscapeString(m[1]))
+				i += len(m[0])
+				continue
+			}
+		case c == '*' || c == '_':
+			if out, end, ok := r.emphasis(s, i); ok {
+				b.WriteString(out)
+				i = end
+				continue
+			}
+			n := run(s[i:], c)
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == ' ' && strings.HasPrefix(s[i:], "  \n"):
+			b.WriteString("<br>\n")
+			i += 3
+			continue
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// run is the number of times c repeats at the start of s.
+func run(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// closingRun is where a run of exactly n backticks starts in s, or -1.
+func closingRun(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		m := run(s[i:], '`')
+		if m == n {
+			return i
+		}
+		i += m
+	}
+	return -1
+}
+
+// emphasis renders the emphasis or strong emphasis opened at s[i], if it
+// is closed: its content mustn't start or end with a space, and an
+// underscore only counts at a word's edges.
+func (r *renderer) emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0, false
+	}
+	for _, n := range []int{2, 1} {
+		if run(s[i:], c) < n {
+			continue
+		}
+		delim := s[i : i+n]
+		start := i + n
+		if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+			continue
+		}
+		for j := start + 1; j+n <= len(s); j++ {
+			if s[j:j+n] != delim || s[j-1] == ' ' || s[j-1] == '\n' || s[j-1] == '\\' {
+				continue
+			}
+			// A lone delimiter doesn't close inside a double one.
+			if n == 1 && j+1 < len(s) && s[j+1] == c {
+				j++
+				continue
+			}
+			if c == '_' && j+n < len(s) && isWordByte(s[j+n]) {
+				continue
+			}
+			tag := "em"
+			if n == 2 {
+				tag = "strong"
+			}
+			return "<" + tag + ">" + r.inline(s[start:j]) + "</" + tag + ">", j + n, true
+		}
+	}
+	return "", 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// linkAt parses the link [text](dest "title") whose bracket is at s[i],
+// returning the index after it.
+func linkAt(s string, i int) (text, dest, title string, end int, ok bool) {
+	depth := 0
+	j := i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j >= len(s)-1 || s[j+1] != '(' {
+		return "", "", "", 0, false
+	}
+	text = s[i+1 : j]
+
+	depth = 0
+	k := j + 1
+	for ; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\n':
+			return "", "", "", 0, false
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if k >= len(s) {
+		return "", "", "", 0, false
+	}
+	inside := strings.TrimSpace(s[j+2 : k])
+	if strings.HasPrefix(inside, "<") {
+		if close := strings.IndexByte(inside, '>'); close > 0 {
+			dest, inside = inside[1:close], strings.TrimSpace(inside[close+1:])
+		}
+	} else {
+		dest, inside, _ = strings.Cut(inside, " ")
+		inside = strings.TrimSpace(inside)
+	}
+	if len(inside) >= 2 && (inside[0] == '"' || inside[0] == '\'') && inside[len(inside)-1] == inside[0] {
+		title = inside[1 : len(inside)-1]
+	} else if inside != "" {
+		return "", "", "", 0, false
+	}
+	return text, dest, title, k + 1, true
+}
+
+// safeURL is dest if it is relative or a web or mail link, and otherwise
+// empty, so a link can't run script.
+func safeURL(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return dest
+	}
+	return ""
+}
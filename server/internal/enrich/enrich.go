@@ -0,0 +1,374 @@
+// Package enrich looks up book metadata in online catalogs to fill the gaps
+// in the frontmatter of book notes, such as those the Calibre importer
+// writes.
+//
+// Lookups only produce proposals. Nothing is written until the user has
+// reviewed the proposed changes and sent back the ones to keep.
+package enrich
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+)
+
+var (
+	ErrDisabled = errors.New("metadata enrichment is disabled")
+	ErrNotFound = errors.New("no matching book found")
+)
+
+const (
+	lookupTimeout = 15 * time.Second
+	maxCoverSize  = 10 << 20
+)
+
+// coverExts are the cover image types kept, by the extension they are
+// saved under; they are the ones covers.Find looks for.
+var coverExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Fields that enrichment may fill in. Cover is not a frontmatter field: its
+// value is an image URL to download next to the note.
+const (
+	FieldAuthors     = "authors"
+	FieldDescription = "description"
+	FieldPublished   = "published"
+	FieldISBN        = "isbn"
+	FieldCover       = "cover"
+)
+
+var Fields = []string{FieldAuthors, FieldDescription, FieldPublished, FieldISBN, FieldCover}
+
+type Query struct {
+	ISBN   string
+	Title  string
+	Author string
+}
+
+type Metadata struct {
+	Title       string
+	Authors     []string
+	Description string
+	Published   string
+	ISBN        string
+	CoverURL    string
+}
+
+type Provider interface {
+	Lookup(ctx context.Context, q Query) (*Metadata, error)
+	// Cover fetches the cover at a URL Lookup returned, with the same
+	// client and timeout, and returns the image and the extension to save
+	// it under.
+	Cover(ctx context.Context, rawURL string) ([]byte, string, error)
+}
+
+type Change struct {
+	Field string `json:"field"`
+	Value any    `json:"value"`
+}
+
+type unavailable struct {
+	err error
+}
+
+func (u unavailable) Lookup(context.Context, Query) (*Metadata, error) {
+	return nil, u.err
+}
+
+func (u unavailable) Cover(context.Context, string) ([]byte, string, error) {
+	return nil, "", u.err
+}
+
+// Enabled reports whether p looks anything up.
+func Enabled(p Provider) bool {
+	_, off := p.(unavailable)
+	return !off
+}
+
+// FromEnv returns the provider named by WISDOM_METADATA_PROVIDER, which is
+// either "openlibrary" or "googlebooks". Enrichment is opt-in: without the
+// variable, every lookup fails with ErrDisabled.
+func FromEnv() Provider {
+	client := &http.Client{Timeout: lookupTimeout}
+	switch name := os.Getenv("WISDOM_METADATA_PROVIDER"); name {
+	case "":
+		return unavailable{ErrDisabled}
+	case "openlibrary":
+		return &OpenLibrary{Client: client}
+	case "googlebooks":
+		return &GoogleBooks{Client: client, APIKey: os.Getenv("WISDOM_GOOGLE_BOOKS_API_KEY")}
+	default:
+		return unavailable{fmt.Errorf("%w: unknown provider %q", ErrDisabled, name)}
+	}
+}
+
+// QueryFor builds a lookup from what the note already knows about its book.
+func QueryFor(doc *frontmatter.Document) Query {
+	q := Query{ISBN: doc.String(FieldISBN), Title: doc.String("title")}
+	if authors := doc.List(FieldAuthors); len(authors) > 0 {
+		q.Author = authors[0]
+	}
+	return q
+}
+
+// Propose lists the fields md would fill in doc. Fields the note already has
+// are never overwritten. hasCover reports whether the book already has a
+// cover image.
+func Propose(doc *frontmatter.Document, md *Metadata, hasCover bool) []Change {
+	changes := []Change{}
+	if len(doc.List(FieldAuthors)) == 0 && len(md.Authors) > 0 {
+		changes = append(changes, Change{FieldAuthors, md.Authors})
+	}
+	for _, c := range []Change{
+		{FieldDescription, md.Description},
+		{FieldPublished, md.Published},
+		{FieldISBN, md.ISBN},
+	} {
+		if doc.String(c.Field) == "" && c.Value != "" {
+			changes = append(changes, c)
+		}
+	}
+	if !hasCover && md.CoverURL != "" {
+		changes = append(changes, Change{FieldCover, md.CoverURL})
+	}
+	return changes
+}
+
+// Apply sets the frontmatter fields of the reviewed changes. Cover changes
+// are skipped, as they are not part of the note.
+func Apply(doc *frontmatter.Document, changes []Change) error {
+	for _, c := range changes {
+		if !slices.Contains(Fields, c.Field) {
+			return fmt.Errorf("unknown field %q", c.Field)
+		}
+		if c.Field == FieldCover {
+			continue
+		}
+		if err := doc.Set(c.Field, c.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func getCover(ctx context.Context, client *http.Client, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching cover: %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ext, ok := coverExts[mediaType]
+	if !ok {
+		return nil, "", fmt.Errorf("cover is %q, not a JPEG or PNG image", mediaType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxCoverSize {
+		return nil, "", fmt.Errorf("cover exceeds %d bytes", maxCoverSize)
+	}
+	return data, ext, nil
+}
+
+// OpenLibrary looks books up in the Open Library search API.
+type OpenLibrary struct {
+	Client *http.Client
+	// BaseURL and CoversURL default to the public Open Library endpoints.
+	BaseURL   string
+	CoversURL string
+}
+
+func (o *OpenLibrary) Cover(ctx context.Context, rawURL string) ([]byte, string, error) {
+	return getCover(ctx, o.Client, rawURL)
+}
+
+func (o *OpenLibrary) Lookup(ctx context.Context, q Query) (*Metadata, error) {
+	base := cmp.Or(o.BaseURL, "https://openlibrary.org")
+	params := url.Values{"limit": {"1"}, "fields": {"key,title,author_name,first_publish_year,isbn,cover_i"}}
+	switch {
+	case q.ISBN != "":
+		params.Set("isbn", q.ISBN)
+	case q.Title != "":
+		params.Set("title", q.Title)
+		if q.Author != "" {
+			params.Set("author", q.Author)
+		}
+	default:
+		return nil, ErrNotFound
+	}
+
+	var result struct {
+		Docs []struct {
+			Key              string   `json:"key"`
+			Title            string   `json:"title"`
+			Authors          []string `json:"author_name"`
+			FirstPublishYear int      `json:"first_publish_year"`
+			ISBN             []string `json:"isbn"`
+			CoverID          int      `json:"cover_i"`
+		} `json:"docs"`
+	}
+	if err := getJSON(ctx, o.Client, base+"/search.json?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Docs) == 0 {
+		return nil, ErrNotFound
+	}
+	doc := result.Docs[0]
+
+	md := &Metadata{Title: doc.Title, Authors: doc.Authors, ISBN: q.ISBN}
+	if doc.FirstPublishYear > 0 {
+		md.Published = strconv.Itoa(doc.FirstPublishYear)
+	}
+	if md.ISBN == "" && len(doc.ISBN) > 0 {
+		md.ISBN = doc.ISBN[0]
+	}
+	if doc.CoverID > 0 {
+		md.CoverURL = fmt.Sprintf("%s/b/id/%d-L.jpg", cmp.Or(o.CoversURL, "https://covers.openlibrary.org"), doc.CoverID)
+	}
+
+	// Descriptions live on the work, not in search results. A book is still
+	// worth proposing without one.
+	if strings.HasPrefix(doc.Key, "/works/") {
+		var work struct {
+			Description json.RawMessage `json:"description"`
+		}
+		if err := getJSON(ctx, o.Client, base+doc.Key+".json", &work); err == nil {
+			md.Description = openLibraryText(work.Description)
+		}
+	}
+	return md, nil
+}
+
+// openLibraryText reads a text field, which Open Library stores either as a
+// string or as a typed {"value": ...} object.
+func openLibraryText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var typed struct {
+		Value string `json:"value"`
+	}
+	json.Unmarshal(raw, &typed)
+	return typed.Value
+}
+
+// GoogleBooks looks books up in the Google Books volumes API.
+type GoogleBooks struct {
+	Client *http.Client
+	// APIKey is optional; anonymous requests have a lower quota.
+	APIKey string
+	// BaseURL defaults to the public Google Books endpoint.
+	BaseURL string
+}
+
+func (g *GoogleBooks) Cover(ctx context.Context, rawURL string) ([]byte, string, error) {
+	return getCover(ctx, g.Client, rawURL)
+}
+
+func (g *GoogleBooks) Lookup(ctx context.Context, q Query) (*Metadata, error) {
+	var terms string
+	switch {
+	case q.ISBN != "":
+		terms = "isbn:" + q.ISBN
+	case q.Title != "":
+		terms = "intitle:" + q.Title
+		if q.Author != "" {
+			terms += " inauthor:" + q.Author
+		}
+	default:
+		return nil, ErrNotFound
+	}
+	params := url.Values{"q": {terms}, "maxResults": {"1"}}
+	if g.APIKey != "" {
+		params.Set("key", g.APIKey)
+	}
+
+	var result struct {
+		Items []struct {
+			VolumeInfo struct {
+				Title               string   `json:"title"`
+				Authors             []string `json:"authors"`
+				Description         string   `json:"description"`
+				PublishedDate       string   `json:"publishedDate"`
+				IndustryIdentifiers []struct {
+					Type       string `json:"type"`
+					Identifier string `json:"identifier"`
+				} `json:"industryIdentifiers"`
+				ImageLinks map[string]string `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	base := cmp.Or(g.BaseURL, "https://www.googleapis.com")
+	if err := getJSON(ctx, g.Client, base+"/books/v1/volumes?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrNotFound
+	}
+	info := result.Items[0].VolumeInfo
+
+	md := &Metadata{
+		Title:       info.Title,
+		Authors:     info.Authors,
+		Description: info.Description,
+		Published:   info.PublishedDate,
+		ISBN:        q.ISBN,
+	}
+	for _, id := range info.IndustryIdentifiers {
+		if md.ISBN == "" && (id.Type == "ISBN_13" || id.Type == "ISBN_10") {
+			md.ISBN = id.Identifier
+		}
+	}
+	for _, size := range []string{"extraLarge", "large", "medium", "thumbnail"} {
+		if link := info.ImageLinks[size]; link != "" {
+			md.CoverURL = strings.Replace(link, "http://", "https://", 1)
+			break
+		}
+	}
+	return md, nil
+}
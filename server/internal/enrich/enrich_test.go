@@ -0,0 +1,153 @@
+package enrich_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/enrich"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+)
+
+func TestOpenLibrary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/search.json" && r.URL.Query().Get("isbn") == "9780441013593":
+			w.Write([]byte(`{"docs":[{"key":"/works/OL1W","title":"Dune","author_name":["Frank Herbert"],
+				"first_publish_year":1965,"isbn":["0441013597"],"cover_i":42}]}`))
+		case r.URL.Path == "/search.json":
+			w.Write([]byte(`{"docs":[]}`))
+		case r.URL.Path == "/works/OL1W.json":
+			w.Write([]byte(`{"description":{"type":"/type/text","value":"Spice."}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ol := &enrich.OpenLibrary{Client: srv.Client(), BaseURL: srv.URL, CoversURL: "https://covers.test"}
+	md, err := ol.Lookup(context.Background(), enrich.Query{ISBN: "9780441013593"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &enrich.Metadata{
+		Title:       "Dune",
+		Authors:     []string{"Frank Herbert"},
+		Description: "Spice.",
+		Published:   "1965",
+		ISBN:        "9780441013593",
+		CoverURL:    "https://covers.test/b/id/42-L.jpg",
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("Lookup = %+v, want %+v", md, want)
+	}
+
+	if _, err := ol.Lookup(context.Background(), enrich.Query{Title: "Nothing"}); !errors.Is(err, enrich.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGoogleBooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "intitle:Dune inauthor:Frank Herbert" || r.URL.Query().Get("key") != "k" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"items":[{"volumeInfo":{"title":"Dune","authors":["Frank Herbert"],
+			"description":"Spice.","publishedDate":"1990-09-01",
+			"industryIdentifiers":[{"type":"OTHER","identifier":"x"},{"type":"ISBN_13","identifier":"9780441172719"}],
+			"imageLinks":{"thumbnail":"http://books.test/cover"}}}]}`))
+	}))
+	defer srv.Close()
+
+	gb := &enrich.GoogleBooks{Client: srv.Client(), BaseURL: srv.URL, APIKey: "k"}
+	md, err := gb.Lookup(context.Background(), enrich.Query{Title: "Dune", Author: "Frank Herbert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &enrich.Metadata{
+		Title:       "Dune",
+		Authors:     []string{"Frank Herbert"},
+		Description: "Spice.",
+		Published:   "1990-09-01",
+		ISBN:        "9780441172719",
+		CoverURL:    "https://books.test/cover",
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("Lookup = %+v, want %+v", md, want)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{"", "nonsense"} {
+		t.Setenv("WISDOM_METADATA_PROVIDER", name)
+		_, err := enrich.FromEnv().Lookup(context.Background(), enrich.Query{Title: "Dune"})
+		if !errors.Is(err, enrich.ErrDisabled) {
+			t.Errorf("provider %q: err = %v, want ErrDisabled", name, err)
+		}
+	}
+}
+
+func TestProposeAndApply(t *testing.T) {
+	doc := frontmatter.Parse("---\ntitle: Dune\ndescription: Mine.\n---\nNotes\n")
+	if q := enrich.QueryFor(doc); q != (enrich.Query{Title: "Dune"}) {
+		t.Errorf("QueryFor = %+v", q)
+	}
+
+	md := &enrich.Metadata{
+		Title:       "Dune",
+		Authors:     []string{"Frank Herbert"},
+		Description: "Theirs.",
+		Published:   "1965",
+		CoverURL:    "https://covers.test/1.jpg",
+	}
+	changes := enrich.Propose(doc, md, false)
+	want := []enrich.Change{
+		{Field: enrich.FieldAuthors, Value: []string{"Frank Herbert"}},
+		{Field: enrich.FieldPublished, Value: "1965"},
+		{Field: enrich.FieldCover, Value: "https://covers.test/1.jpg"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Propose = %+v, want %+v", changes, want)
+	}
+	if got := enrich.Propose(doc, md, true); len(got) != 2 {
+		t.Errorf("Propose with a cover = %+v", got)
+	}
+
+	if err := enrich.Apply(doc, changes); err != nil {
+		t.Fatal(err)
+	}
+	wantNote := "---\ntitle: Dune\ndescription: Mine.\nauthors: [\"Frank Herbert\"]\npublished: \"1965\"\n---\nNotes\n"
+	if got := doc.Render(); got != wantNote {
+		t.Errorf("note =\n%s\nwant\n%s", got, wantNote)
+	}
+
+	if err := enrich.Apply(doc, []enrich.Change{{Field: "title", Value: "x"}}); err == nil {
+		t.Error("expected error for a field enrichment doesn't own")
+	}
+}
+
+func TestCover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cover.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG"))
+		case "/cover.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg/>"))
+		}
+	}))
+	defer srv.Close()
+	ol := &enrich.OpenLibrary{Client: srv.Client()}
+
+	data, ext, err := ol.Cover(context.Background(), srv.URL+"/cover.png")
+	if err != nil || ext != ".png" || string(data) != "\x89PNG" {
+		t.Errorf("Cover(png) = %q, %q, %v", data, ext, err)
+	}
+	if _, _, err := ol.Cover(context.Background(), srv.URL+"/cover.svg"); err == nil {
+		t.Error("an SVG cover was accepted")
+	}
+}
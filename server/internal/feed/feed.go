@@ -0,0 +1,256 @@
+// Package feed publishes the notes in chosen folders, such as a blog
+// folder, as RSS and JSON Feed, so the workspace can double as the source
+// of a small blog or newsletter.
+//
+// Feeds are public: anything in a published folder is readable by whoever
+// has the feed's URL, without a token or signing in.
+package feed
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shrik450/wisdom/internal/baseurl"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// MaxItems is how many of the newest notes a feed has.
+const MaxItems = 50
+
+// summaryLength bounds summaries taken from a note's first paragraph.
+const summaryLength = 280
+
+type Config struct {
+	Title string
+	// Folders are the published folders.
+	Folders []string
+}
+
+// FromEnv reads the published folders from WISDOM_FEED_FOLDERS, a
+// comma-separated list, and the feed's title from WISDOM_FEED_TITLE. It
+// returns nil, with feeds off, if no folder is published.
+func FromEnv() *Config {
+	var folders []string
+	for f := range strings.SplitSeq(os.Getenv("WISDOM_FEED_FOLDERS"), ",") {
+		if f = strings.Trim(strings.TrimSpace(f), "/"); f != "" {
+			folders = append(folders, f)
+		}
+	}
+	if len(folders) == 0 {
+		return nil
+	}
+	return &Config{Title: cmp.Or(os.Getenv("WISDOM_FEED_TITLE"), "Wisdom"), Folders: folders}
+}
+
+type Item struct {
+	Path    string
+	Title   string
+	Summary string
+	// Content is the note's markdown, without its frontmatter.
+	Content string
+	Date    time.Time
+}
+
+// Items returns the newest notes in the published folders, newest first.
+// A note's date is its date property or, without one, when it was last
+// changed; notes marked draft: true are left out.
+func Items(ws *workspace.Workspace, folders []string) ([]Item, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, e := range entries {
+		if e.IsDir || !notes.IsNote(e.Path) || !slices.ContainsFunc(folders, func(f string) bool { return strings.HasPrefix(e.Path, f+"/") }) {
+			continue
+		}
+		content, kind, err := textfile.Read(ws, e.Path)
+		if err != nil || kind == textfile.Binary {
+			continue
+		}
+		doc := frontmatter.Parse(content)
+		if doc.String("draft") == "true" {
+			continue
+		}
+		item := Item{Path: e.Path, Title: notes.Parse(e.Path, content).Title, Content: strings.TrimSpace(doc.Body)}
+		item.Summary = cmp.Or(doc.String("summary"), doc.String("description"), firstParagraph(doc.Body))
+		if item.Date = parseDate(doc.String("date")); item.Date.IsZero() {
+			info, err := ws.Stat(e.Path)
+			if err != nil {
+				continue
+			}
+			item.Date = info.ModTime().UTC()
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b Item) int {
+		return cmp.Or(b.Date.Compare(a.Date), strings.Compare(a.Path, b.Path))
+	})
+	if len(items) > MaxItems {
+		items = items[:MaxItems]
+	}
+	return items, nil
+}
+
+func parseDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// firstParagraph is the first paragraph of body that isn't a heading,
+// cut to summaryLength.
+func firstParagraph(body string) string {
+	for para := range strings.SplitSeq(body, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" || strings.HasPrefix(para, "#") || strings.HasPrefix(para, "```") {
+			continue
+		}
+		para = strings.Join(strings.Fields(para), " ")
+		if utf8.RuneCountInString(para) > summaryLength {
+			para = string([]rune(para)[:summaryLength-1]) + "…"
+		}
+		return para
+	}
+	return ""
+}
+
+// noteURL is where the note at p opens in the app.
+func noteURL(base, p string) string {
+	return base + (&url.URL{Path: "/ws/" + p}).EscapedPath()
+}
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title         string    `xml:"title"`
+		Link          string    `xml:"link"`
+		Description   string    `xml:"description"`
+		LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+		Items         []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// RSS renders items as an RSS 2.0 feed, with links under base.
+func RSS(title, base string, items []Item) ([]byte, error) {
+	var feed rss
+	feed.Version = "2.0"
+	feed.Channel.Title, feed.Channel.Link, feed.Channel.Description = title, base+"/", title
+	if len(items) > 0 {
+		feed.Channel.LastBuildDate = items[0].Date.Format(time.RFC1123Z)
+	}
+	for _, it := range items {
+		link := noteURL(base, it.Path)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       it.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     it.Date.Format(time.RFC1123Z),
+			Description: it.Summary,
+		})
+	}
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	Summary       string `json:"summary,omitempty"`
+	ContentText   string `json:"content_text"`
+	DatePublished string `json:"date_published"`
+}
+
+// JSONFeed renders items as a JSON Feed 1.1, with the notes' markdown as
+// their text content.
+func JSONFeed(title, base string, items []Item) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       title,
+		HomePageURL: base + "/",
+		FeedURL:     base + "/feed.json",
+		Items:       []jsonFeedItem{},
+	}
+	for _, it := range items {
+		link := noteURL(base, it.Path)
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         it.Title,
+			Summary:       it.Summary,
+			ContentText:   it.Content,
+			DatePublished: it.Date.Format(time.RFC3339),
+		})
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+// Handler serves /feed.xml (RSS) and /feed.json (JSON Feed).
+func Handler(cfg Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		render, contentType := RSS, "application/rss+xml; charset=utf-8"
+		switch path.Base(r.URL.Path) {
+		case "feed.xml":
+		case "feed.json":
+			render, contentType = JSONFeed, "application/feed+json; charset=utf-8"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		items, err := Items(workspace.FromContext(r.Context()), cfg.Folders)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err := render(cfg.Title, baseurl.FromRequest(r), items)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,102 @@
+package feed_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/feed"
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestItems(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"blog/hello.md":    "---\ntitle: Hello\ndate: 2026-03-01\nsummary: The first post.\n---\nWelcome.\n",
+		"blog/second.md":   "---\ndate: 2026-04-02T09:30:00Z\n---\n# Second Post\n\nA longer   first\nparagraph.\n\nMore.\n",
+		"blog/draft.md":    "---\ndate: 2026-05-01\ndraft: true\n---\nNot yet.\n",
+		"blog/cover.png":   "\x89PNG",
+		"journal/diary.md": "---\ndate: 2026-06-01\n---\nPrivate.\n",
+	} {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := feed.Items(ws, []string{"blog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range items {
+		got = append(got, it.Date.Format(time.RFC3339)+" "+it.Title+": "+it.Summary)
+	}
+	want := []string{
+		"2026-04-02T09:30:00Z Second Post: A longer first paragraph.",
+		"2026-03-01T00:00:00Z Hello: The first post.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("items:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	srv := httptest.NewServer(middleware.WithWorkspace(feed.Handler(feed.Config{Title: "Blog", Folders: []string{"blog"}}), ws))
+	t.Cleanup(srv.Close)
+
+	t.Run("rss", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/feed.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/rss+xml") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		for _, want := range []string{
+			"<title>Blog</title>",
+			"<link>" + srv.URL + "/ws/blog/second.md</link>",
+			"<pubDate>Sun, 01 Mar 2026 00:00:00 +0000</pubDate>",
+			"<description>The first post.</description>",
+		} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("feed is missing %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/feed.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var got struct {
+			Version string
+			Items   []struct {
+				URL         string
+				ContentText string `json:"content_text"`
+			}
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != "https://jsonfeed.org/version/1.1" || len(got.Items) != 2 {
+			t.Fatalf("feed = %+v", got)
+		}
+		if got.Items[1].URL != srv.URL+"/ws/blog/hello.md" || got.Items[1].ContentText != "Welcome." {
+			t.Errorf("item = %+v", got.Items[1])
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Package filename checks file names against the workspace's naming policy,
+// so a workspace synced to another system, such as a case-insensitive
+// macOS or Windows filesystem, doesn't end up with names it can't hold.
+package filename
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// PolicyPath is where the policy lives:
+//
+//	{"normalize": true, "forbidden": "<>:\"|?*", "caseCollisions": true}
+//
+// A workspace without one has no policy: names are taken as they come.
+const PolicyPath = ".wisdom/filenames.json"
+
+var (
+	ErrInvalidPolicy = errors.New("invalid filename policy")
+	ErrForbidden     = errors.New("forbidden character in name")
+	ErrCaseCollision = errors.New("name differs only in case from an existing one")
+)
+
+type Policy struct {
+	// Normalize composes new names to Unicode NFC, as most systems other
+	// than macOS write them, so the same name isn't stored two ways.
+	Normalize bool `json:"normalize"`
+	// Forbidden are characters names may not contain.
+	Forbidden string `json:"forbidden"`
+	// CaseCollisions rejects a name that differs only in case from another
+	// in the same folder, which a case-insensitive filesystem can't hold.
+	CaseCollisions bool `json:"caseCollisions"`
+}
+
+var defaultPolicy = Policy{}
+
+// Load reads the policy.
+func Load(ws *workspace.Workspace) (Policy, error) {
+	data, err := ws.ReadFile(PolicyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultPolicy, nil
+	}
+	if err != nil {
+		return Policy{}, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Policy{}, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+	return p, nil
+}
+
+// Apply returns the name to create a file or folder at p under, normalized
+// if the policy asks, or an error if the name breaks the policy. Only the
+// new parts of p are checked, so existing folders with names that break
+// the policy can still be written to.
+func (pol Policy) Apply(ws *workspace.Workspace, p string) (string, error) {
+	if p == "." {
+		return p, nil
+	}
+	parent, name := path.Split(p)
+	parent = strings.TrimSuffix(parent, "/")
+	if parent == "" {
+		parent = "."
+	} else if _, err := ws.Stat(parent); errors.Is(err, fs.ErrNotExist) {
+		if parent, err = pol.Apply(ws, parent); err != nil {
+			return "", err
+		}
+	}
+	if pol.Normalize {
+		name = NFC(name)
+	}
+	if i := strings.IndexAny(name, pol.Forbidden); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(name[i:])
+		return "", fmt.Errorf("%w: %q in %q", ErrForbidden, r, name)
+	}
+	if pol.CaseCollisions {
+		entries, err := ws.ReadDir(parent)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		for _, e := range entries {
+			if e.Name() != name && strings.EqualFold(NFC(e.Name()), name) {
+				return "", fmt.Errorf("%w: %q and %q", ErrCaseCollision, name, e.Name())
+			}
+		}
+	}
+	return path.Join(parent, name), nil
+}
+
+// Violation is an existing file or folder whose name breaks the policy.
+type Violation struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+	Message string `json:"message"`
+}
+
+const (
+	ProblemNotNFC        = "not-nfc"
+	ProblemForbidden     = "forbidden"
+	ProblemCaseCollision = "case-collision"
+)
+
+// Check lists the names in the workspace that break the policy, by path.
+// Names that differ only in case are reported together, once per group.
+func (pol Policy) Check(ws *workspace.Workspace) ([]Violation, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	violations := []Violation{}
+	folded := map[string][]string{}
+	for _, e := range entries {
+		name := path.Base(e.Path)
+		if pol.Normalize && NFC(name) != name {
+			violations = append(violations, Violation{e.Path, ProblemNotNFC, "name is not in Unicode NFC form"})
+		}
+		if i := strings.IndexAny(name, pol.Forbidden); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(name[i:])
+			violations = append(violations, Violation{e.Path, ProblemForbidden, fmt.Sprintf("name contains %q", r)})
+		}
+		if pol.CaseCollisions {
+			key := path.Join(path.Dir(e.Path), strings.ToLower(NFC(name)))
+			folded[key] = append(folded[key], e.Path)
+		}
+	}
+	for _, paths := range folded {
+		if len(paths) < 2 {
+			continue
+		}
+		slices.Sort(paths)
+		for _, p := range paths {
+			others := slices.DeleteFunc(slices.Clone(paths), func(o string) bool { return o == p })
+			violations = append(violations, Violation{p, ProblemCaseCollision, "name differs only in case from " + strings.Join(others, ", ")})
+		}
+	}
+	slices.SortFunc(violations, func(a, b Violation) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path), strings.Compare(a.Problem, b.Problem))
+	})
+	return violations, nil
+}
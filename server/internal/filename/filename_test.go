@@ -0,0 +1,99 @@
+package filename_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/filename"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestNFC(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain.md", "plain.md"},
+		{"Cafe\u0301.md", "Caf\u00e9.md"},
+		{"Caf\u00e9.md", "Caf\u00e9.md"},
+		// Marks stack in canonical order: dot below, then circumflex.
+		{"Vie\u0323\u0302t.md", "Vi\u1ec7t.md"},
+		{"\u1112\u1161\u11ab.md", "\ud55c.md"},
+		// A mark with nothing to compose with is kept.
+		{"\u0301x.md", "\u0301x.md"},
+	}
+	for _, tt := range tests {
+		if got := filename.NFC(tt.in); got != tt.want {
+			t.Errorf("NFC(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(p, content string) {
+		t.Helper()
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	none, err := filename.Load(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := none.Apply(ws, "Cafe\u0301.md"); err != nil || got != "Cafe\u0301.md" {
+		t.Errorf("Apply without a policy = %q, %v; want the name as given", got, err)
+	}
+
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write(filename.PolicyPath, `{"normalize": true, "forbidden": ":?", "caseCollisions": true}`)
+	write("Notes.md", "")
+	write("notes.md", "")
+	write("a:b.md", "")
+	write("Cafe\u0301.md", "")
+
+	policy, err := filename.Load(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		if got, err := policy.Apply(ws, "new/Cafe\u0301.md"); err != nil || got != "new/Caf\u00e9.md" {
+			t.Errorf("Apply = %q, %v; want normalized name", got, err)
+		}
+		if _, err := policy.Apply(ws, "why?.md"); !errors.Is(err, filename.ErrForbidden) {
+			t.Errorf("Apply(why?.md) error = %v, want ErrForbidden", err)
+		}
+		if _, err := policy.Apply(ws, "caf\u00e9.MD"); !errors.Is(err, filename.ErrCaseCollision) {
+			t.Errorf("Apply(caf\u00e9.MD) error = %v, want ErrCaseCollision", err)
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		violations, err := policy.Check(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range violations {
+			got = append(got, v.Path+" "+v.Problem)
+		}
+		want := []string{
+			"Cafe\u0301.md not-nfc",
+			"Notes.md case-collision",
+			"a:b.md forbidden",
+			"notes.md case-collision",
+		}
+		if len(got) != len(want) {
+			t.Fatalf("violations = %q, want %q", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("violations = %q, want %q", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+//go:build ignore
+
+// gen_compositions writes compositions.go from the Unicode Character
+// Database:
+//
+//	go run gen_compositions.go [-ucd dir-or-url]
+//
+// A pair is listed if a character's canonical decomposition is exactly
+// that pair, the character is a starter decomposing to a starter, and it
+// isn't in CompositionExclusions.txt: the pairs NFC composes.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const version = "14.0.0"
+
+var ucd = flag.String("ucd", "https://www.unicode.org/Public/"+version+"/ucd", "directory or URL holding UnicodeData.txt and CompositionExclusions.txt")
+
+func open(name string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(*ucd, "https://") && !strings.HasPrefix(*ucd, "http://") {
+		return os.Open(filepath.Join(*ucd, name))
+	}
+	resp, err := http.Get(*ucd + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// lines calls fn with the fields of each line of the file name, comments
+// left out.
+func lines(name string, fn func(fields []string) error) error {
+	f, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return sc.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
+
+type composition struct {
+	a, b, c rune
+}
+
+func main() {
+	flag.Parse()
+
+	excluded := map[rune]bool{}
+	err := lines("CompositionExclusions.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		excluded[r] = true
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	combining := map[rune]bool{}
+	var candidates []composition
+	err = lines("UnicodeData.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		if fields[3] != "0" {
+			combining[r] = true
+		}
+		decomp := strings.Fields(fields[5])
+		// Tagged decompositions, such as <compat>, aren't canonical.
+		if len(decomp) != 2 || strings.HasPrefix(decomp[0], "<") {
+			return nil
+		}
+		a, err := parseRune(decomp[0])
+		if err != nil {
+			return err
+		}
+		b, err := parseRune(decomp[1])
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, composition{a, b, r})
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `// Code generated by gen_compositions.go; DO NOT EDIT.
+
+package filename
+
+// compositions maps a character and a combining mark following it to the
+// precomposed character NFC replaces them with. It holds Unicode %s's
+// canonical compositions, without the composition exclusions; Hangul
+// syllables are composed arithmetically.
+var compositions = map[[2]rune]rune{
+`, version)
+	slices.SortFunc(candidates, func(x, y composition) int { return int(x.c - y.c) })
+	for _, c := range candidates {
+		if excluded[c.c] || combining[c.c] || combining[c.a] {
+			continue
+		}
+		fmt.Fprintf(&buf, "{0x%04X, 0x%04X}: 0x%04X,\n", c.a, c.b, c.c)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("compositions.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
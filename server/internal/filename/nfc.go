@@ -0,0 +1,54 @@
+package filename
+
+import "strings"
+
+//go:generate go run gen_compositions.go
+
+// Hangul syllables are composed arithmetically rather than listed.
+const (
+	hangulBase  = 0xAC00
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11A7
+	hangulLs    = 19
+	hangulVs    = 21
+	hangulTs    = 28
+	hangulCount = hangulLs * hangulVs * hangulTs
+)
+
+// NFC composes s the way Unicode normalization form C does for text that
+// is decomposed in canonical order, as macOS writes file names. Each mark
+// is composed with the character right before it, so marks out of
+// canonical order are left as they are.
+func NFC(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if n := len(out); n > 0 {
+			if c, ok := compose(out[n-1], r); ok {
+				out[n-1] = c
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+func compose(a, b rune) (rune, bool) {
+	switch {
+	case a >= hangulL && a < hangulL+hangulLs && b >= hangulV && b < hangulV+hangulVs:
+		return hangulBase + ((a-hangulL)*hangulVs+(b-hangulV))*hangulTs, true
+	case a >= hangulBase && a < hangulBase+hangulCount && (a-hangulBase)%hangulTs == 0 &&
+		b > hangulT && b < hangulT+hangulTs:
+		return a + (b - hangulT), true
+	}
+	c, ok := compositions[[2]rune{a, b}]
+	return c, ok
+}
+
+func isASCII(s string) bool {
+	return !strings.ContainsFunc(s, func(r rune) bool { return r >= 0x80 })
+}
@@ -0,0 +1,213 @@
+// Package frontmatter reads and edits the YAML frontmatter block at the top
+// of markdown notes.
+//
+// Only the flat subset of YAML that notes use in practice is understood:
+// top-level keys with scalar values, flow lists ([a, b]) and block lists.
+// Fields are kept as written, so rewriting a note only changes the fields
+// that were set.
+package frontmatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const delimiter = "---"
+
+type Document struct {
+	Fields []Field
+	Body   string
+	// HasFrontmatter reports whether the note started with a frontmatter
+	// block. Setting a field adds one when rendering.
+	HasFrontmatter bool
+}
+
+// Field is a top-level key. Raw holds everything after the colon, including
+// the lines of a block list. Comments and blank lines are kept as fields
+// without a key.
+type Field struct {
+	Key string
+	Raw string
+}
+
+// Parse splits note into its frontmatter fields and body. A note without a
+// frontmatter block is all body.
+func Parse(note string) *Document {
+	rest, ok := strings.CutPrefix(note, delimiter+"\n")
+	if !ok {
+		return &Document{Body: note}
+	}
+	var block, body string
+	if after, ok := strings.CutPrefix(rest, delimiter+"\n"); ok {
+		body = after
+	} else if i := strings.Index(rest, "\n"+delimiter+"\n"); i >= 0 {
+		block = rest[:i]
+		body = rest[i+len(delimiter)+2:]
+	} else if strings.HasSuffix(rest, "\n"+delimiter) {
+		block = strings.TrimSuffix(rest, "\n"+delimiter)
+	} else {
+		return &Document{Body: note}
+	}
+
+	doc := &Document{Body: body, HasFrontmatter: true}
+	if block == "" {
+		return doc
+	}
+	for _, line := range strings.Split(block, "\n") {
+		continuation := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "-")
+		if n := len(doc.Fields); continuation && n > 0 && doc.Fields[n-1].Key != "" {
+			doc.Fields[n-1].Raw += "\n" + line
+			continue
+		}
+		key, raw, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "#") || strings.TrimSpace(key) != key || key == "" {
+			doc.Fields = append(doc.Fields, Field{Raw: line})
+			continue
+		}
+		doc.Fields = append(doc.Fields, Field{Key: key, Raw: raw})
+	}
+	return doc
+}
+
+func (d *Document) field(key string) *Field {
+	for i := range d.Fields {
+		if d.Fields[i].Key == key {
+			return &d.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Has reports whether key is present, even with an empty value.
+func (d *Document) Has(key string) bool {
+	return d.field(key) != nil
+}
+
+// String returns the scalar value of key, or "" if it is missing or a list.
+func (d *Document) String(key string) string {
+	f := d.field(key)
+	if f == nil {
+		return ""
+	}
+	return scalar(strings.TrimSpace(f.Raw))
+}
+
+// IsList reports whether key holds a flow or block list.
+func (d *Document) IsList(key string) bool {
+	f := d.field(key)
+	if f == nil {
+		return false
+	}
+	first, rest, _ := strings.Cut(f.Raw, "\n")
+	first = strings.TrimSpace(first)
+	return strings.HasPrefix(first, "[") || first == "" && strings.HasPrefix(strings.TrimSpace(rest), "-")
+}
+
+// List returns the values of a list field. A scalar is treated as a list of
+// one.
+func (d *Document) List(key string) []string {
+	f := d.field(key)
+	if f == nil {
+		return nil
+	}
+
+	first, rest, _ := strings.Cut(f.Raw, "\n")
+	first = strings.TrimSpace(first)
+	switch {
+	case strings.HasPrefix(first, "["):
+		return flowList(first)
+	case first != "":
+		return []string{scalar(first)}
+	}
+
+	var items []string
+	for _, line := range strings.Split(rest, "\n") {
+		item, ok := strings.CutPrefix(strings.TrimSpace(line), "-")
+		if !ok {
+			continue
+		}
+		if v := scalar(strings.TrimSpace(item)); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// Set replaces the value of key, or appends it if it is missing. Values are
+// written as JSON, which is valid YAML and sidesteps quoting rules.
+func (d *Document) Set(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+	d.HasFrontmatter = true
+	if f := d.field(key); f != nil {
+		f.Raw = " " + string(data)
+		return nil
+	}
+	d.Fields = append(d.Fields, Field{Key: key, Raw: " " + string(data)})
+	return nil
+}
+
+// Render writes the document back out as a note.
+func (d *Document) Render() string {
+	if !d.HasFrontmatter {
+		return d.Body
+	}
+	var sb strings.Builder
+	sb.WriteString(delimiter + "\n")
+	for _, f := range d.Fields {
+		if f.Key != "" {
+			sb.WriteString(f.Key + ":")
+		}
+		sb.WriteString(f.Raw + "\n")
+	}
+	sb.WriteString(delimiter + "\n")
+	sb.WriteString(d.Body)
+	return sb.String()
+}
+
+func scalar(v string) string {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		var s string
+		if err := json.Unmarshal([]byte(v), &s); err == nil {
+			return s
+		}
+		return strings.Trim(v, `"`)
+	case strings.HasPrefix(v, "'"):
+		v = strings.TrimSuffix(strings.TrimPrefix(v, "'"), "'")
+		return strings.ReplaceAll(v, "''", "'")
+	case strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{") || v == "~" || v == "null":
+		return ""
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v
+}
+
+func flowList(v string) []string {
+	var values []any
+	if err := json.Unmarshal([]byte(v), &values); err == nil {
+		items := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				items = append(items, s)
+			} else if value != nil {
+				items = append(items, fmt.Sprint(value))
+			}
+		}
+		return items
+	}
+
+	inner := strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		if s := scalar(strings.TrimSpace(item)); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
@@ -0,0 +1,109 @@
+package frontmatter_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+)
+
+const note = `---
+title: "Dune"
+subtitle: 'Book ''One'''
+year: 1965 # first edition
+authors: ["Frank Herbert"]
+tags: [sci-fi, classics]
+aliases:
+  - Dune (novel)
+  - "Arrakis"
+# a comment
+---
+Body text.
+`
+
+func TestParse(t *testing.T) {
+	doc := frontmatter.Parse(note)
+
+	checkString := func(t *testing.T, key, want string) {
+		t.Helper()
+		if got := doc.String(key); got != want {
+			t.Errorf("String(%q) = %q, want %q", key, got, want)
+		}
+	}
+	checkList := func(t *testing.T, key string, want []string) {
+		t.Helper()
+		if got := doc.List(key); !slices.Equal(got, want) {
+			t.Errorf("List(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	checkString(t, "title", "Dune")
+	checkString(t, "subtitle", "Book 'One'")
+	checkString(t, "year", "1965")
+	checkString(t, "authors", "")
+	checkString(t, "missing", "")
+	checkList(t, "authors", []string{"Frank Herbert"})
+	checkList(t, "tags", []string{"sci-fi", "classics"})
+	checkList(t, "aliases", []string{"Dune (novel)", "Arrakis"})
+	checkList(t, "title", []string{"Dune"})
+	for key, want := range map[string]bool{"tags": true, "aliases": true, "title": false, "missing": false} {
+		if got := doc.IsList(key); got != want {
+			t.Errorf("IsList(%q) = %v, want %v", key, got, want)
+		}
+	}
+
+	if doc.Body != "Body text.\n" {
+		t.Errorf("body = %q", doc.Body)
+	}
+	if got := doc.Render(); got != note {
+		t.Errorf("Render changed an unmodified note:\n%s", got)
+	}
+}
+
+func TestParseWithoutFrontmatter(t *testing.T) {
+	for _, input := range []string{"", "Just text.\n", "---\nunterminated: true\n"} {
+		doc := frontmatter.Parse(input)
+		if doc.HasFrontmatter || doc.Body != input || doc.Render() != input {
+			t.Errorf("Parse(%q) = %+v", input, doc)
+		}
+	}
+
+	doc := frontmatter.Parse("---\n---\nBody\n")
+	if !doc.HasFrontmatter || len(doc.Fields) != 0 || doc.Body != "Body\n" {
+		t.Errorf("empty block parsed as %+v", doc)
+	}
+}
+
+func TestSet(t *testing.T) {
+	doc := frontmatter.Parse(note)
+	if err := doc.Set("aliases", []string{"Arrakis"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("published", "1965-08-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `---
+title: "Dune"
+subtitle: 'Book ''One'''
+year: 1965 # first edition
+authors: ["Frank Herbert"]
+tags: [sci-fi, classics]
+aliases: ["Arrakis"]
+# a comment
+published: "1965-08-01"
+---
+Body text.
+`
+	if got := doc.Render(); got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+
+	plain := frontmatter.Parse("Body\n")
+	if err := plain.Set("title", "Plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got := plain.Render(); got != "---\ntitle: \"Plain\"\n---\nBody\n" {
+		t.Errorf("Render() = %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+// Package kanban reads and edits kanban boards kept as markdown: each "## "
+// heading is a column and each list item under it is a card, with a task
+// checkbox marking it done:
+//
+//	## Doing
+//	- [ ] Write the intro
+//	  with notes indented under the card
+//	## Done
+//	- [x] Outline
+//
+// Cards are archived to an "Archive" column. Edits only rewrite the lines
+// of the cards they touch.
+package kanban
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const ArchiveColumn = "Archive"
+
+var (
+	ErrNoColumn = errors.New("no such column")
+	ErrNoCard   = errors.New("no such card")
+	ErrNoText   = errors.New("card text is required")
+)
+
+var cardLine = regexp.MustCompile(`^[-*+] (?:\[([ xX])\] )?(.*)$`)
+
+type Card struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+	// lines are the card's lines as written, including indented lines
+	// under the list item.
+	lines []string
+}
+
+type Column struct {
+	Title string  `json:"title"`
+	Cards []*Card `json:"cards"`
+	// items are the cards and other lines under the heading, in order.
+	// Other lines are kept as they are.
+	items []item
+}
+
+type item struct {
+	card *Card
+	raw  string
+}
+
+type Board struct {
+	Columns []*Column `json:"columns"`
+	// Archived holds the cards of the archive column, which isn't listed
+	// in Columns.
+	Archived []*Card `json:"archive"`
+	preamble []string
+	archive  *Column
+}
+
+// Parse reads a board. Everything before the first column, such as the
+// frontmatter, is kept as it is.
+func Parse(src string) *Board {
+	b := &Board{Columns: []*Column{}, Archived: []*Card{}}
+	var col *Column
+	for _, line := range strings.Split(src, "\n") {
+		if title, ok := strings.CutPrefix(line, "## "); ok {
+			col = &Column{Title: strings.TrimSpace(title), Cards: []*Card{}}
+			if strings.EqualFold(col.Title, ArchiveColumn) && b.archive == nil {
+				b.archive = col
+			} else {
+				b.Columns = append(b.Columns, col)
+			}
+			continue
+		}
+		if col == nil {
+			b.preamble = append(b.preamble, line)
+			continue
+		}
+		if m := cardLine.FindStringSubmatch(line); m != nil {
+			card := &Card{Text: m[2], Done: m[1] == "x" || m[1] == "X", lines: []string{line}}
+			col.items = append(col.items, item{card: card})
+			col.Cards = append(col.Cards, card)
+			continue
+		}
+		if n := len(col.items); n > 0 && col.items[n-1].card != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			card := col.items[n-1].card
+			card.lines = append(card.lines, line)
+			card.Text += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		col.items = append(col.items, item{raw: line})
+	}
+	b.sync()
+	return b
+}
+
+func (b *Board) sync() {
+	if b.archive != nil {
+		b.Archived = b.archive.Cards
+	}
+}
+
+// Render writes the board back out as markdown.
+func (b *Board) Render() string {
+	lines := slices.Clone(b.preamble)
+	columns := b.Columns
+	if b.archive != nil {
+		columns = append(slices.Clone(columns), b.archive)
+	}
+	for _, col := range columns {
+		lines = append(lines, "## "+col.Title)
+		for _, it := range col.items {
+			if it.card != nil {
+				lines = append(lines, it.card.lines...)
+			} else {
+				lines = append(lines, it.raw)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Board) column(title string) (*Column, error) {
+	if strings.EqualFold(title, ArchiveColumn) && b.archive != nil {
+		return b.archive, nil
+	}
+	for _, col := range b.Columns {
+		if strings.EqualFold(col.Title, title) {
+			return col, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrNoColumn, title)
+}
+
+// insert puts card before the index-th card of col, or after the last card
+// if index is past the end. A column that ends with blank lines keeps them
+// after its cards.
+func (col *Column) insert(card *Card, index int) {
+	pos := len(col.items)
+	for pos > 0 && col.items[pos-1].card == nil && strings.TrimSpace(col.items[pos-1].raw) == "" {
+		pos--
+	}
+	seen := 0
+	for i, it := range col.items {
+		if it.card == nil {
+			continue
+		}
+		if seen == index {
+			pos = i
+			break
+		}
+		seen++
+	}
+	col.items = slices.Insert(col.items, pos, item{card: card})
+	index = min(max(index, 0), len(col.Cards))
+	col.Cards = slices.Insert(col.Cards, index, card)
+}
+
+func (col *Column) remove(index int) (*Card, error) {
+	if index < 0 || index >= len(col.Cards) {
+		return nil, fmt.Errorf("%w: %s has no card %d", ErrNoCard, col.Title, index)
+	}
+	card := col.Cards[index]
+	col.Cards = slices.Delete(col.Cards, index, index+1)
+	col.items = slices.DeleteFunc(col.items, func(it item) bool { return it.card == card })
+	return card, nil
+}
+
+// Add appends a card with text to the end of column.
+func (b *Board) Add(column, text string) error {
+	col, err := b.column(column)
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrNoText
+	}
+	lines := strings.Split(text, "\n")
+	card := &Card{Text: text, lines: []string{"- [ ] " + lines[0]}}
+	for _, line := range lines[1:] {
+		card.lines = append(card.lines, "  "+line)
+	}
+	col.insert(card, len(col.Cards))
+	b.sync()
+	return nil
+}
+
+// Move moves the index-th card of column to position toIndex in the column
+// to.
+func (b *Board) Move(column string, index int, to string, toIndex int) error {
+	from, err := b.column(column)
+	if err != nil {
+		return err
+	}
+	dst, err := b.column(to)
+	if err != nil {
+		return err
+	}
+	card, err := from.remove(index)
+	if err != nil {
+		return err
+	}
+	dst.insert(card, toIndex)
+	b.sync()
+	return nil
+}
+
+// Archive moves the index-th card of column to the end of the archive
+// column, which is added at the end of the board if it doesn't exist.
+func (b *Board) Archive(column string, index int) error {
+	from, err := b.column(column)
+	if err != nil {
+		return err
+	}
+	card, err := from.remove(index)
+	if err != nil {
+		return err
+	}
+	if b.archive == nil {
+		// Blank lines ending the file move down with the new column, so
+		// the file still ends the same way.
+		last := b.Columns[len(b.Columns)-1]
+		n := len(last.items)
+		for n > 0 && last.items[n-1].card == nil && strings.TrimSpace(last.items[n-1].raw) == "" {
+			n--
+		}
+		b.archive = &Column{Title: ArchiveColumn, Cards: []*Card{}, items: slices.Clone(last.items[n:])}
+		last.items = last.items[:n]
+	}
+	b.archive.insert(card, len(b.archive.Cards))
+	b.sync()
+	return nil
+}
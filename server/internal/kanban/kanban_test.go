@@ -0,0 +1,100 @@
+package kanban_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/kanban"
+)
+
+const board = `---
+kanban-plugin: basic
+---
+
+## Todo
+- [ ] Write intro
+  with a quote
+- [ ] Pick a title
+
+## Done
+- [x] Outline
+`
+
+func TestParse(t *testing.T) {
+	b := kanban.Parse(board)
+	if len(b.Columns) != 2 || b.Columns[0].Title != "Todo" || b.Columns[1].Title != "Done" {
+		t.Fatalf("columns = %+v", b.Columns)
+	}
+	if c := b.Columns[0].Cards[0]; c.Text != "Write intro\nwith a quote" || c.Done {
+		t.Errorf("card = %+v", c)
+	}
+	if c := b.Columns[1].Cards[0]; c.Text != "Outline" || !c.Done {
+		t.Errorf("card = %+v", c)
+	}
+	if got := b.Render(); got != board {
+		t.Errorf("Render changed an unmodified board:\n%s", got)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*kanban.Board) error
+		want string
+	}{
+		{
+			"add",
+			func(b *kanban.Board) error { return b.Add("todo", "Find an editor") },
+			"---\nkanban-plugin: basic\n---\n\n## Todo\n- [ ] Write intro\n  with a quote\n- [ ] Pick a title\n- [ ] Find an editor\n\n## Done\n- [x] Outline\n",
+		},
+		{
+			"move across columns",
+			func(b *kanban.Board) error { return b.Move("Todo", 0, "Done", 0) },
+			"---\nkanban-plugin: basic\n---\n\n## Todo\n- [ ] Pick a title\n\n## Done\n- [ ] Write intro\n  with a quote\n- [x] Outline\n",
+		},
+		{
+			"move within a column",
+			func(b *kanban.Board) error { return b.Move("Todo", 0, "Todo", 5) },
+			"---\nkanban-plugin: basic\n---\n\n## Todo\n- [ ] Pick a title\n- [ ] Write intro\n  with a quote\n\n## Done\n- [x] Outline\n",
+		},
+		{
+			"archive",
+			func(b *kanban.Board) error { return b.Archive("Done", 0) },
+			"---\nkanban-plugin: basic\n---\n\n## Todo\n- [ ] Write intro\n  with a quote\n- [ ] Pick a title\n\n## Done\n## Archive\n- [x] Outline\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := kanban.Parse(board)
+			if err := tt.edit(b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.Render(); got != tt.want {
+				t.Errorf("Render =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("archive appends to an existing archive", func(t *testing.T) {
+		b := kanban.Parse(board + "## Archive\n- [x] Old\n")
+		if err := b.Archive("todo", 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Archived) != 2 || b.Archived[1].Text != "Pick a title" {
+			t.Errorf("archive = %+v", b.Archived)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		b := kanban.Parse(board)
+		if err := b.Move("Todo", 2, "Done", 0); !errors.Is(err, kanban.ErrNoCard) {
+			t.Errorf("Move missing card err = %v", err)
+		}
+		if err := b.Add("Later", "x"); !errors.Is(err, kanban.ErrNoColumn) {
+			t.Errorf("Add missing column err = %v", err)
+		}
+		if got := b.Render(); got != board {
+			t.Errorf("failed edits changed the board:\n%s", got)
+		}
+	})
+}
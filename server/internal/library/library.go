@@ -0,0 +1,190 @@
+// Package library reads the books in the workspace from their notes.
+//
+// There is no separate catalog: a book is a markdown note whose frontmatter
+// has a title and authors, as written by the Calibre importer. Authors,
+// series and collections are all derived from those fields, so editing a
+// note is all it takes to move a book around the library.
+package library
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const collectionsField = "collections"
+
+var ErrNotABook = errors.New("note is not a book")
+
+type Book struct {
+	Path        string   `json:"path"`
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	Series      string   `json:"series,omitempty"`
+	SeriesIndex float64  `json:"seriesIndex,omitempty"`
+	Collections []string `json:"collections"`
+}
+
+// Group is an author, series or collection with the number of books in it.
+type Group struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func bookFromNote(p string, doc *frontmatter.Document) (Book, bool) {
+	b := Book{
+		Path:        p,
+		Title:       doc.String("title"),
+		Authors:     doc.List("authors"),
+		Series:      doc.String("series"),
+		Collections: doc.List(collectionsField),
+	}
+	if b.Title == "" || len(b.Authors) == 0 {
+		return Book{}, false
+	}
+	if b.Series != "" {
+		b.SeriesIndex, _ = strconv.ParseFloat(doc.String("series_index"), 64)
+	}
+	if b.Collections == nil {
+		b.Collections = []string{}
+	}
+	return b, true
+}
+
+// Scan reads every book note in the workspace, sorted by title. Notes that
+// can't be read are skipped.
+func Scan(ws *workspace.Workspace) ([]Book, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	books := []Book{}
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		if b, ok := bookFromNote(e.Path, frontmatter.Parse(string(data))); ok {
+			books = append(books, b)
+		}
+	}
+	slices.SortFunc(books, func(a, b Book) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), strings.Compare(a.Path, b.Path))
+	})
+	return books, nil
+}
+
+func groups(books []Book, names func(Book) []string) []Group {
+	counts := map[string]int{}
+	for _, b := range books {
+		for _, name := range names(b) {
+			counts[name]++
+		}
+	}
+	result := make([]Group, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, Group{Name: name, Count: count})
+	}
+	slices.SortFunc(result, func(a, b Group) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), strings.Compare(a.Name, b.Name))
+	})
+	return result
+}
+
+func seriesNames(b Book) []string {
+	if b.Series == "" {
+		return nil
+	}
+	return []string{b.Series}
+}
+
+func Authors(books []Book) []Group {
+	return groups(books, func(b Book) []string { return b.Authors })
+}
+
+func Series(books []Book) []Group {
+	return groups(books, seriesNames)
+}
+
+func Collections(books []Book) []Group {
+	return groups(books, func(b Book) []string { return b.Collections })
+}
+
+func ByAuthor(books []Book, name string) []Book {
+	return filter(books, func(b Book) bool { return slices.Contains(b.Authors, name) })
+}
+
+func ByCollection(books []Book, name string) []Book {
+	return filter(books, func(b Book) bool { return slices.Contains(b.Collections, name) })
+}
+
+// BySeries returns the books of a series in reading order.
+func BySeries(books []Book, name string) []Book {
+	result := filter(books, func(b Book) bool { return b.Series == name })
+	slices.SortStableFunc(result, func(a, b Book) int {
+		return cmp.Compare(a.SeriesIndex, b.SeriesIndex)
+	})
+	return result
+}
+
+func filter(books []Book, keep func(Book) bool) []Book {
+	result := []Book{}
+	for _, b := range books {
+		if keep(b) {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// AddToCollection lists the book note at p in the named collection.
+func AddToCollection(ws *workspace.Workspace, p, name string) error {
+	return editCollections(ws, p, func(names []string) []string {
+		if slices.Contains(names, name) {
+			return names
+		}
+		return append(names, name)
+	})
+}
+
+// RemoveFromCollection takes the book note at p out of the named collection.
+func RemoveFromCollection(ws *workspace.Workspace, p, name string) error {
+	return editCollections(ws, p, func(names []string) []string {
+		return slices.DeleteFunc(names, func(n string) bool { return n == name })
+	})
+}
+
+func editCollections(ws *workspace.Workspace, p string, edit func([]string) []string) error {
+	data, err := ws.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	doc := frontmatter.Parse(string(data))
+	if _, ok := bookFromNote(p, doc); !ok {
+		return fmt.Errorf("%w: %s", ErrNotABook, p)
+	}
+
+	current := doc.List(collectionsField)
+	names := edit(slices.Clone(current))
+	if slices.Equal(names, current) {
+		return nil
+	}
+	if names == nil {
+		names = []string{}
+	}
+	if err := doc.Set(collectionsField, names); err != nil {
+		return err
+	}
+	return ws.WriteFile(p, []byte(doc.Render()), 0o644)
+}
@@ -0,0 +1,188 @@
+package library_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/library"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func newLibrary(t *testing.T) *workspace.Workspace {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := map[string]string{
+		"books/Frank Herbert/Dune Messiah/Dune Messiah.md": "---\ntitle: \"Dune Messiah\"\nauthors: [\"Frank Herbert\"]\nseries: \"Dune\"\nseries_index: 2\n---\n",
+		"books/Frank Herbert/Dune/Dune.md":                 "---\ntitle: \"Dune\"\nauthors: [\"Frank Herbert\"]\nseries: \"Dune\"\nseries_index: 1\ncollections: [\"Favourites\"]\n---\nMy notes\n",
+		"books/Good Omens.md":                              "---\ntitle: Good Omens\nauthors:\n  - Terry Pratchett\n  - Neil Gaiman\n---\n",
+		"journal/today.md":                                 "---\ntitle: Today\n---\n",
+		"books/cover.jpg":                                  "jpeg",
+	}
+	for p, content := range notes {
+		if err := ws.MkdirAll(p[:strings.LastIndex(p, "/")], 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ws
+}
+
+func titles(books []library.Book) []string {
+	result := []string{}
+	for _, b := range books {
+		result = append(result, b.Title)
+	}
+	return result
+}
+
+func TestScan(t *testing.T) {
+	ws := newLibrary(t)
+	books, err := library.Scan(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, name string, got, want any) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	check(t, "books", titles(books), []string{"Dune", "Dune Messiah", "Good Omens"})
+	check(t, "authors", library.Authors(books), []library.Group{
+		{Name: "Frank Herbert", Count: 2},
+		{Name: "Neil Gaiman", Count: 1},
+		{Name: "Terry Pratchett", Count: 1},
+	})
+	check(t, "series", library.Series(books), []library.Group{{Name: "Dune", Count: 2}})
+	check(t, "collections", library.Collections(books), []library.Group{{Name: "Favourites", Count: 1}})
+	check(t, "by author", titles(library.ByAuthor(books, "Neil Gaiman")), []string{"Good Omens"})
+	check(t, "by series", titles(library.BySeries(books, "Dune")), []string{"Dune", "Dune Messiah"})
+	check(t, "by collection", titles(library.ByCollection(books, "Favourites")), []string{"Dune"})
+	check(t, "unknown author", library.ByAuthor(books, "Nobody"), []library.Book{})
+}
+
+func TestCollections(t *testing.T) {
+	ws := newLibrary(t)
+	const dune = "books/Frank Herbert/Dune/Dune.md"
+
+	check := func(t *testing.T, collection string, want []string) {
+		t.Helper()
+		books, err := library.Scan(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(library.ByCollection(books, collection)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", collection, got, want)
+		}
+	}
+
+	if err := library.AddToCollection(ws, "books/Good Omens.md", "Favourites"); err != nil {
+		t.Fatal(err)
+	}
+	if err := library.AddToCollection(ws, dune, "Favourites"); err != nil {
+		t.Fatal(err)
+	}
+	check(t, "Favourites", []string{"Dune", "Good Omens"})
+
+	if err := library.RemoveFromCollection(ws, dune, "Favourites"); err != nil {
+		t.Fatal(err)
+	}
+	check(t, "Favourites", []string{"Good Omens"})
+
+	note, err := ws.ReadFile(dune)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(note), "collections: []\n---\nMy notes\n") {
+		t.Errorf("unexpected note:\n%s", note)
+	}
+
+	if err := library.AddToCollection(ws, "journal/today.md", "Favourites"); !errors.Is(err, library.ErrNotABook) {
+		t.Errorf("err = %v, want ErrNotABook", err)
+	}
+}
+
+func epub(t *testing.T, opf string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`,
+		"content.opf":            opf,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReconcile(t *testing.T) {
+	ws := newLibrary(t)
+	files := map[string][]byte{
+		"books/Frank Herbert/Dune/Dune.epub": []byte("epub"),
+		"books/New/Neuromancer.epub": epub(t, `<package><metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title>Neuromancer</dc:title><dc:creator>William Gibson</dc:creator></metadata></package>`),
+		"books/Loose/paper.pdf": []byte("%PDF"),
+		"journal/notes.txt":     []byte("not a book"),
+	}
+	for p, content := range files {
+		if err := ws.MkdirAll(p[:strings.LastIndex(p, "/")], 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := library.Reconcile(ws, "books", false, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUnlisted := []string{"books/Loose/paper.pdf", "books/New/Neuromancer.epub"}
+	wantWithoutFiles := []string{"books/Frank Herbert/Dune Messiah/Dune Messiah.md", "books/Good Omens.md"}
+	if len(report.Added) != 0 || !reflect.DeepEqual(report.Unlisted, wantUnlisted) || !reflect.DeepEqual(report.WithoutFiles, wantWithoutFiles) {
+		t.Errorf("report = %+v", report)
+	}
+
+	report, err = library.Reconcile(ws, "books", true, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"books/Loose/paper.md", "books/New/Neuromancer.md"}; !reflect.DeepEqual(report.Added, want) || len(report.Unlisted) != 0 {
+		t.Errorf("report with add = %+v", report)
+	}
+	books, err := library.Scan(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := map[string][]string{}
+	for _, b := range books {
+		byTitle[b.Title] = b.Authors
+	}
+	if !reflect.DeepEqual(byTitle["Neuromancer"], []string{"William Gibson"}) || !reflect.DeepEqual(byTitle["paper"], []string{"Unknown"}) {
+		t.Errorf("added books = %v", byTitle)
+	}
+
+	last, err := library.LoadReconciliation(ws)
+	if err != nil || last == nil || !reflect.DeepEqual(last.Added, report.Added) {
+		t.Errorf("LoadReconciliation = %+v, %v", last, err)
+	}
+}
@@ -0,0 +1,157 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/calibre"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// ReconcilePath is where the report of the last reconciliation is kept.
+const ReconcilePath = ".wisdom/library-reconcile.json"
+
+// EPUBs are read whole for their metadata, so bigger ones are skipped.
+const maxEPUBSize = 64 << 20
+
+// Reconciliation compares the book files under a folder with the book
+// notes, for libraries also managed outside the server, say with rsync. A
+// book's files are the ones in its note's folder, as the Calibre importer
+// lays them out, so moving a book's folder keeps it whole; moving a file
+// on its own shows up as a folder without files and one without a note.
+type Reconciliation struct {
+	Root     string    `json:"root"`
+	Finished time.Time `json:"finished"`
+	// Added are the notes written for folders of book files that had none.
+	Added []string `json:"added"`
+	// Unlisted are book files with no book note in their folder, which the
+	// library doesn't show.
+	Unlisted []string `json:"unlisted"`
+	// WithoutFiles are book notes with no book file in their folder. They
+	// may be about books kept elsewhere, so they are only reported.
+	WithoutFiles []string `json:"withoutFiles"`
+}
+
+// Reconcile checks the books under root and, with add, writes a note for
+// each folder of unlisted book files. The note is filled from an EPUB's
+// metadata if there is one; otherwise it is titled after the file, by
+// "Unknown" as Calibre does, for the user to correct.
+func Reconcile(ws *workspace.Workspace, root string, add bool, now time.Time) (*Reconciliation, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	books, err := Scan(ws)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	if root == "." || root == "" {
+		prefix = ""
+	}
+
+	files := map[string][]string{}
+	for _, e := range entries {
+		if !e.IsDir && strings.HasPrefix(e.Path, prefix) && calibre.Formats[strings.ToLower(path.Ext(e.Path))] {
+			dir := path.Dir(e.Path)
+			files[dir] = append(files[dir], e.Path)
+		}
+	}
+	noted := map[string]bool{}
+	result := &Reconciliation{Root: root, Added: []string{}, Unlisted: []string{}, WithoutFiles: []string{}}
+	for _, b := range books {
+		if !strings.HasPrefix(b.Path, prefix) {
+			continue
+		}
+		noted[path.Dir(b.Path)] = true
+		if len(files[path.Dir(b.Path)]) == 0 {
+			result.WithoutFiles = append(result.WithoutFiles, b.Path)
+		}
+	}
+
+	for _, dir := range slices.Sorted(maps.Keys(files)) {
+		if noted[dir] {
+			continue
+		}
+		if add {
+			p, err := addNote(ws, files[dir])
+			if err != nil {
+				return nil, err
+			}
+			if p != "" {
+				result.Added = append(result.Added, p)
+				continue
+			}
+		}
+		result.Unlisted = append(result.Unlisted, files[dir]...)
+	}
+	slices.Sort(result.WithoutFiles)
+
+	result.Finished = now.UTC()
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.MkdirAll(path.Dir(ReconcilePath), 0o755); err != nil {
+		return nil, err
+	}
+	return result, ws.WriteFile(ReconcilePath, data, 0o644)
+}
+
+// addNote writes a book note for files, which share a folder. It returns ""
+// if a note of that name is already there, as a note that isn't a book.
+func addNote(ws *workspace.Workspace, files []string) (string, error) {
+	slices.Sort(files)
+	name := path.Base(files[0])
+	book := &calibre.Book{Title: strings.TrimSuffix(name, path.Ext(name)), Authors: []string{"Unknown"}}
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f), ".epub") {
+			continue
+		}
+		if info, err := ws.Stat(f); err != nil || info.Size() > maxEPUBSize {
+			break
+		}
+		data, err := ws.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		if b, err := calibre.ReadEPUB(data); err == nil {
+			if len(b.Authors) == 0 {
+				b.Authors = book.Authors
+			}
+			book = b
+		}
+		break
+	}
+
+	p := path.Join(path.Dir(files[0]), strings.TrimSuffix(name, path.Ext(name))+".md")
+	if _, err := ws.Stat(p); err == nil {
+		return "", nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return p, ws.WriteFile(p, []byte(book.Frontmatter()), 0o644)
+}
+
+// LoadReconciliation returns the report of the last reconciliation, or nil
+// if there hasn't been one.
+func LoadReconciliation(ws *workspace.Workspace) (*Reconciliation, error) {
+	data, err := ws.ReadFile(ReconcilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Reconciliation
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,292 @@
+// Package lint checks the workspace for notes and files that are likely
+// mistakes or that break its conventions: notes without the frontmatter
+// their folder expects, empty notes, notes sharing a title, badly named
+// files and oversized attachments.
+//
+// Unlike the schema, which checks property values as notes are saved, lint
+// looks at the workspace as a whole, on demand or on a schedule, and keeps
+// the last report.
+package lint
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/attachments"
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// ConfigPath is where the rules are configured:
+//
+//	{"fields": {"books": ["title", "author"]}, "filenames": "^[a-z0-9-]+$",
+//	 "maxAttachmentBytes": 10485760, "disable": ["empty-note"]}
+//
+// A workspace without one runs the rules that need no configuration.
+const ConfigPath = ".wisdom/lint.json"
+
+// ReportPath is where the last report is kept.
+const ReportPath = ".wisdom/lint-report.json"
+
+const (
+	RuleMissingField    = "missing-field"
+	RuleEmptyNote       = "empty-note"
+	RuleDuplicateTitle  = "duplicate-title"
+	RuleFilename        = "filename"
+	RuleLargeAttachment = "large-attachment"
+)
+
+var Rules = []string{RuleMissingField, RuleEmptyNote, RuleDuplicateTitle, RuleFilename, RuleLargeAttachment}
+
+const defaultMaxAttachmentBytes = 20 << 20
+
+var (
+	ErrInvalidConfig = errors.New("invalid lint config")
+	ErrNoReport      = errors.New("lint has not run")
+)
+
+// reportMu serializes runs, which rewrite the report.
+var reportMu sync.Mutex
+
+type Config struct {
+	// Fields are the frontmatter fields notes under each folder must have,
+	// "." for every note. A note must have the fields of every folder it
+	// is in.
+	Fields map[string][]string `json:"fields,omitempty"`
+	// Filenames is a regular expression file names, without their
+	// extension, must match.
+	Filenames string `json:"filenames,omitempty"`
+	// MaxAttachmentBytes is the largest size for a file in an attachment
+	// folder, 20 MiB if unset.
+	MaxAttachmentBytes int64 `json:"maxAttachmentBytes,omitempty"`
+	// AttachmentFolders are the folder names holding attachments,
+	// attachments.DefaultFolders if unset.
+	AttachmentFolders []string `json:"attachmentFolders,omitempty"`
+	// Disable turns rules off by name.
+	Disable []string `json:"disable,omitempty"`
+
+	filenames *regexp.Regexp
+}
+
+type Issue struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Related are the other files involved, such as the notes sharing a
+	// title.
+	Related []string `json:"related,omitempty"`
+}
+
+type Report struct {
+	Time   time.Time `json:"time"`
+	Issues []Issue   `json:"issues"`
+	// Counts is the number of issues by rule.
+	Counts map[string]int `json:"counts"`
+}
+
+// LoadConfig reads the configuration.
+func LoadConfig(ws *workspace.Workspace) (Config, error) {
+	var c Config
+	data, err := ws.ReadFile(ConfigPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	for _, rule := range c.Disable {
+		if !slices.Contains(Rules, rule) {
+			return c, fmt.Errorf("%w: unknown rule %q", ErrInvalidConfig, rule)
+		}
+	}
+	if c.Filenames != "" {
+		if c.filenames, err = regexp.Compile(c.Filenames); err != nil {
+			return c, fmt.Errorf("%w: filenames: %v", ErrInvalidConfig, err)
+		}
+	}
+	return c, nil
+}
+
+func (c Config) enabled(rule string) bool {
+	return !slices.Contains(c.Disable, rule)
+}
+
+// fieldsFor returns the fields required of the note at p.
+func (c Config) fieldsFor(p string) []string {
+	var fields []string
+	for folder, names := range c.Fields {
+		folder = strings.Trim(folder, "/")
+		if folder == "" || folder == "." || strings.HasPrefix(p, folder+"/") {
+			fields = append(fields, names...)
+		}
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+func inFolder(p string, folders []string) bool {
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if slices.Contains(folders, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check runs the enabled rules over the workspace and returns the issues
+// sorted by path.
+func Check(ws *workspace.Workspace, c Config, now time.Time) (Report, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return Report{}, err
+	}
+	maxBytes := cmp.Or(c.MaxAttachmentBytes, defaultMaxAttachmentBytes)
+	folders := c.AttachmentFolders
+	if len(folders) == 0 {
+		folders = attachments.DefaultFolders
+	}
+
+	issues := []Issue{}
+	report := func(rule, p, format string, args ...any) {
+		issues = append(issues, Issue{Rule: rule, Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+	byTitle := map[string][]string{}
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		name := path.Base(e.Path)
+		stem := strings.TrimSuffix(name, path.Ext(name))
+		if c.filenames != nil && c.enabled(RuleFilename) && !c.filenames.MatchString(stem) {
+			report(RuleFilename, e.Path, "name does not match %s", c.Filenames)
+		}
+
+		if !notes.IsNote(e.Path) {
+			if !c.enabled(RuleLargeAttachment) || !inFolder(e.Path, folders) {
+				continue
+			}
+			if info, err := ws.Stat(e.Path); err == nil && info.Size() > maxBytes {
+				report(RuleLargeAttachment, e.Path, "attachment is %d bytes, over %d", info.Size(), maxBytes)
+			}
+			continue
+		}
+		content, _, err := textfile.Read(ws, e.Path)
+		if err != nil {
+			continue
+		}
+		doc := frontmatter.Parse(content)
+		if c.enabled(RuleMissingField) {
+			for _, field := range c.fieldsFor(e.Path) {
+				if !doc.Has(field) {
+					report(RuleMissingField, e.Path, "frontmatter has no %s", field)
+				}
+			}
+		}
+		if c.enabled(RuleEmptyNote) && strings.TrimSpace(doc.Body) == "" {
+			report(RuleEmptyNote, e.Path, "note is empty")
+		}
+		title := strings.ToLower(notes.Parse(e.Path, content).Title)
+		byTitle[title] = append(byTitle[title], e.Path)
+	}
+	if c.enabled(RuleDuplicateTitle) {
+		for _, paths := range byTitle {
+			if len(paths) < 2 {
+				continue
+			}
+			for _, p := range paths {
+				others := slices.DeleteFunc(slices.Clone(paths), func(o string) bool { return o == p })
+				issues = append(issues, Issue{Rule: RuleDuplicateTitle, Path: p, Message: "title is shared with other notes", Related: others})
+			}
+		}
+	}
+
+	slices.SortFunc(issues, func(a, b Issue) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path), strings.Compare(a.Rule, b.Rule), strings.Compare(a.Message, b.Message))
+	})
+	return newReport(issues, now), nil
+}
+
+func newReport(issues []Issue, now time.Time) Report {
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Rule]++
+	}
+	return Report{Time: now.UTC(), Issues: issues, Counts: counts}
+}
+
+// visible leaves out of report what is in folders locked in ws.
+func visible(ws *workspace.Workspace, report Report) Report {
+	issues := []Issue{}
+	for _, issue := range report.Issues {
+		if ws.IsLocked(issue.Path) {
+			continue
+		}
+		if issue.Related != nil {
+			issue.Related = slices.DeleteFunc(slices.Clone(issue.Related), ws.IsLocked)
+			if len(issue.Related) == 0 {
+				continue
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return newReport(issues, report.Time)
+}
+
+// Run checks the whole workspace with its configuration and keeps the
+// report, so it is the same whoever runs it. What is in folders locked in
+// ws is left out of the report returned.
+func Run(ws *workspace.Workspace, now time.Time) (Report, error) {
+	c, err := LoadConfig(ws)
+	if err != nil {
+		return Report{}, err
+	}
+	report, err := Check(ws.Full(), c, now)
+	if err != nil {
+		return Report{}, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return Report{}, err
+	}
+
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if err := ws.MkdirAll(path.Dir(ReportPath), 0o755); err != nil {
+		return Report{}, err
+	}
+	if err := ws.WriteFile(ReportPath, data, 0o644); err != nil {
+		return Report{}, err
+	}
+	return visible(ws, report), nil
+}
+
+// Last returns the report of the last run, without what is in folders
+// locked in ws.
+func Last(ws *workspace.Workspace) (Report, error) {
+	data, err := ws.ReadFile(ReportPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Report{}, ErrNoReport
+	}
+	if err != nil {
+		return Report{}, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, err
+	}
+	return visible(ws, report), nil
+}
@@ -0,0 +1,91 @@
+package lint_test
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/lint"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestRun(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		".wisdom/lint.json":       `{"fields": {"books": ["author"]}, "filenames": "^[a-z0-9-]+$", "maxAttachmentBytes": 4}`,
+		"books/dune.md":           "---\ntitle: Dune\n---\nSand.\n",
+		"books/Dune Messiah.md":   "---\ntitle: Dune Messiah\nauthor: Herbert\n---\nMore sand.\n",
+		"inbox/empty.md":          "---\ntags: [todo]\n---\n\n",
+		"inbox/dune.md":           "# dune\n",
+		"inbox/attachments/a.png": "large",
+		"inbox/b.png":             "large",
+		"private/dune.md":         "# Dune\n",
+	} {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := lint.Last(ws); !errors.Is(err, lint.ErrNoReport) {
+		t.Errorf("Last before a run: err = %v, want ErrNoReport", err)
+	}
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	view := ws.Lock([]string{"private"})
+	report, err := lint.Run(view, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, issue.Rule+" "+issue.Path+" "+strings.Join(issue.Related, ","))
+	}
+	want := []string{
+		"filename books/Dune Messiah.md ",
+		"duplicate-title books/dune.md inbox/dune.md",
+		"missing-field books/dune.md ",
+		"large-attachment inbox/attachments/a.png ",
+		"duplicate-title inbox/dune.md books/dune.md",
+		"empty-note inbox/empty.md ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if report.Counts[lint.RuleDuplicateTitle] != 2 {
+		t.Errorf("counts = %v", report.Counts)
+	}
+
+	full, err := lint.Last(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !full.Time.Equal(now) || full.Counts[lint.RuleDuplicateTitle] != 3 {
+		t.Errorf("Last = %+v, want the whole workspace as of the run", full)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, config := range []string{`{"disable": ["spelling"]}`, `{"filenames": "("}`, `[]`} {
+		if err := ws.WriteFile(lint.ConfigPath, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lint.Run(ws, time.Now()); !errors.Is(err, lint.ErrInvalidConfig) {
+			t.Errorf("%s: err = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
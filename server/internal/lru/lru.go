@@ -0,0 +1,101 @@
+// Package lru keeps recently used generated content in memory, bounded by
+// its total size, in front of slower sources such as a disk cache.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+type entry struct {
+	key, tag string
+	value    []byte
+}
+
+// Cache is a least-recently-used cache of byte slices. Each entry has a
+// tag, such as the path of the file it was made from, to drop every entry
+// made from something that changed.
+type Cache struct {
+	maxBytes int64
+
+	mu     sync.Mutex
+	size   int64
+	order  *list.List // front is the most recently used
+	items  map[string]*list.Element
+	tags   map[string]map[string]bool
+	hits   int64
+	misses int64
+}
+
+func New(maxBytes int64) *Cache {
+	return &Cache{maxBytes: maxBytes, order: list.New(), items: map[string]*list.Element{}, tags: map[string]map[string]bool{}}
+}
+
+// Get returns the value stored under key. Callers must not modify it.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Add stores value under key, evicting the least recently used entries to
+// make room. A value bigger than the whole cache isn't stored.
+func (c *Cache) Add(key, tag string, value []byte) {
+	if int64(len(value)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&entry{key, tag, value})
+	if c.tags[tag] == nil {
+		c.tags[tag] = map[string]bool{}
+	}
+	c.tags[tag][key] = true
+	c.size += int64(len(value))
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops the entries tagged tag.
+func (c *Cache) Invalidate(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.tags[tag] {
+		c.remove(c.items[key])
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*entry)
+	delete(c.items, e.key)
+	delete(c.tags[e.tag], e.key)
+	if len(c.tags[e.tag]) == 0 {
+		delete(c.tags, e.tag)
+	}
+	c.size -= int64(len(e.value))
+}
+
+type Stats struct {
+	Entries  int   `json:"entries"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"maxBytes"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Entries: len(c.items), Bytes: c.size, MaxBytes: c.maxBytes, Hits: c.hits, Misses: c.misses}
+}
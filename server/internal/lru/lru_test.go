@@ -0,0 +1,36 @@
+package lru_test
+
+import (
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/lru"
+)
+
+func TestCache(t *testing.T) {
+	c := lru.New(10)
+	c.Add("a", "a.md", []byte("aaaa"))
+	c.Add("b", "b.md", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	// Over the limit: b, the least recently used, goes.
+	c.Add("c", "c.md", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("b kept past the limit")
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "aaaa" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+
+	c.Add("a-small", "a.md", []byte("a"))
+	c.Invalidate("a.md")
+	if _, ok := c.Get("a"); ok {
+		t.Error("a kept after invalidation")
+	}
+	c.Add("huge", "huge.md", make([]byte, 11))
+
+	want := lru.Stats{Entries: 1, Bytes: 4, MaxBytes: 10, Hits: 2, Misses: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
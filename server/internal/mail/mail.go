@@ -0,0 +1,71 @@
+// Package mail sends plain-text email through an SMTP server, for reports
+// a user wants to receive rather than go and look at.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrDisabled = errors.New("email is not configured; set WISDOM_SMTP_ADDR and WISDOM_SMTP_FROM")
+
+type Sender struct {
+	// Addr is the server's host:port.
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// FromEnv returns a sender for the server at WISDOM_SMTP_ADDR, sending as
+// WISDOM_SMTP_FROM and logging in with WISDOM_SMTP_USER and
+// WISDOM_SMTP_PASSWORD if set, or nil if email isn't configured. Mail is
+// sent over STARTTLS when the server offers it; the password is only sent
+// over TLS or to localhost.
+func FromEnv() *Sender {
+	addr, from := os.Getenv("WISDOM_SMTP_ADDR"), os.Getenv("WISDOM_SMTP_FROM")
+	if addr == "" || from == "" {
+		return nil
+	}
+	s := &Sender{Addr: addr, From: from}
+	if user := os.Getenv("WISDOM_SMTP_USER"); user != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.Auth = smtp.PlainAuth("", user, os.Getenv("WISDOM_SMTP_PASSWORD"), host)
+	}
+	return s
+}
+
+// Send mails body to the addresses in to.
+func (s *Sender) Send(to []string, subject, body string, now time.Time) error {
+	if s == nil {
+		return ErrDisabled
+	}
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+	for _, addr := range append([]string{s.From}, to...) {
+		// Addresses go into headers as they are.
+		if strings.ContainsAny(addr, "\r\n") {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	for line := range strings.Lines(body) {
+		// Lines end in CRLF in mail.
+		msg.WriteString(strings.TrimRight(line, "\r\n") + "\r\n")
+	}
+	return smtp.SendMail(s.Addr, s.Auth, s.From, to, msg.Bytes())
+}
@@ -0,0 +1,91 @@
+package mail_test
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/mail"
+)
+
+// fakeServer accepts one message over SMTP and returns what it received.
+func fakeServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		var got strings.Builder
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				tp.PrintfLine("354 go ahead")
+				lines, _ := tp.ReadDotLines()
+				got.WriteString(strings.Join(lines, "\n") + "\n")
+				tp.PrintfLine("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				tp.PrintfLine("221 bye")
+				received <- got.String()
+				return
+			default:
+				got.WriteString(line + "\n")
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func TestSend(t *testing.T) {
+	addr, received := fakeServer(t)
+	s := &mail.Sender{Addr: addr, From: "wisdom@example.com"}
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	if err := s.Send([]string{"me@example.com"}, "Week — recap", "Line one\nLine two\n", now); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	for _, want := range []string{
+		"RCPT TO:<me@example.com>",
+		"To: me@example.com\n",
+		"Subject: =?utf-8?q?Week_=E2=80=94_recap?=\n",
+		"Date: Mon, 02 Mar 2026 09:00:00 +0000\n",
+		"\nLine one\nLine two\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message lacks %q:\n%s", want, got)
+		}
+	}
+
+	t.Run("header injection", func(t *testing.T) {
+		if err := s.Send([]string{"me@example.com\r\nBcc: them@example.com"}, "Hi", "", now); err == nil {
+			t.Error("address with a line break accepted")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var s *mail.Sender
+		if err := s.Send([]string{"me@example.com"}, "Hi", "", now); !errors.Is(err, mail.ErrDisabled) {
+			t.Errorf("err = %v, want ErrDisabled", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Package metrics reports the server's connection and goroutine counts, to
+// tell whether long transfers are starving other clients of connections.
+package metrics
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// Conns counts the server's connections by state. Its Track method is meant
+// to be the server's ConnState hook.
+type Conns struct {
+	mu       sync.Mutex
+	states   map[net.Conn]http.ConnState
+	accepted int64
+	panics   int64
+}
+
+func NewConns() *Conns {
+	return &Conns{states: map[net.Conn]http.ConnState{}}
+}
+
+func (c *Conns) Track(conn net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		c.accepted++
+		c.states[conn] = state
+	case http.StateClosed, http.StateHijacked:
+		delete(c.states, conn)
+	default:
+		c.states[conn] = state
+	}
+}
+
+// CountPanic records that a handler panicked. A panic doesn't always
+// close the connection, but it is as much a sign of trouble.
+func (c *Conns) CountPanic() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.panics++
+}
+
+type Snapshot struct {
+	// Open counts connections in any state: new, active or idle.
+	Open   int `json:"open"`
+	Active int `json:"active"`
+	Idle   int `json:"idle"`
+	// Accepted is the number of connections accepted since startup.
+	Accepted   int64 `json:"accepted"`
+	Goroutines int   `json:"goroutines"`
+	// Panics counts handlers that panicked since startup.
+	Panics int64 `json:"panics"`
+}
+
+func (c *Conns) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Snapshot{Open: len(c.states), Accepted: c.accepted, Goroutines: runtime.NumGoroutine(), Panics: c.panics}
+	for _, state := range c.states {
+		switch state {
+		case http.StateActive:
+			s.Active++
+		case http.StateIdle:
+			s.Idle++
+		}
+	}
+	return s
+}
+
+// Handler serves the current snapshot as JSON.
+func Handler(c *Conns) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(c.Snapshot())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,49 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/metrics"
+)
+
+func TestConns(t *testing.T) {
+	conns := metrics.NewConns()
+	srv := httptest.NewUnstartedServer(metrics.Handler(conns))
+	srv.Config.ConnState = conns.Track
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s metrics.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// The request reporting the snapshot is the active connection.
+	if s.Open != 1 || s.Active != 1 || s.Accepted != 1 || s.Goroutines == 0 {
+		t.Errorf("snapshot = %+v", s)
+	}
+
+	srv.CloseClientConnections()
+	srv.Close()
+	if s := conns.Snapshot(); s.Open != 0 || s.Accepted != 1 {
+		t.Errorf("after close, snapshot = %+v", s)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	r := metrics.ReadRuntime()
+	if r.Goroutines == 0 || r.HeapAlloc == 0 || r.Sys == 0 {
+		t.Errorf("runtime = %+v", r)
+	}
+	if runtime.GOOS == "linux" && r.OpenFiles <= 0 {
+		t.Errorf("open files = %d, want a count on Linux", r.OpenFiles)
+	}
+}
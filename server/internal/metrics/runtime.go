@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Runtime is the state of the Go runtime, to tell a leak or a GC storm
+// from slow disks when the server gets slow.
+type Runtime struct {
+	Goroutines int `json:"goroutines"`
+	// HeapAlloc is the memory held by live and not yet collected objects;
+	// Sys is all memory obtained from the OS.
+	HeapAlloc  uint64    `json:"heapAlloc"`
+	HeapInuse  uint64    `json:"heapInuse"`
+	Sys        uint64    `json:"sys"`
+	NumGC      uint32    `json:"numGC"`
+	GCPauseSum uint64    `json:"gcPauseTotalNs"`
+	LastGC     time.Time `json:"lastGC,omitzero"`
+	// OpenFiles is -1 where it can't be counted, which is everywhere but
+	// Linux.
+	OpenFiles int `json:"openFiles"`
+}
+
+func ReadRuntime() Runtime {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	r := Runtime{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+		HeapInuse:  m.HeapInuse,
+		Sys:        m.Sys,
+		NumGC:      m.NumGC,
+		GCPauseSum: m.PauseTotalNs,
+		OpenFiles:  -1,
+	}
+	if m.LastGC > 0 {
+		r.LastGC = time.Unix(0, int64(m.LastGC)).UTC()
+	}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		r.OpenFiles = len(fds)
+	}
+	return r
+}
+
+// RuntimeHandler serves the runtime state as JSON.
+func RuntimeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(ReadRuntime())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
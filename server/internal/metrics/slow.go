@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Slow tallies the requests that took longer than a threshold by route, so
+// the endpoints that make the server slow stand out.
+type Slow struct {
+	Threshold time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*SlowRoute
+}
+
+type SlowRoute struct {
+	// Route is the method and the pattern the request matched, such as
+	// "GET /api/fs/{path...}".
+	Route   string `json:"route"`
+	Count   int    `json:"count"`
+	TotalMs int64  `json:"totalMs"`
+	MaxMs   int64  `json:"maxMs"`
+	// LastPath is the path of the latest slow request, for a route with
+	// wildcards.
+	LastPath string    `json:"lastPath"`
+	LastAt   time.Time `json:"lastAt"`
+	// MaxBytes is the largest response among them.
+	MaxBytes int64 `json:"maxBytes"`
+}
+
+func NewSlow(threshold time.Duration) *Slow {
+	return &Slow{Threshold: threshold, routes: map[string]*SlowRoute{}}
+}
+
+// Record counts a request that took d, if that is over the threshold, and
+// reports whether it was.
+func (s *Slow) Record(route, path string, d time.Duration, bytes int64, now time.Time) bool {
+	if d < s.Threshold {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.routes[route]
+	if r == nil {
+		r = &SlowRoute{Route: route}
+		s.routes[route] = r
+	}
+	r.Count++
+	r.TotalMs += d.Milliseconds()
+	r.MaxMs = max(r.MaxMs, d.Milliseconds())
+	r.LastPath, r.LastAt = path, now.UTC()
+	r.MaxBytes = max(r.MaxBytes, bytes)
+	return true
+}
+
+// Top returns the n routes that spent the most time in slow requests.
+func (s *Slow) Top(n int) []SlowRoute {
+	s.mu.Lock()
+	routes := make([]SlowRoute, 0, len(s.routes))
+	for _, r := range s.routes {
+		routes = append(routes, *r)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(routes, func(a, b SlowRoute) int {
+		return cmp.Or(cmp.Compare(b.TotalMs, a.TotalMs), strings.Compare(a.Route, b.Route))
+	})
+	return routes[:min(n, len(routes))]
+}
+
+// slowTop is how many routes SlowHandler lists.
+const slowTop = 20
+
+// SlowHandler serves the routes with the most time in slow requests.
+func SlowHandler(s *Slow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(map[string]any{"thresholdMs": s.Threshold.Milliseconds(), "routes": s.Top(slowTop)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
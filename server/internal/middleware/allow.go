@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+)
+
+// AllowIPs answers 403 to clients whose address isn't in one of allowed,
+// unless allowed is empty. It goes by the connection's address, so behind
+// a reverse proxy it is the proxy's address that must be allowed.
+func AllowIPs(next http.Handler, allowed []netip.Prefix) http.Handler {
+	if len(allowed) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, err := netip.ParseAddr(clientAddr(r))
+		if err != nil || !slices.ContainsFunc(allowed, func(p netip.Prefix) bool { return p.Contains(addr.Unmap()) }) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,31 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+)
+
+func TestAllowIPs(t *testing.T) {
+	allowed := []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("::1/128")}
+	handler := middleware.AllowIPs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), allowed)
+	for remote, want := range map[string]int{
+		"192.168.1.20:51000":        http.StatusOK,
+		"[::ffff:192.168.1.20]:443": http.StatusOK,
+		"[::1]:51000":               http.StatusOK,
+		"192.168.2.20:51000":        http.StatusForbidden,
+		"203.0.113.5:51000":         http.StatusForbidden,
+		"garbage":                   http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("from %s = %d, want %d", remote, w.Code, want)
+		}
+	}
+}
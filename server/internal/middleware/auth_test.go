@@ -0,0 +1,29 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := middleware.RequireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "secret")
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Authorization %q = %d, want %d", header, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// prefixWriter puts the base path back on the Location and
+// Content-Location headers handlers send, which are paths from the root.
+type prefixWriter struct {
+	http.ResponseWriter
+	prefix      string
+	wroteHeader bool
+}
+
+func (pw *prefixWriter) WriteHeader(code int) {
+	if !pw.wroteHeader {
+		pw.wroteHeader = true
+		for _, name := range []string{"Location", "Content-Location"} {
+			if loc := pw.Header().Get(name); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+				pw.Header().Set(name, pw.prefix+loc)
+			}
+		}
+	}
+	pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *prefixWriter) Write(b []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	return pw.ResponseWriter.Write(b)
+}
+
+func (pw *prefixWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// BasePath serves next under prefix, such as /wisdom, for a server a
+// reverse proxy mounts on a subpath of its domain and passes the whole
+// path to. The prefix is taken off request paths, so handlers see the
+// paths they would at the root, and put back on redirects. Anything
+// outside the prefix is not found. An empty prefix serves next as is.
+func BasePath(next http.Handler, prefix string) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			u := *r.URL
+			u.Path += "/"
+			u.RawPath = ""
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+		p, ok := strings.CutPrefix(r.URL.Path, prefix+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + p
+		if r.URL.RawPath != "" {
+			rawPath, ok := strings.CutPrefix(r.URL.RawPath, prefix+"/")
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			r2.URL.RawPath = "/" + rawPath
+		}
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(&prefixWriter{ResponseWriter: w, prefix: prefix}, r2)
+	})
+}
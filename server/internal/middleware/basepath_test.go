@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+)
+
+func TestBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/fs/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.PathValue("path"))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ws/?from="+r.URL.RequestURI(), http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/", http.StatusFound)
+	})
+	handler := middleware.BasePath(mux, "/wisdom")
+
+	for _, tc := range []struct {
+		target   string
+		status   int
+		header   string
+		expected string
+	}{
+		{"/wisdom/api/fs/notes/a%2Fb.md", http.StatusOK, "X-Path", "notes/a/b.md"},
+		{"/wisdom/login", http.StatusFound, "Location", "/wisdom/ws/?from=/login"},
+		{"/wisdom/away", http.StatusFound, "Location", "https://example.com/"},
+		{"/wisdom", http.StatusMovedPermanently, "Location", "/wisdom/"},
+		{"/wisdomx/login", http.StatusNotFound, "", ""},
+		{"/api/fs/notes", http.StatusNotFound, "", ""},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s = %d, want %d", tc.target, w.Code, tc.status)
+		}
+		if tc.header != "" && w.Header().Get(tc.header) != tc.expected {
+			t.Errorf("%s: %s = %q, want %q", tc.target, tc.header, w.Header().Get(tc.header), tc.expected)
+		}
+	}
+}
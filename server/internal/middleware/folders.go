@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/protect"
+	"github.com/shrik450/wisdom/internal/wlog"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// LockFolders replaces the request's workspace with a view that has the
+// protected folders locked, except those the client's session unlocked,
+// the app data that would give away what is in them, the list of protected
+// folders and the note index's cache, and the server's scratch space. It
+// goes inside WithWorkspace.
+func LockFolders(next http.Handler, folders *protect.Folders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context())
+		locked, err := folders.Locked(ws, protect.SessionToken(r), time.Now())
+		if err != nil {
+			// Failing closed: an unreadable list mustn't unlock everything.
+			wlog.FromContext(r.Context()).Error("reading protected folders", "err", err)
+			http.Error(w, "reading protected folders: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ws = ws.Lock(append(locked, protect.Path, notes.CachePath, workspace.ScratchDir))
+		next.ServeHTTP(w, r.WithContext(workspace.WithContext(r.Context(), ws)))
+	})
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxReplayedBody bounds the response kept for each key. Mutating
+	// requests answer with small JSON; a bigger response isn't kept, and a
+	// retry runs again.
+	maxReplayedBody = 1 << 20
+	// maxReplayed and maxReplayedBytes bound the responses kept in all.
+	// Past either, the oldest are dropped early, and a retry of one runs
+	// again.
+	maxReplayed      = 1000
+	maxReplayedBytes = 32 << 20
+)
+
+type idempotentResponse struct {
+	// fingerprint and bodySum identify the request, so a key reused for a
+	// different one is caught.
+	fingerprint string
+	bodySum     []byte
+	expires     time.Time
+	// done is closed once the response is recorded.
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Idempotent replays the response to a PUT, POST, PATCH or DELETE retried
+// with the same Idempotency-Key header within window, instead of running
+// it again, so a client that lost the response on a flaky connection can
+// retry safely. A key reused for a different request is refused with 422,
+// and a retry while the first is still running with 409. Server errors
+// aren't kept, so those can be retried.
+//
+// The request is identified by its method, URL, content type and the
+// SHA-256 of its body. The body is hashed as the handler reads it, and a
+// retry's as it is read through, so uploads aren't buffered. Keys are
+// scoped to who Authorize let the request through as, so one device can't
+// be answered with another's response. It goes inside Authorize.
+func Idempotent(next http.Handler, window time.Duration) http.Handler {
+	var mu sync.Mutex
+	seen := map[string]*idempotentResponse{}
+	size := 0
+	drop := func(k string) {
+		size -= len(seen[k].body)
+		delete(seen, k)
+	}
+	// evict drops the oldest finished response, if any, under mu.
+	evict := func() bool {
+		oldest := ""
+		for k, e := range seen {
+			select {
+			case <-e.done:
+			default:
+				continue
+			}
+			if oldest == "" || e.expires.Before(seen[oldest].expires) {
+				oldest = k
+			}
+		}
+		if oldest == "" {
+			return false
+		}
+		drop(oldest)
+		return true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = principal(r.Context()) + "\x00" + key
+		fingerprint := r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("Content-Type")
+		now := time.Now()
+
+		mu.Lock()
+		for k, e := range seen {
+			if now.After(e.expires) {
+				drop(k)
+			}
+		}
+		prev, ok := seen[key]
+		if !ok {
+			for len(seen) >= maxReplayed && evict() {
+			}
+			prev = &idempotentResponse{fingerprint: fingerprint, expires: now.Add(window), done: make(chan struct{})}
+			seen[key] = prev
+		}
+		mu.Unlock()
+
+		if ok {
+			select {
+			case <-prev.done:
+			default:
+				http.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+				return
+			}
+			if prev.fingerprint != fingerprint {
+				http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			sum := sha256.New()
+			if _, err := io.Copy(sum, r.Body); err != nil {
+				http.Error(w, "reading request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !bytes.Equal(prev.bodySum, sum.Sum(nil)) {
+				http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			for k, v := range prev.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(prev.status)
+			w.Write(prev.body)
+			return
+		}
+
+		sum := sha256.New()
+		body := io.TeeReader(r.Body, sum)
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, r.Body}
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		// Deferred so a handler that panics doesn't leave the key in
+		// progress for the whole window.
+		defer func() {
+			keep := completed && !rec.overflow && rec.status < 500
+			if keep {
+				// The handler may not have read the whole body, but a
+				// retry's is hashed in full.
+				_, err := io.Copy(io.Discard, body)
+				keep = err == nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if keep {
+				if !rec.wroteHeader {
+					rec.header = w.Header().Clone()
+				}
+				prev.status, prev.header, prev.body = rec.status, rec.header, rec.body.Bytes()
+				prev.bodySum = sum.Sum(nil)
+			}
+			close(prev.done)
+			// The entry may have expired, or been evicted, meanwhile.
+			if seen[key] != prev {
+				return
+			}
+			if !keep {
+				drop(key)
+				return
+			}
+			size += len(prev.body)
+			for size > maxReplayedBytes && evict() {
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		completed = true
+	})
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// recorder passes a response through while keeping a copy to replay.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+	overflow    bool
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.wroteHeader = true
+		rec.status = code
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxReplayedBody {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,150 @@
+package middleware_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestIdempotent(t *testing.T) {
+	var runs atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := middleware.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		if r.URL.Path == "/fail" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		n := runs.Add(1)
+		w.Header().Set("Location", "/created")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "run %d", n)
+	}), time.Hour)
+
+	do := func(method, target, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	bodyOf := func(w *httptest.ResponseRecorder) string {
+		data, _ := io.ReadAll(w.Body)
+		return string(data)
+	}
+
+	first := do(http.MethodPost, "/items", "a", "x")
+	retry := do(http.MethodPost, "/items", "a", "x")
+	if bodyOf(first) != "run 1" || retry.Code != http.StatusCreated || bodyOf(retry) != "run 1" ||
+		retry.Header().Get("Location") != "/created" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d %v %q, want the first response replayed", retry.Code, retry.Header(), bodyOf(retry))
+	}
+	if w := do(http.MethodPost, "/other", "a", "x"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %d, want 422", w.Code)
+	}
+	if w := do(http.MethodPost, "/items", "a", "y"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key with another body = %d, want 422", w.Code)
+	}
+	if w := do(http.MethodPost, "/items", "", "x"); bodyOf(w) != "run 2" {
+		t.Errorf("without a key = %q, want a new run", bodyOf(w))
+	}
+	if w := do(http.MethodGet, "/items", "a", ""); bodyOf(w) != "run 3" {
+		t.Errorf("GET = %q, want a new run", bodyOf(w))
+	}
+
+	t.Run("server errors are not kept", func(t *testing.T) {
+		do(http.MethodPost, "/fail", "b", "")
+		if w := do(http.MethodPost, "/fail", "b", ""); w.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("server error replayed")
+		}
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			do(http.MethodPut, "/slow", "c", "")
+			close(done)
+		}()
+		<-started
+		if w := do(http.MethodPut, "/slow", "c", ""); w.Code != http.StatusConflict {
+			t.Errorf("concurrent retry = %d, want 409", w.Code)
+		}
+		close(release)
+		<-done
+	})
+}
+
+func TestIdempotentScopedToCaller(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := tokens.New()
+	_, secret, err := store.Create(ws, "phone", []tokens.Scope{tokens.ScopeFSWrite}, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs atomic.Int32
+	handler := middleware.WithWorkspace(middleware.Authorize(middleware.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "run %d", runs.Add(1))
+	}), time.Hour), store, "admin", nil), ws)
+	do := func(auth string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPut, "/api/fs/a.md", strings.NewReader("x"))
+		r.Header.Set("Authorization", "Bearer "+auth)
+		r.Header.Set("Idempotency-Key", "a")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	if got := do("admin"); got != "run 1" {
+		t.Fatalf("admin = %q, want run 1", got)
+	}
+	if got := do(secret); got != "run 2" {
+		t.Errorf("another caller with the same key = %q, want a new run", got)
+	}
+	if got := do("admin"); got != "run 1" {
+		t.Errorf("admin retry = %q, want run 1 replayed", got)
+	}
+}
+
+func TestIdempotentEvictsOldest(t *testing.T) {
+	var runs atomic.Int32
+	handler := middleware.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "run %d", runs.Add(1))
+	}), time.Hour)
+	do := func(key string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/items", nil)
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	for i := range 1001 {
+		do(fmt.Sprint(i))
+	}
+	if got := do("1000"); got != "run 1001" {
+		t.Errorf("newest retry = %q, want run 1001 replayed", got)
+	}
+	if got := do("0"); got != "run 1002" {
+		t.Errorf("oldest retry = %q, want a new run once evicted", got)
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log/slog"
 	"net/http"
 	"time"
@@ -18,12 +20,32 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, to
+// flush streamed responses.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// RequestLogger logs each request and gives it an ID, which is added to
+// every log line for it and sent back in X-Request-ID, so a user's report
+// can be matched to the logs. A client's own X-Request-ID is kept if it
+// looks like one.
 func RequestLogger(next http.Handler, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		logger := logger.With("requestId", id)
+
 		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
-		r = r.WithContext(wlog.WithLogger(r.Context(), logger))
+		ctx := wlog.WithRequestID(wlog.WithLogger(r.Context(), logger), id)
+		r = r.WithContext(ctx)
 		next.ServeHTTP(rw, r)
 
 		logger.Info("request",
@@ -34,3 +56,15 @@ func RequestLogger(next http.Handler, logger *slog.Logger) http.Handler {
 		)
 	})
 }
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/wlog"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := middleware.RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = wlog.RequestID(r.Context())
+	}), slog.Default())
+
+	for sent, keep := range map[string]bool{"": false, "client-42": true, "bad id\n": false} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if sent != "" {
+			r.Header.Set("X-Request-ID", sent)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		got := w.Header().Get("X-Request-ID")
+		if got == "" || got != seen || (got == sent) != keep {
+			t.Errorf("X-Request-ID %q: responded %q, handler saw %q", sent, got, seen)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/shrik450/wisdom/internal/report"
+	"github.com/shrik450/wisdom/internal/wlog"
+)
+
+// statusWriter records the status of a response, and whether it started.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Recover turns a panicking handler into a 500 instead of a dropped
+// connection, logging the stack and calling onPanic, and reports panics and
+// other server errors to reporter; both may be nil. It must run inside
+// RequestLogger, whose logger and request ID it uses.
+func Recover(next http.Handler, reporter *report.Reporter, onPanic func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			v := recover()
+			if v == http.ErrAbortHandler {
+				// Handlers panic with it on purpose, to abort a response.
+				panic(v)
+			}
+			ev := report.Event{Method: r.Method, URL: r.URL.Path, Status: sw.status, RequestID: wlog.RequestID(r.Context())}
+			if v != nil {
+				ev.Message = fmt.Sprintf("panic: %v", v)
+				ev.Stack = string(debug.Stack())
+				ev.Status = http.StatusInternalServerError
+				wlog.FromContext(r.Context()).Error("panic serving request", "path", r.URL.Path, "panic", v, "stack", ev.Stack)
+				if onPanic != nil {
+					onPanic()
+				}
+				if !sw.wroteHeader {
+					writeInternalError(sw, wlog.RequestID(r.Context()))
+				}
+			} else if sw.status >= 500 {
+				ev.Message = fmt.Sprintf("%s %s: %d %s", r.Method, r.URL.Path, sw.status, http.StatusText(sw.status))
+			} else {
+				return
+			}
+			reporter.Report(ev)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// writeInternalError answers with a JSON body, as API clients expect,
+// carrying the request ID to quote in a bug report.
+func writeInternalError(w http.ResponseWriter, requestID string) {
+	data, _ := json.Marshal(map[string]string{"error": "internal server error", "requestId": requestID})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(data)
+}
@@ -0,0 +1,81 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/report"
+)
+
+func TestRecover(t *testing.T) {
+	panics := 0
+	handler := middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/panic":
+			panic("boom")
+		case "/abort":
+			panic(http.ErrAbortHandler)
+		}
+	}), nil, func() { panics++ })
+
+	w := httptest.NewRecorder()
+	middleware.RequestLogger(handler, slog.Default()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	var body struct {
+		Error     string `json:"error"`
+		RequestID string `json:"requestId"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusInternalServerError || body.Error == "" || body.RequestID == "" || body.RequestID != w.Header().Get("X-Request-ID") {
+		t.Errorf("panic = %d %+v, want 500 with the request ID", w.Code, body)
+	}
+	if panics != 1 {
+		t.Errorf("panics counted = %d, want 1", panics)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("ok = %d, want 200", w.Code)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("aborted handler recovered as %v, want it to propagate", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
+
+func TestRecoverReportsPathOnly(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		got <- body
+	}))
+	defer tracker.Close()
+	reporter, err := report.New(strings.Replace(tracker.URL, "://", "://public@", 1)+"/1", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}), reporter, nil)
+	handler = middleware.RequestLogger(handler, slog.Default())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/share/capture/inbox.md?sig=secret", nil))
+
+	select {
+	case body := <-got:
+		request, _ := body["request"].(map[string]any)
+		if request["url"] != "/share/capture/inbox.md" {
+			t.Errorf("reported url = %v, want the path without the query", request["url"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no report sent")
+	}
+}
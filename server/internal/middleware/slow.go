@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/metrics"
+	"github.com/shrik450/wisdom/internal/wlog"
+)
+
+type countingWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.bytes += int64(n)
+	return n, err
+}
+
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+type patternKey struct{}
+
+// LogSlowRequests logs requests that take longer than slow's threshold, with
+// the route they matched and the bytes sent, and tallies them in slow. It
+// must run inside RequestLogger, whose logger it uses, and the mux inside it
+// must be wrapped in PublishPattern for the route to be known.
+func LogSlowRequests(next http.Handler, slow *metrics.Slow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		cw := &countingWriter{ResponseWriter: w}
+		pattern := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), patternKey{}, pattern))
+		next.ServeHTTP(cw, r)
+		d := time.Since(start)
+		// Event streams are meant to last.
+		if cw.Header().Get("Content-Type") == "text/event-stream" {
+			return
+		}
+
+		route := r.Method + " " + cmp.Or(*pattern, r.Pattern, r.URL.Path)
+		if slow.Record(route, r.URL.Path, d, cw.bytes, time.Now()) {
+			wlog.FromContext(r.Context()).Warn("slow request",
+				"route", route,
+				"path", r.URL.Path,
+				"bytes", cw.bytes,
+				"duration", d,
+			)
+		}
+	})
+}
+
+// PublishPattern passes the pattern the mux next matched back to
+// LogSlowRequests. A mux sets the pattern on the request it is given, but
+// the middleware in between pass copies of the request on, so the one
+// LogSlowRequests holds never sees it.
+func PublishPattern(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if pattern, ok := r.Context().Value(patternKey{}).(*string); ok {
+			*pattern = r.Pattern
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/metrics"
+	"github.com/shrik450/wisdom/internal/middleware"
+)
+
+func TestLogSlowRequests(t *testing.T) {
+	inner := http.NewServeMux()
+	inner.HandleFunc("/api/fs/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Write([]byte("content"))
+	})
+	inner.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		time.Sleep(20 * time.Millisecond)
+	})
+	outer := http.NewServeMux()
+	outer.Handle("/api/", inner)
+	slow := metrics.NewSlow(10 * time.Millisecond)
+	handler := middleware.LogSlowRequests(outer, slow)
+
+	for _, target := range []string{"/api/fs/a.md", "/api/fs/b.md?slow=1", "/api/fs/c.md?slow=1", "/api/events"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	top := slow.Top(10)
+	if len(top) != 1 {
+		t.Fatalf("slow routes = %+v, want only the slow reads", top)
+	}
+	r := top[0]
+	if r.Route != "GET /api/fs/{path...}" || r.Count != 2 || r.LastPath != "/api/fs/c.md" || r.MaxBytes != 7 || r.MaxMs < 10 {
+		t.Errorf("slow route = %+v", r)
+	}
+}
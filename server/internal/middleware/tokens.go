@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/oidc"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/wlog"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Authorize requires a token with a scope allowing the request on /api/
+// and /opds/, once any token was created. The admin token, if set, is
+// allowed everything. The token goes in an Authorization header, as a
+// bearer token or as the password of basic auth, which is all most OPDS
+// readers can send. The tokens file is locked to every request. It goes
+// inside WithWorkspace and outside Idempotent, so a replay is authorized
+// too.
+//
+// With sso set, everything but the sign-in flow, /readyz, the calendar
+// feed and share and capture links, which carry a token or signature of
+// their own, and the published notes' feeds also requires a token or a
+// signed-in user, who is allowed everything. Pages send browsers to sign in.
+func Authorize(next http.Handler, store *tokens.Store, admin string, sso *oidc.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := workspace.FromContext(r.Context()).Lock([]string{tokens.Path})
+		r = r.WithContext(workspace.WithContext(r.Context(), ws))
+		api := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/opds/")
+		if !api && (sso == nil || public(r.URL.Path)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, secret, _ = r.BasicAuth()
+		}
+		if admin != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(admin)) == 1 {
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), "admin")))
+			return
+		}
+		if sso != nil {
+			if user, ok := sso.User(r, time.Now()); ok {
+				next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), "user:"+user)))
+				return
+			}
+			if secret == "" && !api {
+				http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+		}
+		enabled, err := store.Enabled(ws)
+		if err != nil {
+			// Failing closed: an unreadable file mustn't open the API.
+			wlog.FromContext(r.Context()).Error("reading tokens", "err", err)
+			http.Error(w, "reading tokens: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !enabled && sso == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t, err := store.Check(ws, secret, time.Now())
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer, Basic realm="wisdom"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		store.Used(t.ID, tokens.Use{At: time.Now(), UserAgent: r.UserAgent(), Addr: clientAddr(r)})
+		if !t.Allows(r, ws) {
+			http.Error(w, "the token's scopes don't allow this request", http.StatusForbidden)
+			return
+		}
+		if t.OnlyCreates(r, ws) {
+			r = r.WithContext(tokens.WithCreateOnly(r.Context()))
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), "token:"+t.ID)))
+	})
+}
+
+type principalKey struct{}
+
+// withPrincipal records who Authorize let the request through as.
+func withPrincipal(ctx context.Context, p string) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principal returns who Authorize let ctx's request through as: "admin",
+// "user:" and a signed-in user's name or "token:" and a token's ID, or ""
+// if it needed no credentials.
+func principal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// public reports whether p is served without signing in.
+func public(p string) bool {
+	return strings.HasPrefix(p, "/auth/") || strings.HasPrefix(p, "/share/") || strings.HasPrefix(p, "/capture/") ||
+		p == "/readyz" || p == "/calendar.ics" || p == "/feed.xml" || p == "/feed.json"
+}
+
+// RequireAdmin lets through only requests with the admin token or from a
+// signed-in user, for managing what can access the workspace. It goes
+// inside Authorize.
+func RequireAdmin(next http.Handler, admin string, sso *oidc.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sso != nil {
+			if _, ok := sso.User(r, time.Now()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if admin == "" || subtle.ConstantTimeCompare([]byte(got), []byte(admin)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
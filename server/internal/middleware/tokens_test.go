@@ -0,0 +1,141 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/oidc"
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestAuthorize(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := tokens.New()
+	handler := middleware.WithWorkspace(middleware.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := workspace.FromContext(r.Context()).ReadFile(tokens.Path); err == nil {
+			t.Errorf("%s %s could read the tokens", r.Method, r.URL)
+		}
+	}), store, "admin", nil), ws)
+	do := func(method, target, auth string) int {
+		t.Helper()
+		r := httptest.NewRequest(method, target, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := do(http.MethodGet, "/api/fs/", ""); code != http.StatusOK {
+		t.Errorf("before any token was created = %d, want the API open", code)
+	}
+	_, secret, err := store.Create(ws, "reader", []tokens.Scope{tokens.ScopeRead}, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method, target, auth string
+		want                 int
+	}{
+		{http.MethodGet, "/api/fs/", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/fs/", "Bearer wsd_wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/fs/", "Bearer " + secret, http.StatusOK},
+		{http.MethodPut, "/api/fs/a.md", "Bearer " + secret, http.StatusForbidden},
+		{http.MethodPut, "/api/fs/a.md", "Bearer admin", http.StatusOK},
+		{http.MethodGet, "/index.html", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := do(tt.method, tt.target, tt.auth); code != tt.want {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.target, tt.auth, code, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/opds/", nil)
+	r.SetBasicAuth("kobo", secret)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("the token as a basic auth password = %d, want 200", w.Code)
+	}
+}
+
+func TestAuthorizeCreateOnly(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := tokens.New()
+	var createOnly bool
+	handler := middleware.WithWorkspace(middleware.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		createOnly = tokens.CreateOnly(r.Context())
+	}), store, "admin", nil), ws)
+	_, importer, err := store.Create(ws, "scanner", []tokens.Scope{tokens.ScopeImport}, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, writer, err := store.Create(ws, "laptop", []tokens.Scope{tokens.ScopeImport, tokens.ScopeFSWrite}, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		secret, target string
+		want           bool
+	}{
+		{importer, "/api/uploads", true},
+		{writer, "/api/uploads", true},
+		{writer, "/api/fs/a.md", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		r.Header.Set("Authorization", "Bearer "+tt.secret)
+		createOnly = false
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if createOnly != tt.want {
+			t.Errorf("POST %s: CreateOnly = %v, want %v", tt.target, createOnly, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeSSO(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sso, err := oidc.New(oidc.Config{Issuer: "https://id.example.com", ClientID: "wisdom", RedirectURL: "https://notes.example.com/auth/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.WithWorkspace(middleware.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), tokens.New(), "admin", sso), ws)
+	tests := []struct {
+		target, auth string
+		want         int
+	}{
+		{"/notes/inbox.md?x=1", "", http.StatusFound},
+		{"/api/fs/", "", http.StatusUnauthorized},
+		{"/api/fs/", "Bearer admin", http.StatusOK},
+		{"/auth/login", "", http.StatusOK},
+		{"/readyz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s with %q = %d, want %d", tt.target, tt.auth, w.Code, tt.want)
+		}
+		if w.Code == http.StatusFound && w.Header().Get("Location") != "/auth/login?next=%2Fnotes%2Finbox.md%3Fx%3D1" {
+			t.Errorf("%s redirected to %q", tt.target, w.Header().Get("Location"))
+		}
+	}
+}
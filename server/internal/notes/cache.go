@@ -0,0 +1,59 @@
+package notes
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// CachePath is where the index is saved, so a restart only reads the notes
+// that changed while the server was down.
+const CachePath = ".wisdom/notes-index.json"
+
+// cacheVersion is bumped whenever parse changes, so notes saved with the
+// old rules are read again.
+const cacheVersion = 1
+
+type cache struct {
+	Version int                    `json:"version"`
+	Notes   map[string]cachedEntry `json:"notes"`
+}
+
+type cachedEntry struct {
+	Note    Note      `json:"note"`
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+}
+
+// load fills the index from the cache. A missing, unreadable or outdated
+// cache is ignored; the notes are then read from scratch.
+func (x *Index) load(ws *workspace.Workspace) {
+	data, err := ws.ReadFile(CachePath)
+	if err != nil {
+		return
+	}
+	var c cache
+	if err := json.Unmarshal(data, &c); err != nil || c.Version != cacheVersion {
+		return
+	}
+	for p, e := range c.Notes {
+		x.notes[p] = indexed{note: e.Note, modTime: e.ModTime, size: e.Size}
+	}
+}
+
+func (x *Index) save(ws *workspace.Workspace) error {
+	c := cache{Version: cacheVersion, Notes: make(map[string]cachedEntry, len(x.notes))}
+	for p, n := range x.notes {
+		c.Notes[p] = cachedEntry{Note: n.note, ModTime: n.modTime, Size: n.size}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(CachePath), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(CachePath, data, 0o644)
+}
@@ -0,0 +1,349 @@
+// Package notes keeps an in-memory index of the markdown notes in the
+// workspace: their titles and aliases, and how often and recently each was
+// opened.
+//
+// Lookups answer from memory, which keeps them fast enough to run on
+// every keystroke; the index is kept current by updating it with the paths
+// that changed, as the watcher and the API's writes report them. Only notes
+// whose size or modification time changed are read again.
+//
+// The index is saved in the workspace after changes, so after a restart
+// only the notes changed meanwhile are read. The first build still stats
+// every note, and reads them all without a saved index, so it is started
+// at startup. Until it finishes, lookups that would wait for it get notes
+// named after their files instead.
+package notes
+
+import (
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type Note struct {
+	Path    string   `json:"path"`
+	Title   string   `json:"title"`
+	Aliases []string `json:"aliases"`
+	// Type is the frontmatter type, such as "person".
+	Type string `json:"type,omitempty"`
+}
+
+type indexed struct {
+	note    Note
+	modTime time.Time
+	size    int64
+}
+
+type visit struct {
+	count int
+	last  time.Time
+}
+
+type Index struct {
+	mu     sync.Mutex
+	loaded bool
+	notes  map[string]indexed
+	visits map[string]visit
+
+	// Progress of the current refresh, readable while it runs.
+	ready          atomic.Bool
+	indexed, total atomic.Int64
+}
+
+// Progress reports how far the index has been built.
+type Progress struct {
+	// Ready is set once the first build has finished.
+	Ready   bool  `json:"ready"`
+	Indexed int64 `json:"indexed"`
+	Total   int64 `json:"total"`
+}
+
+func (x *Index) Progress() Progress {
+	return Progress{Ready: x.ready.Load(), Indexed: x.indexed.Load(), Total: x.total.Load()}
+}
+
+func NewIndex() *Index {
+	return &Index{notes: map[string]indexed{}, visits: map[string]visit{}}
+}
+
+// IsNote reports whether p is a markdown note.
+func IsNote(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".md")
+}
+
+// Notes returns every note in the workspace, from memory once the index
+// is built; Refresh and Update keep it current. Notes in folders locked in
+// ws are indexed but not returned.
+func (x *Index) Notes(ws *workspace.Workspace) ([]Note, error) {
+	if !x.ready.Load() {
+		if !x.mu.TryLock() {
+			entries, err := ws.Full().WalkFiles()
+			if err != nil {
+				return nil, err
+			}
+			return namedAfterFiles(ws, entries), nil
+		}
+		// Nothing started the build, so the first lookup does.
+		err := x.refresh(ws.Full())
+		x.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	result := make([]Note, 0, len(x.notes))
+	for p, n := range x.notes {
+		if !ws.IsLocked(p) {
+			result = append(result, n.note)
+		}
+	}
+	slices.SortFunc(result, func(a, b Note) int { return strings.Compare(a.Path, b.Path) })
+	return result, nil
+}
+
+// Refresh walks the whole workspace and rereads the notes that changed,
+// which builds the index the first time. Afterwards, Update with the
+// paths that changed is enough.
+func (x *Index) Refresh(ws *workspace.Workspace) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.refresh(ws.Full())
+}
+
+func (x *Index) refresh(ws *workspace.Workspace) error {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return err
+	}
+	if !x.loaded {
+		x.load(ws)
+		x.loaded = true
+	}
+
+	entries = slices.DeleteFunc(entries, func(e workspace.WalkEntry) bool { return e.IsDir || !IsNote(e.Path) })
+	x.indexed.Store(0)
+	x.total.Store(int64(len(entries)))
+	seen := make(map[string]bool, len(x.notes))
+	changed := false
+	for _, e := range entries {
+		x.indexed.Add(1)
+		seen[e.Path] = true
+		if x.reread(ws, e.Path) {
+			changed = true
+		}
+	}
+	for p := range x.notes {
+		if !seen[p] {
+			delete(x.notes, p)
+			changed = true
+		}
+	}
+	x.saveIf(ws, changed)
+	x.ready.Store(true)
+	return nil
+}
+
+// Update rereads the notes at paths, such as the watcher reports, and
+// drops those that are gone. A folder's path covers the notes under it.
+// Before the index is built it does nothing, as the build sees them all.
+func (x *Index) Update(ws *workspace.Workspace, paths ...string) {
+	ws = ws.Full()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.ready.Load() {
+		return
+	}
+	changed := false
+	for _, p := range paths {
+		// Walks leave out hidden folders at the root, and so does the index.
+		if p == "." || strings.HasPrefix(p, ".") {
+			continue
+		}
+		for q := range x.notes {
+			if q == p || strings.HasPrefix(q, p+"/") {
+				if _, err := ws.Stat(q); err != nil {
+					delete(x.notes, q)
+					changed = true
+				}
+			}
+		}
+		info, err := ws.Stat(p)
+		switch {
+		case err != nil:
+		case info.IsDir():
+			if x.rereadDir(ws, p) {
+				changed = true
+			}
+		case IsNote(p):
+			if x.reread(ws, p) {
+				changed = true
+			}
+		}
+	}
+	x.saveIf(ws, changed)
+}
+
+// reread reads the note at p again if its size or modification time
+// changed, and reports whether it did.
+func (x *Index) reread(ws *workspace.Workspace, p string) bool {
+	info, err := ws.Stat(p)
+	if err != nil {
+		return false
+	}
+	cached, ok := x.notes[p]
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return false
+	}
+	// A binary file named .md is indexed by its name; an oversized note by
+	// its start, which holds its frontmatter and title.
+	content, _, err := textfile.Read(ws, p)
+	if err != nil {
+		return false
+	}
+	x.notes[p] = indexed{note: Parse(p, content), modTime: info.ModTime(), size: info.Size()}
+	return true
+}
+
+// rereadDir rereads the notes under the folder dir, and reports whether
+// any changed.
+func (x *Index) rereadDir(ws *workspace.Workspace, dir string) bool {
+	entries, err := ws.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	changed := false
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		if e.IsDir() && x.rereadDir(ws, p) || !e.IsDir() && IsNote(p) && x.reread(ws, p) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (x *Index) saveIf(ws *workspace.Workspace, changed bool) {
+	if !changed {
+		return
+	}
+	// The cache only saves work; the index is right without it.
+	if err := x.save(ws); err != nil {
+		slog.Warn("saving note index", "err", err)
+	}
+}
+
+// namedAfterFiles stands in for the index while it is first built.
+func namedAfterFiles(view *workspace.Workspace, entries []workspace.WalkEntry) []Note {
+	result := []Note{}
+	for _, e := range entries {
+		if !e.IsDir && IsNote(e.Path) && !view.IsLocked(e.Path) {
+			result = append(result, Parse(e.Path, ""))
+		}
+	}
+	return result
+}
+
+// Parse reads a note's title from its frontmatter, falling back to its first
+// heading and then its file name.
+func Parse(p, content string) Note {
+	doc := frontmatter.Parse(content)
+	n := Note{Path: p, Title: doc.String("title"), Aliases: doc.List("aliases"), Type: doc.String("type")}
+	if n.Aliases == nil {
+		n.Aliases = []string{}
+	}
+	if n.Title == "" {
+		for _, line := range strings.Split(doc.Body, "\n") {
+			if heading, ok := strings.CutPrefix(line, "# "); ok {
+				n.Title = strings.TrimSpace(heading)
+				break
+			}
+		}
+	}
+	if n.Title == "" {
+		name := path.Base(p)
+		n.Title = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return n
+}
+
+// Visit records that the note at p was opened.
+func (x *Index) Visit(p string, now time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	v := x.visits[p]
+	v.count++
+	v.last = now
+	x.visits[p] = v
+}
+
+// Frecency scores how often and how recently the note at p was opened, with
+// recent visits weighing more. Notes that were never opened score 0.
+func (x *Index) Frecency(p string, now time.Time) int {
+	x.mu.Lock()
+	v, ok := x.visits[p]
+	x.mu.Unlock()
+	if !ok {
+		return 0
+	}
+
+	age := now.Sub(v.last)
+	weight := 10
+	switch {
+	case age < 4*24*time.Hour:
+		weight = 100
+	case age < 14*24*time.Hour:
+		weight = 70
+	case age < 31*24*time.Hour:
+		weight = 50
+	case age < 90*24*time.Hour:
+		weight = 30
+	}
+	return v.count * weight
+}
+
+type Match struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	// By is what name matched: "path", "title", "alias" or "filename".
+	By string `json:"by"`
+}
+
+// Resolve finds the notes a human-written name such as a wiki link target
+// refers to. A workspace path, with or without the .md extension, wins
+// outright; otherwise every note whose title, alias or file name equals the
+// name, ignoring case, is a match.
+func Resolve(all []Note, name string) []Match {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	for _, n := range all {
+		if n.Path == name || strings.TrimSuffix(n.Path, path.Ext(n.Path)) == name {
+			return []Match{{Path: n.Path, Title: n.Title, By: "path"}}
+		}
+	}
+
+	var matches []Match
+	for _, n := range all {
+		filename := path.Base(n.Path)
+		filename = strings.TrimSuffix(filename, path.Ext(filename))
+		switch {
+		case strings.EqualFold(n.Title, name):
+			matches = append(matches, Match{Path: n.Path, Title: n.Title, By: "title"})
+		case slices.ContainsFunc(n.Aliases, func(a string) bool { return strings.EqualFold(a, name) }):
+			matches = append(matches, Match{Path: n.Path, Title: n.Title, By: "alias"})
+		case strings.EqualFold(filename, name):
+			matches = append(matches, Match{Path: n.Path, Title: n.Title, By: "filename"})
+		}
+	}
+	return matches
+}
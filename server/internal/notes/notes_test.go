@@ -0,0 +1,166 @@
+package notes_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestIndex(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(t *testing.T, p, content string) {
+		t.Helper()
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := notes.NewIndex()
+
+	check := func(t *testing.T, want []notes.Note) {
+		t.Helper()
+		got, err := index.Notes(ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Notes =\n%+v\nwant\n%+v", got, want)
+		}
+	}
+
+	write(t, "a.md", "---\ntitle: Alpha\naliases: [First, A]\ntype: person\n---\n# Ignored\n")
+	write(t, "b.md", "Intro\n# Beta Heading\n")
+	write(t, "c.md", "no title\n")
+	write(t, "d.txt", "# Not a note\n")
+	if p := index.Progress(); p.Ready {
+		t.Errorf("Progress before the first build = %+v", p)
+	}
+	check(t, []notes.Note{
+		{Path: "a.md", Title: "Alpha", Aliases: []string{"First", "A"}, Type: "person"},
+		{Path: "b.md", Title: "Beta Heading", Aliases: []string{}},
+		{Path: "c.md", Title: "c", Aliases: []string{}},
+	})
+	if p := index.Progress(); p != (notes.Progress{Ready: true, Indexed: 3, Total: 3}) {
+		t.Errorf("Progress after the first build = %+v", p)
+	}
+
+	t.Run("changed and removed notes are picked up on update", func(t *testing.T) {
+		write(t, "a.md", "---\ntitle: Alpha Two\n---\n")
+		abs, err := ws.Resolve("a.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(abs, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.Remove("c.md"); err != nil {
+			t.Fatal(err)
+		}
+		// Lookups answer from memory until told what changed.
+		check(t, []notes.Note{
+			{Path: "a.md", Title: "Alpha", Aliases: []string{"First", "A"}, Type: "person"},
+			{Path: "b.md", Title: "Beta Heading", Aliases: []string{}},
+			{Path: "c.md", Title: "c", Aliases: []string{}},
+		})
+		index.Update(ws, "a.md", "c.md")
+		check(t, []notes.Note{
+			{Path: "a.md", Title: "Alpha Two", Aliases: []string{}},
+			{Path: "b.md", Title: "Beta Heading", Aliases: []string{}},
+		})
+	})
+
+	t.Run("updating a folder covers the notes in it", func(t *testing.T) {
+		if err := ws.MkdirAll("journal", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		write(t, "journal/monday.md", "# Monday\n")
+		index.Update(ws, "journal")
+		got, err := index.Notes(ws)
+		if err != nil || len(got) != 3 || got[2].Title != "Monday" {
+			t.Fatalf("Notes after adding a folder = %+v, %v", got, err)
+		}
+		if err := ws.RemoveAll("journal"); err != nil {
+			t.Fatal(err)
+		}
+		index.Update(ws, "journal")
+		check(t, []notes.Note{
+			{Path: "a.md", Title: "Alpha Two", Aliases: []string{}},
+			{Path: "b.md", Title: "Beta Heading", Aliases: []string{}},
+		})
+	})
+
+	t.Run("a new index starts from the saved one", func(t *testing.T) {
+		abs, err := ws.Resolve("b.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Same size and time, so only a reread would notice.
+		write(t, "b.md", "Intro\n# Beta Secret\n ")
+		if err := os.Chtimes(abs, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+		index = notes.NewIndex()
+		check(t, []notes.Note{
+			{Path: "a.md", Title: "Alpha Two", Aliases: []string{}},
+			{Path: "b.md", Title: "Beta Heading", Aliases: []string{}},
+		})
+	})
+}
+
+func TestFrecency(t *testing.T) {
+	index := notes.NewIndex()
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	index.Visit("old.md", now.AddDate(0, -6, 0))
+	index.Visit("old.md", now.AddDate(0, -6, 0))
+	index.Visit("old.md", now.AddDate(0, -6, 0))
+	index.Visit("recent.md", now.Add(-time.Hour))
+
+	if got := index.Frecency("never.md", now); got != 0 {
+		t.Errorf("unvisited frecency = %d", got)
+	}
+	if old, recent := index.Frecency("old.md", now), index.Frecency("recent.md", now); recent <= old {
+		t.Errorf("recent visit scored %d, below three old visits at %d", recent, old)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	all := []notes.Note{
+		{Path: "people/ada.md", Title: "Ada Lovelace", Aliases: []string{"Countess of Lovelace"}},
+		{Path: "ships/ada.md", Title: "HMS Ada", Aliases: []string{}},
+		{Path: "lang/Ada Lovelace.md", Title: "Ada (language)", Aliases: []string{}},
+	}
+
+	check := func(t *testing.T, name string, want ...notes.Match) {
+		t.Helper()
+		got := notes.Resolve(all, name)
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("Resolve(%q) = %+v, want %+v", name, got, want)
+		}
+	}
+
+	check(t, "people/ada", notes.Match{Path: "people/ada.md", Title: "Ada Lovelace", By: "path"})
+	check(t, "ships/ada.md", notes.Match{Path: "ships/ada.md", Title: "HMS Ada", By: "path"})
+	check(t, "countess of lovelace", notes.Match{Path: "people/ada.md", Title: "Ada Lovelace", By: "alias"})
+	check(t, "ada lovelace",
+		notes.Match{Path: "people/ada.md", Title: "Ada Lovelace", By: "title"},
+		notes.Match{Path: "lang/Ada Lovelace.md", Title: "Ada (language)", By: "filename"},
+	)
+	check(t, "ada",
+		notes.Match{Path: "people/ada.md", Title: "Ada Lovelace", By: "filename"},
+		notes.Match{Path: "ships/ada.md", Title: "HMS Ada", By: "filename"},
+	)
+	check(t, "babbage")
+	check(t, " ")
+}
@@ -0,0 +1,135 @@
+// Package ocr extracts the text of scanned documents, images and PDFs
+// without a text layer, by running tesseract. PDF pages are rendered to
+// images with pdftoppm first.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var imageExts = []string{".png", ".jpg", ".jpeg", ".tif", ".tiff", ".bmp", ".gif", ".webp"}
+
+// ErrHasText is returned for PDFs that already have a text layer.
+var ErrHasText = errors.New("document already has text")
+
+// IsScannable reports whether p is an image or a PDF, the files OCR can
+// read.
+func IsScannable(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	return ext == ".pdf" || slices.Contains(imageExts, ext)
+}
+
+// SidecarPath is where the text extracted from p is stored: next to it,
+// with .txt added, so "scan.pdf" gets "scan.pdf.txt".
+func SidecarPath(p string) string {
+	return p + ".txt"
+}
+
+// HasText reports whether a PDF has a text layer, going by whether it
+// declares any fonts. Fonts hidden in compressed object streams are missed,
+// which only means a PDF is scanned when it didn't need to be.
+func HasText(pdf []byte) bool {
+	return bytes.Contains(pdf, []byte("/Font"))
+}
+
+type Engine struct {
+	Tesseract string
+	Pdftoppm  string
+	// Lang is passed to tesseract's -l, such as "eng" or "eng+deu".
+	Lang string
+}
+
+// FromEnv returns an engine running the tesseract at WISDOM_TESSERACT, in
+// the languages in WISDOM_OCR_LANG, and the pdftoppm at WISDOM_PDFTOPPM
+// (from PATH by default). OCR is opt-in, so it returns nil without
+// WISDOM_TESSERACT.
+func FromEnv() *Engine {
+	tesseract := os.Getenv("WISDOM_TESSERACT")
+	if tesseract == "" {
+		return nil
+	}
+	pdftoppm := os.Getenv("WISDOM_PDFTOPPM")
+	if pdftoppm == "" {
+		pdftoppm = "pdftoppm"
+	}
+	return &Engine{Tesseract: tesseract, Pdftoppm: pdftoppm, Lang: os.Getenv("WISDOM_OCR_LANG")}
+}
+
+// Extract returns the text of the document named name. The content is
+// copied to a temporary directory first, since the workspace may be
+// encrypted on disk.
+func (e *Engine) Extract(ctx context.Context, name string, content io.Reader) (string, error) {
+	dir, err := os.MkdirTemp("", "wisdom-ocr-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "source"+strings.ToLower(path.Ext(name)))
+	f, err := os.Create(src)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pages := []string{src}
+	if strings.EqualFold(path.Ext(name), ".pdf") {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return "", err
+		}
+		if HasText(data) {
+			return "", ErrHasText
+		}
+		if _, err := run(ctx, e.Pdftoppm, "-r", "300", "-png", src, filepath.Join(dir, "page")); err != nil {
+			return "", err
+		}
+		// pdftoppm zero-pads page numbers, so names sort in page order.
+		if pages, err = filepath.Glob(filepath.Join(dir, "page-*.png")); err != nil {
+			return "", err
+		}
+		slices.Sort(pages)
+	}
+
+	var texts []string
+	for _, page := range pages {
+		args := []string{page, "stdout"}
+		if e.Lang != "" {
+			args = append(args, "-l", e.Lang)
+		}
+		text, err := run(ctx, e.Tesseract, args...)
+		if err != nil {
+			return "", err
+		}
+		texts = append(texts, strings.TrimSpace(text))
+	}
+	// Pages are separated by form feeds, as pdftotext does.
+	return strings.Join(texts, "\n\f\n") + "\n", nil
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", filepath.Base(name), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
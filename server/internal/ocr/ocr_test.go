@@ -0,0 +1,59 @@
+package ocr_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/ocr"
+)
+
+// fakeTools writes stand-ins for tesseract, which prints the name of the
+// image it was given, and pdftoppm, which renders two pages.
+func fakeTools(t *testing.T) *ocr.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"tesseract": "#!/bin/sh\necho \"text of $(basename \"$1\") $4\"\n",
+		"pdftoppm":  "#!/bin/sh\ntouch \"$5-2.png\" \"$5-1.png\"\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &ocr.Engine{Tesseract: filepath.Join(dir, "tesseract"), Pdftoppm: filepath.Join(dir, "pdftoppm"), Lang: "eng"}
+}
+
+func TestExtract(t *testing.T) {
+	e := fakeTools(t)
+	ctx := context.Background()
+
+	text, err := e.Extract(ctx, "receipt.JPG", strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "text of source.jpg eng\n" {
+		t.Errorf("image text = %q", text)
+	}
+
+	text, err = e.Extract(ctx, "scan.pdf", strings.NewReader("%PDF-1.4 /XObject /Image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "text of page-1.png eng\n\f\ntext of page-2.png eng\n" {
+		t.Errorf("pdf text = %q", text)
+	}
+
+	if _, err := e.Extract(ctx, "paper.pdf", strings.NewReader("%PDF-1.4 /Font /F1")); !errors.Is(err, ocr.ErrHasText) {
+		t.Errorf("err = %v, want ErrHasText", err)
+	}
+
+	e.Tesseract = filepath.Join(t.TempDir(), "missing")
+	if _, err := e.Extract(ctx, "receipt.png", strings.NewReader("png")); err == nil {
+		t.Error("expected an error without tesseract")
+	}
+}
@@ -0,0 +1,626 @@
+// Package oidc signs users in with an OpenID Connect provider, such as
+// Authelia or Keycloak, so a homelab can guard Wisdom with the identity
+// provider it already runs.
+//
+// It uses the authorization code flow with PKCE. The provider's endpoints
+// and keys are discovered from the issuer on first use, so the server
+// starts even while the provider is down. Sessions are kept in memory and
+// end when the server restarts.
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Cookie carries a signed-in browser's session.
+	Cookie = "wisdom_session"
+	// SessionTTL is how long a session lasts after it was last used.
+	SessionTTL = 7 * 24 * time.Hour
+	// loginTTL is how long a user has to sign in at the provider.
+	loginTTL = 10 * time.Minute
+	// skew allows for clocks that are slightly off between the server and
+	// the provider.
+	skew = time.Minute
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid ID token")
+	ErrNotAllowed   = errors.New("user is not allowed")
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the server's /auth/callback as the provider reaches
+	// it, which must be registered with the provider.
+	RedirectURL string
+	// Claim names the ID token claim that identifies the user.
+	Claim string
+	// Users maps values of Claim to local user names. When it is empty,
+	// every user the provider signs in is let in under their claim value.
+	Users map[string]string
+}
+
+// FromEnv returns a provider for WISDOM_OIDC_ISSUER, signing in as
+// WISDOM_OIDC_CLIENT_ID with WISDOM_OIDC_CLIENT_SECRET and called back at
+// WISDOM_OIDC_REDIRECT_URL, or nil when no issuer is set. Users are
+// identified by the claim in WISDOM_OIDC_CLAIM, preferred_username by
+// default, and WISDOM_OIDC_USERS limits who is let in, as a comma-separated
+// list of claim values, each optionally mapped to a local name with "=":
+// "alice@example.com=alice,bob".
+func FromEnv() (*Provider, error) {
+	issuer := os.Getenv("WISDOM_OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	c := Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("WISDOM_OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("WISDOM_OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("WISDOM_OIDC_REDIRECT_URL"),
+		Claim:        os.Getenv("WISDOM_OIDC_CLAIM"),
+	}
+	if users := os.Getenv("WISDOM_OIDC_USERS"); users != "" {
+		c.Users = map[string]string{}
+		for _, entry := range strings.Split(users, ",") {
+			value, name, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				name = value
+			}
+			if value == "" || name == "" {
+				return nil, fmt.Errorf("WISDOM_OIDC_USERS: invalid entry %q", entry)
+			}
+			c.Users[value] = name
+		}
+	}
+	return New(c)
+}
+
+func New(c Config) (*Provider, error) {
+	if c.ClientID == "" || c.RedirectURL == "" {
+		return nil, errors.New("an OIDC issuer needs a client ID and a redirect URL")
+	}
+	if u, err := url.Parse(c.RedirectURL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid redirect URL %q", c.RedirectURL)
+	}
+	c.Issuer = strings.TrimSuffix(c.Issuer, "/")
+	if c.Claim == "" {
+		c.Claim = "preferred_username"
+	}
+	return &Provider{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		config:   c,
+		pending:  map[string]login{},
+		sessions: map[string]*Session{},
+	}, nil
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// login is a sign-in started at the provider, found again by its state.
+type login struct {
+	nonce    string
+	verifier string
+	next     string
+	expires  time.Time
+}
+
+type Session struct {
+	// ID names the session when it is listed. It isn't the cookie's
+	// value, which would let whoever lists sessions take them over.
+	ID        string
+	User      string
+	Created   time.Time
+	Expires   time.Time
+	LastSeen  time.Time
+	UserAgent string
+	Addr      string
+}
+
+// Provider signs users in with an OpenID Connect provider and keeps their
+// sessions.
+type Provider struct {
+	Client *http.Client
+	config Config
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	pending     map[string]login
+	sessions    map[string]*Session
+}
+
+// Handler serves the sign-in flow: GET /auth/login?next= sends the browser
+// to the provider, which sends it back to GET /auth/callback, and
+// POST /auth/logout ends the session. GET /auth/me returns the signed-in
+// user.
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/login", p.login)
+	mux.HandleFunc("GET /auth/callback", p.callback)
+	mux.HandleFunc("POST /auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(Cookie); err == nil {
+			p.mu.Lock()
+			delete(p.sessions, c.Value)
+			p.mu.Unlock()
+		}
+		http.SetCookie(w, &http.Cookie{Name: Cookie, Path: "/", MaxAge: -1})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /auth/me", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := p.User(r, time.Now())
+		if !ok {
+			http.Error(w, "not signed in", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"user": user})
+	})
+	return mux
+}
+
+func randomString(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// localPath returns next if it is a path on this server, so the login
+// can't be used to send users elsewhere, and "/" otherwise.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func (p *Provider) login(w http.ResponseWriter, r *http.Request) {
+	meta, err := p.discover()
+	if err != nil {
+		http.Error(w, "reaching the identity provider: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	now := time.Now()
+	state := randomString(16)
+	l := login{
+		nonce:    randomString(16),
+		verifier: randomString(32),
+		next:     localPath(r.URL.Query().Get("next")),
+		expires:  now.Add(loginTTL),
+	}
+	p.mu.Lock()
+	for s, pending := range p.pending {
+		if now.After(pending.expires) {
+			delete(p.pending, s)
+		}
+	}
+	p.pending[state] = l
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(l.verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {"openid profile email"},
+		"state":                 {state},
+		"nonce":                 {l.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, meta.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+}
+
+func (p *Provider) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "sign-in failed: "+e+" "+q.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	p.mu.Lock()
+	l, ok := p.pending[q.Get("state")]
+	delete(p.pending, q.Get("state"))
+	p.mu.Unlock()
+	if !ok || now.After(l.expires) {
+		http.Error(w, "sign-in expired, try again", http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := p.exchange(q.Get("code"), l.verifier)
+	if err != nil {
+		http.Error(w, "reaching the identity provider: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	user, err := p.verify(idToken, l.nonce, now)
+	if errors.Is(err, ErrNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token := randomString(32)
+	s := &Session{
+		ID:        randomString(8),
+		User:      user,
+		Created:   now,
+		Expires:   now.Add(SessionTTL),
+		LastSeen:  now,
+		UserAgent: r.UserAgent(),
+		Addr:      remoteHost(r),
+	}
+	p.mu.Lock()
+	p.sessions[token] = s
+	p.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     Cookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(p.config.RedirectURL, "https://"),
+		// Lax, so following a link to a note from elsewhere keeps the
+		// session.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, l.next, http.StatusFound)
+}
+
+// User returns the user signed in with the session of r, and extends the
+// session.
+func (p *Provider) User(r *http.Request, now time.Time) (string, bool) {
+	c, err := r.Cookie(Cookie)
+	if err != nil {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for t, s := range p.sessions {
+		if now.After(s.Expires) {
+			delete(p.sessions, t)
+		}
+	}
+	s, ok := p.sessions[c.Value]
+	if !ok {
+		return "", false
+	}
+	s.Expires = now.Add(SessionTTL)
+	s.LastSeen, s.UserAgent, s.Addr = now, r.UserAgent(), remoteHost(r)
+	return s.User, true
+}
+
+// Sessions returns the live sessions, oldest first.
+func (p *Provider) Sessions(now time.Time) []Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var list []Session
+	for _, s := range p.sessions {
+		if !now.After(s.Expires) {
+			list = append(list, *s)
+		}
+	}
+	slices.SortFunc(list, func(a, b Session) int { return a.Created.Compare(b.Created) })
+	return list
+}
+
+// End signs out the session with id, reporting whether there was one.
+func (p *Provider) End(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for t, s := range p.sessions {
+		if s.ID == id {
+			delete(p.sessions, t)
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (p *Provider) getJSON(u string, v any) error {
+	resp, err := p.Client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover returns the provider's endpoints, fetching them the first time.
+func (p *Provider) discover() (*metadata, error) {
+	p.mu.Lock()
+	meta := p.meta
+	p.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+	meta = &metadata{}
+	if err := p.getJSON(p.config.Issuer+"/.well-known/openid-configuration", meta); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("provider claims to be issuer %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("provider metadata lacks an endpoint")
+	}
+	p.mu.Lock()
+	p.meta = meta
+	p.mu.Unlock()
+	return meta, nil
+}
+
+// exchange trades the authorization code for an ID token.
+func (p *Provider) exchange(code, verifier string) (string, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint: %w", err)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint sent no ID token")
+	}
+	return body.IDToken, nil
+}
+
+// audience is the aud claim, which is a string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*a = audience{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// verify checks the ID token's signature and claims, and returns the local
+// name of its user.
+func (p *Provider) verify(raw, nonce string, now time.Time) (string, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodePart(parts[0], &header); err != nil {
+		return "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	key, err := p.key(header.Kid, now)
+	if err != nil {
+		return "", err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return "", err
+	}
+
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Audience audience `json:"aud"`
+		Expiry   int64    `json:"exp"`
+		Nonce    string   `json:"nonce"`
+	}
+	if err := decodePart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != p.config.Issuer:
+		return "", fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return "", fmt.Errorf("%w: not issued to this client", ErrInvalidToken)
+	case now.After(time.Unix(claims.Expiry, 0).Add(skew)):
+		return "", fmt.Errorf("%w: expired", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return "", fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	var all map[string]any
+	if err := decodePart(parts[1], &all); err != nil {
+		return "", err
+	}
+	value, _ := all[p.config.Claim].(string)
+	if value == "" {
+		return "", fmt.Errorf("%w: no %s claim", ErrInvalidToken, p.config.Claim)
+	}
+	if len(p.config.Users) == 0 {
+		return value, nil
+	}
+	name, ok := p.config.Users[value]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotAllowed, value)
+	}
+	return name, nil
+}
+
+func decodePart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		if k, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil {
+			return nil
+		}
+	case "ES256":
+		k, ok := key.(*ecdsa.PublicKey)
+		if ok && len(sig) == 64 {
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(k, sum[:], r, s) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+}
+
+// key returns the provider's signing key with id kid, fetching the keys
+// again when it is unknown, as providers rotate them, but at most once a
+// minute.
+func (p *Provider) key(kid string, now time.Time) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := now.Sub(p.keysFetched) > time.Minute
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	meta, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	p.mu.Lock()
+	p.keys, p.keysFetched = keys, now
+	p.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid EC key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid EC key")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,177 @@
+package oidc_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/oidc"
+)
+
+// fakeProvider is an identity provider that signs in whoever it is told
+// to.
+type fakeProvider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	user  string
+	nonce string
+	// tamper breaks the signature of the ID tokens it issues.
+	tamper bool
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "wisdom" || secret != "s3cret" || r.FormValue("code") != "the-code" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": f.idToken(t)})
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeProvider) idToken(t *testing.T) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":                f.URL,
+		"aud":                "wisdom",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              f.nonce,
+		"preferred_username": f.user,
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.tamper {
+		sig[0] ^= 1
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestSignIn(t *testing.T) {
+	idp := newFakeProvider(t)
+	p, err := oidc.New(oidc.Config{
+		Issuer:       idp.URL,
+		ClientID:     "wisdom",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://notes.example.com/auth/callback",
+		Users:        map[string]string{"alice@example.com": "alice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := p.Handler()
+
+	// signIn goes through the flow as user and returns the callback's
+	// response.
+	signIn := func(t *testing.T, user, next string) *http.Response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/login?next="+url.QueryEscape(next), nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("login = %d %s", w.Code, w.Body)
+		}
+		to, _ := url.Parse(w.Header().Get("Location"))
+		q := to.Query()
+		if !strings.HasPrefix(to.String(), idp.URL+"/authorize?") || q.Get("code_challenge_method") != "S256" ||
+			q.Get("redirect_uri") != "https://notes.example.com/auth/callback" {
+			t.Fatalf("login sent the browser to %s", to)
+		}
+		idp.user, idp.nonce = user, q.Get("nonce")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/callback?code=the-code&state="+q.Get("state"), nil))
+		return w.Result()
+	}
+
+	resp := signIn(t, "alice@example.com", "/notes/inbox.md")
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/notes/inbox.md" {
+		t.Fatalf("callback = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidc.Cookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("session cookies = %v", cookies)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/fs/", nil)
+	r.AddCookie(cookies[0])
+	if user, ok := p.User(r, time.Now()); !ok || user != "alice" {
+		t.Errorf("User = %q, %v, want alice", user, ok)
+	}
+	sessions := p.Sessions(time.Now())
+	if len(sessions) != 1 || sessions[0].User != "alice" || sessions[0].ID == cookies[0].Value {
+		t.Fatalf("Sessions = %+v, want alice's, not named by its cookie", sessions)
+	}
+	if !p.End(sessions[0].ID) {
+		t.Fatal("End found no session")
+	}
+	if _, ok := p.User(r, time.Now()); ok {
+		t.Error("the session outlived End")
+	}
+
+	t.Run("replayed state", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/callback?code=the-code&state=unknown", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("callback with an unknown state = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("user not allowed", func(t *testing.T) {
+		if resp := signIn(t, "mallory@example.com", "/"); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("callback = %d, want 403", resp.StatusCode)
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		idp.tamper = true
+		defer func() { idp.tamper = false }()
+		if resp := signIn(t, "alice@example.com", "/"); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("callback = %d, want 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("open redirect", func(t *testing.T) {
+		resp := signIn(t, "alice@example.com", "//evil.example.com/")
+		if got := resp.Header.Get("Location"); got != "/" {
+			t.Errorf("redirected to %q, want /", got)
+		}
+	})
+}
@@ -0,0 +1,267 @@
+// Package opds serves the workspace as an OPDS 1.2 catalog so e-reader apps
+// can browse it and download books.
+package opds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/api"
+	"github.com/shrik450/wisdom/internal/baseurl"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	navigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	acquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	acquisitionRel  = "http://opds-spec.org/acquisition"
+	searchLimit     = 50
+)
+
+var bookTypes = map[string]string{
+	".epub": "application/epub+zip",
+	".pdf":  "application/pdf",
+	".mobi": "application/x-mobipocket-ebook",
+	".azw3": "application/x-mobi8-ebook",
+	".fb2":  "application/x-fictionbook+xml",
+	".cbz":  "application/vnd.comicbook+zip",
+	".cbr":  "application/vnd.comicbook-rar",
+	".djvu": "image/vnd.djvu",
+}
+
+type feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Author  author   `xml:"author"`
+	Links   []link   `xml:"link"`
+	Entries []entry  `xml:"entry"`
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type entry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Links   []link `xml:"link"`
+}
+
+type link struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/opds/search", searchHandler())
+	mux.Handle("/opds/{path...}", catalogHandler())
+	return mux
+}
+
+func bookType(name string) (string, bool) {
+	t, ok := bookTypes[strings.ToLower(filepath.Ext(name))]
+	return t, ok
+}
+
+// escapePath escapes each segment of a workspace-relative path for use in a
+// URL.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func catalogHref(dir string) string {
+	if dir == "." {
+		return "/opds/"
+	}
+	return "/opds/" + escapePath(dir) + "/"
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func bookEntry(p string, modTime time.Time, mediaType string) entry {
+	name := path.Base(p)
+	return entry{
+		ID:      "urn:wisdom:" + p,
+		Title:   strings.TrimSuffix(name, path.Ext(name)),
+		Updated: formatTime(modTime),
+		Links: []link{{
+			Rel:  acquisitionRel,
+			Href: "/api/fs/" + escapePath(p),
+			Type: mediaType,
+		}},
+	}
+}
+
+func commonLinks(self, selfType string) []link {
+	return []link{
+		{Rel: "self", Href: self, Type: selfType},
+		{Rel: "start", Href: "/opds/", Type: navigationType},
+		{Rel: "search", Href: "/opds/search?q={searchTerms}", Type: acquisitionType},
+	}
+}
+
+// writeFeed writes f, with its links under the server's base path.
+func writeFeed(w http.ResponseWriter, f feed, feedType string) {
+	prefix := func(links []link) {
+		for i := range links {
+			links[i].Href = baseurl.Path() + links[i].Href
+		}
+	}
+	prefix(f.Links)
+	for _, e := range f.Entries {
+		prefix(e.Links)
+	}
+	data, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", feedType)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+func catalogHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		dir := strings.TrimPrefix(path.Clean("/"+r.PathValue("path")), "/")
+		if dir == "" {
+			dir = "."
+		}
+
+		ws := workspace.FromContext(r.Context())
+		info, err := ws.Stat(dir)
+		if err != nil {
+			http.Error(w, "catalog not found", http.StatusNotFound)
+			return
+		}
+		if !info.IsDir() {
+			http.Error(w, "not a directory", http.StatusNotFound)
+			return
+		}
+		dirEntries, err := ws.ReadDir(dir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		title := "Wisdom"
+		if dir != "." {
+			title = path.Base(dir)
+		}
+
+		var entries []entry
+		hasBooks := false
+		for _, e := range dirEntries {
+			name := e.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			p := name
+			if dir != "." {
+				p = dir + "/" + name
+			}
+			eInfo, err := e.Info()
+			if err != nil {
+				continue
+			}
+
+			if e.IsDir() {
+				entries = append(entries, entry{
+					ID:      "urn:wisdom:" + p + "/",
+					Title:   name,
+					Updated: formatTime(eInfo.ModTime()),
+					Links: []link{{
+						Rel:  "subsection",
+						Href: catalogHref(p),
+						Type: navigationType,
+					}},
+				})
+				continue
+			}
+			if mediaType, ok := bookType(name); ok {
+				hasBooks = true
+				entries = append(entries, bookEntry(p, eInfo.ModTime(), mediaType))
+			}
+		}
+
+		feedType := navigationType
+		if hasBooks {
+			feedType = acquisitionType
+		}
+		writeFeed(w, feed{
+			ID:      "urn:wisdom:" + dir,
+			Title:   title,
+			Updated: formatTime(info.ModTime()),
+			Author:  author{Name: "Wisdom"},
+			Links:   commonLinks(catalogHref(dir), feedType),
+			Entries: entries,
+		}, feedType)
+	})
+}
+
+func searchHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		ws := workspace.FromContext(r.Context())
+
+		var entries []entry
+		if query != "" {
+			all, err := ws.WalkFiles()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			books := make([]workspace.WalkEntry, 0, len(all))
+			for _, e := range all {
+				if _, ok := bookType(e.Path); ok && !e.IsDir {
+					books = append(books, e)
+				}
+			}
+			for _, result := range api.FuzzySearch(query, books, searchLimit) {
+				info, err := ws.Stat(result.Path)
+				if err != nil {
+					continue
+				}
+				mediaType, _ := bookType(result.Path)
+				entries = append(entries, bookEntry(result.Path, info.ModTime(), mediaType))
+			}
+		}
+
+		writeFeed(w, feed{
+			ID:      "urn:wisdom:search:" + query,
+			Title:   "Search: " + query,
+			Updated: formatTime(time.Now()),
+			Author:  author{Name: "Wisdom"},
+			Links:   commonLinks("/opds/search?q="+url.QueryEscape(query), acquisitionType),
+			Entries: entries,
+		}, acquisitionType)
+	})
+}
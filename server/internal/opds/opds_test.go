@@ -0,0 +1,126 @@
+package opds_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/opds"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type feed struct {
+	Title   string  `xml:"title"`
+	Entries []entry `xml:"entry"`
+}
+
+type entry struct {
+	Title string `xml:"title"`
+	Links []link `xml:"link"`
+}
+
+type link struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"books/sci fi", "notes", ".git"} {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{
+		"books/Dune.epub",
+		"books/sci fi/Solaris.pdf",
+		"books/cover.jpg",
+		"notes/todo.md",
+	} {
+		if err := ws.WriteFile(file, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(middleware.WithWorkspace(opds.Handler(), ws))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCatalog(t *testing.T) {
+	srv := newTestServer(t)
+
+	check := func(t *testing.T, path string, wantKind string, want map[string]link) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("status=%d body=%s", resp.StatusCode, body)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasSuffix(ct, "kind="+wantKind) {
+			t.Fatalf("content-type = %q, want kind=%s", ct, wantKind)
+		}
+
+		var f feed
+		if err := xml.NewDecoder(resp.Body).Decode(&f); err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]link)
+		for _, e := range f.Entries {
+			got[e.Title] = e.Links[0]
+		}
+		if len(got) != len(want) {
+			t.Fatalf("entries = %+v, want %+v", got, want)
+		}
+		for title, l := range want {
+			if got[title] != l {
+				t.Fatalf("entry %q = %+v, want %+v", title, got[title], l)
+			}
+		}
+	}
+
+	t.Run("root lists directories", func(t *testing.T) {
+		check(t, "/opds/", "navigation", map[string]link{
+			"books": {Rel: "subsection", Href: "/opds/books/", Type: "application/atom+xml;profile=opds-catalog;kind=navigation"},
+			"notes": {Rel: "subsection", Href: "/opds/notes/", Type: "application/atom+xml;profile=opds-catalog;kind=navigation"},
+		})
+	})
+
+	t.Run("directory lists books and subsections", func(t *testing.T) {
+		check(t, "/opds/books/", "acquisition", map[string]link{
+			"Dune":   {Rel: "http://opds-spec.org/acquisition", Href: "/api/fs/books/Dune.epub", Type: "application/epub+zip"},
+			"sci fi": {Rel: "subsection", Href: "/opds/books/sci%20fi/", Type: "application/atom+xml;profile=opds-catalog;kind=navigation"},
+		})
+	})
+
+	t.Run("search finds books only", func(t *testing.T) {
+		check(t, "/opds/search?q=sol", "acquisition", map[string]link{
+			"Solaris": {Rel: "http://opds-spec.org/acquisition", Href: "/api/fs/books/sci%20fi/Solaris.pdf", Type: "application/pdf"},
+		})
+	})
+
+	t.Run("missing and file paths are not catalogs", func(t *testing.T) {
+		for _, path := range []string{"/opds/nope/", "/opds/books/Dune.epub", "/opds/../../etc/"} {
+			resp, err := http.Get(srv.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("%s: status=%d, want 404", path, resp.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Package people finds the notes about people, those with "type: person" in
+// their frontmatter, and where the rest of the workspace mentions them,
+// either as @name or as a [[Person]] wiki link.
+package people
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Type = "person"
+
+var (
+	// wikiLink skips ![[embeds]], which are attachments.
+	wikiLink  = regexp.MustCompile(`(?:^|[^!])\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]`)
+	atMention = regexp.MustCompile(`(?:^|[\s(])@([\p{L}\p{N}_.-]+)`)
+	datedName = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+)
+
+type Mention struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	// Date is the note's date: its date property, a date in its file name,
+	// or else the day it was last modified.
+	Date string `json:"date"`
+}
+
+// People returns the person notes among all.
+func People(all []notes.Note) []notes.Note {
+	result := []notes.Note{}
+	for _, n := range all {
+		if strings.EqualFold(n.Type, Type) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// handle reduces a name to how it's written after an @, so @ada-lovelace,
+// @AdaLovelace and @ada_lovelace all mention "Ada Lovelace".
+func handle(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// Mentions returns where each person is mentioned, keyed by the path of the
+// person's note and newest first. A note's mentions of its own person
+// aren't counted, nor are ambiguous names.
+func Mentions(ws *workspace.Workspace, all []notes.Note) map[string][]Mention {
+	persons := People(all)
+	byHandle := map[string][]string{}
+	for _, p := range persons {
+		filename := strings.TrimSuffix(path.Base(p.Path), path.Ext(p.Path))
+		names := append([]string{p.Title, filename}, p.Aliases...)
+		for _, name := range names {
+			h := handle(name)
+			if h != "" && !slices.Contains(byHandle[h], p.Path) {
+				byHandle[h] = append(byHandle[h], p.Path)
+			}
+		}
+	}
+
+	result := map[string][]Mention{}
+	for _, n := range all {
+		data, err := ws.ReadFile(n.Path)
+		if err != nil {
+			continue
+		}
+		doc := frontmatter.Parse(string(data))
+		date := noteDate(ws, n.Path, doc)
+		// Line numbers count from the top of the file, frontmatter included.
+		offset := len(strings.Split(string(data), "\n")) - len(strings.Split(doc.Body, "\n"))
+
+		inCode := false
+		for i, line := range strings.Split(doc.Body, "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				inCode = !inCode
+				continue
+			}
+			if inCode {
+				continue
+			}
+
+			var mentioned []string
+			for _, m := range wikiLink.FindAllStringSubmatch(line, -1) {
+				if matches := notes.Resolve(persons, m[1]); len(matches) == 1 {
+					mentioned = append(mentioned, matches[0].Path)
+				}
+			}
+			for _, m := range atMention.FindAllStringSubmatch(line, -1) {
+				if paths := byHandle[handle(m[1])]; len(paths) == 1 {
+					mentioned = append(mentioned, paths[0])
+				}
+			}
+			slices.Sort(mentioned)
+			for _, p := range slices.Compact(mentioned) {
+				if p == n.Path {
+					continue
+				}
+				result[p] = append(result[p], Mention{Path: n.Path, Line: offset + i + 1, Text: strings.TrimSpace(line), Date: date})
+			}
+		}
+	}
+
+	for _, mentions := range result {
+		slices.SortStableFunc(mentions, func(a, b Mention) int {
+			if c := strings.Compare(b.Date, a.Date); c != 0 {
+				return c
+			}
+			if c := strings.Compare(a.Path, b.Path); c != 0 {
+				return c
+			}
+			return a.Line - b.Line
+		})
+	}
+	return result
+}
+
+func noteDate(ws *workspace.Workspace, p string, doc *frontmatter.Document) string {
+	if d := doc.String("date"); len(d) >= 10 {
+		if _, err := time.Parse(time.DateOnly, d[:10]); err == nil {
+			return d[:10]
+		}
+	}
+	if d := datedName.FindString(path.Base(p)); d != "" {
+		if _, err := time.Parse(time.DateOnly, d); err == nil {
+			return d
+		}
+	}
+	if info, err := ws.Stat(p); err == nil {
+		return info.ModTime().Format(time.DateOnly)
+	}
+	return ""
+}
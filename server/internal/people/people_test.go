@@ -0,0 +1,59 @@
+package people_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/people"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestMentions(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"people", "journal", "projects"} {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, content := range map[string]string{
+		"people/ada.md":            "---\ntitle: Ada Lovelace\ntype: person\naliases: [Countess]\n---\nI am @ada.\n",
+		"people/charles.md":        "---\ntype: Person\n---\n# Charles Babbage\n",
+		"people/other-ada.md":      "---\ntitle: Ada\ntype: person\n---\n",
+		"journal/2024-03-01.md":    "Lunch with @AdaLovelace and [[Charles Babbage|Charles]].\n",
+		"journal/2024-05-10.md":    "Called [[Countess]], emailed x@ada.com.\n```\n@ada-lovelace\n```\n",
+		"projects/engine.md":       "---\ndate: 2023-12-25T10:00\n---\n\n@charles drew plans; ![[ada.png]] and [[Ada]] too.\n",
+		"journal/unrelated-ada.md": "ada\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := notes.NewIndex().Notes(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(people.People(all)); got != 3 {
+		t.Errorf("People = %d, want 3", got)
+	}
+
+	// [[Ada]] is both a title and a file name, so it is ambiguous.
+	got := people.Mentions(ws, all)
+	want := map[string][]people.Mention{
+		"people/ada.md": {
+			{Path: "journal/2024-05-10.md", Line: 1, Text: "Called [[Countess]], emailed x@ada.com.", Date: "2024-05-10"},
+			{Path: "journal/2024-03-01.md", Line: 1, Text: "Lunch with @AdaLovelace and [[Charles Babbage|Charles]].", Date: "2024-03-01"},
+		},
+		"people/charles.md": {
+			{Path: "journal/2024-03-01.md", Line: 1, Text: "Lunch with @AdaLovelace and [[Charles Babbage|Charles]].", Date: "2024-03-01"},
+			{Path: "projects/engine.md", Line: 5, Text: "@charles drew plans; ![[ada.png]] and [[Ada]] too.", Date: "2023-12-25"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Mentions =\n%+v\nwant\n%+v", got, want)
+	}
+}
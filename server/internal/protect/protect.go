@@ -0,0 +1,268 @@
+// Package protect guards folders with a passphrase. Until a client unlocks
+// a protected folder, the workspace it is given per request has the folder
+// locked, so every endpoint answers 423 Locked for what is in it.
+//
+// Protection is about what the API serves, not what is on disk: the files
+// stay as they are, readable by anything with access to the workspace.
+// Seal notes (see package sealed) or encrypt the workspace for that.
+package protect
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Path holds the protected folders. It is always locked to clients, so
+// protection can't be lifted by rewriting it.
+const Path = ".wisdom/protected.json"
+
+const (
+	// TokenHeader and Cookie carry a client's unlock session. Browsers get
+	// the cookie, so images and downloads in an unlocked folder load too.
+	TokenHeader = "Wisdom-Folder-Token"
+	Cookie      = "wisdom_folders"
+	// SessionTTL is how long a session lasts after it was last used.
+	SessionTTL = time.Hour
+	iterations = 600_000
+)
+
+var (
+	ErrWrongPassphrase  = errors.New("wrong passphrase")
+	ErrNoPassphrase     = errors.New("passphrase is required")
+	ErrNotProtected     = errors.New("folder is not protected")
+	ErrAlreadyProtected = errors.New("folder is already protected")
+)
+
+type folder struct {
+	Folder     string `json:"folder"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Hash       []byte `json:"hash"`
+}
+
+type session struct {
+	unlocked map[string]bool
+	expires  time.Time
+}
+
+// Folders knows the protected folders of a workspace and which ones each
+// session has unlocked. Sessions are kept in memory and end when the
+// server restarts.
+type Folders struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	// The protected folders are read on every request, so they are kept
+	// until the file changes.
+	cached     []folder
+	cachedAt   time.Time
+	cachedSize int64
+	// storeMu serializes changes, which rewrite the whole file.
+	storeMu sync.Mutex
+}
+
+func New() *Folders {
+	return &Folders{sessions: map[string]*session{}}
+}
+
+// load reads the protected folders from the full workspace ws.
+func (f *Folders) load(ws *workspace.Workspace) ([]folder, error) {
+	info, err := ws.Stat(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	if info.ModTime().Equal(f.cachedAt) && info.Size() == f.cachedSize {
+		defer f.mu.Unlock()
+		return f.cached, nil
+	}
+	f.mu.Unlock()
+
+	data, err := ws.ReadFile(Path)
+	if err != nil {
+		return nil, err
+	}
+	var folders []folder
+	if err := json.Unmarshal(data, &folders); err != nil {
+		return nil, fmt.Errorf("reading protected folders: %w", err)
+	}
+	f.mu.Lock()
+	f.cached, f.cachedAt, f.cachedSize = folders, info.ModTime(), info.Size()
+	f.mu.Unlock()
+	return folders, nil
+}
+
+func (f *Folders) save(ws *workspace.Workspace, folders []folder) error {
+	f.mu.Lock()
+	f.cachedAt = time.Time{}
+	f.mu.Unlock()
+	data, err := json.MarshalIndent(folders, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(Path), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(Path, data, 0o644)
+}
+
+func hash(passphrase string, salt []byte, iter int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+}
+
+// List returns the protected folders.
+func (f *Folders) List(ws *workspace.Workspace) ([]string, error) {
+	folders, err := f.load(ws.Full())
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(folders))
+	for i, p := range folders {
+		names[i] = p.Folder
+	}
+	return names, nil
+}
+
+// Protect guards the folder at p with passphrase.
+func (f *Folders) Protect(ws *workspace.Workspace, p, passphrase string) error {
+	if passphrase == "" {
+		return ErrNoPassphrase
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	h, err := hash(passphrase, salt, iterations)
+	if err != nil {
+		return err
+	}
+
+	f.storeMu.Lock()
+	defer f.storeMu.Unlock()
+	ws = ws.Full()
+	folders, err := f.load(ws)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(folders, func(x folder) bool { return x.Folder == p }) {
+		return ErrAlreadyProtected
+	}
+	folders = append(slices.Clone(folders), folder{Folder: p, Iterations: iterations, Salt: salt, Hash: h})
+	return f.save(ws, folders)
+}
+
+// Unprotect lifts the protection of the folder at p.
+func (f *Folders) Unprotect(ws *workspace.Workspace, p string) error {
+	f.storeMu.Lock()
+	defer f.storeMu.Unlock()
+	ws = ws.Full()
+	folders, err := f.load(ws)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(folders, func(x folder) bool { return x.Folder == p })
+	if i < 0 {
+		return ErrNotProtected
+	}
+	return f.save(ws, slices.Delete(slices.Clone(folders), i, i+1))
+}
+
+// Unlock checks the passphrase of the folder at p and adds it to the
+// session with token, or to a new session if token is unknown. It returns
+// the session's token and when it expires.
+func (f *Folders) Unlock(ws *workspace.Workspace, token, p, passphrase string, now time.Time) (string, time.Time, error) {
+	folders, err := f.load(ws.Full())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	i := slices.IndexFunc(folders, func(x folder) bool { return x.Folder == p })
+	if i < 0 {
+		return "", time.Time{}, ErrNotProtected
+	}
+	h, err := hash(passphrase, folders[i].Salt, folders[i].Iterations)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if subtle.ConstantTimeCompare(h, folders[i].Hash) != 1 {
+		return "", time.Time{}, ErrWrongPassphrase
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.session(token, now)
+	if s == nil {
+		buf := make([]byte, 16)
+		rand.Read(buf)
+		token = hex.EncodeToString(buf)
+		s = &session{unlocked: map[string]bool{}}
+		f.sessions[token] = s
+	}
+	s.unlocked[p] = true
+	s.expires = now.Add(SessionTTL)
+	return token, s.expires, nil
+}
+
+// SessionToken returns the client's session token, from the header or the
+// cookie.
+func SessionToken(r *http.Request) string {
+	if token := r.Header.Get(TokenHeader); token != "" {
+		return token
+	}
+	if c, err := r.Cookie(Cookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// session returns the live session with token, dropping expired ones.
+func (f *Folders) session(token string, now time.Time) *session {
+	for t, s := range f.sessions {
+		if now.After(s.expires) {
+			delete(f.sessions, t)
+		}
+	}
+	return f.sessions[token]
+}
+
+// End forgets the session with token, locking its folders again.
+func (f *Folders) End(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sessions, token)
+}
+
+// Locked returns the protected folders the session with token hasn't
+// unlocked, and extends the session.
+func (f *Folders) Locked(ws *workspace.Workspace, token string, now time.Time) ([]string, error) {
+	folders, err := f.load(ws.Full())
+	if err != nil || len(folders) == 0 {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.session(token, now)
+	if s != nil {
+		s.expires = now.Add(SessionTTL)
+	}
+	var locked []string
+	for _, x := range folders {
+		if s == nil || !s.unlocked[x.Folder] {
+			locked = append(locked, x.Folder)
+		}
+	}
+	return locked, nil
+}
@@ -0,0 +1,278 @@
+// Package query evaluates table queries over the frontmatter and file
+// metadata of notes, like "title and rating of every book rated 4 or more,
+// grouped by status".
+package query
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// File metadata is available under these fields, next to the frontmatter
+// properties.
+const (
+	FilePath   = "file.path"
+	FileName   = "file.name"
+	FileFolder = "file.folder"
+	FileSize   = "file.size"
+	FileMtime  = "file.mtime"
+)
+
+var Ops = []string{"=", "!=", "<", "<=", ">", ">=", "contains", "exists", "missing"}
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+type Condition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+type Order struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Query selects fields from the notes under From ("" or "." for the whole
+// workspace) that meet every condition in Where.
+type Query struct {
+	From    string      `json:"from"`
+	Select  []string    `json:"select"`
+	Where   []Condition `json:"where"`
+	Sort    []Order     `json:"sort"`
+	GroupBy string      `json:"groupBy"`
+	Limit   int         `json:"limit"`
+}
+
+// Cells are a string, a []string for list properties, an int64 for
+// file.size or nil when the note doesn't have the field.
+type Row []any
+
+type Group struct {
+	Key  string `json:"key"`
+	Rows []Row  `json:"rows"`
+}
+
+// Result is a table with one column per selected field. Grouped queries
+// fill Groups instead of Rows.
+type Result struct {
+	Columns []string `json:"columns"`
+	Rows    []Row    `json:"rows,omitzero"`
+	Groups  []Group  `json:"groups,omitzero"`
+}
+
+func (q Query) validate() error {
+	for _, c := range q.Where {
+		if c.Field == "" || !slices.Contains(Ops, c.Op) {
+			return fmt.Errorf("%w: condition needs a field and one of the ops %s", ErrInvalidQuery, strings.Join(Ops, " "))
+		}
+	}
+	for _, o := range q.Sort {
+		if o.Field == "" {
+			return fmt.Errorf("%w: sort needs a field", ErrInvalidQuery)
+		}
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit can't be negative", ErrInvalidQuery)
+	}
+	return nil
+}
+
+type note struct {
+	path  string
+	size  int64
+	mtime time.Time
+	doc   *frontmatter.Document
+}
+
+func (n *note) value(field string) any {
+	switch field {
+	case FilePath:
+		return n.path
+	case FileName:
+		return strings.TrimSuffix(path.Base(n.path), path.Ext(n.path))
+	case FileFolder:
+		return path.Dir(n.path)
+	case FileSize:
+		return n.size
+	case FileMtime:
+		return n.mtime.UTC().Format(time.RFC3339)
+	}
+	switch {
+	case !n.doc.Has(field):
+		return nil
+	case n.doc.IsList(field):
+		return n.doc.List(field)
+	}
+	return n.doc.String(field)
+}
+
+// values flattens a cell, so conditions on a list property hold when any
+// item meets them.
+func values(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	}
+	return nil
+}
+
+// compare orders numbers numerically and everything else as text, which
+// also orders ISO dates.
+func compare(a, b string) int {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX == nil && errY == nil {
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func (c Condition) match(n *note) bool {
+	vs := values(n.value(c.Field))
+	switch c.Op {
+	case "exists":
+		return n.value(c.Field) != nil
+	case "missing":
+		return n.value(c.Field) == nil
+	case "!=":
+		return !slices.ContainsFunc(vs, func(v string) bool { return compare(v, c.Value) == 0 })
+	case "contains":
+		return slices.ContainsFunc(vs, func(v string) bool {
+			return strings.Contains(strings.ToLower(v), strings.ToLower(c.Value))
+		})
+	}
+	return slices.ContainsFunc(vs, func(v string) bool {
+		r := compare(v, c.Value)
+		switch c.Op {
+		case "=":
+			return r == 0
+		case "<":
+			return r < 0
+		case "<=":
+			return r <= 0
+		case ">":
+			return r > 0
+		}
+		return r >= 0
+	})
+}
+
+// Run evaluates q over the notes in the workspace.
+func Run(ws *workspace.Workspace, q Query) (*Result, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if len(q.Select) == 0 {
+		q.Select = []string{FilePath}
+	}
+	prefix := strings.Trim(q.From, "/")
+	if prefix == "." {
+		prefix = ""
+	}
+
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var matched []*note
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(e.Path, prefix+"/") {
+			continue
+		}
+		info, err := ws.Stat(e.Path)
+		if err != nil {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		n := &note{path: e.Path, size: info.Size(), mtime: info.ModTime(), doc: frontmatter.Parse(string(data))}
+		if !slices.ContainsFunc(q.Where, func(c Condition) bool { return !c.match(n) }) {
+			matched = append(matched, n)
+		}
+	}
+
+	// Notes missing a sort field go last either way.
+	slices.SortStableFunc(matched, func(a, b *note) int {
+		for _, o := range q.Sort {
+			x, y := values(a.value(o.Field)), values(b.value(o.Field))
+			switch {
+			case len(x) == 0 && len(y) == 0:
+				continue
+			case len(x) == 0:
+				return 1
+			case len(y) == 0:
+				return -1
+			}
+			r := compare(x[0], y[0])
+			if o.Desc {
+				r = -r
+			}
+			if r != 0 {
+				return r
+			}
+		}
+		return strings.Compare(a.path, b.path)
+	})
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+
+	result := &Result{Columns: q.Select}
+	row := func(n *note) Row {
+		r := make(Row, len(q.Select))
+		for i, field := range q.Select {
+			r[i] = n.value(field)
+		}
+		return r
+	}
+	if q.GroupBy == "" {
+		result.Rows = make([]Row, 0, len(matched))
+		for _, n := range matched {
+			result.Rows = append(result.Rows, row(n))
+		}
+		return result, nil
+	}
+
+	// A note is grouped under each item of a list property, and under ""
+	// when it has no value.
+	byKey := map[string]*Group{}
+	for _, n := range matched {
+		keys := values(n.value(q.GroupBy))
+		if len(keys) == 0 {
+			keys = []string{""}
+		}
+		for _, key := range keys {
+			g, ok := byKey[key]
+			if !ok {
+				g = &Group{Key: key}
+				byKey[key] = g
+			}
+			g.Rows = append(g.Rows, row(n))
+		}
+	}
+	result.Groups = []Group{}
+	for _, key := range slices.SortedFunc(maps.Keys(byKey), compare) {
+		result.Groups = append(result.Groups, *byKey[key])
+	}
+	return result, nil
+}
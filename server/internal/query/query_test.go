@@ -0,0 +1,106 @@
+package query_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/query"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func newWorkspace(t *testing.T) *workspace.Workspace {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MkdirAll("books", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range map[string]string{
+		"books/dune.md":        "---\ntitle: Dune\nrating: 5\nstatus: read\ntags: [sci-fi, classic]\n---\n",
+		"books/emma.md":        "---\ntitle: Emma\nrating: 4\nstatus: read\ntags: [classic]\n---\n",
+		"books/neuromancer.md": "---\ntitle: Neuromancer\nrating: 10\nstatus: reading\ntags: [sci-fi]\n---\n",
+		"books/unrated.md":     "---\ntitle: Unrated\n---\n",
+		"books/cover.jpg":      "jpeg",
+		"inbox.md":             "---\nrating: 5\n---\n",
+	} {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ws
+}
+
+// table renders a result as JSON so cells of different types compare
+// easily.
+func table(t *testing.T, r *query.Result) string {
+	t.Helper()
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+	ws := newWorkspace(t)
+	tests := []struct {
+		name string
+		q    query.Query
+		want string
+	}{
+		{
+			"where and sort",
+			query.Query{
+				From:   "books",
+				Select: []string{"title", "rating"},
+				Where:  []query.Condition{{Field: "rating", Op: ">=", Value: "4"}},
+				Sort:   []query.Order{{Field: "rating", Desc: true}},
+			},
+			`{"columns":["title","rating"],"rows":[["Neuromancer","10"],["Dune","5"],["Emma","4"]]}`,
+		},
+		{
+			"missing sort fields go last",
+			query.Query{From: "books/", Select: []string{"file.name", "tags"}, Sort: []query.Order{{Field: "rating"}}},
+			`{"columns":["file.name","tags"],"rows":[["emma",["classic"]],["dune",["sci-fi","classic"]],["neuromancer",["sci-fi"]],["unrated",null]]}`,
+		},
+		{
+			"list conditions and limit",
+			query.Query{
+				Where: []query.Condition{{Field: "tags", Op: "contains", Value: "SCI"}, {Field: "status", Op: "!=", Value: "reading"}},
+				Limit: 1,
+			},
+			`{"columns":["file.path"],"rows":[["books/dune.md"]]}`,
+		},
+		{
+			"group by list",
+			query.Query{Select: []string{"title"}, Where: []query.Condition{{Field: "title", Op: "exists"}}, GroupBy: "tags"},
+			`{"columns":["title"],"groups":[{"key":"","rows":[["Unrated"]]},{"key":"classic","rows":[["Dune"],["Emma"]]},{"key":"sci-fi","rows":[["Dune"],["Neuromancer"]]}]}`,
+		},
+		{
+			"no matches",
+			query.Query{Where: []query.Condition{{Field: "rating", Op: ">", Value: "10"}}},
+			`{"columns":["file.path"],"rows":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := query.Run(ws, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := table(t, r); got != tt.want {
+				t.Errorf("Run =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := query.Run(ws, query.Query{Where: []query.Condition{{Field: "rating", Op: "~"}}})
+		if !errors.Is(err, query.ErrInvalidQuery) {
+			t.Errorf("err = %v, want ErrInvalidQuery", err)
+		}
+	})
+}
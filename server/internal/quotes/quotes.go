@@ -0,0 +1,98 @@
+// Package quotes collects the passages quoted in the user's notes and picks
+// one per day.
+//
+// A quote is a markdown blockquote: consecutive lines starting with ">". That
+// is how highlights and annotations are kept in notes, so no separate store
+// is needed.
+package quotes
+
+import (
+	"hash/fnv"
+	"path"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type Quote struct {
+	Text string `json:"text"`
+	// Source is the note the quote is in, and Title its frontmatter title
+	// when it has one.
+	Source string `json:"source"`
+	Title  string `json:"title,omitempty"`
+	// Line is where the quote starts in the note, counting from 1.
+	Line int `json:"line"`
+}
+
+// Collect reads the quotes from every markdown note in the workspace, in
+// path order.
+func Collect(ws *workspace.Workspace) ([]Quote, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []Quote
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		quotes = append(quotes, Parse(e.Path, string(data))...)
+	}
+	return quotes, nil
+}
+
+// Parse returns the blockquotes in note. Nested quotes are flattened into
+// the quote around them.
+func Parse(source, note string) []Quote {
+	doc := frontmatter.Parse(note)
+	title := doc.String("title")
+	offset := strings.Count(note[:len(note)-len(doc.Body)], "\n")
+
+	var quotes []Quote
+	var lines []string
+	start := 0
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			quotes = append(quotes, Quote{Text: text, Source: source, Title: title, Line: start})
+		}
+		lines = nil
+	}
+
+	inCode := false
+	for i, line := range strings.Split(doc.Body, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if inCode || !strings.HasPrefix(trimmed, ">") {
+			flush()
+			continue
+		}
+		if lines == nil {
+			start = offset + i + 1
+		}
+		for strings.HasPrefix(trimmed, ">") {
+			trimmed = strings.TrimPrefix(strings.TrimPrefix(trimmed, ">"), " ")
+		}
+		lines = append(lines, trimmed)
+	}
+	flush()
+	return quotes
+}
+
+// Daily picks the quote for date, given as YYYY-MM-DD. The same day always
+// gets the same quote as long as the set of quotes doesn't change.
+func Daily(quotes []Quote, date string) (Quote, bool) {
+	if len(quotes) == 0 {
+		return Quote{}, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(date))
+	return quotes[h.Sum64()%uint64(len(quotes))], true
+}
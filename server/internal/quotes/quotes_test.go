@@ -0,0 +1,89 @@
+package quotes_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/quotes"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestParse(t *testing.T) {
+	check := func(t *testing.T, note string, want []quotes.Quote) {
+		t.Helper()
+		got := quotes.Parse("dune.md", note)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse =\n%+v\nwant\n%+v", got, want)
+		}
+	}
+
+	t.Run("blockquotes with frontmatter", func(t *testing.T) {
+		check(t, "---\ntitle: Dune\n---\n# Highlights\n\n> I must not fear.\n> Fear is the mind-killer.\n\nMy thoughts.\n\n>> Nested\n", []quotes.Quote{
+			{Text: "I must not fear.\nFear is the mind-killer.", Source: "dune.md", Title: "Dune", Line: 6},
+			{Text: "Nested", Source: "dune.md", Title: "Dune", Line: 11},
+		})
+	})
+
+	t.Run("code blocks and empty quotes are skipped", func(t *testing.T) {
+		check(t, "```\n> not a quote\n```\n>\n> \n", nil)
+	})
+}
+
+func TestDaily(t *testing.T) {
+	all := []quotes.Quote{{Text: "a"}, {Text: "b"}, {Text: "c"}, {Text: "d"}}
+
+	first, ok := quotes.Daily(all, "2026-03-01")
+	if !ok {
+		t.Fatal("expected a quote")
+	}
+	again, _ := quotes.Daily(all, "2026-03-01")
+	if first != again {
+		t.Errorf("same day gave %q and %q", first.Text, again.Text)
+	}
+
+	seen := map[string]bool{}
+	for _, date := range []string{"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06"} {
+		q, _ := quotes.Daily(all, date)
+		seen[q.Text] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("a week of quotes only covered %v", seen)
+	}
+
+	if _, ok := quotes.Daily(nil, "2026-03-01"); ok {
+		t.Error("expected no quote from an empty collection")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"b.md":   "> second\n",
+		"a.md":   "> first\n",
+		"c.txt":  "> not a note\n",
+		"d/e.md": "no quotes\n",
+	}
+	if err := ws.MkdirAll("d", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range files {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := quotes.Collect(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []quotes.Quote{
+		{Text: "first", Source: "a.md", Line: 1},
+		{Text: "second", Source: "b.md", Line: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Collect = %+v, want %+v", got, want)
+	}
+}
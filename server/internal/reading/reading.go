@@ -0,0 +1,243 @@
+// Package reading keeps a log of reading progress and derives statistics from
+// it: pages and minutes read per day, reading streaks and yearly goals.
+//
+// The log is a JSON lines file in the workspace. Viewers report the page a
+// book is on as the user reads; a reading session is a run of updates for the
+// same book with no gap longer than SessionGap between them.
+package reading
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const LogPath = ".wisdom/reading.jsonl"
+
+// SessionGap is the longest pause between two updates that still counts as
+// reading time.
+const SessionGap = 30 * time.Minute
+
+const dateLayout = "2006-01-02"
+
+// logMu serializes appends.
+var logMu sync.Mutex
+
+type EntryKind string
+
+const (
+	KindProgress EntryKind = "progress"
+	KindGoal     EntryKind = "goal"
+)
+
+// Entry is a line in the log. Progress entries carry Path, Page and,
+// when known, Pages; goal entries carry Year and Books.
+type Entry struct {
+	Kind  EntryKind `json:"kind"`
+	Time  time.Time `json:"time"`
+	Path  string    `json:"path,omitempty"`
+	Page  int       `json:"page,omitempty"`
+	Pages int       `json:"pages,omitempty"`
+	Year  int       `json:"year,omitempty"`
+	Books int       `json:"books,omitempty"`
+}
+
+type Day struct {
+	Date    string `json:"date"`
+	Pages   int    `json:"pages"`
+	Minutes int    `json:"minutes"`
+}
+
+type Stats struct {
+	Year          int   `json:"year"`
+	Goal          int   `json:"goal"`
+	BooksFinished int   `json:"booksFinished"`
+	Pages         int   `json:"pages"`
+	Minutes       int   `json:"minutes"`
+	CurrentStreak int   `json:"currentStreak"`
+	LongestStreak int   `json:"longestStreak"`
+	Days          []Day `json:"days"`
+}
+
+// Append adds e to the reading log.
+func Append(ws *workspace.Workspace, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if err := ws.MkdirAll(path.Dir(LogPath), 0o755); err != nil {
+		return err
+	}
+	return ws.AppendFile(LogPath, append(line, '\n'), 0o644)
+}
+
+// ReadLog returns the entries in the reading log, oldest first. Lines that
+// can't be parsed are skipped so a hand edit can't break the stats.
+func ReadLog(ws *workspace.Workspace) ([]Entry, error) {
+	data, err := ws.ReadFile(LogPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading log: %w", err)
+	}
+
+	var entries []Entry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	return entries, sc.Err()
+}
+
+// Compute derives the stats for year from the log. Days are in now's
+// location, and the current streak is still alive if the user read yesterday
+// but not yet today.
+func Compute(entries []Entry, year int, now time.Time) Stats {
+	loc := now.Location()
+	stats := Stats{Year: year, Days: []Day{}}
+
+	type position struct {
+		page int
+		at   time.Time
+	}
+	last := map[string]position{}
+	finished := map[string]bool{}
+	days := map[string]*Day{}
+	readOn := map[string]bool{}
+
+	for _, e := range entries {
+		if e.Kind == KindGoal {
+			if e.Year == year {
+				stats.Goal = e.Books
+			}
+			continue
+		}
+		if e.Kind != KindProgress {
+			continue
+		}
+
+		at := e.Time.In(loc)
+		prev, seen := last[e.Path]
+		last[e.Path] = position{page: e.Page, at: at}
+		// The first update for a book only sets where reading starts.
+		if !seen {
+			continue
+		}
+
+		pages := max(0, e.Page-prev.page)
+		minutes := 0
+		if gap := at.Sub(prev.at); gap <= SessionGap {
+			minutes = int(gap.Round(time.Minute) / time.Minute)
+		}
+		if pages == 0 && minutes == 0 {
+			continue
+		}
+
+		date := at.Format(dateLayout)
+		readOn[date] = true
+		if at.Year() != year {
+			continue
+		}
+		d := days[date]
+		if d == nil {
+			d = &Day{Date: date}
+			days[date] = d
+		}
+		d.Pages += pages
+		d.Minutes += minutes
+		stats.Pages += pages
+		stats.Minutes += minutes
+		if e.Pages > 0 && e.Page >= e.Pages && !finished[e.Path] {
+			finished[e.Path] = true
+			stats.BooksFinished++
+		}
+	}
+
+	for _, d := range days {
+		stats.Days = append(stats.Days, *d)
+	}
+	slices.SortFunc(stats.Days, func(a, b Day) int { return strings.Compare(a.Date, b.Date) })
+
+	stats.CurrentStreak, stats.LongestStreak = streaks(readOn, now)
+	return stats
+}
+
+func streaks(readOn map[string]bool, now time.Time) (current, longest int) {
+	dates := make([]string, 0, len(readOn))
+	for d := range readOn {
+		dates = append(dates, d)
+	}
+	slices.Sort(dates)
+
+	run := 0
+	var prev time.Time
+	for _, d := range dates {
+		t, _ := time.ParseInLocation(dateLayout, d, now.Location())
+		if run > 0 && t.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = t
+		longest = max(longest, run)
+	}
+
+	day := now
+	if !readOn[day.Format(dateLayout)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for readOn[day.Format(dateLayout)] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+// Session is a run of progress updates for one book with no gap longer
+// than SessionGap.
+type Session struct {
+	Path     string    `json:"path"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	FromPage int       `json:"fromPage"`
+	ToPage   int       `json:"toPage"`
+}
+
+// Sessions groups the progress entries of the log, oldest first, into
+// sessions, in the order they started. A single update makes a session of
+// its own, with no time read.
+func Sessions(entries []Entry) []Session {
+	var sessions []Session
+	open := map[string]int{}
+	for _, e := range entries {
+		if e.Kind != KindProgress {
+			continue
+		}
+		if i, ok := open[e.Path]; ok && e.Time.Sub(sessions[i].End) <= SessionGap {
+			sessions[i].End = e.Time
+			sessions[i].ToPage = e.Page
+			continue
+		}
+		open[e.Path] = len(sessions)
+		sessions = append(sessions, Session{Path: e.Path, Start: e.Time, End: e.Time, FromPage: e.Page, ToPage: e.Page})
+	}
+	return sessions
+}
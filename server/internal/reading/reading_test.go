@@ -0,0 +1,131 @@
+package reading_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reading"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func progress(t time.Time, path string, page, pages int) reading.Entry {
+	return reading.Entry{Kind: reading.KindProgress, Time: t, Path: path, Page: page, Pages: pages}
+}
+
+func TestCompute(t *testing.T) {
+	entries := []reading.Entry{
+		{Kind: reading.KindGoal, Time: at(1, 8, 0), Year: 2026, Books: 12},
+		{Kind: reading.KindGoal, Time: at(1, 9, 0), Year: 2026, Books: 20},
+		// Last year's reading only counts towards streaks.
+		progress(time.Date(2025, time.December, 31, 22, 0, 0, 0, time.UTC), "old.epub", 1, 0),
+		progress(time.Date(2025, time.December, 31, 22, 20, 0, 0, time.UTC), "old.epub", 30, 0),
+
+		progress(at(2, 20, 0), "dune.epub", 10, 100),
+		progress(at(2, 20, 15), "dune.epub", 25, 100),
+		progress(at(2, 20, 40), "dune.epub", 40, 100),
+		// A gap longer than a session adds pages but no minutes.
+		progress(at(3, 7, 0), "dune.epub", 50, 100),
+		progress(at(3, 7, 10), "dune.epub", 100, 100),
+		// Going back a few pages isn't negative reading.
+		progress(at(3, 7, 20), "dune.epub", 90, 100),
+
+		progress(at(5, 12, 0), "paper.pdf", 1, 10),
+		progress(at(5, 12, 30), "paper.pdf", 10, 10),
+	}
+
+	check := func(t *testing.T, now time.Time, year int, want reading.Stats) {
+		t.Helper()
+		got := reading.Compute(entries, year, now)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Compute =\n%+v\nwant\n%+v", got, want)
+		}
+	}
+
+	t.Run("current year", func(t *testing.T) {
+		check(t, at(6, 10, 0), 2026, reading.Stats{
+			Year:          2026,
+			Goal:          20,
+			BooksFinished: 2,
+			Pages:         99,
+			Minutes:       90,
+			CurrentStreak: 1,
+			LongestStreak: 2,
+			Days: []reading.Day{
+				{Date: "2026-03-02", Pages: 30, Minutes: 40},
+				{Date: "2026-03-03", Pages: 60, Minutes: 20},
+				{Date: "2026-03-05", Pages: 9, Minutes: 30},
+			},
+		})
+	})
+
+	t.Run("broken streak", func(t *testing.T) {
+		got := reading.Compute(entries, 2026, at(8, 10, 0))
+		if got.CurrentStreak != 0 || got.LongestStreak != 2 {
+			t.Errorf("streaks = %d, %d", got.CurrentStreak, got.LongestStreak)
+		}
+	})
+
+	t.Run("past year", func(t *testing.T) {
+		check(t, at(6, 10, 0), 2025, reading.Stats{
+			Year:          2025,
+			Pages:         29,
+			Minutes:       20,
+			CurrentStreak: 1,
+			LongestStreak: 2,
+			Days:          []reading.Day{{Date: "2025-12-31", Pages: 29, Minutes: 20}},
+		})
+	})
+}
+
+func TestSessions(t *testing.T) {
+	got := reading.Sessions([]reading.Entry{
+		progress(at(2, 20, 0), "dune.epub", 10, 100),
+		{Kind: reading.KindGoal, Time: at(2, 20, 5), Year: 2026, Books: 12},
+		progress(at(2, 20, 10), "paper.pdf", 1, 10),
+		progress(at(2, 20, 30), "dune.epub", 25, 100),
+		progress(at(2, 21, 0), "dune.epub", 40, 100),
+		progress(at(3, 7, 0), "dune.epub", 50, 100),
+	})
+	want := []reading.Session{
+		{Path: "dune.epub", Start: at(2, 20, 0), End: at(2, 21, 0), FromPage: 10, ToPage: 40},
+		{Path: "paper.pdf", Start: at(2, 20, 10), End: at(2, 20, 10), FromPage: 1, ToPage: 1},
+		{Path: "dune.epub", Start: at(3, 7, 0), End: at(3, 7, 0), FromPage: 50, ToPage: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sessions =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestLog(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := reading.ReadLog(ws)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("empty log = %v, %v", entries, err)
+	}
+
+	want := []reading.Entry{
+		progress(at(2, 20, 0), "dune.epub", 10, 100),
+		{Kind: reading.KindGoal, Time: at(2, 21, 0), Year: 2026, Books: 12},
+	}
+	for _, e := range want {
+		if err := reading.Append(ws, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err = reading.ReadLog(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ReadLog = %+v, want %+v", entries, want)
+	}
+}
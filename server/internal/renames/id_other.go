@@ -0,0 +1,10 @@
+//go:build !unix
+
+package renames
+
+import "io/fs"
+
+// There is no inode in what os.Stat returns here.
+func fileID(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,67 @@
+// Package renames tells a file renamed by another program apart from a
+// deletion and an unrelated new file. Code that notices changes by
+// comparing walks of the workspace sees a rename as both, which would
+// make clients download the file again and lose track of it.
+//
+// A file that vanished and one that appeared are the same file if they
+// have the same size and modification time, which a rename keeps, and the
+// same inode where the platform has them. Elsewhere the pairing must be
+// unambiguous: two identical-looking files renamed at once are left as
+// deletions and new files.
+package renames
+
+import (
+	"io/fs"
+	"time"
+)
+
+type File struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// ID is the file's inode, or 0 where there is none.
+	ID uint64
+}
+
+func FromInfo(p string, info fs.FileInfo) File {
+	return File{Path: p, Size: info.Size(), ModTime: info.ModTime(), ID: fileID(info)}
+}
+
+func (f File) same(g File) bool {
+	if f.Size != g.Size || !f.ModTime.Equal(g.ModTime) {
+		return false
+	}
+	return f.ID == 0 || g.ID == 0 || f.ID == g.ID
+}
+
+// Match returns the path each appeared file was renamed from, keyed by its
+// new path.
+func Match(gone, appeared []File) map[string]string {
+	moves := map[string]string{}
+	if len(gone) == 0 || len(appeared) == 0 {
+		return moves
+	}
+	for _, a := range appeared {
+		var from *File
+		n := 0
+		for i, g := range gone {
+			if a.same(g) {
+				from, n = &gone[i], n+1
+			}
+		}
+		if n != 1 {
+			continue
+		}
+		// The vanished file must look like this one only, too.
+		others := 0
+		for _, b := range appeared {
+			if b.same(*from) {
+				others++
+			}
+		}
+		if others == 1 {
+			moves[a.Path] = from.Path
+		}
+	}
+	return moves
+}
@@ -0,0 +1,54 @@
+package renames_test
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/renames"
+)
+
+func TestMatch(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		gone, appeared []renames.File
+		want           map[string]string
+	}{
+		{
+			"rename",
+			[]renames.File{{Path: "a.md", Size: 10, ModTime: at}},
+			[]renames.File{{Path: "b.md", Size: 10, ModTime: at}},
+			map[string]string{"b.md": "a.md"},
+		},
+		{
+			"different size",
+			[]renames.File{{Path: "a.md", Size: 10, ModTime: at}},
+			[]renames.File{{Path: "b.md", Size: 11, ModTime: at}},
+			map[string]string{},
+		},
+		{
+			"ambiguous without inodes",
+			[]renames.File{{Path: "a.md", Size: 10, ModTime: at}, {Path: "b.md", Size: 10, ModTime: at}},
+			[]renames.File{{Path: "x/a.md", Size: 10, ModTime: at}, {Path: "x/b.md", Size: 10, ModTime: at}},
+			map[string]string{},
+		},
+		{
+			"told apart by inodes",
+			[]renames.File{{Path: "a.md", Size: 10, ModTime: at, ID: 1}, {Path: "b.md", Size: 10, ModTime: at, ID: 2}},
+			[]renames.File{{Path: "x/b.md", Size: 10, ModTime: at, ID: 2}, {Path: "x/a.md", Size: 10, ModTime: at, ID: 1}},
+			map[string]string{"x/a.md": "a.md", "x/b.md": "b.md"},
+		},
+		{
+			"another inode",
+			[]renames.File{{Path: "a.md", Size: 10, ModTime: at, ID: 1}},
+			[]renames.File{{Path: "b.md", Size: 10, ModTime: at, ID: 7}},
+			map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := renames.Match(tt.gone, tt.appeared); !maps.Equal(got, tt.want) {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
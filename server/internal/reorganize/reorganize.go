@@ -0,0 +1,409 @@
+// Package reorganize moves many files and folders at once without breaking
+// the links between them.
+//
+// A reorganization is a list of rules, each moving a file, a folder or the
+// notes with a tag. It is worked out as a plan first: the files that move
+// and the notes whose links need rewriting. Executing a plan snapshots the
+// workspace, so the reorganization can be undone, moves the files, rewrites
+// the links and records one entry in a log.
+package reorganize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const LogPath = ".wisdom/reorganize.jsonl"
+
+var (
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrConflict is returned when executing a plan with conflicts.
+	ErrConflict = errors.New("reorganization has conflicts")
+)
+
+// mu serializes executions, and appends to the log.
+var mu sync.Mutex
+
+var (
+	// markdownLink matches the target of [text](target) and ![alt](target),
+	// like the attachments check.
+	markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	// wikiLink matches the name in [[name]], [[name|label]] and embeds.
+	wikiLink = regexp.MustCompile(`\[\[([^\]|#]+)`)
+)
+
+// Rule moves From, a file or folder, to To. With Tag, it moves the notes
+// under From, the whole workspace if it is empty, that have the tag or one
+// nested under it into the folder To instead.
+type Rule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Rewrite is a note whose links change, at its path after the moves.
+type Rewrite struct {
+	Note  string `json:"note"`
+	Links int    `json:"links"`
+}
+
+type Plan struct {
+	Moves    []Move    `json:"moves"`
+	Rewrites []Rewrite `json:"rewrites"`
+	// Conflicts are destinations that already exist or that more than one
+	// file would move to. A plan with conflicts can't be executed.
+	Conflicts []string `json:"conflicts"`
+
+	// content is the rewritten notes, by their paths before the moves.
+	content map[string]string
+}
+
+// Entry is a reorganization in the log.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Rules []Rule    `json:"rules"`
+	Moved int       `json:"moved"`
+	// Rewritten is the number of notes whose links were rewritten.
+	Rewritten int `json:"rewritten"`
+	// Snapshot is the snapshot taken before, to undo the reorganization.
+	Snapshot string `json:"snapshot"`
+}
+
+func clean(p string) string {
+	return path.Clean(strings.Trim(p, "/"))
+}
+
+// NewPlan works out what the rules would do, without changing anything. A
+// file matched by more than one rule moves by the first.
+func NewPlan(ws *workspace.Workspace, rules []Rule) (*Plan, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%w: no rules", ErrInvalidRule)
+	}
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	exists := map[string]bool{}
+	for _, e := range entries {
+		exists[e.Path] = true
+		if !e.IsDir {
+			files = append(files, e.Path)
+		}
+	}
+
+	var byTag map[string][]string
+	moved := map[string]string{}
+	var order []string
+	for _, rule := range rules {
+		from, to := clean(rule.From), clean(rule.To)
+		if rule.To == "" || to == "." || strings.HasPrefix(to, "../") {
+			return nil, fmt.Errorf("%w: %q is not a destination", ErrInvalidRule, rule.To)
+		}
+		if strings.HasPrefix(from, "../") || (from == "." && rule.Tag == "") {
+			return nil, fmt.Errorf("%w: %q can't be moved", ErrInvalidRule, rule.From)
+		}
+		prefix := from + "/"
+		if from == "." {
+			prefix = ""
+		}
+		add := func(src, dst string) {
+			if _, ok := moved[src]; !ok && src != dst {
+				moved[src] = dst
+				order = append(order, src)
+			}
+		}
+
+		if rule.Tag != "" {
+			if byTag == nil {
+				if byTag, err = tags.Scan(ws); err != nil {
+					return nil, err
+				}
+			}
+			for _, note := range tags.Notes(byTag, tags.Normalize(rule.Tag)) {
+				if strings.HasPrefix(note, prefix) {
+					add(note, path.Join(to, path.Base(note)))
+				}
+			}
+			continue
+		}
+		if !exists[from] {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidRule, rule.From, fs.ErrNotExist)
+		}
+		if slices.Contains(files, from) {
+			add(from, to)
+			continue
+		}
+		for _, f := range files {
+			if rest, ok := strings.CutPrefix(f, prefix); ok {
+				add(f, path.Join(to, rest))
+			}
+		}
+	}
+
+	plan := &Plan{Moves: []Move{}, Rewrites: []Rewrite{}, Conflicts: []string{}, content: map[string]string{}}
+	claimed := map[string]bool{}
+	for _, src := range order {
+		dst := moved[src]
+		_, leaving := moved[dst]
+		if claimed[dst] || (exists[dst] && !leaving) {
+			plan.Conflicts = append(plan.Conflicts, dst)
+		}
+		claimed[dst] = true
+		plan.Moves = append(plan.Moves, Move{From: src, To: dst})
+	}
+
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f), ".md") {
+			continue
+		}
+		content, _, err := textfile.Read(ws, f)
+		if err != nil {
+			continue
+		}
+		updated, n := rewrite(content, f, moved)
+		if n == 0 {
+			continue
+		}
+		note := f
+		if dst, ok := moved[f]; ok {
+			note = dst
+		}
+		plan.Rewrites = append(plan.Rewrites, Rewrite{Note: note, Links: n})
+		plan.content[f] = updated
+	}
+	slices.SortFunc(plan.Rewrites, func(a, b Rewrite) int { return strings.Compare(a.Note, b.Note) })
+	slices.Sort(plan.Conflicts)
+	plan.Conflicts = slices.Compact(plan.Conflicts)
+	return plan, nil
+}
+
+// Rebase rewrites the relative links in note, which is at from, to point at
+// the same files from to, for a note copied somewhere else.
+func Rebase(note, from, to string) string {
+	rebased, _ := rewrite(note, from, map[string]string{from: to})
+	return rebased
+}
+
+// rewrite points the links in the note at notePath to where their targets
+// move, and, if the note itself moves, keeps its relative links pointing at
+// the same files. It returns the note and how many links changed.
+func rewrite(note, notePath string, moved map[string]string) (string, int) {
+	newPath, noteMoves := moved[notePath]
+	if !noteMoves {
+		newPath = notePath
+	}
+	dir, newDir := path.Dir(notePath), path.Dir(newPath)
+	n := 0
+
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownLink.FindAllStringSubmatchIndex(note, -1) {
+		target := note[m[2]:m[3]]
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+			continue
+		}
+		p, fragment, hasFragment := strings.Cut(target, "#")
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			p = unescaped
+		}
+		absolute := strings.HasPrefix(p, "/")
+		if absolute {
+			p = path.Clean(strings.TrimPrefix(p, "/"))
+		} else {
+			p = path.Join(dir, p)
+		}
+		if p == "." || strings.HasPrefix(p, "../") {
+			continue
+		}
+		dst, targetMoves := moved[p]
+		if !targetMoves {
+			dst = p
+		}
+		var replacement string
+		switch {
+		case absolute && targetMoves:
+			replacement = "/" + dst
+		case !absolute && (targetMoves || noteMoves):
+			rel, err := filepath.Rel(newDir, dst)
+			if err != nil {
+				continue
+			}
+			replacement = filepath.ToSlash(rel)
+		default:
+			continue
+		}
+		replacement = (&url.URL{Path: replacement}).EscapedPath()
+		if hasFragment {
+			replacement += "#" + fragment
+		}
+		if replacement == target {
+			continue
+		}
+		b.WriteString(note[last:m[2]])
+		b.WriteString(replacement)
+		last = m[3]
+		n++
+	}
+	b.WriteString(note[last:])
+	note = b.String()
+
+	// Wiki links by name still resolve after a move; only those written as
+	// a path need rewriting.
+	b.Reset()
+	last = 0
+	for _, m := range wikiLink.FindAllStringSubmatchIndex(note, -1) {
+		name := strings.TrimSpace(note[m[2]:m[3]])
+		if !strings.Contains(name, "/") {
+			continue
+		}
+		replacement, ok := moved[name]
+		if !ok {
+			if replacement, ok = moved[name+".md"]; !ok {
+				continue
+			}
+			replacement = strings.TrimSuffix(replacement, ".md")
+		}
+		b.WriteString(note[last:m[2]])
+		b.WriteString(replacement)
+		last = m[3]
+		n++
+	}
+	b.WriteString(note[last:])
+	return b.String(), n
+}
+
+// Execute works out the plan for rules again, so it reflects the workspace
+// as it is now, and carries it out. If a step fails part way, the snapshot
+// taken first, named in the error, has the workspace as it was.
+func Execute(ws *workspace.Workspace, rules []Rule, now time.Time) (*Plan, Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	plan, err := NewPlan(ws, rules)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	if len(plan.Conflicts) > 0 {
+		return plan, Entry{}, fmt.Errorf("%w: %s", ErrConflict, strings.Join(plan.Conflicts, ", "))
+	}
+	snap, err := snapshot.Create(ws, now)
+	if err != nil {
+		return nil, Entry{}, fmt.Errorf("snapshot: %w", err)
+	}
+	failed := func(err error) (*Plan, Entry, error) {
+		return nil, Entry{}, fmt.Errorf("%w (snapshot %s has the workspace as it was)", err, snap.ID)
+	}
+
+	// Moves within the plan may swap files, so everything moves aside
+	// first.
+	aside := make([]string, len(plan.Moves))
+	for i, m := range plan.Moves {
+		aside[i] = fmt.Sprintf("%s.reorganize-%d", m.From, i)
+		if err := ws.Move(m.From, aside[i]); err != nil {
+			return failed(err)
+		}
+	}
+	for i, m := range plan.Moves {
+		if err := ws.MkdirAll(path.Dir(m.To), 0o755); err != nil {
+			return failed(err)
+		}
+		if err := ws.Move(aside[i], m.To); err != nil {
+			return failed(err)
+		}
+	}
+	moved := map[string]string{}
+	for _, m := range plan.Moves {
+		moved[m.From] = m.To
+	}
+	for p, content := range plan.content {
+		if dst, ok := moved[p]; ok {
+			p = dst
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			return failed(err)
+		}
+	}
+	for _, m := range plan.Moves {
+		removeEmpty(ws, path.Dir(m.From))
+	}
+
+	entry := Entry{Time: now.UTC(), Rules: rules, Moved: len(plan.Moves), Rewritten: len(plan.Rewrites), Snapshot: snap.ID}
+	if err := appendLog(ws, entry); err != nil {
+		return failed(err)
+	}
+	return plan, entry, nil
+}
+
+// removeEmpty removes dir and its parents for as long as they are empty.
+func removeEmpty(ws *workspace.Workspace, dir string) {
+	for dir != "." {
+		if entries, err := ws.ReadDir(dir); err != nil || len(entries) > 0 {
+			return
+		}
+		if ws.Remove(dir) != nil {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+func appendLog(ws *workspace.Workspace, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data, err := ws.ReadFile(LogPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(LogPath), 0o755); err != nil {
+		return err
+	}
+	data = append(data, line...)
+	data = append(data, '\n')
+	return ws.WriteFile(LogPath, data, 0o644)
+}
+
+// Log returns the reorganizations carried out, newest first.
+func Log(ws *workspace.Workspace) ([]Entry, error) {
+	data, err := ws.ReadFile(LogPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	slices.Reverse(entries)
+	return entries, sc.Err()
+}
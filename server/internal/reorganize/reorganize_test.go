@@ -0,0 +1,143 @@
+package reorganize_test
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reorganize"
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func newWorkspace(t *testing.T, files map[string]string) *workspace.Workspace {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range files {
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ws
+}
+
+func TestExecute(t *testing.T) {
+	ws := newWorkspace(t, map[string]string{
+		"journal/2024/01/retro.md":    "See [the plan](../../../projects/plan.md#goals) and ![](diagram.png).\n",
+		"journal/2024/01/diagram.png": "png",
+		"projects/plan.md":            "Started in [January](/journal/2024/01/retro.md), [[journal/2024/01/retro]] and [[retro]].\n",
+		"index.md":                    "[Plan](projects/plan.md) and [site](https://example.com/journal/2024/01/retro.md)\n",
+	})
+	rules := []reorganize.Rule{{From: "journal/2024/01", To: "journal/2024"}}
+
+	plan, err := reorganize.NewPlan(ws, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMoves := []reorganize.Move{
+		{From: "journal/2024/01/diagram.png", To: "journal/2024/diagram.png"},
+		{From: "journal/2024/01/retro.md", To: "journal/2024/retro.md"},
+	}
+	if !reflect.DeepEqual(plan.Moves, wantMoves) {
+		t.Errorf("moves = %v, want %v", plan.Moves, wantMoves)
+	}
+	wantRewrites := []reorganize.Rewrite{{Note: "journal/2024/retro.md", Links: 1}, {Note: "projects/plan.md", Links: 2}}
+	if !reflect.DeepEqual(plan.Rewrites, wantRewrites) {
+		t.Errorf("rewrites = %v, want %v", plan.Rewrites, wantRewrites)
+	}
+	if _, err := ws.Stat("journal/2024/01/retro.md"); err != nil {
+		t.Errorf("planning moved files: %v", err)
+	}
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, _, err := reorganize.Execute(ws, rules, now); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]string{
+		"journal/2024/retro.md": "See [the plan](../../projects/plan.md#goals) and ![](diagram.png).\n",
+		"projects/plan.md":      "Started in [January](/journal/2024/retro.md), [[journal/2024/retro]] and [[retro]].\n",
+		"index.md":              "[Plan](projects/plan.md) and [site](https://example.com/journal/2024/01/retro.md)\n",
+	} {
+		if data, err := ws.ReadFile(p); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", p, data, err, want)
+		}
+	}
+	if _, err := ws.Stat("journal/2024/01"); err == nil {
+		t.Error("the emptied folder was left behind")
+	}
+
+	log, err := reorganize.Log(ws)
+	if err != nil || len(log) != 1 {
+		t.Fatalf("Log = %v, %v, want one entry", log, err)
+	}
+	if e := log[0]; e.Moved != 2 || e.Rewritten != 2 || !reflect.DeepEqual(e.Rules, rules) {
+		t.Errorf("entry = %+v", e)
+	}
+	snap, err := snapshot.Load(ws, log[0].Snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snap.Entries["journal/2024/01/retro.md"]; !ok {
+		t.Error("the snapshot doesn't have the workspace as it was")
+	}
+}
+
+func TestSplitByTag(t *testing.T) {
+	ws := newWorkspace(t, map[string]string{
+		"inbox/a.md": "---\ntags: [work/meetings]\n---\n",
+		"inbox/b.md": "#home chores\n",
+		"inbox/c.md": "#work\n",
+	})
+	plan, err := reorganize.NewPlan(ws, []reorganize.Rule{
+		{From: "inbox", Tag: "work", To: "work"},
+		{Tag: "#home", To: "home"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []reorganize.Move{
+		{From: "inbox/a.md", To: "work/a.md"},
+		{From: "inbox/c.md", To: "work/c.md"},
+		{From: "inbox/b.md", To: "home/b.md"},
+	}
+	if !reflect.DeepEqual(plan.Moves, want) {
+		t.Errorf("moves = %v, want %v", plan.Moves, want)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	ws := newWorkspace(t, map[string]string{
+		"a/note.md":    "a\n",
+		"b/note.md":    "b\n",
+		"old/x.md":     "x\n",
+		"archive/x.md": "taken\n",
+	})
+	rules := []reorganize.Rule{{From: "a", To: "all"}, {From: "b", To: "all"}, {From: "old", To: "archive"}}
+	plan, err := reorganize.NewPlan(ws, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"all/note.md", "archive/x.md"}; !reflect.DeepEqual(plan.Conflicts, want) {
+		t.Errorf("conflicts = %v, want %v", plan.Conflicts, want)
+	}
+	if _, _, err := reorganize.Execute(ws, rules, time.Now()); !errors.Is(err, reorganize.ErrConflict) {
+		t.Errorf("Execute err = %v, want ErrConflict", err)
+	}
+	if data, _ := ws.ReadFile("a/note.md"); string(data) != "a\n" {
+		t.Error("a plan with conflicts was carried out")
+	}
+
+	for _, rule := range []reorganize.Rule{{From: "missing", To: "x"}, {From: "a", To: "."}, {From: "", To: "x"}} {
+		if _, err := reorganize.NewPlan(ws, []reorganize.Rule{rule}); !errors.Is(err, reorganize.ErrInvalidRule) {
+			t.Errorf("%+v: err = %v, want ErrInvalidRule", rule, err)
+		}
+	}
+}
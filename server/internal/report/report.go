@@ -0,0 +1,142 @@
+// Package report sends server errors to a Sentry-compatible error tracker,
+// so crashes on a headless install are noticed without reading its logs.
+package report
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/buildinfo"
+)
+
+// maxInFlight bounds the reports being sent at once. Past it, reports are
+// dropped: a tracker that is down or slow mustn't pile up goroutines while
+// the server is already failing.
+const maxInFlight = 4
+
+type Event struct {
+	Message string
+	Method  string
+	// URL is the request's path only: queries carry credentials, such as
+	// share signatures and sign-in codes, that mustn't leave the server.
+	URL    string
+	Status int
+	// RequestID matches the event to the server's logs.
+	RequestID string
+	// Stack is set for panics.
+	Stack string
+}
+
+// Reporter sends events to the store endpoint of a Sentry project. A nil
+// Reporter drops them, so callers needn't check whether reporting is on.
+type Reporter struct {
+	Client   *http.Client
+	endpoint string
+	auth     string
+	inFlight chan struct{}
+	logger   *slog.Logger
+}
+
+// FromEnv returns a reporter for the DSN in WISDOM_SENTRY_DSN, of the form
+// https://<key>@<host>/<project>, or nil when it isn't set.
+func FromEnv(logger *slog.Logger) (*Reporter, error) {
+	dsn := os.Getenv("WISDOM_SENTRY_DSN")
+	if dsn == "" {
+		return nil, nil
+	}
+	return New(dsn, logger)
+}
+
+func New(dsn string, logger *slog.Logger) (*Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	project := projectID(u.Path)
+	if u.User == nil || u.User.Username() == "" || project == "" || u.Host == "" {
+		return nil, errors.New("invalid DSN: want https://<key>@<host>/<project>")
+	}
+	prefix := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), project)
+	return &Reporter{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		endpoint: u.Scheme + "://" + u.Host + prefix + "api/" + project + "/store/",
+		auth:     "Sentry sentry_version=7, sentry_client=wisdom/" + buildinfo.Version + ", sentry_key=" + u.User.Username(),
+		inFlight: make(chan struct{}, maxInFlight),
+		logger:   logger,
+	}, nil
+}
+
+// projectID returns the last element of a DSN path.
+func projectID(p string) string {
+	p = strings.TrimSuffix(p, "/")
+	return p[strings.LastIndexByte(p, '/')+1:]
+}
+
+// Report sends ev in the background.
+func (r *Reporter) Report(ev Event) {
+	if r == nil {
+		return
+	}
+	select {
+	case r.inFlight <- struct{}{}:
+	default:
+		r.logger.Warn("error report dropped: too many in flight")
+		return
+	}
+	go func() {
+		defer func() { <-r.inFlight }()
+		if err := r.send(ev, time.Now()); err != nil {
+			r.logger.Warn("sending error report", "err", err)
+		}
+	}()
+}
+
+func (r *Reporter) send(ev Event, now time.Time) error {
+	id := make([]byte, 16)
+	rand.Read(id)
+	hostname, _ := os.Hostname()
+	payload := map[string]any{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   now.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      "wisdom",
+		"release":     buildinfo.Version,
+		"server_name": hostname,
+		"message":     map[string]string{"formatted": ev.Message},
+		"request":     map[string]string{"method": ev.Method, "url": ev.URL},
+		"tags":        map[string]string{"status": fmt.Sprint(ev.Status), "request_id": ev.RequestID},
+	}
+	if ev.Stack != "" {
+		payload["extra"] = map[string]string{"stack": ev.Stack}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package report_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/report"
+)
+
+func TestReport(t *testing.T) {
+	type received struct {
+		path, auth string
+		body       map[string]any
+	}
+	got := make(chan received, 1)
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		got <- received{r.URL.Path, r.Header.Get("X-Sentry-Auth"), body}
+	}))
+	defer tracker.Close()
+
+	dsn := strings.Replace(tracker.URL, "://", "://public@", 1) + "/sentry/42"
+	reporter, err := report.New(dsn, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter.Report(report.Event{Message: "panic: boom", Method: "GET", URL: "/api/fs/a.md", Status: 500, Stack: "main.go:1"})
+
+	select {
+	case r := <-got:
+		if r.path != "/sentry/api/42/store/" || !strings.Contains(r.auth, "sentry_key=public") {
+			t.Errorf("sent to %s with auth %q", r.path, r.auth)
+		}
+		message, _ := r.body["message"].(map[string]any)
+		extra, _ := r.body["extra"].(map[string]any)
+		if message["formatted"] != "panic: boom" || extra["stack"] != "main.go:1" || len(r.body["event_id"].(string)) != 32 {
+			t.Errorf("event = %v", r.body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no report sent")
+	}
+
+	t.Run("invalid DSN", func(t *testing.T) {
+		for _, dsn := range []string{"https://sentry.example.com/42", "https://key@sentry.example.com/", "::"} {
+			if _, err := report.New(dsn, slog.Default()); err == nil {
+				t.Errorf("New(%q) succeeded", dsn)
+			}
+		}
+	})
+}
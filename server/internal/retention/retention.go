@@ -0,0 +1,232 @@
+// Package retention archives or flags notes that have gone untouched for
+// long enough, by policies set per folder, so an inbox or a projects
+// folder doesn't fill up with notes nobody looks at.
+//
+// Archiving moves notes with a reorganization, so links to them keep
+// working and the move can be undone from its snapshot. Flagging tags them
+// for review and leaves them where they are.
+package retention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/reorganize"
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Path is where the policies live, by folder, "." for the whole
+// workspace:
+//
+//	{"inbox": {"months": 3, "action": "archive"},
+//	 "projects": {"months": 12, "tags": ["done"], "action": "flag"}}
+const Path = ".wisdom/retention.json"
+
+const (
+	ActionArchive = "archive"
+	ActionFlag    = "flag"
+)
+
+const (
+	// DefaultArchive is the folder archived notes move into, keeping their
+	// paths.
+	DefaultArchive = "Archive"
+	// DefaultFlag is the tag flagged notes get.
+	DefaultFlag = "stale"
+)
+
+var ErrInvalidPolicy = errors.New("invalid retention policy")
+
+type Policy struct {
+	// Months is how long a note must have gone unchanged.
+	Months int `json:"months"`
+	// Tags limits the policy to notes with one of these tags or one nested
+	// under it.
+	Tags   []string `json:"tags,omitempty"`
+	Action string   `json:"action"`
+	// Archive is the folder to archive into, DefaultArchive if empty.
+	Archive string `json:"archive,omitempty"`
+	// Flag is the tag to flag with, DefaultFlag if empty.
+	Flag string `json:"flag,omitempty"`
+}
+
+type Policies map[string]Policy
+
+// Candidate is a note a policy applies to.
+type Candidate struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"modTime"`
+	Folder  string    `json:"folder"`
+	Action  string    `json:"action"`
+	// To is where an archived note moves, or the tag a flagged note gets.
+	To string `json:"to"`
+}
+
+// Load reads the policies. A workspace without any has none.
+func Load(ws *workspace.Workspace) (Policies, error) {
+	data, err := ws.ReadFile(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Policies{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw Policies
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+	policies := Policies{}
+	for folder, p := range raw {
+		if p.Months < 1 {
+			return nil, fmt.Errorf("%w: %s: months must be at least 1", ErrInvalidPolicy, folder)
+		}
+		switch p.Action {
+		case ActionArchive:
+			p.Archive = strings.Trim(p.Archive, "/")
+			if p.Archive == "" {
+				p.Archive = DefaultArchive
+			}
+		case ActionFlag:
+			p.Flag = tags.Normalize(p.Flag)
+			if p.Flag == "" {
+				p.Flag = DefaultFlag
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s: action must be %s or %s", ErrInvalidPolicy, folder, ActionArchive, ActionFlag)
+		}
+		folder = strings.Trim(folder, "/")
+		if folder == "" {
+			folder = "."
+		}
+		policies[folder] = p
+	}
+	return policies, nil
+}
+
+// For returns the policy for the note at p, from the most specific folder
+// containing it.
+func (ps Policies) For(p string) (string, Policy, bool) {
+	best, bestDepth := "", -1
+	for folder := range ps {
+		depth := 0
+		if folder != "." {
+			if !strings.HasPrefix(p, folder+"/") {
+				continue
+			}
+			depth = strings.Count(folder, "/") + 1
+		}
+		if depth > bestDepth {
+			best, bestDepth = folder, depth
+		}
+	}
+	return best, ps[best], bestDepth >= 0
+}
+
+// Preview returns the notes the policies apply to as of now, sorted by
+// path, without changing anything. Notes already in an archive folder are
+// left alone, as are flagged notes that already have the tag.
+func Preview(ws *workspace.Workspace, ps Policies, now time.Time) ([]Candidate, error) {
+	candidates := []Candidate{}
+	if len(ps) == 0 {
+		return candidates, nil
+	}
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var archives []string
+	for _, p := range ps {
+		if p.Action == ActionArchive {
+			archives = append(archives, p.Archive)
+		}
+	}
+	for _, e := range entries {
+		if e.IsDir || !notes.IsNote(e.Path) {
+			continue
+		}
+		if slices.ContainsFunc(archives, func(a string) bool { return strings.HasPrefix(e.Path, a+"/") }) {
+			continue
+		}
+		folder, p, ok := ps.For(e.Path)
+		if !ok {
+			continue
+		}
+		info, err := ws.Stat(e.Path)
+		if err != nil || info.ModTime().After(now.AddDate(0, -p.Months, 0)) {
+			continue
+		}
+		if len(p.Tags) > 0 || p.Action == ActionFlag {
+			content, _, err := textfile.Read(ws, e.Path)
+			if err != nil {
+				continue
+			}
+			noteTags := tags.Extract(content)
+			has := func(prefix string) bool {
+				return slices.ContainsFunc(noteTags, func(t string) bool { return tags.Matches(t, tags.Normalize(prefix)) })
+			}
+			if len(p.Tags) > 0 && !slices.ContainsFunc(p.Tags, has) {
+				continue
+			}
+			if p.Action == ActionFlag && slices.ContainsFunc(noteTags, func(t string) bool { return strings.EqualFold(t, p.Flag) }) {
+				continue
+			}
+		}
+		c := Candidate{Path: e.Path, ModTime: info.ModTime().UTC(), Folder: folder, Action: p.Action, To: p.Flag}
+		if p.Action == ActionArchive {
+			c.To = path.Join(p.Archive, e.Path)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// Apply carries out the policies as of now and returns the notes it
+// archived or flagged. Flagging keeps a note's modification time, so it
+// doesn't count as touching it.
+func Apply(ws *workspace.Workspace, now time.Time) ([]Candidate, error) {
+	ps, err := Load(ws)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := Preview(ws, ps, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []reorganize.Rule
+	for _, c := range candidates {
+		if c.Action == ActionArchive {
+			rules = append(rules, reorganize.Rule{From: c.Path, To: c.To})
+			continue
+		}
+		data, err := ws.ReadFile(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		flagged, err := tags.Apply(string(data), tags.Op{Kind: tags.OpAdd, Tag: c.To})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Path, err)
+		}
+		if err := ws.WriteFile(c.Path, []byte(flagged), 0o644); err != nil {
+			return nil, err
+		}
+		if err := ws.Chtimes(c.Path, c.ModTime); err != nil {
+			return nil, err
+		}
+	}
+	if len(rules) > 0 {
+		if _, _, err := reorganize.Execute(ws, rules, now); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
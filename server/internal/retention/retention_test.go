@@ -0,0 +1,117 @@
+package retention_test
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/retention"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestApply(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(-1, 0, 0)
+	for p, modTime := range map[string]time.Time{
+		"inbox/old.md":             old,
+		"inbox/new.md":             now,
+		"inbox/keep/old.md":        old,
+		"projects/done.md":         old,
+		"projects/active.md":       old,
+		"index.md":                 old,
+		"Archive/inbox/earlier.md": old,
+	} {
+		content := "Notes.\n"
+		switch p {
+		case "projects/done.md":
+			content = "---\ntags: [status/done]\n---\nShipped.\n"
+		case "index.md":
+			content = "See [[inbox/old]].\n"
+		}
+		if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.Chtimes(p, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policies := `{
+		"inbox": {"months": 3, "action": "archive"},
+		"inbox/keep": {"months": 24, "action": "archive"},
+		"/projects/": {"months": 6, "tags": ["status"], "action": "flag", "flag": "#review"}
+	}`
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile(retention.Path, []byte(policies), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := retention.Load(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := retention.Preview(ws, ps, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []retention.Candidate{
+		{Path: "inbox/old.md", ModTime: old, Folder: "inbox", Action: retention.ActionArchive, To: "Archive/inbox/old.md"},
+		{Path: "projects/done.md", ModTime: old, Folder: "projects", Action: retention.ActionFlag, To: "review"},
+	}
+	if !reflect.DeepEqual(preview, want) {
+		t.Fatalf("preview = %+v, want %+v", preview, want)
+	}
+
+	applied, err := retention.Apply(ws, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %+v, want %+v", applied, want)
+	}
+	if _, err := ws.Stat("Archive/inbox/old.md"); err != nil {
+		t.Errorf("the old inbox note wasn't archived: %v", err)
+	}
+	data, _ := ws.ReadFile("projects/done.md")
+	if want := "---\ntags: [\"status/done\",\"review\"]\n---\nShipped.\n"; string(data) != want {
+		t.Errorf("done.md = %q, want %q", data, want)
+	}
+	if data, _ := ws.ReadFile("index.md"); string(data) != "See [[Archive/inbox/old]].\n" {
+		t.Errorf("index.md = %q, want the link to follow the archived note", data)
+	}
+	if info, _ := ws.Stat("projects/done.md"); !info.ModTime().Equal(old) {
+		t.Errorf("flagging touched the note: modified %v", info.ModTime())
+	}
+
+	if again, err := retention.Apply(ws, now); err != nil || len(again) != 0 {
+		t.Errorf("applying again = %+v, %v, want nothing to do", again, err)
+	}
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, policies := range []string{`{"inbox": {"months": 0, "action": "archive"}}`, `{"inbox": {"months": 3, "action": "delete"}}`, `[]`} {
+		if err := ws.WriteFile(retention.Path, []byte(policies), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := retention.Load(ws); !errors.Is(err, retention.ErrInvalidPolicy) {
+			t.Errorf("%s: err = %v, want ErrInvalidPolicy", policies, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Spec is a parsed cron expression. Each field is a bit set of the values
+// it matches.
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+	// Like cron, when both days are restricted a time matches if either
+	// does.
+	domAny, dowAny bool
+}
+
+// Parse reads a standard five field cron expression (minute, hour, day of
+// month, month, day of week) or one of the @daily style macros. Fields take
+// *, values, ranges (1-5), steps (*/15, 1-30/2) and comma separated lists of
+// these. Sunday is 0 or 7.
+func Parse(expr string) (Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Spec{}, fmt.Errorf("%w: want 5 fields, got %d", ErrInvalidCron, len(fields))
+	}
+
+	var s Spec
+	var err error
+	bounds := []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.set, err = parseField(fields[i], b.min, b.max); err != nil {
+			return Spec{}, fmt.Errorf("%w: %q: %v", ErrInvalidCron, fields[i], err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	return s, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, errors.New("bad step")
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, errors.New("bad value")
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, errors.New("bad range")
+				}
+			} else if hasStep {
+				// "5/15" means from 5 to the end, every 15.
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
+
+func (s Spec) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t that the spec matches, in t's
+// location, or the zero time if there is none in the next five years, as
+// for February 30th.
+func (s Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(s.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,46 @@
+package schedule_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/schedule"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2026, 3, 4, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 3, 4, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1,5", time.Date(2026, 3, 6, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2026, 3, 8, 8, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day matches when both are restricted.
+		{"0 0 15 * 4", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := schedule.Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := spec.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@sometimes"} {
+		if _, err := schedule.Parse(expr); !errors.Is(err, schedule.ErrInvalidCron) {
+			t.Errorf("Parse(%q) err = %v, want ErrInvalidCron", expr, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package schedule runs actions, such as reindexing or running a tool, on
+// cron schedules.
+//
+// Schedules are kept in a JSON file in the workspace and read every minute,
+// so edits to the file apply without a restart. Which actions exist is up
+// to the server, which registers them by name; a schedule can only name a
+// registered action.
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Path = ".wisdom/schedules.json"
+
+var (
+	ErrUnknownSchedule = errors.New("unknown schedule")
+	ErrUnknownAction   = errors.New("unknown action")
+	ErrRunning         = errors.New("schedule is already running")
+)
+
+// storeMu serializes changes, which rewrite the whole file.
+var storeMu sync.Mutex
+
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Cron string `json:"cron"`
+	// Action is the registered action to run, with Args as its options.
+	Action string            `json:"action"`
+	Args   map[string]string `json:"args,omitempty"`
+	Paused bool              `json:"paused,omitempty"`
+}
+
+// Action is a task a schedule can run.
+type Action func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error
+
+// Status is how a schedule's last run went. It is only kept in memory.
+type Status struct {
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+	Running  bool      `json:"running,omitempty"`
+}
+
+func Load(ws *workspace.Workspace) ([]Schedule, error) {
+	schedules := []Schedule{}
+	data, err := ws.ReadFile(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return schedules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("reading schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func save(ws *workspace.Workspace, schedules []Schedule) error {
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(Path), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(Path, data, 0o644)
+}
+
+// Put adds sched, or replaces the schedule with its ID. A schedule without
+// an ID is given one.
+func Put(ws *workspace.Workspace, sched Schedule) (Schedule, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	schedules, err := Load(ws)
+	if err != nil {
+		return sched, err
+	}
+	if sched.ID == "" {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		sched.ID = hex.EncodeToString(buf)
+	}
+	if i := slices.IndexFunc(schedules, func(s Schedule) bool { return s.ID == sched.ID }); i >= 0 {
+		schedules[i] = sched
+	} else {
+		schedules = append(schedules, sched)
+	}
+	return sched, save(ws, schedules)
+}
+
+func Delete(ws *workspace.Workspace, id string) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	schedules, err := Load(ws)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(schedules, func(s Schedule) bool { return s.ID == id })
+	if i < 0 {
+		return ErrUnknownSchedule
+	}
+	return save(ws, slices.Delete(schedules, i, i+1))
+}
+
+// Scheduler runs the schedules of a workspace.
+type Scheduler struct {
+	ws     *workspace.Workspace
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	actions map[string]Action
+	status  map[string]Status
+}
+
+func New(ws *workspace.Workspace, logger *slog.Logger) *Scheduler {
+	return &Scheduler{ws: ws, logger: logger, actions: map[string]Action{}, status: map[string]Status{}}
+}
+
+// Register makes an action available to schedules under name.
+func (s *Scheduler) Register(name string, action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions[name] = action
+}
+
+// Actions returns the names of the registered actions, sorted.
+func (s *Scheduler) Actions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.actions))
+}
+
+// Validate checks that sched has a valid cron expression and names a
+// registered action.
+func (s *Scheduler) Validate(sched Schedule) error {
+	if _, err := Parse(sched.Cron); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.actions[sched.Action]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownAction, sched.Action)
+	}
+	return nil
+}
+
+// Status returns the status of the schedule with id.
+func (s *Scheduler) Status(id string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status[id]
+}
+
+// Run starts the schedules that are due every minute until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	last := time.Now()
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now = <-timer.C:
+		}
+
+		schedules, err := Load(s.ws)
+		if err != nil {
+			s.logger.Error("load schedules", "err", err)
+			continue
+		}
+		for _, sched := range schedules {
+			spec, err := Parse(sched.Cron)
+			if err != nil || sched.Paused {
+				continue
+			}
+			// Anything due since the last check runs once, so a slow
+			// check doesn't skip a minute.
+			if next := spec.Next(last); !next.IsZero() && !next.After(now) {
+				go func() {
+					if _, err := s.RunNow(ctx, sched); err != nil && !errors.Is(err, ErrRunning) {
+						s.logger.Error("scheduled run", "schedule", sched.Name, "action", sched.Action, "err", err)
+					}
+				}()
+			}
+		}
+		last = now
+	}
+}
+
+// RunNow runs a schedule's action and waits for it to finish. A schedule
+// never runs twice at once.
+func (s *Scheduler) RunNow(ctx context.Context, sched Schedule) (Status, error) {
+	s.mu.Lock()
+	action, ok := s.actions[sched.Action]
+	status := s.status[sched.ID]
+	if !ok {
+		s.mu.Unlock()
+		return status, fmt.Errorf("%w %q", ErrUnknownAction, sched.Action)
+	}
+	if status.Running {
+		s.mu.Unlock()
+		return status, ErrRunning
+	}
+	status.Running = true
+	s.status[sched.ID] = status
+	s.mu.Unlock()
+
+	start := time.Now().UTC()
+	err := action(ctx, s.ws, sched.Args)
+	status = Status{Started: start, Finished: time.Now().UTC()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	s.mu.Lock()
+	s.status[sched.ID] = status
+	s.mu.Unlock()
+	return status, err
+}
@@ -0,0 +1,61 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/schedule"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestScheduler(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := schedule.New(ws, slog.Default())
+	var got map[string]string
+	s.Register("echo", func(ctx context.Context, ws *workspace.Workspace, args map[string]string) error {
+		got = args
+		if args["fail"] != "" {
+			return errors.New(args["fail"])
+		}
+		return nil
+	})
+
+	if err := s.Validate(schedule.Schedule{Cron: "@hourly", Action: "backup"}); !errors.Is(err, schedule.ErrUnknownAction) {
+		t.Errorf("err = %v, want ErrUnknownAction", err)
+	}
+
+	sched, err := schedule.Put(ws, schedule.Schedule{Name: "Echo", Cron: "@hourly", Action: "echo", Args: map[string]string{"say": "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := schedule.Load(ws)
+	if err != nil || len(all) != 1 || all[0].ID != sched.ID || sched.ID == "" {
+		t.Fatalf("Load = %+v, %v", all, err)
+	}
+
+	status, err := s.RunNow(context.Background(), sched)
+	if err != nil || got["say"] != "hi" || status.Error != "" || status.Finished.IsZero() {
+		t.Errorf("RunNow = %+v, %v; args %v", status, err, got)
+	}
+
+	sched.Args = map[string]string{"fail": "disk full"}
+	if _, err := schedule.Put(ws, sched); err != nil {
+		t.Fatal(err)
+	}
+	s.RunNow(context.Background(), sched)
+	if status := s.Status(sched.ID); status.Error != "disk full" {
+		t.Errorf("status = %+v", status)
+	}
+
+	if err := schedule.Delete(ws, sched.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := schedule.Delete(ws, sched.ID); !errors.Is(err, schedule.ErrUnknownSchedule) {
+		t.Errorf("err = %v, want ErrUnknownSchedule", err)
+	}
+}
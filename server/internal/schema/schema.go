@@ -0,0 +1,182 @@
+// Package schema checks note frontmatter against the properties each folder
+// is expected to have, so structured notes such as books or contacts stay
+// consistent.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// Path is where the schema lives. It maps a folder to the properties of
+// the notes under it:
+//
+//	{"books": {"title": {"type": "string", "required": true},
+//	           "status": {"type": "string", "values": ["reading", "read"]}}}
+//
+// "." applies to the whole workspace.
+const Path = ".wisdom/schema.json"
+
+var Types = []string{"string", "number", "boolean", "date", "list"}
+
+var ErrInvalidSchema = errors.New("invalid schema")
+
+type Property struct {
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// Values limits a property, or each item of a list, to these values.
+	Values []string `json:"values,omitempty"`
+}
+
+type Schema map[string]map[string]Property
+
+type Violation struct {
+	Path     string `json:"path"`
+	Property string `json:"property"`
+	Message  string `json:"message"`
+}
+
+// Load reads the schema. A workspace without one has an empty schema.
+func Load(ws *workspace.Workspace) (Schema, error) {
+	data, err := ws.ReadFile(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Schema{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	normalized := Schema{}
+	for folder, props := range s {
+		for name, prop := range props {
+			if !slices.Contains(Types, prop.Type) {
+				return nil, fmt.Errorf("%w: %s: %s has unknown type %q", ErrInvalidSchema, folder, name, prop.Type)
+			}
+		}
+		folder = strings.Trim(folder, "/")
+		if folder == "" {
+			folder = "."
+		}
+		normalized[folder] = props
+	}
+	return normalized, nil
+}
+
+// For returns the properties for the note at notePath, from the most
+// specific folder containing it.
+func (s Schema) For(notePath string) map[string]Property {
+	var best map[string]Property
+	bestDepth := -1
+	for folder, props := range s {
+		depth := 0
+		if folder != "." {
+			if !strings.HasPrefix(notePath, folder+"/") {
+				continue
+			}
+			depth = strings.Count(folder, "/") + 1
+		}
+		if depth > bestDepth {
+			best, bestDepth = props, depth
+		}
+	}
+	return best
+}
+
+// Check returns the ways the note at notePath breaks the schema, sorted by
+// property.
+func (s Schema) Check(notePath, note string) []Violation {
+	props := s.For(notePath)
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	doc := frontmatter.Parse(note)
+	violations := []Violation{}
+	report := func(name, format string, args ...any) {
+		violations = append(violations, Violation{Path: notePath, Property: name, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, name := range names {
+		prop := props[name]
+		if !doc.Has(name) || !doc.IsList(name) && doc.String(name) == "" {
+			if prop.Required {
+				report(name, "is required")
+			}
+			continue
+		}
+
+		values := doc.List(name)
+		if prop.Type == "list" {
+			if !doc.IsList(name) {
+				report(name, "must be a list")
+				continue
+			}
+		} else {
+			if doc.IsList(name) {
+				report(name, "must be a %s, not a list", prop.Type)
+				continue
+			}
+			if !valid(prop.Type, values[0]) {
+				report(name, "must be a %s, got %q", prop.Type, values[0])
+				continue
+			}
+		}
+		for _, v := range values {
+			if len(prop.Values) > 0 && !slices.Contains(prop.Values, v) {
+				report(name, "%q is not one of %s", v, strings.Join(prop.Values, ", "))
+			}
+		}
+	}
+	return violations
+}
+
+func valid(typ, v string) bool {
+	switch typ {
+	case "number":
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	case "boolean":
+		return v == "true" || v == "false"
+	case "date":
+		_, err := time.Parse(time.DateOnly, v)
+		return err == nil
+	}
+	return true
+}
+
+// Validate checks every note the schema applies to.
+func Validate(ws *workspace.Workspace, s Schema) ([]Violation, error) {
+	violations := []Violation{}
+	if len(s) == 0 {
+		return violations, nil
+	}
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") || s.For(e.Path) == nil {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, s.Check(e.Path, string(data))...)
+	}
+	return violations, nil
+}
@@ -0,0 +1,97 @@
+package schema_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/schema"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func newWorkspace(t *testing.T, files map[string]string) *workspace.Workspace {
+	t.Helper()
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{".wisdom", "books/fiction", "contacts"} {
+		if err := ws.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, content := range files {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ws
+}
+
+const testSchema = `{
+	"/books/": {
+		"title": {"type": "string", "required": true},
+		"rating": {"type": "number"},
+		"status": {"type": "string", "values": ["reading", "read"]},
+		"tags": {"type": "list", "values": ["fiction", "history"]},
+		"finished": {"type": "date"}
+	},
+	"books/fiction": {"title": {"type": "string"}},
+	".": {"draft": {"type": "boolean"}}
+}`
+
+func TestValidate(t *testing.T) {
+	ws := newWorkspace(t, map[string]string{
+		schema.Path:           testSchema,
+		"books/good.md":       "---\ntitle: Dune\nrating: 4.5\nstatus: read\ntags: [fiction]\nfinished: 2024-03-01\n---\n",
+		"books/bad.md":        "---\nrating: great\nstatus: [read]\ntags: [fiction, poetry]\nfinished: March\n---\n",
+		"books/fiction/ok.md": "No frontmatter needed here.\n",
+		"contacts/ada.md":     "---\ndraft: maybe\n---\n",
+		"contacts/card.txt":   "draft: maybe\n",
+	})
+
+	s, err := schema.Load(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := schema.Validate(ws, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type short struct{ Path, Property string }
+	var got []short
+	for _, v := range violations {
+		got = append(got, short{v.Path, v.Property})
+	}
+	want := []short{
+		{"books/bad.md", "finished"},
+		{"books/bad.md", "rating"},
+		{"books/bad.md", "status"},
+		{"books/bad.md", "tags"},
+		{"books/bad.md", "title"},
+		{"contacts/ada.md", "draft"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations = %+v, want %+v", violations, want)
+	}
+	if msg := violations[3].Message; msg != `"poetry" is not one of fiction, history` {
+		t.Errorf("tags message = %q", msg)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("missing schema is empty", func(t *testing.T) {
+		s, err := schema.Load(newWorkspace(t, nil))
+		if err != nil || len(s) != 0 {
+			t.Errorf("Load = %v, %v", s, err)
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		ws := newWorkspace(t, map[string]string{schema.Path: `{"books": {"title": {"type": "text"}}}`})
+		if _, err := schema.Load(ws); !errors.Is(err, schema.ErrInvalidSchema) {
+			t.Errorf("err = %v, want ErrInvalidSchema", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package sealed encrypts individual notes with a password, for the few
+// notes that need more than encryption at rest: a sealed note stays
+// unreadable to anyone with the workspace, or its key, but not the
+// password.
+//
+// A sealed note is stored in place, under its own name, as
+//
+//	magic | version | iterations | salt | nonce | AES-GCM ciphertext
+//
+// with the key derived from the password by PBKDF2-SHA256 and the header
+// authenticated along with the note. The magic holds a NUL byte, so the
+// note reads as binary and stays out of the note index and everything
+// else that reads notes as text.
+package sealed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	magic   = "WSDMSEAL\x00"
+	version = 1
+	// Iterations follows OWASP's advice for PBKDF2-SHA256. It is stored in
+	// each note, so it can be raised without breaking older notes.
+	Iterations = 600_000
+	saltLen    = 16
+	keyLen     = 32
+	nonceLen   = 12
+	headerLen  = len(magic) + 1 + 4 + saltLen
+)
+
+var (
+	ErrNotSealed     = errors.New("note is not sealed")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrNoPassword    = errors.New("password is required")
+	// ErrSealed is returned for changes to a sealed note made without its
+	// key, which would replace or corrupt it.
+	ErrSealed = errors.New("note is sealed; unlock it with its password")
+)
+
+// IsSealed reports whether data is a sealed note.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Sniff reports whether r holds a sealed note, reading no more than its
+// header needs.
+func Sniff(r io.Reader) (bool, error) {
+	buf := make([]byte, len(magic))
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return IsSealed(buf[:n]), nil
+}
+
+// Key is a key derived from a password, with what is needed to seal a note
+// again under the same password without asking for it.
+type Key struct {
+	iterations uint32
+	salt       []byte
+	aead       cipher.AEAD
+}
+
+// NewKey derives a key from password with a new salt, to seal a note.
+func NewKey(password string) (Key, error) {
+	if password == "" {
+		return Key{}, ErrNoPassword
+	}
+	salt := make([]byte, saltLen)
+	rand.Read(salt)
+	return deriveKey(password, salt, Iterations)
+}
+
+func deriveKey(password string, salt []byte, iterations uint32) (Key, error) {
+	raw, err := pbkdf2.Key(sha256.New, password, salt, int(iterations), keyLen)
+	if err != nil {
+		return Key{}, err
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return Key{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{iterations: iterations, salt: salt, aead: aead}, nil
+}
+
+// Unlock derives the key of the sealed note data from password and checks
+// it by opening the note.
+func Unlock(data []byte, password string) (Key, []byte, error) {
+	if !IsSealed(data) {
+		return Key{}, nil, ErrNotSealed
+	}
+	if len(data) < headerLen+nonceLen || data[len(magic)] != version {
+		return Key{}, nil, fmt.Errorf("unsupported sealed note version %d", data[len(magic)])
+	}
+	iterations := binary.BigEndian.Uint32(data[len(magic)+1:])
+	salt := bytes.Clone(data[len(magic)+5 : headerLen])
+	key, err := deriveKey(password, salt, iterations)
+	if err != nil {
+		return Key{}, nil, err
+	}
+	plain, err := key.Open(data)
+	if err != nil {
+		return Key{}, nil, err
+	}
+	return key, plain, nil
+}
+
+func (k Key) header() []byte {
+	h := make([]byte, 0, headerLen)
+	h = append(h, magic...)
+	h = append(h, version)
+	h = binary.BigEndian.AppendUint32(h, k.iterations)
+	return append(h, k.salt...)
+}
+
+// Seal encrypts a note with k.
+func (k Key) Seal(plain []byte) []byte {
+	header := k.header()
+	nonce := make([]byte, nonceLen)
+	rand.Read(nonce)
+	out := append(header, nonce...)
+	return k.aead.Seal(out, nonce, plain, header)
+}
+
+// Open decrypts a note sealed with k. A note sealed again since k was
+// derived, under another password or salt, fails as a wrong password.
+func (k Key) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	header := k.header()
+	if len(data) < headerLen+nonceLen || !bytes.Equal(data[:headerLen], header) {
+		return nil, ErrWrongPassword
+	}
+	plain, err := k.aead.Open(nil, data[headerLen:headerLen+nonceLen], data[headerLen+nonceLen:], header)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return plain, nil
+}
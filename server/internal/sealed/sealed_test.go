@@ -0,0 +1,100 @@
+package sealed_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/textfile"
+)
+
+func TestSeal(t *testing.T) {
+	note := []byte("# Diary\n\nDear diary.\n")
+	key, err := sealed.NewKey("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := key.Seal(note)
+	if !sealed.IsSealed(data) || bytes.Contains(data, []byte("diary")) {
+		t.Fatalf("sealed note = %q", data)
+	}
+	if kind := textfile.Classify(data, int64(len(data))); kind != textfile.Binary {
+		t.Errorf("sealed note classified as %s, want binary so it isn't indexed", kind)
+	}
+	if ok, err := sealed.Sniff(bytes.NewReader(data)); !ok || err != nil {
+		t.Errorf("Sniff(sealed) = %v, %v", ok, err)
+	}
+	if ok, _ := sealed.Sniff(bytes.NewReader([]byte("WSDM"))); ok {
+		t.Error("Sniff took a short note for a sealed one")
+	}
+
+	key, plain, err := sealed.Unlock(data, "hunter2")
+	if err != nil || !bytes.Equal(plain, note) {
+		t.Fatalf("Unlock = %q, %v", plain, err)
+	}
+	edited := key.Seal([]byte("# Diary\n\nDear diary, again.\n"))
+	if plain, err := key.Open(edited); err != nil || !bytes.Contains(plain, []byte("again")) {
+		t.Errorf("reopening an edit = %q, %v", plain, err)
+	}
+	if _, _, err := sealed.Unlock(edited, "hunter2"); err != nil {
+		t.Errorf("the password no longer opens the note after an edit: %v", err)
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		if _, _, err := sealed.Unlock(data, "hunter3"); !errors.Is(err, sealed.ErrWrongPassword) {
+			t.Errorf("err = %v, want ErrWrongPassword", err)
+		}
+		other, _ := sealed.NewKey("hunter2")
+		if _, err := other.Open(data); !errors.Is(err, sealed.ErrWrongPassword) {
+			t.Errorf("a key with another salt: err = %v, want ErrWrongPassword", err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(data)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := key.Open(tampered); !errors.Is(err, sealed.ErrWrongPassword) {
+			t.Errorf("err = %v, want ErrWrongPassword", err)
+		}
+	})
+
+	t.Run("not sealed", func(t *testing.T) {
+		if _, _, err := sealed.Unlock(note, "hunter2"); !errors.Is(err, sealed.ErrNotSealed) {
+			t.Errorf("err = %v, want ErrNotSealed", err)
+		}
+	})
+
+	t.Run("no password", func(t *testing.T) {
+		if _, err := sealed.NewKey(""); !errors.Is(err, sealed.ErrNoPassword) {
+			t.Errorf("err = %v, want ErrNoPassword", err)
+		}
+	})
+}
+
+func TestUnlocks(t *testing.T) {
+	key, err := sealed.NewKey("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := sealed.NewUnlocks()
+	now := time.Now()
+	token, expires := u.Grant("diary.md", key, now)
+	if !expires.Equal(now.Add(sealed.UnlockTTL)) {
+		t.Errorf("expires = %v", expires)
+	}
+	if _, ok := u.Key(token, "diary.md", now); !ok {
+		t.Error("token not accepted")
+	}
+	if _, ok := u.Key(token, "other.md", now); ok {
+		t.Error("token accepted for another note")
+	}
+	if _, ok := u.Key(token, "diary.md", expires.Add(time.Second)); ok {
+		t.Error("expired token accepted")
+	}
+	u.Revoke("diary.md")
+	if _, ok := u.Key(token, "diary.md", now); ok {
+		t.Error("revoked token accepted")
+	}
+}
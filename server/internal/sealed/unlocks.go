@@ -0,0 +1,71 @@
+package sealed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// UnlockTTL is how long an unlock token lasts: long enough to read and
+// edit a note, short enough that a token left behind soon stops working.
+const UnlockTTL = 15 * time.Minute
+
+type unlock struct {
+	path    string
+	key     Key
+	expires time.Time
+}
+
+// Unlocks holds the keys of unlocked notes in memory, by token, so a client
+// sends the password once and not on every read and save. Tokens are lost
+// when the server restarts.
+type Unlocks struct {
+	mu     sync.Mutex
+	tokens map[string]unlock
+}
+
+func NewUnlocks() *Unlocks {
+	return &Unlocks{tokens: map[string]unlock{}}
+}
+
+// Grant returns a token for the note at p, and when it expires.
+func (u *Unlocks) Grant(p string, key Key, now time.Time) (string, time.Time) {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	expires := now.Add(UnlockTTL)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for t, e := range u.tokens {
+		if now.After(e.expires) {
+			delete(u.tokens, t)
+		}
+	}
+	u.tokens[token] = unlock{path: p, key: key, expires: expires}
+	return token, expires
+}
+
+// Key returns the key token unlocked, if it is for the note at p and
+// hasn't expired.
+func (u *Unlocks) Key(token, p string, now time.Time) (Key, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	e, ok := u.tokens[token]
+	if !ok || e.path != p || now.After(e.expires) {
+		return Key{}, false
+	}
+	return e.key, true
+}
+
+// Revoke drops every token for the note at p.
+func (u *Unlocks) Revoke(p string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for t, e := range u.tokens {
+		if e.path == p {
+			delete(u.tokens, t)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package share makes links to one file that work without a token or
+// signing in: share links, which show a note or attachment, and capture
+// links, which add text to a note, say from a phone's share sheet.
+//
+// A link carries its path, its expiry and an HMAC of both under the key in
+// WISDOM_SHARE_KEY, so it can't be made for another file by guessing, and
+// changing the key revokes every link given out.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/notes"
+	"github.com/shrik450/wisdom/internal/sealed"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// MaxCapture bounds the text one capture adds.
+const MaxCapture = 1 << 20
+
+var (
+	ErrDisabled    = errors.New("sharing is off")
+	ErrInvalidLink = errors.New("invalid or expired link")
+	ErrUnknownKind = errors.New("unknown link kind")
+	ErrNotNote     = errors.New("only notes can be captured to")
+)
+
+type Kind string
+
+const (
+	// KindShare links serve the file to GET.
+	KindShare Kind = "share"
+	// KindCapture links append the text POSTed to them to the note,
+	// creating it if need be.
+	KindCapture Kind = "capture"
+)
+
+type Signer struct {
+	key []byte
+}
+
+// FromEnv returns a signer with the key in WISDOM_SHARE_KEY, or nil, with
+// sharing off, if it isn't set.
+func FromEnv() *Signer {
+	if key := os.Getenv("WISDOM_SHARE_KEY"); key != "" {
+		return New(key)
+	}
+	return nil
+}
+
+func New(key string) *Signer {
+	return &Signer{key: []byte(key)}
+}
+
+// clean is the workspace path p, as links are signed for.
+func clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func (s *Signer) sign(kind Kind, p string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s\x00%s\x00%d", kind, p, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the link of kind to the file at p, under base. A zero
+// expires makes a link that doesn't expire.
+func (s *Signer) URL(base string, kind Kind, p string, expires time.Time) (string, error) {
+	if kind != KindShare && kind != KindCapture {
+		return "", ErrUnknownKind
+	}
+	p = clean(p)
+	if kind == KindCapture && !notes.IsNote(p) {
+		return "", ErrNotNote
+	}
+	var exp int64
+	q := url.Values{}
+	if !expires.IsZero() {
+		exp = expires.Unix()
+		q.Set("expires", strconv.FormatInt(exp, 10))
+	}
+	q.Set("sig", s.sign(kind, p, exp))
+	return strings.TrimSuffix(base, "/") + (&url.URL{Path: "/" + string(kind) + "/" + p}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Verify checks that q signs a link of kind to p that hasn't expired.
+func (s *Signer) Verify(kind Kind, p string, q url.Values, now time.Time) error {
+	var exp int64
+	if e := q.Get("expires"); e != "" {
+		var err error
+		if exp, err = strconv.ParseInt(e, 10, 64); err != nil || now.Unix() > exp {
+			return ErrInvalidLink
+		}
+	}
+	want := s.sign(kind, clean(p), exp)
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(want)) {
+		return ErrInvalidLink
+	}
+	return nil
+}
+
+// Handler serves share links under /share/ and capture links under
+// /capture/. A share link to a file that is gone, or in a locked folder,
+// is not found.
+func (s *Signer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/share/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		p := r.PathValue("path")
+		if err := s.Verify(KindShare, p, r.URL.Query(), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		ws := workspace.FromContext(r.Context())
+		info, err := ws.Stat(clean(p))
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := ws.Open(clean(p))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		// The file is the sharer's, not the server's: it mustn't run as a
+		// page of this origin.
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, no-cache")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+	mux.HandleFunc("/capture/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		p := r.PathValue("path")
+		if err := s.Verify(KindCapture, p, r.URL.Query(), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		text, err := captured(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := Capture(workspace.FromContext(r.Context()), clean(p), text); errors.Is(err, workspace.ErrLocked) || errors.Is(err, sealed.ErrSealed) {
+			http.Error(w, err.Error(), http.StatusLocked)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// captured is the text POSTed to a capture link: a form's text field, or
+// else the whole body.
+func captured(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxCapture)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			return "", err
+		}
+		return r.PostForm.Get("text"), nil
+	}
+	data, err := io.ReadAll(r.Body)
+	return string(data), err
+}
+
+// Capture appends text to the note at p, on lines of its own. A sealed
+// note can't be appended to and fails with sealed.ErrSealed.
+func Capture(ws *workspace.Workspace, p, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	data, err := ws.ReadFile(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if sealed.IsSealed(data) {
+		return sealed.ErrSealed
+	}
+	if len(data) > 0 {
+		if data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		data = append(data, '\n')
+	} else if err := ws.MkdirAll(path.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return ws.WriteFile(p, append(data, text+"\n"...), 0o644)
+}
@@ -0,0 +1,108 @@
+package share_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/middleware"
+	"github.com/shrik450/wisdom/internal/share"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestVerify(t *testing.T) {
+	signer := share.New("secret")
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	link, err := signer.URL("https://notes.example.com/", share.KindShare, "/blog/Hello World.md", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link, "https://notes.example.com/share/blog/Hello%20World.md?expires=") {
+		t.Fatalf("link = %q", link)
+	}
+	u, _ := url.Parse(link)
+	p := strings.TrimPrefix(u.Path, "/share/")
+
+	if err := signer.Verify(share.KindShare, p, u.Query(), now); err != nil {
+		t.Errorf("verify = %v", err)
+	}
+	for name, check := range map[string]func() error{
+		"expired":    func() error { return signer.Verify(share.KindShare, p, u.Query(), now.Add(2*time.Hour)) },
+		"other path": func() error { return signer.Verify(share.KindShare, "blog/other.md", u.Query(), now) },
+		"other kind": func() error { return signer.Verify(share.KindCapture, p, u.Query(), now) },
+		"other key":  func() error { return share.New("guess").Verify(share.KindShare, p, u.Query(), now) },
+		"extended": func() error {
+			q := u.Query()
+			q.Set("expires", "9999999999")
+			return signer.Verify(share.KindShare, p, q, now)
+		},
+	} {
+		if err := check(); !errors.Is(err, share.ErrInvalidLink) {
+			t.Errorf("%s: err = %v, want ErrInvalidLink", name, err)
+		}
+	}
+
+	if _, err := signer.URL("", share.KindCapture, "photo.png", time.Time{}); !errors.Is(err, share.ErrNotNote) {
+		t.Errorf("capturing to a file: err = %v, want ErrNotNote", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("note.md", []byte("Shared.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	signer := share.New("secret")
+	srv := httptest.NewServer(middleware.WithWorkspace(signer.Handler(), ws))
+	t.Cleanup(srv.Close)
+
+	t.Run("share", func(t *testing.T) {
+		link, _ := signer.URL(srv.URL, share.KindShare, "note.md", time.Time{})
+		resp, err := http.Get(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Security-Policy") != "sandbox" {
+			t.Errorf("share: %s, CSP %q", resp.Status, resp.Header.Get("Content-Security-Policy"))
+		}
+		resp, err = http.Get(strings.Replace(link, "note.md", "other.md", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("forged link: %s, want 403", resp.Status)
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		link, _ := signer.URL(srv.URL, share.KindCapture, "inbox/captured.md", time.Time{})
+		for _, text := range []string{"First thought", "Second thought\n"} {
+			resp, err := http.Post(link, "text/plain", strings.NewReader(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent {
+				t.Fatalf("capture: %s", resp.Status)
+			}
+		}
+		resp, err := http.PostForm(link, url.Values{"text": {"From a form"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		data, _ := ws.ReadFile("inbox/captured.md")
+		if want := "First thought\n\nSecond thought\n\nFrom a form\n"; string(data) != want {
+			t.Errorf("captured = %q, want %q", data, want)
+		}
+	})
+}
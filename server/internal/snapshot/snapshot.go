@@ -0,0 +1,401 @@
+// Package snapshot keeps point-in-time copies of the workspace, so old
+// versions of files can be read back.
+//
+// Files are stored once per distinct content, named by their SHA-256, and a
+// snapshot is a manifest mapping paths to contents. Unchanged files cost
+// nothing in later snapshots, and files whose size and modification time
+// match the previous snapshot are not even read again. Everything lives in
+// the workspace under Dir and goes through the workspace, so it is
+// encrypted like the rest when encryption is on.
+//
+// Like other workspace walks, hidden directories at the root, including
+// .wisdom itself, are not included. Empty directories aren't recorded.
+package snapshot
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Dir = ".wisdom/snapshots"
+
+var ErrUnknownSnapshot = errors.New("unknown snapshot")
+
+// mu serializes creating and deleting snapshots, so a prune never removes
+// a file a snapshot being created relies on.
+var mu sync.Mutex
+
+type Entry struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Files   int       `json:"files"`
+	// Size is the total size of the files, not the space taken on disk,
+	// which is less thanks to sharing.
+	Size int64 `json:"size"`
+}
+
+type Manifest struct {
+	Snapshot
+	Entries map[string]Entry `json:"entries"`
+}
+
+func manifestPath(id string) string {
+	return Dir + "/" + id + ".json"
+}
+
+func objectPath(hash string) string {
+	return Dir + "/objects/" + hash[:2] + "/" + hash[2:]
+}
+
+// Create snapshots the workspace as of now.
+func Create(ws *workspace.Workspace, now time.Time) (Snapshot, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	all, err := List(ws)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var previous map[string]Entry
+	if len(all) > 0 {
+		m, err := Load(ws, all[0].ID)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		previous = m.Entries
+	}
+
+	walked, err := ws.WalkFiles()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	m := Manifest{
+		Snapshot: Snapshot{ID: hex.EncodeToString(buf), Created: now.UTC()},
+		Entries:  map[string]Entry{},
+	}
+	for _, e := range walked {
+		if e.IsDir {
+			continue
+		}
+		info, err := ws.Stat(e.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		entry := Entry{Size: info.Size(), ModTime: info.ModTime().UTC()}
+		if prev, ok := previous[e.Path]; ok && prev.Size == entry.Size && prev.ModTime.Equal(entry.ModTime) {
+			entry.Hash = prev.Hash
+		} else if entry.Hash, err = store(ws, e.Path); err != nil {
+			return Snapshot{}, fmt.Errorf("%s: %w", e.Path, err)
+		}
+		m.Entries[e.Path] = entry
+		m.Files++
+		m.Size += entry.Size
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := ws.WriteFile(manifestPath(m.ID), data, 0o644); err != nil {
+		return Snapshot{}, err
+	}
+	return m.Snapshot, nil
+}
+
+// store copies the file at p into the object store, unless its content is
+// there already, and returns its hash.
+func store(ws *workspace.Workspace, p string) (string, error) {
+	f, err := ws.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	obj := objectPath(hash)
+	if _, err := ws.Stat(obj); err == nil {
+		return hash, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := ws.MkdirAll(path.Dir(obj), 0o755); err != nil {
+		return "", err
+	}
+	// Written to a temporary name first, so an interrupted copy is never
+	// taken for the complete object.
+	return hash, ws.WriteStream(obj, f, 0o444)
+}
+
+// List returns the snapshots, newest first.
+func List(ws *workspace.Workspace) ([]Snapshot, error) {
+	entries, err := ws.ReadDir(Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []Snapshot{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok {
+			continue
+		}
+		m, err := Load(ws, id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m.Snapshot)
+	}
+	slices.SortFunc(result, func(a, b Snapshot) int {
+		return cmp.Or(b.Created.Compare(a.Created), strings.Compare(a.ID, b.ID))
+	})
+	return result, nil
+}
+
+func Load(ws *workspace.Workspace, id string) (*Manifest, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrUnknownSnapshot
+	}
+	data, err := ws.ReadFile(manifestPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrUnknownSnapshot
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// Open opens the file at p as it was in the snapshot. Files in folders
+// locked in ws fail with workspace.ErrLocked, as they would in ws itself.
+func (m *Manifest) Open(ws *workspace.Workspace, p string) (io.ReadSeekCloser, Entry, error) {
+	if ws.IsLocked(p) {
+		return nil, Entry{}, fmt.Errorf("%w: %s", workspace.ErrLocked, p)
+	}
+	entry, ok := m.Entries[p]
+	if !ok {
+		return nil, Entry{}, fs.ErrNotExist
+	}
+	f, err := ws.Open(objectPath(entry.Hash))
+	return f, entry, err
+}
+
+// DirEntry is a file or directory in a snapshot's directory listing.
+type DirEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// ReadDir lists the directory at dir ("." for the root) as it was in the
+// snapshot, sorted by name. It reports false if there was no such
+// directory. Directories are dated by their newest file. Files in folders
+// locked in ws are left out, as walks of ws leave them out.
+func (m *Manifest) ReadDir(ws *workspace.Workspace, dir string) ([]DirEntry, bool) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	if dir == "." || dir == "" {
+		prefix = ""
+	}
+	byName := map[string]*DirEntry{}
+	for p, entry := range m.Entries {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok || ws.IsLocked(p) {
+			continue
+		}
+		name, _, isDir := strings.Cut(rest, "/")
+		d, seen := byName[name]
+		if !seen {
+			d = &DirEntry{Name: name, IsDir: isDir}
+			byName[name] = d
+		}
+		if isDir {
+			if entry.ModTime.After(d.ModTime) {
+				d.ModTime = entry.ModTime
+			}
+		} else {
+			d.Size, d.ModTime = entry.Size, entry.ModTime
+		}
+	}
+	if len(byName) == 0 && prefix != "" {
+		return nil, false
+	}
+	result := make([]DirEntry, 0, len(byName))
+	for _, d := range byName {
+		result = append(result, *d)
+	}
+	slices.SortFunc(result, func(a, b DirEntry) int { return strings.Compare(a.Name, b.Name) })
+	return result, true
+}
+
+// Delete removes a snapshot and the stored files no other snapshot uses.
+func Delete(ws *workspace.Workspace, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := Load(ws, id); err != nil {
+		return err
+	}
+	if err := ws.Remove(manifestPath(id)); err != nil {
+		return err
+	}
+	return prune(ws)
+}
+
+// Forget removes the file at p from every snapshot, along with its stored
+// contents no other file uses, so a version of it that must not be kept,
+// such as a note's plaintext before it was sealed, can't be read back.
+func Forget(ws *workspace.Workspace, p string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	all, err := List(ws)
+	if err != nil {
+		return err
+	}
+	for _, s := range all {
+		m, err := Load(ws, s.ID)
+		if err != nil {
+			return err
+		}
+		e, ok := m.Entries[p]
+		if !ok {
+			continue
+		}
+		delete(m.Entries, p)
+		m.Files--
+		m.Size -= e.Size
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := ws.WriteFile(manifestPath(m.ID), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return prune(ws)
+}
+
+func prune(ws *workspace.Workspace) error {
+	all, err := List(ws)
+	if err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	for _, s := range all {
+		m, err := Load(ws, s.ID)
+		if err != nil {
+			return err
+		}
+		for _, e := range m.Entries {
+			used[e.Hash] = true
+		}
+	}
+
+	objects := Dir + "/objects"
+	buckets, err := ws.ReadDir(objects)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		files, err := ws.ReadDir(objects + "/" + b.Name())
+		if err != nil {
+			return err
+		}
+		removed := 0
+		for _, f := range files {
+			if used[b.Name()+f.Name()] {
+				continue
+			}
+			if err := ws.Remove(objects + "/" + b.Name() + "/" + f.Name()); err != nil {
+				return err
+			}
+			removed++
+		}
+		if removed == len(files) {
+			ws.Remove(objects + "/" + b.Name())
+		}
+	}
+	return nil
+}
+
+// Keep deletes all but the newest n snapshots.
+func Keep(ws *workspace.Workspace, n int) error {
+	all, err := List(ws)
+	if err != nil {
+		return err
+	}
+	for _, s := range all[min(n, len(all)):] {
+		if err := Delete(ws, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage returns the total size of the files under Dir: the stored contents
+// and the manifests.
+func Usage(ws *workspace.Workspace) (int64, error) {
+	var walk func(dir string) (int64, error)
+	walk = func(dir string) (int64, error) {
+		entries, err := ws.ReadDir(dir)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, e := range entries {
+			if e.IsDir() {
+				n, err := walk(dir + "/" + e.Name())
+				if err != nil {
+					return 0, err
+				}
+				total += n
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return total, nil
+	}
+	total, err := walk(Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	return total, err
+}
@@ -0,0 +1,132 @@
+package snapshot_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/snapshot"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func read(t *testing.T, ws *workspace.Workspace, m *snapshot.Manifest, p string) string {
+	t.Helper()
+	f, _, err := m.Open(ws, p)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", p, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func countObjects(t *testing.T, root string) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(filepath.Join(root, ".wisdom/snapshots/objects"), func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestSnapshots(t *testing.T) {
+	root := t.TempDir()
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MkdirAll("journal", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"journal/monday.md": "Rainy.\n",
+		"todo.md":           "Buy milk.\n",
+		"copy.md":           "Buy milk.\n",
+	}
+	for p, content := range files {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	week := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	first, err := snapshot.Create(ws, week)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Files != 3 || first.Size != 27 {
+		t.Errorf("first = %+v", first)
+	}
+	// Identical files are stored once.
+	if n := countObjects(t, root); n != 2 {
+		t.Errorf("objects = %d, want 2", n)
+	}
+
+	if err := ws.WriteFile("todo.md", []byte("Buy eggs.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := snapshot.Create(ws, week.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countObjects(t, root); n != 3 {
+		t.Errorf("objects after a change = %d, want 3", n)
+	}
+
+	all, err := snapshot.List(ws)
+	if err != nil || len(all) != 2 || all[0].ID != second.ID {
+		t.Fatalf("List = %+v, %v", all, err)
+	}
+	old, err := snapshot.Load(ws, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, ws, old, "todo.md"); got != "Buy milk.\n" {
+		t.Errorf("old todo.md = %q", got)
+	}
+	entries, ok := old.ReadDir(ws, ".")
+	if !ok || len(entries) != 3 || entries[0].Name != "copy.md" || !entries[1].IsDir || entries[1].Name != "journal" {
+		t.Errorf("ReadDir(.) = %+v", entries)
+	}
+	if _, ok := old.ReadDir(ws, "missing"); ok {
+		t.Error("ReadDir found a directory that wasn't there")
+	}
+
+	locked := ws.Lock([]string{"journal"})
+	if _, _, err := old.Open(locked, "journal/monday.md"); !errors.Is(err, workspace.ErrLocked) {
+		t.Errorf("Open in a locked folder = %v, want ErrLocked", err)
+	}
+	if entries, _ := old.ReadDir(locked, "."); len(entries) != 2 {
+		t.Errorf("ReadDir(.) with journal locked = %+v", entries)
+	}
+
+	// The second snapshot still uses every object, so deleting the first
+	// frees nothing; deleting both frees everything.
+	if err := snapshot.Delete(ws, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := countObjects(t, root); n != 3 {
+		t.Errorf("objects after delete = %d, want 3", n)
+	}
+	if err := snapshot.Keep(ws, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countObjects(t, root); n != 0 {
+		t.Errorf("objects after deleting all = %d, want 0", n)
+	}
+	if _, err := snapshot.Load(ws, first.ID); !errors.Is(err, snapshot.ErrUnknownSnapshot) {
+		t.Errorf("err = %v, want ErrUnknownSnapshot", err)
+	}
+	if _, err := snapshot.Load(ws, "../schema"); !errors.Is(err, snapshot.ErrUnknownSnapshot) {
+		t.Errorf("err = %v, want ErrUnknownSnapshot", err)
+	}
+}
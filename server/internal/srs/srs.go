@@ -0,0 +1,268 @@
+// Package srs turns question/answer blocks and cloze deletions in notes into
+// flashcards and schedules their reviews with SM-2.
+//
+// Cards live in the notes they are written in and are read fresh on every
+// request; only the review schedule is stored, in a JSON file in the
+// workspace. A card's ID is derived from its note and question, so editing
+// an answer keeps its schedule while rewording the question starts over.
+package srs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const StatePath = ".wisdom/srs.json"
+
+const (
+	KindQA    = "qa"
+	KindCloze = "cloze"
+)
+
+var (
+	ErrUnknownCard = errors.New("unknown card")
+	ErrQuality     = errors.New("quality must be between 0 and 5")
+)
+
+// cloze matches Anki style deletions: {{c1::answer}} or {{c1::answer::hint}}.
+var cloze = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+// stateMu serializes answers, which rewrite the whole schedule.
+var stateMu sync.Mutex
+
+type Card struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// Review is a card's SM-2 state. Due is a date (YYYY-MM-DD).
+type Review struct {
+	Ease        float64 `json:"ease"`
+	Interval    int     `json:"interval"`
+	Repetitions int     `json:"repetitions"`
+	Due         string  `json:"due"`
+}
+
+func cardID(p, front string) string {
+	sum := sha256.Sum256([]byte(p + "\x00" + front))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Parse returns the cards in note. A "Q:" line starts a question and the
+// "A:" line after it its answer; both may continue on following lines until
+// a blank line. Each cloze number in a paragraph makes one card, with that
+// deletion hidden and the others shown.
+func Parse(p, note string) []Card {
+	doc := frontmatter.Parse(note)
+	offset := strings.Count(note[:len(note)-len(doc.Body)], "\n")
+	lines := strings.Split(doc.Body, "\n")
+
+	var cards []Card
+	for i := 0; i < len(lines); i++ {
+		start := i
+		para := []string{}
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			para = append(para, strings.TrimSpace(lines[i]))
+		}
+		if len(para) == 0 {
+			continue
+		}
+		line := offset + start + 1
+		if card, ok := parseQA(para); ok {
+			card.Path, card.Line, card.ID = p, line, cardID(p, card.Front)
+			cards = append(cards, card)
+			continue
+		}
+		for _, card := range parseCloze(strings.Join(para, "\n")) {
+			card.Path, card.Line, card.ID = p, line, cardID(p, card.Front)
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+func parseQA(para []string) (Card, bool) {
+	q, ok := strings.CutPrefix(para[0], "Q:")
+	if !ok {
+		return Card{}, false
+	}
+	question := []string{strings.TrimSpace(q)}
+	for i, l := range para[1:] {
+		a, ok := strings.CutPrefix(l, "A:")
+		if !ok {
+			question = append(question, l)
+			continue
+		}
+		answer := append([]string{strings.TrimSpace(a)}, para[i+2:]...)
+		return Card{
+			Kind:  KindQA,
+			Front: strings.Join(question, "\n"),
+			Back:  strings.Join(answer, "\n"),
+		}, true
+	}
+	return Card{}, false
+}
+
+func parseCloze(text string) []Card {
+	var numbers []string
+	for _, m := range cloze.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(numbers, m[1]) {
+			numbers = append(numbers, m[1])
+		}
+	}
+
+	back := cloze.ReplaceAllString(text, "$2")
+	var cards []Card
+	for _, n := range numbers {
+		front := cloze.ReplaceAllStringFunc(text, func(s string) string {
+			m := cloze.FindStringSubmatch(s)
+			if m[1] != n {
+				return m[2]
+			}
+			if m[3] != "" {
+				return "[" + m[3] + "]"
+			}
+			return "[...]"
+		})
+		cards = append(cards, Card{Kind: KindCloze, Front: front, Back: back})
+	}
+	return cards
+}
+
+// Collect reads the cards from every markdown note in the workspace.
+func Collect(ws *workspace.Workspace) ([]Card, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	var cards []Card
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		data, err := ws.ReadFile(e.Path)
+		if err != nil {
+			continue
+		}
+		cards = append(cards, Parse(e.Path, string(data))...)
+	}
+	return cards, nil
+}
+
+func LoadState(ws *workspace.Workspace) (map[string]Review, error) {
+	state := map[string]Review{}
+	data, err := ws.ReadFile(StatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading review schedule: %w", err)
+	}
+	return state, nil
+}
+
+// Due returns the cards to review on today, reviews that are overdue the
+// longest first and new cards last.
+func Due(cards []Card, state map[string]Review, today string) []Card {
+	var due []Card
+	for _, c := range cards {
+		r, seen := state[c.ID]
+		if !seen || r.Due <= today {
+			due = append(due, c)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b Card) int {
+		ra, aSeen := state[a.ID]
+		rb, bSeen := state[b.ID]
+		switch {
+		case aSeen && !bSeen:
+			return -1
+		case !aSeen && bSeen:
+			return 1
+		}
+		return strings.Compare(ra.Due, rb.Due)
+	})
+	return due
+}
+
+// Schedule applies an answer of the given quality (0-5) to r with SM-2.
+func Schedule(r Review, quality int, today time.Time) Review {
+	if r.Ease == 0 {
+		r.Ease = 2.5
+	}
+	if quality < 3 {
+		r.Repetitions = 0
+		r.Interval = 1
+	} else {
+		r.Repetitions++
+		switch r.Repetitions {
+		case 1:
+			r.Interval = 1
+		case 2:
+			r.Interval = 6
+		default:
+			r.Interval = int(math.Round(float64(r.Interval) * r.Ease))
+		}
+	}
+	q := float64(5 - quality)
+	r.Ease = max(1.3, r.Ease+0.1-q*(0.08+q*0.02))
+	r.Due = today.AddDate(0, 0, r.Interval).Format(time.DateOnly)
+	return r
+}
+
+// Answer records a review of the card with the given ID and returns its new
+// schedule.
+func Answer(ws *workspace.Workspace, id string, quality int, today time.Time) (Review, error) {
+	if quality < 0 || quality > 5 {
+		return Review{}, ErrQuality
+	}
+	cards, err := Collect(ws)
+	if err != nil {
+		return Review{}, err
+	}
+	if !slices.ContainsFunc(cards, func(c Card) bool { return c.ID == id }) {
+		return Review{}, fmt.Errorf("%w: %s", ErrUnknownCard, id)
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	state, err := LoadState(ws)
+	if err != nil {
+		return Review{}, err
+	}
+	r := Schedule(state[id], quality, today)
+	state[id] = r
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return Review{}, err
+	}
+	if err := ws.MkdirAll(path.Dir(StatePath), 0o755); err != nil {
+		return Review{}, err
+	}
+	if err := ws.WriteFile(StatePath, data, 0o644); err != nil {
+		return Review{}, err
+	}
+	return r, nil
+}
@@ -0,0 +1,93 @@
+package srs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/srs"
+)
+
+func TestParse(t *testing.T) {
+	check := func(t *testing.T, note string, want []srs.Card) {
+		t.Helper()
+		got := srs.Parse("bio.md", note)
+		for i := range got {
+			if got[i].ID == "" {
+				t.Errorf("card %d has no ID", i)
+			}
+			got[i].ID = ""
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse =\n%+v\nwant\n%+v", got, want)
+		}
+	}
+
+	t.Run("question and answer", func(t *testing.T) {
+		check(t, "---\ntitle: Bio\n---\nQ: What is the powerhouse\nof the cell?\nA: The mitochondria.\nIt makes ATP.\n\nQ: Unanswered\n", []srs.Card{{
+			Kind:  srs.KindQA,
+			Path:  "bio.md",
+			Line:  4,
+			Front: "What is the powerhouse\nof the cell?",
+			Back:  "The mitochondria.\nIt makes ATP.",
+		}})
+	})
+
+	t.Run("cloze deletions", func(t *testing.T) {
+		check(t, "Intro.\n\n{{c1::Canberra}} is the capital of {{c2::Australia::country}}.\n", []srs.Card{
+			{Kind: srs.KindCloze, Path: "bio.md", Line: 3, Front: "[...] is the capital of Australia.", Back: "Canberra is the capital of Australia."},
+			{Kind: srs.KindCloze, Path: "bio.md", Line: 3, Front: "Canberra is the capital of [country].", Back: "Canberra is the capital of Australia."},
+		})
+	})
+
+	t.Run("ids follow the question", func(t *testing.T) {
+		a := srs.Parse("bio.md", "Q: Same\nA: One\n")
+		b := srs.Parse("bio.md", "Intro\n\nQ: Same\nA: Two\n")
+		c := srs.Parse("bio.md", "Q: Different\nA: One\n")
+		if a[0].ID != b[0].ID || a[0].ID == c[0].ID {
+			t.Errorf("ids = %s, %s, %s", a[0].ID, b[0].ID, c[0].ID)
+		}
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	today := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	r := srs.Schedule(srs.Review{}, 5, today)
+	want := srs.Review{Ease: 2.6, Interval: 1, Repetitions: 1, Due: "2026-03-02"}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("first review = %+v, want %+v", r, want)
+	}
+
+	r = srs.Schedule(r, 4, today)
+	if r.Interval != 6 || r.Repetitions != 2 || r.Due != "2026-03-07" {
+		t.Fatalf("second review = %+v", r)
+	}
+
+	r = srs.Schedule(r, 4, today)
+	if r.Interval != 16 || r.Repetitions != 3 {
+		t.Fatalf("third review = %+v", r)
+	}
+
+	r = srs.Schedule(r, 1, today)
+	if r.Interval != 1 || r.Repetitions != 0 || r.Ease < 1.3 {
+		t.Fatalf("lapse = %+v", r)
+	}
+}
+
+func TestDue(t *testing.T) {
+	cards := []srs.Card{{ID: "new"}, {ID: "later"}, {ID: "overdue"}, {ID: "today"}}
+	state := map[string]srs.Review{
+		"later":   {Due: "2026-03-05"},
+		"overdue": {Due: "2026-02-20"},
+		"today":   {Due: "2026-03-01"},
+	}
+
+	var ids []string
+	for _, c := range srs.Due(cards, state, "2026-03-01") {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"overdue", "today", "new"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Due = %v, want %v", ids, want)
+	}
+}
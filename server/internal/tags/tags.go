@@ -0,0 +1,318 @@
+// Package tags reads and rewrites the tags of markdown notes: the tags list
+// in the frontmatter and inline #tags in the body.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const field = "tags"
+
+// inlineTag matches #tag after the start of a line or whitespace, so
+// headings ("# Title") and URL fragments aren't tags.
+var inlineTag = regexp.MustCompile(`(^|[\s(])#([\p{L}\p{N}_][\p{L}\p{N}_/-]*)`)
+
+var ErrInvalidOp = errors.New("invalid tag operation")
+
+type OpKind string
+
+const (
+	OpAdd    OpKind = "add"
+	OpRemove OpKind = "remove"
+	OpRename OpKind = "rename"
+)
+
+// Op is an edit to a tag. To is only used by renames.
+type Op struct {
+	Kind OpKind `json:"op"`
+	Tag  string `json:"tag"`
+	To   string `json:"to,omitempty"`
+}
+
+// Normalize strips the leading # a tag may be written with.
+func Normalize(tag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(tag), "#")
+}
+
+func (op Op) Validate() error {
+	if Normalize(op.Tag) == "" || strings.ContainsAny(Normalize(op.Tag), " \t\n") {
+		return fmt.Errorf("%w: a tag without spaces is required", ErrInvalidOp)
+	}
+	switch op.Kind {
+	case OpAdd, OpRemove:
+		return nil
+	case OpRename:
+		if Normalize(op.To) == "" || strings.ContainsAny(Normalize(op.To), " \t\n") {
+			return fmt.Errorf("%w: rename needs a tag to rename to", ErrInvalidOp)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown op %q", ErrInvalidOp, op.Kind)
+}
+
+// bodyLines calls fn with each line of body outside fenced code blocks and
+// returns the body with the lines fn returned.
+func bodyLines(body string, fn func(string) string) string {
+	lines := strings.Split(body, "\n")
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if !inCode {
+			lines[i] = fn(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Extract returns the tags of note, frontmatter tags first, without
+// duplicates.
+func Extract(note string) []string {
+	doc := frontmatter.Parse(note)
+	var result []string
+	add := func(tag string) {
+		if tag = Normalize(tag); tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	for _, tag := range doc.List(field) {
+		add(tag)
+	}
+	bodyLines(doc.Body, func(line string) string {
+		for _, m := range inlineTag.FindAllStringSubmatch(line, -1) {
+			add(m[2])
+		}
+		return line
+	})
+	return result
+}
+
+// Apply edits the tags of note, comparing them case-insensitively. Added
+// tags go in the frontmatter; removes and renames also rewrite inline tags.
+func Apply(note string, op Op) (string, error) {
+	tag, to := Normalize(op.Tag), Normalize(op.To)
+	doc := frontmatter.Parse(note)
+
+	current := doc.List(field)
+	updated := slices.Clone(current)
+	switch op.Kind {
+	case OpAdd:
+		if !slices.ContainsFunc(Extract(note), func(t string) bool { return strings.EqualFold(t, tag) }) {
+			updated = append(updated, tag)
+		}
+	case OpRemove:
+		updated = slices.DeleteFunc(updated, func(t string) bool { return strings.EqualFold(Normalize(t), tag) })
+	case OpRename:
+		// Renaming onto a tag the note already has merges the two.
+		updated = nil
+		for _, t := range current {
+			if strings.EqualFold(Normalize(t), tag) {
+				t = to
+			}
+			if !slices.ContainsFunc(updated, func(u string) bool { return strings.EqualFold(Normalize(u), Normalize(t)) }) {
+				updated = append(updated, t)
+			}
+		}
+	}
+	if !slices.Equal(updated, current) {
+		if updated == nil {
+			updated = []string{}
+		}
+		if err := doc.Set(field, updated); err != nil {
+			return note, err
+		}
+	}
+
+	if op.Kind != OpAdd {
+		doc.Body = bodyLines(doc.Body, func(line string) string {
+			return inlineTag.ReplaceAllStringFunc(line, func(m string) string {
+				sub := inlineTag.FindStringSubmatch(m)
+				if !strings.EqualFold(sub[2], tag) {
+					return m
+				}
+				if op.Kind == OpRename {
+					return sub[1] + "#" + to
+				}
+				return sub[1]
+			})
+		})
+	}
+
+	return doc.Render(), nil
+}
+
+// Batch applies op to the notes at paths and to every note under folder,
+// and returns the notes it changed. Every edit is worked out before any is
+// written, and if a write fails the notes already written are restored, so
+// the batch applies in full or not at all.
+func Batch(ws *workspace.Workspace, paths []string, folder string, op Op) ([]string, error) {
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+
+	targets := slices.Clone(paths)
+	if folder != "" {
+		entries, err := ws.WalkFiles()
+		if err != nil {
+			return nil, err
+		}
+		prefix := strings.TrimSuffix(folder, "/") + "/"
+		if folder == "." {
+			prefix = ""
+		}
+		for _, e := range entries {
+			if !e.IsDir && strings.HasPrefix(e.Path, prefix) && strings.EqualFold(path.Ext(e.Path), ".md") {
+				targets = append(targets, e.Path)
+			}
+		}
+	}
+	slices.Sort(targets)
+	targets = slices.Compact(targets)
+
+	type edit struct {
+		path          string
+		before, after []byte
+	}
+	var edits []edit
+	for _, p := range targets {
+		data, err := ws.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := Apply(string(data), op)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if updated != string(data) {
+			edits = append(edits, edit{p, data, []byte(updated)})
+		}
+	}
+
+	changed := []string{}
+	for i, e := range edits {
+		if err := ws.WriteFile(e.path, e.after, 0o644); err != nil {
+			for _, done := range edits[:i] {
+				_ = ws.WriteFile(done.path, done.before, 0o644)
+			}
+			return nil, err
+		}
+		changed = append(changed, e.path)
+	}
+	return changed, nil
+}
+
+// Scan returns the tags of every note in the workspace, by note path.
+func Scan(ws *workspace.Workspace) (map[string][]string, error) {
+	entries, err := ws.WalkFiles()
+	if err != nil {
+		return nil, err
+	}
+	byNote := map[string][]string{}
+	for _, e := range entries {
+		if e.IsDir || !strings.EqualFold(path.Ext(e.Path), ".md") {
+			continue
+		}
+		content, _, err := textfile.Read(ws, e.Path)
+		if err != nil {
+			continue
+		}
+		if noteTags := Extract(content); len(noteTags) > 0 {
+			byNote[e.Path] = noteTags
+		}
+	}
+	return byNote, nil
+}
+
+// Node is a tag in the hierarchy formed by splitting tags on "/".
+type Node struct {
+	// Name is the last segment of Tag.
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+	// Count is the number of notes with exactly this tag, Total also counts
+	// notes with tags below it, once each.
+	Count    int     `json:"count"`
+	Total    int     `json:"total"`
+	Children []*Node `json:"children"`
+}
+
+// Matches reports whether tag is prefix or nested under it, ignoring case.
+func Matches(tag, prefix string) bool {
+	tag, prefix = strings.ToLower(tag), strings.ToLower(strings.TrimSuffix(Normalize(prefix), "/"))
+	return tag == prefix || strings.HasPrefix(tag, prefix+"/")
+}
+
+// Tree builds the tag hierarchy from the result of Scan. Tags that differ
+// only in case share a node, named after the first spelling seen. Nodes are
+// sorted by name.
+func Tree(byNote map[string][]string) []*Node {
+	root := &Node{Children: []*Node{}}
+	nodes := map[string]*Node{}
+	direct := map[string]map[string]bool{}
+	below := map[string]map[string]bool{}
+
+	notePaths := slices.Sorted(maps.Keys(byNote))
+	for _, note := range notePaths {
+		for _, tag := range byNote[note] {
+			parent := root
+			segments := strings.Split(strings.Trim(tag, "/"), "/")
+			for i, segment := range segments {
+				full := strings.Join(segments[:i+1], "/")
+				key := strings.ToLower(full)
+				node, ok := nodes[key]
+				if !ok {
+					node = &Node{Name: segment, Tag: full, Children: []*Node{}}
+					nodes[key] = node
+					parent.Children = append(parent.Children, node)
+					below[key] = map[string]bool{}
+				}
+				below[key][note] = true
+				if i == len(segments)-1 {
+					if direct[key] == nil {
+						direct[key] = map[string]bool{}
+					}
+					direct[key][note] = true
+				}
+				parent = node
+			}
+		}
+	}
+
+	for key, node := range nodes {
+		node.Count = len(direct[key])
+		node.Total = len(below[key])
+		sortNodes(node.Children)
+	}
+	sortNodes(root.Children)
+	return root.Children
+}
+
+func sortNodes(nodes []*Node) {
+	slices.SortFunc(nodes, func(a, b *Node) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+}
+
+// Notes returns the notes tagged with prefix or any tag nested under it,
+// sorted by path.
+func Notes(byNote map[string][]string, prefix string) []string {
+	result := []string{}
+	for note, noteTags := range byNote {
+		if slices.ContainsFunc(noteTags, func(t string) bool { return Matches(t, prefix) }) {
+			result = append(result, note)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
@@ -0,0 +1,174 @@
+package tags_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/tags"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const note = "---\ntitle: Trip\ntags: [travel, Japan]\n---\n# Kyoto #trip\n\nTemples #travel/asia and #food.\nSee https://example.com/#anchor\n```\n#code\n```\n"
+
+func TestExtract(t *testing.T) {
+	got := tags.Extract(note)
+	want := []string{"travel", "Japan", "trip", "travel/asia", "food"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract = %q, want %q", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		op   tags.Op
+		want string
+	}{
+		{
+			"add",
+			tags.Op{Kind: tags.OpAdd, Tag: "#kyoto"},
+			"---\ntitle: Trip\ntags: [\"travel\",\"Japan\",\"kyoto\"]\n---\n# Kyoto #trip\n\nTemples #travel/asia and #food.\nSee https://example.com/#anchor\n```\n#code\n```\n",
+		},
+		{
+			"add existing inline tag",
+			tags.Op{Kind: tags.OpAdd, Tag: "FOOD"},
+			note,
+		},
+		{
+			"remove",
+			tags.Op{Kind: tags.OpRemove, Tag: "food"},
+			"---\ntitle: Trip\ntags: [travel, Japan]\n---\n# Kyoto #trip\n\nTemples #travel/asia and .\nSee https://example.com/#anchor\n```\n#code\n```\n",
+		},
+		{
+			"rename",
+			tags.Op{Kind: tags.OpRename, Tag: "japan", To: "nihon"},
+			"---\ntitle: Trip\ntags: [\"travel\",\"nihon\"]\n---\n# Kyoto #trip\n\nTemples #travel/asia and #food.\nSee https://example.com/#anchor\n```\n#code\n```\n",
+		},
+		{
+			"rename merges",
+			tags.Op{Kind: tags.OpRename, Tag: "japan", To: "travel"},
+			"---\ntitle: Trip\ntags: [\"travel\"]\n---\n# Kyoto #trip\n\nTemples #travel/asia and #food.\nSee https://example.com/#anchor\n```\n#code\n```\n",
+		},
+		{
+			"rename leaves code alone",
+			tags.Op{Kind: tags.OpRename, Tag: "code", To: "snippet"},
+			note,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tags.Apply(note, tt.op)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("add without frontmatter", func(t *testing.T) {
+		got, err := tags.Apply("Plain note.\n", tags.Op{Kind: tags.OpAdd, Tag: "idea"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "---\ntags: [\"idea\"]\n---\nPlain note.\n"; got != want {
+			t.Errorf("Apply = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MkdirAll("projects/old", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"projects/a.md":     "Working on #alpha.\n",
+		"projects/old/b.md": "---\ntags: [alpha]\n---\n",
+		"projects/c.md":     "Nothing here.\n",
+		"projects/d.txt":    "#alpha\n",
+		"inbox.md":          "#alpha too\n",
+	}
+	for p, content := range files {
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, err := tags.Batch(ws, []string{"inbox.md"}, "projects", tags.Op{Kind: tags.OpRename, Tag: "alpha", To: "beta"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"inbox.md", "projects/a.md", "projects/old/b.md"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %q, want %q", changed, want)
+	}
+	if data, _ := ws.ReadFile("projects/a.md"); string(data) != "Working on #beta.\n" {
+		t.Errorf("a.md = %q", data)
+	}
+	if data, _ := ws.ReadFile("projects/d.txt"); string(data) != "#alpha\n" {
+		t.Errorf("non-note changed: %q", data)
+	}
+
+	t.Run("missing note changes nothing", func(t *testing.T) {
+		_, err := tags.Batch(ws, []string{"inbox.md", "gone.md"}, "", tags.Op{Kind: tags.OpRemove, Tag: "beta"})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if data, _ := ws.ReadFile("inbox.md"); string(data) != "#beta too\n" {
+			t.Errorf("inbox.md = %q", data)
+		}
+	})
+
+	t.Run("invalid op", func(t *testing.T) {
+		_, err := tags.Batch(ws, []string{"inbox.md"}, "", tags.Op{Kind: tags.OpRename, Tag: "beta"})
+		if !errors.Is(err, tags.ErrInvalidOp) {
+			t.Errorf("err = %v, want ErrInvalidOp", err)
+		}
+	})
+}
+
+func TestTree(t *testing.T) {
+	byNote := map[string][]string{
+		"a.md": {"project/alpha", "reading"},
+		"b.md": {"Project/beta", "project/alpha/urgent"},
+		"c.md": {"project"},
+		"d.md": {"projects"},
+	}
+
+	type flat struct {
+		Tag          string
+		Count, Total int
+	}
+	var got []flat
+	var walk func([]*tags.Node)
+	walk = func(nodes []*tags.Node) {
+		for _, n := range nodes {
+			got = append(got, flat{n.Tag, n.Count, n.Total})
+			walk(n.Children)
+		}
+	}
+	walk(tags.Tree(byNote))
+	want := []flat{
+		{"project", 1, 3},
+		{"project/alpha", 1, 2},
+		{"project/alpha/urgent", 1, 1},
+		{"Project/beta", 1, 1},
+		{"projects", 1, 1},
+		{"reading", 1, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tree =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got, want := tags.Notes(byNote, "#project"), []string{"a.md", "b.md", "c.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes(project) = %v, want %v", got, want)
+	}
+	if got, want := tags.Notes(byNote, "project/alpha/"), []string{"a.md", "b.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes(project/alpha/) = %v, want %v", got, want)
+	}
+}
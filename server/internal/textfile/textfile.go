@@ -0,0 +1,126 @@
+// Package textfile tells text files from binary ones and reads text whatever
+// its encoding, so features that read files as text, such as indexing and
+// previews, can skip what they can't use rather than load a huge log or
+// garble a Latin-1 file.
+package textfile
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+type Kind string
+
+const (
+	Text   Kind = "text"
+	Binary Kind = "binary"
+	// TooLarge is text over MaxSize, which is read only up to MaxSize.
+	TooLarge Kind = "too-large"
+)
+
+// MaxSize is the most of a text file that is read. Notes are far smaller;
+// bigger text files are logs and data dumps.
+const MaxSize = 8 << 20
+
+// sniffLen is how much of a file is looked at to classify it, as git does.
+const sniffLen = 8000
+
+// Classify tells the kind of a file of size from its first bytes. A file
+// with a NUL byte is binary: text in any common encoding other than UTF-16
+// has none.
+func Classify(head []byte, size int64) Kind {
+	if bytes.IndexByte(head[:min(len(head), sniffLen)], 0) >= 0 {
+		return Binary
+	}
+	if size > MaxSize {
+		return TooLarge
+	}
+	return Text
+}
+
+// Sniff classifies the file at p and reports whether it starts as UTF-8.
+func Sniff(ws *workspace.Workspace, p string) (kind Kind, isUTF8 bool, err error) {
+	info, err := ws.Stat(p)
+	if err != nil {
+		return "", false, err
+	}
+	f, err := ws.Open(p)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", false, err
+	}
+	head = head[:n]
+	if int64(n) < info.Size() {
+		// A character cut off at the end doesn't count against the file.
+		head = head[:n-trailingPartial(head)]
+	}
+	return Classify(head, info.Size()), utf8.Valid(head), nil
+}
+
+// Read returns the text of the file at p, decoded by Decode, and its kind.
+// Binary files have no text, and text over MaxSize is cut off there.
+func Read(ws *workspace.Workspace, p string) (string, Kind, error) {
+	info, err := ws.Stat(p)
+	if err != nil {
+		return "", "", err
+	}
+	f, err := ws.Open(p)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxSize))
+	if err != nil {
+		return "", "", err
+	}
+	kind := Classify(data, info.Size())
+	switch kind {
+	case Binary:
+		return "", kind, nil
+	case TooLarge:
+		return Decode(data[:len(data)-trailingPartial(data)]), kind, nil
+	}
+	return Decode(data), kind, nil
+}
+
+// Decode returns data as a string, reading it as Latin-1 if it isn't valid
+// UTF-8. Latin-1 is the likeliest other encoding of a text file, and every
+// byte sequence is valid in it, so nothing is lost.
+func Decode(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	var b strings.Builder
+	b.Grow(len(data) * 2)
+	for _, c := range data {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
+
+// trailingPartial returns how many bytes at the end of data start a UTF-8
+// character that was cut off.
+func trailingPartial(data []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		c := data[len(data)-i]
+		if c < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if r, _ := utf8.DecodeRune(data[len(data)-i:]); r == utf8.RuneError {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package textfile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/textfile"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestRead(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "é" takes two bytes, so the cut at MaxSize falls inside one.
+	huge := "x" + strings.Repeat("é", textfile.MaxSize/2)
+	for name, content := range map[string]string{
+		"utf8.md":   "# Café\n",
+		"latin1.md": "# Caf\xe9\n",
+		"binary.md": "PK\x03\x04\x00\x00",
+		"huge.log":  huge,
+	} {
+		if err := ws.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		wantKind textfile.Kind
+		wantText string
+		wantUTF8 bool
+	}{
+		{"utf8.md", textfile.Text, "# Café\n", true},
+		{"latin1.md", textfile.Text, "# Café\n", false},
+		{"binary.md", textfile.Binary, "", true},
+		{"huge.log", textfile.TooLarge, huge[:textfile.MaxSize-1], true},
+	}
+	for _, tt := range tests {
+		text, kind, err := textfile.Read(ws, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != tt.wantKind || text != tt.wantText {
+			t.Errorf("Read(%s) = %.20q (%d bytes), %s; want %.20q (%d bytes), %s",
+				tt.path, text, len(text), kind, tt.wantText, len(tt.wantText), tt.wantKind)
+		}
+		kind, isUTF8, err := textfile.Sniff(ws, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != tt.wantKind || isUTF8 != tt.wantUTF8 {
+			t.Errorf("Sniff(%s) = %s, %v; want %s, %v", tt.path, kind, isUTF8, tt.wantKind, tt.wantUTF8)
+		}
+	}
+}
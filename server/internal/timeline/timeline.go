@@ -0,0 +1,81 @@
+// Package timeline merges what happened in the workspace into one feed,
+// newest first: files edited, books imported and reading sessions.
+//
+// Like the change feed, it keeps no record of its own. Edits and imports
+// come from modification times, so each file appears once, at its last
+// change, and deleted files not at all.
+package timeline
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/calibre"
+	"github.com/shrik450/wisdom/internal/reading"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const (
+	TypeEdit    = "edit"
+	TypeImport  = "import"
+	TypeReading = "reading"
+)
+
+var Types = []string{TypeEdit, TypeImport, TypeReading}
+
+type Item struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+	// Reading sessions only.
+	End      time.Time `json:"end,omitzero"`
+	FromPage int       `json:"fromPage,omitempty"`
+	ToPage   int       `json:"toPage,omitempty"`
+}
+
+// Build returns the items of the given types, all of them if types is
+// empty, newest first. Book files count as imports, and every other file
+// as an edit.
+func Build(ws *workspace.Workspace, types []string) ([]Item, error) {
+	want := func(t string) bool { return len(types) == 0 || slices.Contains(types, t) }
+	var items []Item
+	if want(TypeEdit) || want(TypeImport) {
+		entries, err := ws.WalkFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir {
+				continue
+			}
+			t := TypeEdit
+			if calibre.Formats[strings.ToLower(path.Ext(e.Path))] {
+				t = TypeImport
+			}
+			if !want(t) {
+				continue
+			}
+			info, err := ws.Stat(e.Path)
+			if err != nil {
+				continue
+			}
+			items = append(items, Item{Type: t, Time: info.ModTime(), Path: e.Path})
+		}
+	}
+	if want(TypeReading) {
+		entries, err := reading.ReadLog(ws)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range reading.Sessions(entries) {
+			items = append(items, Item{Type: TypeReading, Time: s.Start, Path: s.Path, End: s.End, FromPage: s.FromPage, ToPage: s.ToPage})
+		}
+	}
+	slices.SortFunc(items, func(a, b Item) int {
+		return cmp.Or(b.Time.Compare(a.Time), strings.Compare(a.Path, b.Path), strings.Compare(a.Type, b.Type))
+	})
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package timeline_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/reading"
+	"github.com/shrik450/wisdom/internal/timeline"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestBuild(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	for p, hour := range map[string]int{"plan.md": 3, "dune.epub": 1, "photo.jpg": 2} {
+		if err := ws.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.Chtimes(p, base.Add(time.Duration(hour)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []reading.Entry{
+		{Kind: reading.KindProgress, Time: base.Add(4 * time.Hour), Path: "dune.epub", Page: 1},
+		{Kind: reading.KindProgress, Time: base.Add(4*time.Hour + 20*time.Minute), Path: "dune.epub", Page: 30},
+	} {
+		if err := reading.Append(ws, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	describe := func(items []timeline.Item) string {
+		var s []string
+		for _, it := range items {
+			s = append(s, it.Type+" "+it.Path)
+		}
+		return strings.Join(s, ", ")
+	}
+
+	items, err := timeline.Build(ws, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(items), "reading dune.epub, edit plan.md, edit photo.jpg, import dune.epub"; got != want {
+		t.Errorf("timeline = %s, want %s", got, want)
+	}
+	if s := items[0]; s.FromPage != 1 || s.ToPage != 30 || s.End.Sub(s.Time) != 20*time.Minute {
+		t.Errorf("session = %+v", s)
+	}
+
+	items, err = timeline.Build(ws, []string{timeline.TypeImport, timeline.TypeReading})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(items), "reading dune.epub, import dune.epub"; got != want {
+		t.Errorf("filtered timeline = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,320 @@
+// Package tokens gives each device its own API credential, limited to what
+// the device needs: a phone that only captures notes gets a token that
+// can't read or delete any.
+//
+// Only a hash of each token is kept, in a file that is always locked to
+// clients (see Workspace.Lock), so a leaked workspace or backup doesn't
+// leak working tokens.
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+const Path = ".wisdom/tokens.json"
+
+// prefix marks a secret as a wisdom token, so one pasted somewhere it
+// shouldn't be is easy to spot.
+const prefix = "wsd_"
+
+type Scope string
+
+const (
+	// ScopeRead allows every GET and HEAD, and batch reads.
+	ScopeRead Scope = "read-only"
+	// ScopeFSWrite allows changing files through /api/fs/.
+	ScopeFSWrite Scope = "fs-write"
+	// ScopeImport allows uploading new files, but not replacing any. It
+	// doesn't allow restoring a backup or importing from the server's disk.
+	ScopeImport Scope = "import-only"
+	// ScopeCapture allows creating files through /api/fs/, but not reading,
+	// replacing or deleting any.
+	ScopeCapture Scope = "capture-only"
+)
+
+var Scopes = []Scope{ScopeRead, ScopeFSWrite, ScopeImport, ScopeCapture}
+
+var (
+	ErrUnknownToken = errors.New("unknown token")
+	ErrExpired      = errors.New("token has expired")
+	ErrNoScopes     = errors.New("at least one scope is required")
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+type Token struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// Hash is the SHA-256 of the secret. The secrets are random, so a
+	// slow hash would add nothing but a cost to every request.
+	Hash    []byte    `json:"hash"`
+	Created time.Time `json:"created"`
+	// Expires is zero for a token that doesn't expire.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Use is the last request made with a token.
+type Use struct {
+	At        time.Time
+	UserAgent string
+	Addr      string
+}
+
+// Store keeps the tokens of a workspace.
+type Store struct {
+	mu sync.Mutex
+	// uses are kept in memory only: writing the file on every request
+	// would cost more than knowing the last use across restarts is worth.
+	uses map[string]Use
+	// Tokens are checked on every request, so they are kept until the file
+	// changes.
+	cached     []Token
+	cachedAt   time.Time
+	cachedSize int64
+	// storeMu serializes changes, which rewrite the whole file.
+	storeMu sync.Mutex
+}
+
+func New() *Store {
+	return &Store{uses: map[string]Use{}}
+}
+
+// load reads the tokens from the full workspace ws.
+func (s *Store) load(ws *workspace.Workspace) ([]Token, error) {
+	info, err := ws.Stat(Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if info.ModTime().Equal(s.cachedAt) && info.Size() == s.cachedSize {
+		defer s.mu.Unlock()
+		return s.cached, nil
+	}
+	s.mu.Unlock()
+
+	data, err := ws.ReadFile(Path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("reading tokens: %w", err)
+	}
+	s.mu.Lock()
+	s.cached, s.cachedAt, s.cachedSize = tokens, info.ModTime(), info.Size()
+	s.mu.Unlock()
+	return tokens, nil
+}
+
+func (s *Store) save(ws *workspace.Workspace, tokens []Token) error {
+	s.mu.Lock()
+	s.cachedAt = time.Time{}
+	s.mu.Unlock()
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ws.MkdirAll(path.Dir(Path), 0o755); err != nil {
+		return err
+	}
+	// Only the server needs to read it.
+	return ws.WriteFile(Path, data, 0o600)
+}
+
+// List returns the tokens, oldest first, expired ones included.
+func (s *Store) List(ws *workspace.Workspace) ([]Token, error) {
+	tokens, err := s.load(ws.Full())
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(tokens), nil
+}
+
+// Create adds a token with the given scopes, expiring at expires unless
+// that is zero, and returns it with its secret. The secret isn't kept and
+// can't be shown again.
+func (s *Store) Create(ws *workspace.Workspace, name string, scopes []Scope, expires, now time.Time) (Token, string, error) {
+	if len(scopes) == 0 {
+		return Token{}, "", ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return Token{}, "", fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	secret := prefix + hex.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(secret))
+	t := Token{
+		ID:      hex.EncodeToString(id),
+		Name:    name,
+		Scopes:  slices.Clone(scopes),
+		Hash:    sum[:],
+		Created: now.UTC(),
+	}
+	if !expires.IsZero() {
+		t.Expires = expires.UTC()
+	}
+
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	ws = ws.Full()
+	tokens, err := s.load(ws)
+	if err != nil {
+		return Token{}, "", err
+	}
+	if err := s.save(ws, append(slices.Clone(tokens), t)); err != nil {
+		return Token{}, "", err
+	}
+	return t, secret, nil
+}
+
+// Revoke deletes the token with id.
+func (s *Store) Revoke(ws *workspace.Workspace, id string) error {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	ws = ws.Full()
+	tokens, err := s.load(ws)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tokens, func(t Token) bool { return t.ID == id })
+	if i < 0 {
+		return ErrUnknownToken
+	}
+	if err := s.save(ws, slices.Delete(slices.Clone(tokens), i, i+1)); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.uses, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Enabled reports whether any token was created. Until one is, the API is
+// open as it always was.
+func (s *Store) Enabled(ws *workspace.Workspace) (bool, error) {
+	tokens, err := s.load(ws.Full())
+	return len(tokens) > 0, err
+}
+
+// Check returns the token whose secret is secret.
+func (s *Store) Check(ws *workspace.Workspace, secret string, now time.Time) (Token, error) {
+	tokens, err := s.load(ws.Full())
+	if err != nil {
+		return Token{}, err
+	}
+	sum := sha256.Sum256([]byte(secret))
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(sum[:], t.Hash) != 1 {
+			continue
+		}
+		if !t.Expires.IsZero() && now.After(t.Expires) {
+			return Token{}, ErrExpired
+		}
+		return t, nil
+	}
+	return Token{}, ErrUnknownToken
+}
+
+// Used records u as the last use of the token with id.
+func (s *Store) Used(id string, u Use) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uses[id] = u
+}
+
+// LastUse returns the last use of the token with id since the server
+// started.
+func (s *Store) LastUse(id string) (Use, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uses[id]
+	return u, ok
+}
+
+// Allows reports whether t may make request r, whose workspace is ws.
+func (t Token) Allows(r *http.Request, ws *workspace.Workspace) bool {
+	for _, scope := range t.Scopes {
+		if scope.allows(r, ws) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlyCreates reports whether t may make request r only as an upload that
+// mustn't replace a file: whether no scope but ScopeImport allows it. The
+// upload's path is in its body, so the upload handlers enforce that; see
+// CreateOnly.
+func (t Token) OnlyCreates(r *http.Request, ws *workspace.Workspace) bool {
+	for _, scope := range t.Scopes {
+		if scope != ScopeImport && scope.allows(r, ws) {
+			return false
+		}
+	}
+	return true
+}
+
+type createOnlyKey struct{}
+
+// WithCreateOnly marks a request made with a token that may only create
+// files.
+func WithCreateOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, createOnlyKey{}, true)
+}
+
+// CreateOnly reports whether ctx's request may only create files, not
+// replace them.
+func CreateOnly(ctx context.Context) bool {
+	only, _ := ctx.Value(createOnlyKey{}).(bool)
+	return only
+}
+
+// batchReads are POSTs that only read, taking their paths in the body.
+var batchReads = []string{"/api/fs:stat", "/api/sync/pull"}
+
+func (scope Scope) allows(r *http.Request, ws *workspace.Workspace) bool {
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	fsPath, isFS := strings.CutPrefix(r.URL.Path, "/api/fs/")
+	switch scope {
+	case ScopeRead:
+		return read || (r.Method == http.MethodPost && slices.Contains(batchReads, r.URL.Path))
+	case ScopeFSWrite:
+		return isFS
+	case ScopeImport:
+		if read {
+			return false
+		}
+		return r.URL.Path == "/api/uploads" || strings.HasPrefix(r.URL.Path, "/api/uploads/")
+	case ScopeCapture:
+		if !isFS || r.Method != http.MethodPut {
+			return false
+		}
+		_, err := ws.Stat(fsPath)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package tokens_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/tokens"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestStore(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := tokens.New()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if enabled, err := store.Enabled(ws); err != nil || enabled {
+		t.Fatalf("Enabled with no tokens = %v, %v", enabled, err)
+	}
+	if _, _, err := store.Create(ws, "phone", nil, time.Time{}, now); !errors.Is(err, tokens.ErrNoScopes) {
+		t.Errorf("no scopes: err = %v, want ErrNoScopes", err)
+	}
+	if _, _, err := store.Create(ws, "phone", []tokens.Scope{"admin"}, time.Time{}, now); !errors.Is(err, tokens.ErrUnknownScope) {
+		t.Errorf("unknown scope: err = %v, want ErrUnknownScope", err)
+	}
+
+	phone, secret, err := store.Create(ws, "phone", []tokens.Scope{tokens.ScopeCapture}, now.Add(time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, "wsd_") {
+		t.Errorf("secret = %q, want a wsd_ prefix", secret)
+	}
+	data, _ := ws.ReadFile(tokens.Path)
+	if strings.Contains(string(data), secret) {
+		t.Errorf("the secret is stored in the clear: %s", data)
+	}
+	if enabled, err := store.Enabled(ws); err != nil || !enabled {
+		t.Errorf("Enabled with a token = %v, %v", enabled, err)
+	}
+
+	if got, err := store.Check(ws, secret, now); err != nil || got.ID != phone.ID {
+		t.Errorf("Check = %+v, %v, want the phone's token", got, err)
+	}
+	if _, err := store.Check(ws, secret+"0", now); !errors.Is(err, tokens.ErrUnknownToken) {
+		t.Errorf("a wrong secret: err = %v, want ErrUnknownToken", err)
+	}
+	if _, err := store.Check(ws, secret, now.Add(2*time.Hour)); !errors.Is(err, tokens.ErrExpired) {
+		t.Errorf("after expiry: err = %v, want ErrExpired", err)
+	}
+
+	if err := store.Revoke(ws, phone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Check(ws, secret, now); !errors.Is(err, tokens.ErrUnknownToken) {
+		t.Errorf("after revoking: err = %v, want ErrUnknownToken", err)
+	}
+	if err := store.Revoke(ws, phone.ID); !errors.Is(err, tokens.ErrUnknownToken) {
+		t.Errorf("revoking twice: err = %v, want ErrUnknownToken", err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("inbox.md", []byte("# Inbox\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		scope  tokens.Scope
+		method string
+		target string
+		want   bool
+	}{
+		{tokens.ScopeRead, http.MethodGet, "/api/fs/inbox.md", true},
+		{tokens.ScopeRead, http.MethodPut, "/api/fs/inbox.md", false},
+		{tokens.ScopeRead, http.MethodPost, "/api/fs:stat", true},
+		{tokens.ScopeRead, http.MethodPost, "/api/sync/push", false},
+		{tokens.ScopeFSWrite, http.MethodDelete, "/api/fs/inbox.md", true},
+		{tokens.ScopeFSWrite, http.MethodPost, "/api/import", false},
+		{tokens.ScopeImport, http.MethodPost, "/api/import", false},
+		{tokens.ScopeImport, http.MethodPost, "/api/imports/calibre", false},
+		{tokens.ScopeImport, http.MethodPost, "/api/uploads", true},
+		{tokens.ScopeImport, http.MethodGet, "/api/imports/1", false},
+		{tokens.ScopeCapture, http.MethodPut, "/api/fs/new.md", true},
+		{tokens.ScopeCapture, http.MethodPut, "/api/fs/inbox.md", false},
+		{tokens.ScopeCapture, http.MethodGet, "/api/fs/new.md", false},
+	}
+	for _, tt := range tests {
+		token := tokens.Token{Scopes: []tokens.Scope{tt.scope}}
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := token.Allows(r, ws); got != tt.want {
+			t.Errorf("%s: %s %s = %v, want %v", tt.scope, tt.method, tt.target, got, tt.want)
+		}
+	}
+}
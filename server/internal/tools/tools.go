@@ -0,0 +1,210 @@
+// Package tools runs user-configured commands, such as pandoc or
+// imagemagick, on files from the workspace.
+//
+// The registry is a JSON file outside the workspace, named by WISDOM_TOOLS,
+// so that nobody who can only write to the workspace can make the server
+// run a command. Each run gets its own temporary directory holding a copy
+// of the input, and only the output file is brought back.
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 2 * time.Minute
+	// maxOutput caps the size of what a tool can write back.
+	maxOutput = 256 << 20
+)
+
+var (
+	ErrDisabled    = errors.New("tools are disabled")
+	ErrUnknownTool = errors.New("unknown tool")
+	ErrInvalidTool = errors.New("invalid tool")
+	ErrInputType   = errors.New("tool does not accept this file type")
+)
+
+// Tool is a command run on one file. In Command, {input} is replaced with
+// the input's path and {output} with the path the tool should write to.
+// The input is also given on stdin and, without {output}, what the tool
+// prints is the output.
+type Tool struct {
+	Command []string `json:"command"`
+	// Inputs are the extensions the tool accepts, such as ".md".
+	Inputs []string `json:"inputs"`
+	// Output is the extension of the output, such as ".docx".
+	Output string `json:"output"`
+	// Timeout is in seconds.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+func (t Tool) validate() error {
+	switch {
+	case len(t.Command) == 0:
+		return errors.New("command is required")
+	case len(t.Inputs) == 0:
+		return errors.New("inputs are required")
+	case !strings.HasPrefix(t.Output, ".") || strings.ContainsAny(t.Output, `/\`):
+		return errors.New("output must be an extension such as .pdf")
+	case t.Timeout < 0:
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// Accepts reports whether the tool takes the file at p.
+func (t Tool) Accepts(p string) bool {
+	ext := path.Ext(p)
+	return slices.ContainsFunc(t.Inputs, func(in string) bool { return strings.EqualFold(in, ext) })
+}
+
+// Registry is the file the tools are defined in, as a JSON object of tools
+// by name. It is read on every use, so edits apply without a restart.
+type Registry struct {
+	Path string
+}
+
+// FromEnv returns the registry at WISDOM_TOOLS, or nil if it isn't set.
+func FromEnv() *Registry {
+	p := os.Getenv("WISDOM_TOOLS")
+	if p == "" {
+		return nil
+	}
+	return &Registry{Path: p}
+}
+
+func (r *Registry) Load() (map[string]Tool, error) {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]Tool
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTool, err)
+	}
+	for name, t := range all {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidTool, name, err)
+		}
+	}
+	return all, nil
+}
+
+func (r *Registry) Get(name string) (Tool, error) {
+	all, err := r.Load()
+	if err != nil {
+		return Tool{}, err
+	}
+	t, ok := all[name]
+	if !ok {
+		return Tool{}, fmt.Errorf("%w %q", ErrUnknownTool, name)
+	}
+	return t, nil
+}
+
+// Run runs the tool on content, the file named name, and returns its
+// output. The command runs in a temporary directory with only PATH from
+// the environment, and is killed after its timeout.
+func (t Tool) Run(ctx context.Context, name string, content io.Reader) ([]byte, error) {
+	if !t.Accepts(name) {
+		return nil, ErrInputType
+	}
+	timeout := defaultTimeout
+	if t.Timeout > 0 {
+		timeout = time.Duration(t.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "wisdom-tool-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The input keeps its extension, which tools often go by.
+	input := filepath.Join(dir, "input"+strings.ToLower(path.Ext(name)))
+	output := filepath.Join(dir, "output"+t.Output)
+	f, err := os.Create(input)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usesOutput := false
+	args := make([]string, len(t.Command))
+	for i, arg := range t.Command {
+		usesOutput = usesOutput || strings.Contains(arg, "{output}")
+		args[i] = strings.NewReplacer("{input}", input, "{output}", output).Replace(arg)
+	}
+
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: 64 << 10}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir}
+	stdin, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer stdin.Close()
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("%s: %w: %s", filepath.Base(args[0]), err, strings.TrimSpace(stderr.String()))
+	}
+	if !usesOutput {
+		if stdout.dropped {
+			return nil, fmt.Errorf("%s output is larger than %d bytes", filepath.Base(args[0]), maxOutput)
+		}
+		return stdout.Bytes(), nil
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil, fmt.Errorf("%s wrote no output: %w", filepath.Base(args[0]), err)
+	}
+	if info.Size() > maxOutput {
+		return nil, fmt.Errorf("%s output is larger than %d bytes", filepath.Base(args[0]), maxOutput)
+	}
+	return os.ReadFile(output)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the
+// rest, so a runaway tool can't exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit   int
+	dropped bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - b.Len()
+	if len(p) > room {
+		b.dropped = true
+	}
+	b.Buffer.Write(p[:max(0, min(len(p), room))])
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/tools"
+)
+
+func TestRegistry(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "tools.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &tools.Registry{Path: p}
+
+	write(`{"upper": {"command": ["tr", "a-z", "A-Z"], "inputs": [".md"], "output": ".txt"}}`)
+	tool, err := r.Get("upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tool.Accepts("notes/a.MD") || tool.Accepts("a.pdf") {
+		t.Error("Accepts matched the wrong extensions")
+	}
+	if _, err := r.Get("missing"); !errors.Is(err, tools.ErrUnknownTool) {
+		t.Errorf("err = %v, want ErrUnknownTool", err)
+	}
+
+	write(`{"bad": {"command": ["true"], "inputs": [".md"], "output": "txt"}}`)
+	if _, err := r.Load(); !errors.Is(err, tools.ErrInvalidTool) {
+		t.Errorf("err = %v, want ErrInvalidTool", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	stdout := tools.Tool{Command: []string{"tr", "a-z", "A-Z"}, Inputs: []string{".md"}, Output: ".txt"}
+	out, err := stdout.Run(ctx, "a.md", strings.NewReader("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "HELLO\n" {
+		t.Errorf("stdout output = %q", out)
+	}
+
+	file := tools.Tool{Command: []string{"sh", "-c", `cp "$0" "$1" && pwd >> "$1"`, "{input}", "{output}"}, Inputs: []string{".md"}, Output: ".txt"}
+	out, err = file.Run(ctx, "a.md", strings.NewReader("copied\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); len(lines) != 2 || lines[0] != "copied" || !strings.Contains(lines[1], "wisdom-tool-") {
+		t.Errorf("file output = %q, want the input and the temporary directory", out)
+	}
+
+	if _, err := stdout.Run(ctx, "a.pdf", strings.NewReader("")); !errors.Is(err, tools.ErrInputType) {
+		t.Errorf("err = %v, want ErrInputType", err)
+	}
+
+	failing := tools.Tool{Command: []string{"sh", "-c", "echo broken >&2; exit 3"}, Inputs: []string{".md"}, Output: ".txt"}
+	if _, err := failing.Run(ctx, "a.md", strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("err = %v, want the tool's stderr", err)
+	}
+
+	slow := tools.Tool{Command: []string{"sleep", "5"}, Inputs: []string{".md"}, Output: ".txt", Timeout: 1}
+	if _, err := slow.Run(ctx, "a.md", strings.NewReader("")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
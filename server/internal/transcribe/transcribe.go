@@ -0,0 +1,167 @@
+// Package transcribe turns audio and video files into timestamped
+// transcript notes using a speech-to-text server.
+//
+// Any server that speaks the OpenAI transcription API works, which
+// includes a local whisper.cpp server as well as hosted APIs.
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/frontmatter"
+)
+
+var mediaExts = []string{".mp3", ".m4a", ".wav", ".ogg", ".oga", ".opus", ".flac", ".aac", ".webm", ".mp4", ".m4v", ".mov", ".mkv"}
+
+// IsMedia reports whether p is an audio or video file worth transcribing.
+func IsMedia(p string) bool {
+	return slices.Contains(mediaExts, strings.ToLower(path.Ext(p)))
+}
+
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+type Backend interface {
+	Transcribe(ctx context.Context, name string, media io.Reader) ([]Segment, error)
+}
+
+// Server is a speech-to-text server with an OpenAI-compatible
+// transcription endpoint, such as whisper.cpp's /inference or OpenAI's
+// /v1/audio/transcriptions.
+type Server struct {
+	Client *http.Client
+	URL    string
+	APIKey string
+	// Model is sent along when set. whisper.cpp ignores it.
+	Model string
+}
+
+// FromEnv returns the server at WISDOM_TRANSCRIBE_URL, authenticated with
+// WISDOM_TRANSCRIBE_API_KEY and using WISDOM_TRANSCRIBE_MODEL if they are
+// set. Transcription is opt-in, so it returns nil without a URL.
+func FromEnv() Backend {
+	u := os.Getenv("WISDOM_TRANSCRIBE_URL")
+	if u == "" {
+		return nil
+	}
+	// Long recordings take a while; the job's context bounds the request.
+	return &Server{
+		Client: &http.Client{},
+		URL:    u,
+		APIKey: os.Getenv("WISDOM_TRANSCRIBE_API_KEY"),
+		Model:  os.Getenv("WISDOM_TRANSCRIBE_MODEL"),
+	}
+}
+
+// Transcribe streams media to the server as a multipart upload, so large
+// recordings aren't held in memory.
+func (s *Server) Transcribe(ctx context.Context, name string, media io.Reader) ([]Segment, error) {
+	body, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			fields := map[string]string{"response_format": "verbose_json", "timestamp_granularities[]": "segment"}
+			if s.Model != "" {
+				fields["model"] = s.Model
+			}
+			for k, v := range fields {
+				if err := form.WriteField(k, v); err != nil {
+					return err
+				}
+			}
+			part, err := form.CreateFormFile("file", name)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, media); err != nil {
+				return err
+			}
+			return form.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("transcription server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Text     string `json:"text"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		} `json:"segments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding transcription: %w", err)
+	}
+	seconds := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+	segments := []Segment{}
+	for _, seg := range result.Segments {
+		if text := strings.TrimSpace(seg.Text); text != "" {
+			segments = append(segments, Segment{Start: seconds(seg.Start), End: seconds(seg.End), Text: text})
+		}
+	}
+	// Servers that don't split into segments still return the text.
+	if len(segments) == 0 && strings.TrimSpace(result.Text) != "" {
+		segments = append(segments, Segment{Text: strings.TrimSpace(result.Text)})
+	}
+	return segments, nil
+}
+
+// NotePath is where the transcript of the media at p goes: next to it, so
+// the link between them is short.
+func NotePath(p string) string {
+	return strings.TrimSuffix(p, path.Ext(p)) + ".transcript.md"
+}
+
+// Note renders segments as a markdown note that links back to the media at
+// mediaPath, with each line prefixed by its [hh:mm:ss] start time.
+func Note(mediaPath string, segments []Segment) string {
+	name := path.Base(mediaPath)
+	doc := &frontmatter.Document{}
+	doc.Set("title", "Transcript of "+name)
+	doc.Set("source", mediaPath)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Transcript of [%s](%s).\n\n", name, (&url.URL{Path: name}).EscapedPath())
+	for _, seg := range segments {
+		fmt.Fprintf(&sb, "[%s] %s\n", timestamp(seg.Start), seg.Text)
+	}
+	doc.Body = sb.String()
+	return doc.Render()
+}
+
+func timestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,65 @@
+package transcribe_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/transcribe"
+)
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "talk.mp3" || string(data) != "audio" || r.FormValue("model") != "whisper-1" || r.FormValue("response_format") != "verbose_json" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text":"Hello there. General.","segments":[{"start":0,"end":1.5,"text":" Hello there."},{"start":3725.2,"end":3727,"text":" General."}]}`))
+	}))
+	defer srv.Close()
+
+	s := &transcribe.Server{Client: srv.Client(), URL: srv.URL, APIKey: "secret", Model: "whisper-1"}
+	segments, err := s.Transcribe(context.Background(), "talk.mp3", strings.NewReader("audio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []transcribe.Segment{
+		{Start: 0, End: 1500 * time.Millisecond, Text: "Hello there."},
+		{Start: 3725200 * time.Millisecond, End: 3727 * time.Second, Text: "General."},
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Fatalf("segments = %+v", segments)
+	}
+
+	got := transcribe.Note("audio/my talk.mp3", segments)
+	wantNote := "---\ntitle: \"Transcript of my talk.mp3\"\nsource: \"audio/my talk.mp3\"\n---\n" +
+		"Transcript of [my talk.mp3](my%20talk.mp3).\n\n[00:00:00] Hello there.\n[01:02:05] General.\n"
+	if got != wantNote {
+		t.Errorf("Note =\n%s\nwant\n%s", got, wantNote)
+	}
+	if p := transcribe.NotePath("audio/my talk.mp3"); p != "audio/my talk.transcript.md" {
+		t.Errorf("NotePath = %q", p)
+	}
+
+	t.Run("server errors", func(t *testing.T) {
+		s := &transcribe.Server{Client: srv.Client(), URL: srv.URL}
+		if _, err := s.Transcribe(context.Background(), "talk.mp3", strings.NewReader("audio")); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("err = %v, want a 401 error", err)
+		}
+	})
+}
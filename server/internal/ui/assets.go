@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// manifestName is the file in dist that maps the build's outputs, such as
+// app.js, to their names with a content hash, such as main-5GXK2B3C.js.
+const manifestName = "manifest.json"
+
+// Assets lists the files the UI folder serves and the build's manifest,
+// so requests are routed without looking at the disk. It is refreshed
+// after each build and whenever the UI folder changes.
+type Assets struct {
+	dir string
+
+	mu       sync.RWMutex
+	files    map[string]bool
+	folders  map[string]bool
+	manifest map[string]string
+	hashed   map[string]bool
+}
+
+// NewAssets lists the files in the UI folder dir.
+func NewAssets(dir string) (*Assets, error) {
+	a := &Assets{dir: dir}
+	if err := a.Refresh(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Refresh lists the UI folder again. node_modules is left out: the UI is
+// bundled, so its dependencies are never fetched on their own.
+func (a *Assets) Refresh() error {
+	files := map[string]bool{}
+	folders := map[string]bool{}
+	err := filepath.WalkDir(a.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(a.dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			if !strings.Contains(rel, "/") {
+				folders[rel] = true
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	manifest := readManifest(a.dir)
+	hashed := map[string]bool{}
+	for _, name := range manifest {
+		hashed["dist/"+name] = true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.files, a.folders, a.manifest, a.hashed = files, folders, manifest, hashed
+	return nil
+}
+
+// lookup reports whether p, relative to the UI folder, is a file and
+// whether it is a hashed build output.
+func (a *Assets) lookup(p string) (file, hashed bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.files[p], a.hashed[p]
+}
+
+// inFolder reports whether p is in one of the UI folder's folders, such as
+// dist or src, rather than a route of the UI.
+func (a *Assets) inFolder(p string) bool {
+	top, _, _ := strings.Cut(p, "/")
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.folders[top]
+}
+
+func (a *Assets) hashedNames() map[string]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.manifest
+}
+
+// readManifest returns the build's manifest, or nil before the first build.
+func readManifest(uiDir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(uiDir, "dist", manifestName))
+	if err != nil {
+		return nil
+	}
+	var manifest map[string]string
+	if json.Unmarshal(data, &manifest) != nil {
+		return nil
+	}
+	return manifest
+}
@@ -1,18 +1,28 @@
 package ui
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 
 	esbuild "github.com/evanw/esbuild/pkg/api"
 )
 
+// entryPoint is the UI's source entry point, which index.html loads as
+// dist/app.js.
+const entryPoint = "src/main.tsx"
+
 type Builder struct {
 	ctx esbuild.BuildContext
 }
 
-func StartWatching(uiDir string) (*Builder, error) {
+// StartWatching builds the UI in uiDir and again whenever its sources
+// change, calling onBuild after each build that succeeds. With compress, the
+// outputs of each build are also precompressed; development leaves it off,
+// as compressing at the best levels slows down the rebuild after each save.
+func StartWatching(uiDir string, compress bool, onBuild func() error) (*Builder, error) {
 	if !filepath.IsAbs(uiDir) {
 		abs, err := filepath.Abs(uiDir)
 		if err != nil {
@@ -28,9 +38,11 @@ func StartWatching(uiDir string) (*Builder, error) {
 
 	ctx, err := esbuild.Context(esbuild.BuildOptions{
 		AbsWorkingDir: uiDir,
-		EntryPoints:   []string{"src/main.tsx"},
+		EntryPoints:   []string{entryPoint},
 		Bundle:        true,
-		Outfile:       "dist/app.js",
+		Outdir:        "dist",
+		EntryNames:    "[name]-[hash]",
+		Metafile:      true,
 		Format:        esbuild.FormatESModule,
 		Platform:      esbuild.PlatformBrowser,
 		JSX:           esbuild.JSXTransform,
@@ -40,6 +52,20 @@ func StartWatching(uiDir string) (*Builder, error) {
 			".ts":  esbuild.LoaderTS,
 			".tsx": esbuild.LoaderTSX,
 		},
+		Plugins: []esbuild.Plugin{{
+			Name: "manifest",
+			Setup: func(build esbuild.PluginBuild) {
+				build.OnEnd(func(result *esbuild.BuildResult) (esbuild.OnEndResult, error) {
+					if len(result.Errors) > 0 {
+						return esbuild.OnEndResult{}, nil
+					}
+					if err := writeManifest(distDir, result.Metafile, compress); err != nil {
+						return esbuild.OnEndResult{}, err
+					}
+					return esbuild.OnEndResult{}, onBuild()
+				})
+			},
+		}},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create esbuild context: %w", err)
@@ -62,3 +88,59 @@ func StartWatching(uiDir string) (*Builder, error) {
 func (b *Builder) Close() {
 	b.ctx.Dispose()
 }
+
+// writeManifest records the hashed names of a build's outputs, from its
+// metafile, in the manifest, writes their compressed variants if asked and
+// removes the outputs of earlier builds, variants included.
+func writeManifest(distDir, metafile string, compress bool) error {
+	var meta struct {
+		Outputs map[string]struct {
+			EntryPoint string `json:"entryPoint"`
+			CSSBundle  string `json:"cssBundle"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("read metafile: %w", err)
+	}
+	manifest := map[string]string{}
+	var outputs []string
+	for out, o := range meta.Outputs {
+		outputs = append(outputs, path.Base(out))
+		if o.EntryPoint != entryPoint {
+			continue
+		}
+		manifest["app"+path.Ext(out)] = path.Base(out)
+		if o.CSSBundle != "" {
+			manifest["app.css"] = path.Base(o.CSSBundle)
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(distDir, manifestName), data, 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	var variants []string
+	if compress {
+		if variants, err = precompress(distDir, outputs); err != nil {
+			return fmt.Errorf("compress ui: %w", err)
+		}
+	}
+	current := map[string]bool{manifestName: true}
+	for _, name := range append(outputs, variants...) {
+		current[name] = true
+	}
+
+	entries, err := os.ReadDir(distDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && !current[e.Name()] {
+			os.Remove(filepath.Join(distDir, e.Name()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWriteManifestCompression(t *testing.T) {
+	dir := t.TempDir()
+	app := "app-ABC.js"
+	if err := os.WriteFile(filepath.Join(dir, app), []byte(strings.Repeat("const a = 1;\n", 200)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	metafile := `{"outputs": {"dist/` + app + `": {"entryPoint": "` + entryPoint + `"}}}`
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	if err := writeManifest(dir, metafile, true); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(files(), app+".gz") {
+		t.Fatalf("files = %v, want a gzip variant", files())
+	}
+
+	// Without compression, as in development, variants of earlier builds
+	// are removed rather than served stale.
+	if err := writeManifest(dir, metafile, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := files(); !slices.Equal(got, []string{app, manifestName}) {
+		t.Fatalf("files = %v, want only the output and the manifest", got)
+	}
+}
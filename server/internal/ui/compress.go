@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// precompressMin is the smallest build output worth compressing.
+const precompressMin = 1024
+
+// encodings are the precompressed variants the file server can send, in
+// order of preference, by the suffix of their files.
+var encodings = []struct{ name, suffix string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompress writes a gzip variant of each of the files named in dir and,
+// with the brotli command installed, a brotli one, for the file server to
+// send to clients that accept them. It returns the variants' names. Go has
+// no brotli encoder, so brotli is left out without the command.
+func precompress(dir string, names []string) ([]string, error) {
+	brotli, _ := exec.LookPath("brotli")
+	var variants []string
+	for _, name := range names {
+		src := filepath.Join(dir, name)
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() < precompressMin {
+			continue
+		}
+		if err := gzipFile(src, src+".gz"); err != nil {
+			return nil, err
+		}
+		variants = append(variants, name+".gz")
+		if brotli != "" {
+			if out, err := exec.Command(brotli, "--best", "--force", "--output="+src+".br", src).CombinedOutput(); err != nil {
+				return nil, fmt.Errorf("brotli %s: %w: %s", name, err, out)
+			}
+			variants = append(variants, name+".br")
+		}
+	}
+	return variants, nil
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw, _ := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
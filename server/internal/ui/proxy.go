@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// Proxy sends requests for the UI to the frontend dev server at target,
+// such as Vite's at http://localhost:5173, so the UI can be worked on with
+// that toolchain while the API, and the middleware in front of both, stay
+// the server's. The base path is put back on the paths it forwards, for a
+// dev server configured with the same base. WebSocket upgrades, for hot
+// reloading, pass through.
+func Proxy(target, basePath string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid dev server URL %q", target)
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Path = basePath + pr.In.URL.Path
+			if pr.In.URL.RawPath != "" {
+				pr.Out.URL.RawPath = basePath + pr.In.URL.RawPath
+			}
+			pr.SetURL(u)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "ui dev server unreachable: "+err.Error(), http.StatusBadGateway)
+		},
+	}, nil
+}
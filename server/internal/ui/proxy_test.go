@@ -0,0 +1,37 @@
+package ui_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/ui"
+)
+
+func TestProxy(t *testing.T) {
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "vite "+r.URL.Path)
+	}))
+	t.Cleanup(dev.Close)
+
+	proxy, err := ui.Proxy(dev.URL, "/wisdom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL + "/src/main.tsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "vite /wisdom/src/main.tsx" {
+		t.Errorf("proxied = %q, want the dev server's answer for the path under the base path", body)
+	}
+
+	if _, err := ui.Proxy("localhost:5173", ""); err == nil {
+		t.Error("a URL without a scheme was accepted")
+	}
+}
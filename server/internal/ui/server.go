@@ -3,36 +3,189 @@
 package ui
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-func FileServer(uiDir string) http.Handler {
-	indexPath := filepath.Join(uiDir, "index.html")
+// baseElement is the <base> in index.html, which the UI's asset URLs and
+// routes are relative to.
+const baseElement = `<base href="/" />`
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cleanPath := path.Clean(r.URL.Path)
-		if cleanPath == "/" {
-			http.ServeFile(w, r, indexPath)
+// immutable caches a file for a year without asking again, for build
+// outputs, whose names change with their content.
+const immutable = "public, max-age=31536000, immutable"
+
+// FileServer serves the UI folder listed by assets. A path that isn't a
+// file is a route of the UI, which gets index.html, if a browser is
+// navigating to it; a missing file in one of the folder's own folders, or
+// any request that isn't a page load, is not found. With basePath set,
+// index.html's <base> is rewritten to it.
+//
+// index.html is sent with its references to dist/ replaced by the hashed
+// names in the build's manifest and is always revalidated, so a new build
+// is picked up on the next load; the hashed files are cached for good.
+// Everything else, such as the stylesheets in src, is revalidated with an
+// ETag of its content.
+func FileServer(assets *Assets, basePath string) http.Handler {
+	indexPath := filepath.Join(assets.dir, "index.html")
+	etags := &etagCache{entries: map[string]etagEntry{}}
+	serveIndex := func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(indexPath)
+		if err != nil {
+			http.NotFound(w, r)
 			return
 		}
+		if basePath != "" {
+			data = bytes.Replace(data, []byte(baseElement), []byte(`<base href="`+basePath+`/" />`), 1)
+		}
+		for name, hashed := range assets.hashedNames() {
+			data = bytes.ReplaceAll(data, []byte(`"dist/`+name+`"`), []byte(`"dist/`+hashed+`"`))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag(data))
+		http.ServeContent(w, r, "index.html", time.Time{}, bytes.NewReader(data))
+	}
 
-		relPath := strings.TrimPrefix(cleanPath, "/")
-		if strings.HasPrefix(relPath, "..") {
-			http.ServeFile(w, r, indexPath)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relPath := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if relPath == "" || relPath == "index.html" {
+			serveIndex(w, r)
 			return
 		}
 
-		fullPath := filepath.Join(uiDir, filepath.FromSlash(relPath))
-		info, err := os.Stat(fullPath)
-		if err == nil && !info.IsDir() {
-			http.ServeFile(w, r, fullPath)
+		file, hashed := assets.lookup(relPath)
+		if !file {
+			if assets.inFolder(relPath) || !navigation(r) {
+				http.NotFound(w, r)
+				return
+			}
+			serveIndex(w, r)
 			return
 		}
 
-		http.ServeFile(w, r, indexPath)
+		// A precompressed variant is sent as is, as the file in the
+		// encoding the client prefers.
+		variant, encoding, varies := relPath, "", false
+		for _, e := range encodings {
+			if ok, _ := assets.lookup(relPath + e.suffix); ok {
+				varies = true
+				if encoding == "" && accepts(r, e.name) {
+					variant, encoding = relPath+e.suffix, e.name
+				}
+			}
+		}
+
+		fullPath := filepath.Join(assets.dir, filepath.FromSlash(variant))
+		f, err := os.Open(fullPath)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		if hashed {
+			w.Header().Set("Cache-Control", immutable)
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		if varies {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			w.Header().Set("Content-Type", cmp.Or(mime.TypeByExtension(path.Ext(relPath)), "application/octet-stream"))
+		}
+		if tag, err := etags.get(fullPath, info); err == nil {
+			w.Header().Set("ETag", tag)
+		}
+		http.ServeContent(w, r, path.Base(relPath), info.ModTime(), f)
 	})
 }
+
+// navigation reports whether r is a browser loading a page, rather than
+// fetching a script, stylesheet or image.
+func navigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// accepts reports whether r's Accept-Encoding names enc without q=0.
+func accepts(r *http.Request, enc string) bool {
+	for part := range strings.SplitSeq(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), enc) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	tag     string
+}
+
+// etagCache keeps the ETags of files, so a file is only hashed again once
+// it changes.
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+func (c *etagCache) get(p string, info fs.FileInfo) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[p]
+	c.mu.Unlock()
+	if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.tag, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	tag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	c.mu.Lock()
+	c.entries[p] = etagEntry{modTime: info.ModTime(), size: info.Size(), tag: tag}
+	c.mu.Unlock()
+	return tag, nil
+}
@@ -0,0 +1,128 @@
+package ui_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/ui"
+)
+
+func TestFileServer(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.html":            `<head><base href="/" /><link href="src/theme.css"></head><script src="dist/app.js"></script>`,
+		"src/theme.css":         "body {}",
+		"dist/main-AB12CD34.js": "console.log(1)",
+		"dist/manifest.json":    `{"app.js": "main-AB12CD34.js"}`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assets, err := ui.NewAssets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(ui.FileServer(assets, "/wisdom"))
+	t.Cleanup(srv.Close)
+
+	get := func(p, etag string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+p, nil)
+		req.Header.Set("Accept", "text/html")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("/ws/notes/", "")
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `<base href="/wisdom/" />`) || !strings.Contains(string(body), `src="dist/main-AB12CD34.js"`) {
+		t.Errorf("index = %s", body)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("index Cache-Control = %q, want no-cache", cc)
+	}
+	if again := get("/", resp.Header.Get("ETag")); again.StatusCode != http.StatusNotModified {
+		t.Errorf("index with its ETag = %d, want 304", again.StatusCode)
+	}
+
+	if cc := get("/dist/main-AB12CD34.js", "").Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("hashed asset Cache-Control = %q, want immutable", cc)
+	}
+	css := get("/src/theme.css", "")
+	if cc := css.Header.Get("Cache-Control"); cc != "no-cache" || css.Header.Get("ETag") == "" {
+		t.Errorf("stylesheet Cache-Control = %q, ETag %q, want no-cache with an ETag", cc, css.Header.Get("ETag"))
+	}
+	if again := get("/src/theme.css", css.Header.Get("ETag")); again.StatusCode != http.StatusNotModified {
+		t.Errorf("stylesheet with its ETag = %d, want 304", again.StatusCode)
+	}
+
+	for _, p := range []string{"/dist/main-OLD.js", "/src/missing.css"} {
+		if resp := get(p, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", p, resp.StatusCode)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws/cover.png", nil)
+	req.Header.Set("Sec-Fetch-Mode", "no-cors")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("an image fetched from a route = %d, want 404", resp.StatusCode)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("console.log(1)"))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "dist", "main-AB12CD34.js.gz"), gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "extra.css"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := assets.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if resp := get("/src/extra.css", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("a file added since = %d, want 200 after a refresh", resp.StatusCode)
+	}
+
+	for accept, want := range map[string]string{"br, gzip": "gzip", "gzip;q=0, br": "", "": ""} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/dist/main-AB12CD34.js", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Encoding"); got != want || resp.Header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Content-Encoding %q, Vary %q, want %q", accept, got, resp.Header.Get("Vary"), want)
+		}
+		if want == "gzip" && !bytes.Equal(body, gz.Bytes()) {
+			t.Errorf("Accept-Encoding %q: sent %q, want the gzip variant", accept, body)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+			t.Errorf("Accept-Encoding %q: Content-Type %q", accept, ct)
+		}
+	}
+}
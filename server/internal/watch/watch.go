@@ -0,0 +1,239 @@
+// Package watch notices files changed in the workspace, including outside
+// the API by an external editor or a sync tool, so the server can catch up.
+//
+// It polls: every file's size and modification time are compared with the
+// previous poll. That needs no platform-specific notification API, works on
+// network filesystems, and costs a stat per file per poll.
+package watch
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shrik450/wisdom/internal/renames"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+// IgnorePath lists globs of files not to report, one per line, matched
+// against the workspace path and against the file name. Lines starting
+// with # are comments.
+const IgnorePath = ".wisdom/watchignore"
+
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// Event is a change to a file. A file renamed outside the server is
+// reported as its deletion, with To set, and its creation, with From set.
+type Event struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type stamp struct {
+	size    int64
+	modTime time.Time
+	id      uint64
+}
+
+// Watcher polls the workspace and tells its subscribers what changed.
+// Changes are reported once a file has stayed the same for a whole poll,
+// so an editor writing in bursts, or a sync copying a tree, makes one
+// report rather than many.
+type Watcher struct {
+	ws     *workspace.Workspace
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	subs    map[int]func(*workspace.Workspace, []Event)
+	nextSub int
+
+	// Only touched by Poll.
+	pollMu  sync.Mutex
+	seen    map[string]stamp
+	pending map[string]string
+	// vanished holds the last stamp of pending deletions, and appeared the
+	// pending puts of new files, to pair them up as renames.
+	vanished map[string]stamp
+	appeared map[string]bool
+}
+
+func New(ws *workspace.Workspace, logger *slog.Logger) *Watcher {
+	return &Watcher{ws: ws, logger: logger, subs: map[int]func(*workspace.Workspace, []Event){}}
+}
+
+// Subscribe calls fn with every batch of changes until cancel is called.
+// fn is called from the polling goroutine and should not block for long.
+func (w *Watcher) Subscribe(fn func(ws *workspace.Workspace, events []Event)) (cancel func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextSub
+	w.nextSub++
+	w.subs[id] = fn
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// Run polls every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.Poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll compares the workspace with the previous poll and reports the
+// changes that have settled. The first poll only records the workspace.
+func (w *Watcher) Poll() []Event {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	entries, err := w.ws.WalkFiles()
+	if err != nil {
+		w.logger.Error("watch workspace", "err", err)
+		return nil
+	}
+	current := make(map[string]stamp, len(entries))
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		if info, err := w.ws.Stat(e.Path); err == nil && info.Mode().IsRegular() {
+			f := renames.FromInfo(e.Path, info)
+			current[e.Path] = stamp{size: f.Size, modTime: f.ModTime, id: f.ID}
+		}
+	}
+	if w.seen == nil {
+		w.seen, w.pending = current, map[string]string{}
+		w.vanished, w.appeared = map[string]stamp{}, map[string]bool{}
+		return nil
+	}
+
+	changed := map[string]string{}
+	for p, s := range current {
+		old, ok := w.seen[p]
+		if ok && old == s {
+			continue
+		}
+		changed[p] = OpPut
+		if _, wasDeleted := w.vanished[p]; !ok && !wasDeleted {
+			w.appeared[p] = true
+		}
+		delete(w.vanished, p)
+	}
+	for p, s := range w.seen {
+		if _, ok := current[p]; !ok {
+			changed[p] = OpDelete
+			if !w.appeared[p] {
+				w.vanished[p] = s
+			}
+			delete(w.appeared, p)
+		}
+	}
+	w.seen = current
+
+	ignore := w.loadIgnore()
+	var events []Event
+	for p, op := range w.pending {
+		if _, again := changed[p]; again {
+			continue
+		}
+		delete(w.pending, p)
+		if ignored(ignore, p) {
+			delete(w.vanished, p)
+			delete(w.appeared, p)
+			continue
+		}
+		events = append(events, Event{Op: op, Path: p})
+	}
+	for p, op := range changed {
+		w.pending[p] = op
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	w.pairRenames(events)
+	slices.SortFunc(events, func(a, b Event) int { return strings.Compare(a.Path, b.Path) })
+
+	w.mu.Lock()
+	subs := make([]func(*workspace.Workspace, []Event), 0, len(w.subs))
+	for _, fn := range w.subs {
+		subs = append(subs, fn)
+	}
+	w.mu.Unlock()
+	for _, fn := range subs {
+		fn(w.ws, events)
+	}
+	return events
+}
+
+func (w *Watcher) loadIgnore() []string {
+	data, err := w.ws.ReadFile(IgnorePath)
+	if err != nil {
+		return nil
+	}
+	var globs []string
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			globs = append(globs, line)
+		}
+	}
+	return globs
+}
+
+func ignored(globs []string, p string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(g, path.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pairRenames sets From and To on the events that are a file renamed
+// outside the server, and forgets the stamps kept for pairing the
+// reported events.
+func (w *Watcher) pairRenames(events []Event) {
+	var gone, appeared []renames.File
+	for _, e := range events {
+		if s, ok := w.vanished[e.Path]; ok && e.Op == OpDelete {
+			gone = append(gone, renames.File{Path: e.Path, Size: s.size, ModTime: s.modTime, ID: s.id})
+		}
+		if s := w.seen[e.Path]; w.appeared[e.Path] && e.Op == OpPut {
+			appeared = append(appeared, renames.File{Path: e.Path, Size: s.size, ModTime: s.modTime, ID: s.id})
+		}
+		delete(w.vanished, e.Path)
+		delete(w.appeared, e.Path)
+	}
+	moves := renames.Match(gone, appeared)
+	if len(moves) == 0 {
+		return
+	}
+	to := make(map[string]string, len(moves))
+	for newPath, oldPath := range moves {
+		to[oldPath] = newPath
+	}
+	for i, e := range events {
+		events[i].From, events[i].To = moves[e.Path], to[e.Path]
+	}
+}
@@ -0,0 +1,107 @@
+package watch_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/watch"
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestPoll(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(p, content string) {
+		t.Helper()
+		if err := ws.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "one")
+	if err := ws.MkdirAll(".wisdom", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write(watch.IgnorePath, "# editor swap files\n*.swp\n")
+
+	w := watch.New(ws, slog.Default())
+	var notified [][]watch.Event
+	w.Subscribe(func(_ *workspace.Workspace, events []watch.Event) {
+		notified = append(notified, events)
+	})
+	if events := w.Poll(); events != nil {
+		t.Fatalf("first poll = %v, want nothing", events)
+	}
+
+	write("a.md", "one, two")
+	write("b.md", "new")
+	write(".a.md.swp", "swap")
+	if events := w.Poll(); events != nil {
+		t.Fatalf("poll right after writing = %v, want nothing until the files settle", events)
+	}
+	// A file still being written is held back until it settles.
+	write("b.md", "newer")
+	events := w.Poll()
+	if len(events) != 1 || events[0] != (watch.Event{Op: watch.OpPut, Path: "a.md"}) {
+		t.Fatalf("events = %v, want put a.md", events)
+	}
+	events = w.Poll()
+	if len(events) != 1 || events[0] != (watch.Event{Op: watch.OpPut, Path: "b.md"}) {
+		t.Fatalf("events = %v, want put b.md", events)
+	}
+
+	if err := ws.Remove("a.md"); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	events = w.Poll()
+	if len(events) != 1 || events[0] != (watch.Event{Op: watch.OpDelete, Path: "a.md"}) {
+		t.Fatalf("events = %v, want delete a.md", events)
+	}
+	if len(notified) != 3 {
+		t.Errorf("subscriber got %d batches, want 3", len(notified))
+	}
+}
+
+func TestPollRename(t *testing.T) {
+	root := t.TempDir()
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"plan.md", "notes.md"} {
+		if err := ws.WriteFile(p, []byte("content of "+p), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := watch.New(ws, slog.Default())
+	w.Poll()
+
+	if err := ws.MkdirAll("projects", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "plan.md"), filepath.Join(root, "projects", "plan.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Remove("notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("other.md", []byte("unrelated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	events := w.Poll()
+	want := []watch.Event{
+		{Op: watch.OpDelete, Path: "notes.md"},
+		{Op: watch.OpPut, Path: "other.md"},
+		{Op: watch.OpDelete, Path: "plan.md", To: "projects/plan.md"},
+		{Op: watch.OpPut, Path: "projects/plan.md", From: "plan.md"},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
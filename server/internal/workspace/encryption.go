@@ -0,0 +1,424 @@
+package workspace
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted files are a header followed by a sequence of AES-GCM sealed
+// chunks. Each chunk's nonce is the per-file random prefix, the chunk index
+// and a flag marking the final chunk, so chunks can't be reordered, dropped
+// or truncated without failing authentication. Chunking keeps memory
+// constant and lets readers seek without decrypting the whole file.
+const (
+	encMagic     = "WSDMENC1"
+	encPrefixLen = 7
+	encHeaderLen = len(encMagic) + encPrefixLen
+	encChunkSize = 64 * 1024
+	encTagSize   = 16
+	encKeySize   = 32
+)
+
+const (
+	encryptionKeyEnvVar     = "WISDOM_ENCRYPTION_KEY"
+	encryptionKeyFileEnvVar = "WISDOM_ENCRYPTION_KEY_FILE"
+)
+
+var ErrDecrypt = errors.New("decrypting file: data is corrupt or the key is wrong")
+
+// Files under these top-level directories are always stored in plaintext,
+// since the UI is read straight from disk by esbuild.
+var plaintextDirs = []string{"ui"}
+
+// ParseKey decodes a hex encoded 256-bit key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("decoding encryption key: %w", err)
+	}
+	if len(key) != encKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", encKeySize, len(key))
+	}
+	return key, nil
+}
+
+// keyFromEnv returns the key configured via the environment, or nil if
+// encryption is not enabled.
+func keyFromEnv() ([]byte, error) {
+	if s := os.Getenv(encryptionKeyEnvVar); s != "" {
+		return ParseKey(s)
+	}
+	if p := os.Getenv(encryptionKeyFileEnvVar); p != "" {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading encryption key file: %w", err)
+		}
+		return ParseKey(string(data))
+	}
+	return nil, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, idx uint32, last bool) []byte {
+	nonce := make([]byte, 0, encPrefixLen+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, idx)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// plaintextSize returns the decrypted size of an encrypted file of the given
+// size on disk.
+func plaintextSize(size int64) (int64, error) {
+	body := size - int64(encHeaderLen)
+	if body < encTagSize {
+		return 0, ErrDecrypt
+	}
+	chunks := (body + encChunkSize + encTagSize - 1) / (encChunkSize + encTagSize)
+	if rem := body - (chunks-1)*(encChunkSize+encTagSize); rem < encTagSize {
+		return 0, ErrDecrypt
+	}
+	return body - chunks*encTagSize, nil
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	idx    uint32
+	buf    []byte
+	out    []byte
+}
+
+func newEncryptWriter(w io.Writer, aead cipher.AEAD) (*encryptWriter, error) {
+	prefix := make([]byte, encPrefixLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, encChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, so that the
+		// final chunk is always sealed by Close with the last flag set.
+		if len(e.buf) == encChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(e.buf[len(e.buf):encChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	if e.idx == math.MaxUint32 {
+		return errors.New("file too large to encrypt")
+	}
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.prefix, e.idx, last), e.buf, nil)
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.buf = e.buf[:0]
+	e.idx++
+	return nil
+}
+
+// appendEncrypted appends data to f, an encrypted file of fsize bytes on
+// disk, decrypting and resealing only its final chunk.
+func (w *Workspace) appendEncrypted(f *os.File, prefix []byte, fsize int64, data []byte) error {
+	d, err := newDecryptReader(f, w.aead, prefix, fsize)
+	if err != nil {
+		return err
+	}
+	last := d.chunks - 1
+	if err := d.load(last); err != nil {
+		return err
+	}
+	// The resealed chunk is never shorter than the old one, so writing
+	// over it needs no truncation.
+	if _, err := f.Seek(int64(encHeaderLen)+last*(encChunkSize+encTagSize), io.SeekStart); err != nil {
+		return err
+	}
+	e := &encryptWriter{
+		w:      f,
+		aead:   w.aead,
+		prefix: prefix,
+		idx:    uint32(last),
+		buf:    append(make([]byte, 0, encChunkSize), d.plain...),
+	}
+	if _, err := e.Write(data); err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+type encryptedFile struct {
+	*encryptWriter
+	f *os.File
+}
+
+func (e *encryptedFile) Close() error {
+	if err := e.encryptWriter.Close(); err != nil {
+		e.f.Close()
+		return err
+	}
+	return e.f.Close()
+}
+
+type decryptReader struct {
+	f      *os.File
+	aead   cipher.AEAD
+	prefix []byte
+	size   int64
+	chunks int64
+	off    int64
+	cur    int64
+	plain  []byte
+	ct     []byte
+	fsize  int64
+}
+
+func newDecryptReader(f *os.File, aead cipher.AEAD, prefix []byte, fsize int64) (*decryptReader, error) {
+	size, err := plaintextSize(fsize)
+	if err != nil {
+		return nil, err
+	}
+	chunks := (size + encChunkSize - 1) / encChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	d := &decryptReader{
+		f:      f,
+		aead:   aead,
+		prefix: prefix,
+		size:   size,
+		chunks: chunks,
+		cur:    -1,
+		fsize:  fsize,
+	}
+	if size == 0 {
+		// Nothing will ever be read, so authenticate the empty final chunk
+		// up front to detect a file truncated down to its header.
+		if err := d.load(0); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *decryptReader) load(idx int64) error {
+	start := int64(encHeaderLen) + idx*(encChunkSize+encTagSize)
+	length := min(int64(encChunkSize+encTagSize), d.fsize-start)
+	if cap(d.ct) < int(length) {
+		d.ct = make([]byte, encChunkSize+encTagSize)
+	}
+	ct := d.ct[:length]
+	if _, err := d.f.ReadAt(ct, start); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	plain, err := d.aead.Open(d.plain[:0], chunkNonce(d.prefix, uint32(idx), idx == d.chunks-1), ct, nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.plain = plain
+	d.cur = idx
+	return nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.off >= d.size {
+		return 0, io.EOF
+	}
+	idx := d.off / encChunkSize
+	if idx != d.cur {
+		if err := d.load(idx); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain[d.off-idx*encChunkSize:])
+	d.off += int64(n)
+	return n, nil
+}
+
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.off
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+	d.off = offset
+	return offset, nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.f.Close()
+}
+
+type sizedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i sizedFileInfo) Size() int64 { return i.size }
+
+type plaintextDirEntry struct {
+	fs.DirEntry
+	path string
+	w    *Workspace
+}
+
+func (e plaintextDirEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.w.plaintextInfo(e.path, info)
+}
+
+// encrypts reports whether content written to the resolved path p should be
+// encrypted.
+func (w *Workspace) encrypts(p string) bool {
+	if w.aead == nil {
+		return false
+	}
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	for _, dir := range plaintextDirs {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// readHeader reports whether f is encrypted, returning its nonce prefix.
+// Files without the header are treated as plaintext, which lets encryption be
+// enabled on an existing workspace: files are encrypted as they are rewritten.
+func readHeader(f *os.File) ([]byte, bool, error) {
+	header := make([]byte, encHeaderLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, err
+	}
+	if n < encHeaderLen || !bytes.Equal(header[:len(encMagic)], []byte(encMagic)) {
+		return nil, false, nil
+	}
+	return header[len(encMagic):], true, nil
+}
+
+// openContent opens the file at the resolved path p for reading its
+// plaintext content.
+func (w *Workspace) openContent(p string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	if w.aead == nil {
+		return f, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return f, nil
+	}
+
+	prefix, ok, err := readHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !ok {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	d, err := newDecryptReader(f, w.aead, prefix, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// plaintextInfo corrects the size reported for encrypted files at the
+// resolved path p.
+func (w *Workspace) plaintextInfo(p string, info fs.FileInfo) (fs.FileInfo, error) {
+	if w.aead == nil || !info.Mode().IsRegular() {
+		return info, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, ok, err := readHeader(f)
+	if err != nil || !ok {
+		return info, err
+	}
+	size, err := plaintextSize(info.Size())
+	if err != nil {
+		return nil, err
+	}
+	return sizedFileInfo{FileInfo: info, size: size}, nil
+}
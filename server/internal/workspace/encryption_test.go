@@ -0,0 +1,289 @@
+package workspace_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+func newEncryptedWorkspace(t *testing.T) (*workspace.Workspace, string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.NewEncrypted(root, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws, root
+}
+
+func TestEncryptedRoundtrip(t *testing.T) {
+	ws, root := newEncryptedWorkspace(t)
+
+	// Sizes around the chunk boundary exercise the final-chunk handling.
+	sizes := []int{0, 1, 64*1024 - 1, 64 * 1024, 64*1024 + 1, 3*64*1024 + 17}
+	for _, size := range sizes {
+		data := bytes.Repeat([]byte("journal "), size/8+1)[:size]
+		name := "entry.md"
+
+		check := func(t *testing.T) {
+			t.Helper()
+			onDisk, err := os.ReadFile(filepath.Join(root, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size >= 16 && bytes.Contains(onDisk, data[:16]) {
+				t.Fatal("plaintext found on disk")
+			}
+
+			got, err := ws.ReadFile(name)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("ReadFile returned %d bytes, want %d", len(got), size)
+			}
+
+			info, err := ws.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != int64(size) {
+				t.Fatalf("Stat size = %d, want %d", info.Size(), size)
+			}
+		}
+
+		t.Run("WriteFile", func(t *testing.T) {
+			if err := ws.WriteFile(name, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			check(t)
+		})
+
+		t.Run("WriteStream", func(t *testing.T) {
+			if err := ws.WriteStream(name, bytes.NewReader(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			check(t)
+		})
+
+		t.Run("Create", func(t *testing.T) {
+			f, err := ws.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+			check(t)
+		})
+	}
+}
+
+func TestEncryptedSeek(t *testing.T) {
+	ws, _ := newEncryptedWorkspace(t)
+
+	data := make([]byte, 200*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := ws.WriteFile("book.pdf", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ws.Open("book.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, off := range []int64{0, 65535, 65536, 150000, int64(len(data)) - 10} {
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 10)
+		if _, err := io.ReadFull(f, buf); err != nil {
+			t.Fatalf("read at %d: %v", off, err)
+		}
+		if !bytes.Equal(buf, data[off:off+10]) {
+			t.Fatalf("read at %d returned wrong bytes", off)
+		}
+	}
+
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != int64(len(data)) {
+		t.Fatalf("SeekEnd = %d, want %d", end, len(data))
+	}
+}
+
+func TestEncryptedReadDirSizes(t *testing.T) {
+	ws, _ := newEncryptedWorkspace(t)
+
+	if err := ws.WriteFile("a.md", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ws.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 5 {
+		t.Fatalf("size = %d, want 5", info.Size())
+	}
+}
+
+func TestEncryptedAppend(t *testing.T) {
+	ws, root := newEncryptedWorkspace(t)
+
+	// Appends cross the chunk boundary, so both resealing a partial final
+	// chunk and starting a new one are exercised.
+	var want []byte
+	for i, size := range []int{0, 10, 64*1024 - 5, 64 * 1024, 3} {
+		data := bytes.Repeat([]byte{byte('a' + i)}, size)
+		if err := ws.AppendFile("log.jsonl", data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data...)
+		got, err := ws.ReadFile("log.jsonl")
+		if err != nil {
+			t.Fatalf("after appending %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("after appending %d bytes: got %d bytes, want %d", size, len(got), len(want))
+		}
+	}
+
+	t.Run("a plaintext file is encrypted", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "old.jsonl"), []byte("before\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.AppendFile("old.jsonl", []byte("after\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		onDisk, err := os.ReadFile(filepath.Join(root, "old.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(onDisk, []byte("before")) {
+			t.Error("appended file still stored in plaintext")
+		}
+		if got, err := ws.ReadFile("old.jsonl"); err != nil || string(got) != "before\nafter\n" {
+			t.Errorf("got %q, %v", got, err)
+		}
+	})
+}
+
+func TestEncryptedPlaintextFiles(t *testing.T) {
+	ws, root := newEncryptedWorkspace(t)
+
+	t.Run("existing plaintext files stay readable", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "old.md"), []byte("before"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ws.ReadFile("old.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "before" {
+			t.Fatalf("got %q", got)
+		}
+	})
+
+	t.Run("ui stays plaintext", func(t *testing.T) {
+		if err := ws.MkdirAll("ui/src", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteStream("ui/src/main.tsx", strings.NewReader("render()"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		onDisk, err := os.ReadFile(filepath.Join(root, "ui", "src", "main.tsx"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(onDisk) != "render()" {
+			t.Fatalf("ui file was not stored in plaintext: %q", onDisk)
+		}
+	})
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	ws, root := newEncryptedWorkspace(t)
+
+	check := func(t *testing.T, mutate func([]byte) []byte) {
+		t.Helper()
+		if err := ws.WriteFile("secret.md", bytes.Repeat([]byte("x"), 100*1024), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(root, "secret.md")
+		onDisk, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, mutate(onDisk), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ws.ReadFile("secret.md"); !errors.Is(err, workspace.ErrDecrypt) {
+			t.Fatalf("expected ErrDecrypt, got %v", err)
+		}
+	}
+
+	t.Run("flipped byte", func(t *testing.T) {
+		check(t, func(b []byte) []byte {
+			b[len(b)/2] ^= 1
+			return b
+		})
+	})
+
+	t.Run("truncated at chunk boundary", func(t *testing.T) {
+		check(t, func(b []byte) []byte {
+			return b[:15+64*1024+16]
+		})
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		if err := ws.WriteFile("secret.md", []byte("hi"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		other, err := workspace.NewEncrypted(root, bytes.Repeat([]byte{0x24}, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.ReadFile("secret.md"); !errors.Is(err, workspace.ErrDecrypt) {
+			t.Fatalf("expected ErrDecrypt, got %v", err)
+		}
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	check := func(t *testing.T, input string, wantErr bool) {
+		t.Helper()
+		_, err := workspace.ParseKey(input)
+		if (err != nil) != wantErr {
+			t.Fatalf("ParseKey(%q) err = %v, wantErr %v", input, err, wantErr)
+		}
+	}
+
+	check(t, strings.Repeat("ab", 32), false)
+	check(t, strings.Repeat("ab", 32)+"\n", false)
+	check(t, strings.Repeat("ab", 16), true)
+	check(t, strings.Repeat("zz", 32), true)
+}
@@ -0,0 +1,66 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrLocked = errors.New("path is in a locked folder")
+
+// Lock returns a view of the workspace in which the given workspace-relative
+// paths, and everything under them, can't be read, written or listed:
+// resolving them fails with ErrLocked and walks leave them out. The view
+// is set per request, so every handler respects a folder's lock without
+// checking for it.
+func (w *Workspace) Lock(paths []string) *Workspace {
+	if len(paths) == 0 {
+		return w
+	}
+	view := *w
+	view.full = w.Full()
+	view.locked = slices.Clone(w.locked)
+	for _, p := range paths {
+		abs, err := evalExisting(filepath.Join(w.root, filepath.FromSlash(p)))
+		if err != nil {
+			abs = filepath.Join(w.root, filepath.FromSlash(p))
+		}
+		view.locked = append(view.locked, abs)
+	}
+	return &view
+}
+
+// Full returns the workspace without locks, for state shared by every
+// request, such as the note index, which must not see locked files vanish
+// and come back as requests can or can't see them. What it reads must be
+// filtered with IsLocked before it is served.
+func (w *Workspace) Full() *Workspace {
+	if w.full != nil {
+		return w.full
+	}
+	return w
+}
+
+// IsLocked reports whether the workspace-relative path p is locked in this
+// view.
+func (w *Workspace) IsLocked(p string) bool {
+	return w.lockedAbs(filepath.Join(w.root, filepath.FromSlash(p)))
+}
+
+func (w *Workspace) lockedAbs(abs string) bool {
+	for _, l := range w.locked {
+		if isSubpath(l, abs) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Workspace) checkLocked(name, resolved string) error {
+	if w.lockedAbs(resolved) {
+		return fmt.Errorf("%w: %s", ErrLocked, strings.TrimPrefix(name, "/"))
+	}
+	return nil
+}
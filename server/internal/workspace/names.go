@@ -0,0 +1,24 @@
+package workspace
+
+import "strings"
+
+// reservedNames are the device names Windows reserves in every folder,
+// with or without an extension. The superscript digits count as digits.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"COM¹": true, "COM²": true, "COM³": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	"LPT¹": true, "LPT²": true, "LPT³": true,
+}
+
+// IsReservedName reports whether Windows treats the file name as a device,
+// like CON or nul.txt, rather than a file. It is checked on every platform
+// so a workspace can be kept fit to copy to Windows.
+func IsReservedName(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package workspace
+
+func checkName(string) error {
+	return nil
+}
+
+func samePath(a, b string) bool {
+	return a == b
+}
@@ -0,0 +1,40 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkName rejects names Windows would take to mean something other than
+// the file they spell: a drive-relative path such as C:notes, relative to
+// that drive's current folder, and components that are device names, such
+// as CON or nul.txt, or that end in a dot or space, which Windows drops so
+// that "a.md." opens a.md.
+//
+// Deep trees need nothing here: paths reach the os package absolute, and it
+// adds the \\?\ prefix that lifts the MAX_PATH limit itself.
+func checkName(name string) error {
+	if filepath.VolumeName(name) != "" && !filepath.IsAbs(name) {
+		return fmt.Errorf("%w: %s", ErrOutsideWorkspace, name)
+	}
+	for part := range strings.FieldsFuncSeq(name, isSeparator) {
+		if part == "." || part == ".." {
+			continue
+		}
+		if IsReservedName(part) || strings.HasSuffix(part, ".") || strings.HasSuffix(part, " ") {
+			return fmt.Errorf("%w: %s", ErrReservedName, name)
+		}
+	}
+	return nil
+}
+
+// Windows paths are case-insensitive, and the drive letter of a resolved
+// path may not be cased as the root's is.
+func samePath(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func isSeparator(r rune) bool {
+	return r == '\\' || r == '/'
+}
@@ -2,7 +2,9 @@
 package workspace
 
 import (
+	"bytes"
 	"context"
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
@@ -11,19 +13,33 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 )
 
 var (
 	ErrOutsideWorkspace = errors.New("path is outside workspace")
 	ErrNoWorkspaceRoot  = errors.New("WISDOM_WORKSPACE_ROOT is not set")
+	ErrReservedName     = errors.New("name is reserved on Windows")
 )
 
 const workspaceEnvVar = "WISDOM_WORKSPACE_ROOT"
 
+// ScratchDir holds what the server stages or caches, such as partial
+// uploads and cover thumbnails. It is inside the workspace so that it is
+// encrypted like the rest, is always locked to clients and is left out of
+// backups.
+const ScratchDir = ".wisdom/scratch"
+
 // Workspace provides safe, sandboxed file access within a root directory.
 type Workspace struct {
 	// Cleaned, absolute path to the workspace root.
 	root string
+	// Set when file contents are encrypted at rest.
+	aead cipher.AEAD
+	// Resolved paths locked in this view, and the workspace it is a view
+	// of; see Lock.
+	locked []string
+	full   *Workspace
 }
 
 type WalkEntry struct {
@@ -41,7 +57,8 @@ var (
 )
 
 // Default returns the workspace defined by the WISDOM_WORKSPACE_ROOT env var,
-// initializing it on the first call.
+// initializing it on the first call. Encryption at rest is enabled when
+// WISDOM_ENCRYPTION_KEY or WISDOM_ENCRYPTION_KEY_FILE is set.
 func Default() (*Workspace, error) {
 	defaultWsOnce.Do(func() {
 		root := os.Getenv(workspaceEnvVar)
@@ -49,6 +66,15 @@ func Default() (*Workspace, error) {
 			defaultErr = ErrNoWorkspaceRoot
 			return
 		}
+		key, err := keyFromEnv()
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		if key != nil {
+			defaultWorkspace, defaultErr = NewEncrypted(root, key)
+			return
+		}
 		defaultWorkspace, defaultErr = New(root)
 	})
 
@@ -79,6 +105,20 @@ func New(root string) (*Workspace, error) {
 	return &Workspace{root: resolved}, nil
 }
 
+// NewEncrypted creates a Workspace whose file contents are encrypted on disk
+// with the given 256-bit key and decrypted transparently when read.
+func NewEncrypted(root string, key []byte) (*Workspace, error) {
+	ws, err := New(root)
+	if err != nil {
+		return nil, err
+	}
+	ws.aead, err = newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
 func (w *Workspace) Resolve(name string) (string, error) {
 	return w.resolve(name)
 }
@@ -88,7 +128,15 @@ func (w *Workspace) ReadFile(name string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return os.ReadFile(p)
+	if w.aead == nil {
+		return os.ReadFile(p)
+	}
+	f, err := w.openContent(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
 }
 
 func (w *Workspace) WriteFile(name string, data []byte, perm fs.FileMode) error {
@@ -96,7 +144,81 @@ func (w *Workspace) WriteFile(name string, data []byte, perm fs.FileMode) error
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(p, data, perm)
+	if !w.encrypts(p) {
+		return os.WriteFile(p, data, perm)
+	}
+	var buf bytes.Buffer
+	enc, err := newEncryptWriter(&buf, w.aead)
+	if err != nil {
+		return err
+	}
+	if _, err := enc.Write(data); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(p, buf.Bytes(), perm)
+}
+
+// AppendFile appends data to name, creating it if needed. Only an encrypted
+// file's final chunk is rewritten, so appending stays cheap as the file
+// grows; a plaintext file that should be encrypted is rewritten once.
+// Callers serialize appends to the same file.
+func (w *Workspace) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := w.resolve(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if w.encrypts(p) {
+		prefix, ok, err := readHeader(f)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := w.appendEncrypted(f, prefix, info.Size(), data); err != nil {
+				return err
+			}
+			return f.Close()
+		}
+		if info.Size() > 0 {
+			f.Close()
+			old, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return w.WriteFile(name, append(old, data...), perm)
+		}
+		enc, err := newEncryptWriter(f, w.aead)
+		if err != nil {
+			return err
+		}
+		if _, err := enc.Write(data); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Close()
 }
 
 // WriteStream atomically writes the contents of r to name. It streams through
@@ -124,7 +246,7 @@ func (w *Workspace) WriteStream(name string, r io.Reader, perm fs.FileMode) erro
 		}
 	}()
 
-	if _, err := io.Copy(tmp, r); err != nil {
+	if err := w.copyContent(tmp, r, p); err != nil {
 		tmp.Close()
 		return err
 	}
@@ -148,6 +270,23 @@ func (w *Workspace) WriteStream(name string, r io.Reader, perm fs.FileMode) erro
 	return nil
 }
 
+// copyContent copies r to dst, encrypting it if the resolved destination p
+// is stored encrypted.
+func (w *Workspace) copyContent(dst io.Writer, r io.Reader, p string) error {
+	if !w.encrypts(p) {
+		_, err := io.Copy(dst, r)
+		return err
+	}
+	enc, err := newEncryptWriter(dst, w.aead)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
 func moveTempFileAcrossFilesystems(src, dst string, perm fs.FileMode) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -198,23 +337,65 @@ func (w *Workspace) Stat(name string) (fs.FileInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return os.Stat(p)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return w.plaintextInfo(p, info)
 }
 
-func (w *Workspace) Open(name string) (*os.File, error) {
+// Lstat is Stat without following a symlink at name.
+func (w *Workspace) Lstat(name string) (fs.FileInfo, error) {
 	p, err := w.resolve(name)
 	if err != nil {
 		return nil, err
 	}
-	return os.Open(p)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return w.plaintextInfo(p, info)
 }
 
-func (w *Workspace) Create(name string) (*os.File, error) {
+// Open opens name for reading its content, decrypting it if needed.
+func (w *Workspace) Open(name string) (io.ReadSeekCloser, error) {
 	p, err := w.resolve(name)
 	if err != nil {
 		return nil, err
 	}
-	return os.Create(p)
+	return w.openContent(p)
+}
+
+// Create creates or truncates name for writing, encrypting its content if
+// needed. The returned writer must be closed to finish the file.
+func (w *Workspace) Create(name string) (io.WriteCloser, error) {
+	return w.create(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+// CreateNew is Create for a file that mustn't exist yet: it fails with
+// fs.ErrExist if one does, so a caller picking a free name can claim it.
+func (w *Workspace) CreateNew(name string) (io.WriteCloser, error) {
+	return w.create(name, os.O_RDWR|os.O_CREATE|os.O_EXCL)
+}
+
+func (w *Workspace) create(name string, flag int) (io.WriteCloser, error) {
+	p, err := w.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, flag, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	if !w.encrypts(p) {
+		return f, nil
+	}
+	enc, err := newEncryptWriter(f, w.aead)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &encryptedFile{encryptWriter: enc, f: f}, nil
 }
 
 func (w *Workspace) Remove(name string) error {
@@ -226,7 +407,7 @@ func (w *Workspace) Remove(name string) error {
 }
 
 func (w *Workspace) RemoveAll(name string) error {
-	p, err := w.resolve(name)
+	p, err := w.resolveTree(name)
 	if err != nil {
 		return err
 	}
@@ -234,7 +415,30 @@ func (w *Workspace) RemoveAll(name string) error {
 }
 
 func (w *Workspace) Move(oldname, newname string) error {
-	oldpath, err := w.resolve(oldname)
+	oldpath, err := w.resolveTree(oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := w.resolve(newname)
+	if err != nil {
+		return err
+	}
+	err = renameFile(oldpath, newpath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	// A folder in the workspace may be another filesystem's mount point.
+	if err := w.copyTree(oldpath, newpath, true); err != nil {
+		return err
+	}
+	return os.RemoveAll(oldpath)
+}
+
+// Copy copies the file or folder oldname to newname. With preserve, the
+// copies keep their sources' modification times and, where the platform
+// supports them, extended attributes; otherwise they are new files.
+func (w *Workspace) Copy(oldname, newname string, preserve bool) error {
+	oldpath, err := w.resolveTree(oldname)
 	if err != nil {
 		return err
 	}
@@ -242,7 +446,71 @@ func (w *Workspace) Move(oldname, newname string) error {
 	if err != nil {
 		return err
 	}
-	return os.Rename(oldpath, newpath)
+	if isSubpath(oldpath, newpath) {
+		return fmt.Errorf("copying %s into itself", oldname)
+	}
+	return w.copyTree(oldpath, newpath, preserve)
+}
+
+// copyTree copies the resolved path src to dst. Symlinks are skipped, as
+// WalkFiles skips what they point to.
+func (w *Workspace) copyTree(src, dst string, preserve bool) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			err = os.MkdirAll(target, info.Mode().Perm())
+		case d.Type().IsRegular():
+			err = w.copyFile(p, target, info.Mode().Perm())
+		default:
+			return nil
+		}
+		if err != nil || !preserve {
+			return err
+		}
+		if err := copyXattrs(p, target); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func (w *Workspace) copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := w.openContent(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := w.copyContent(out, in, dst); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Chtimes sets the modification time of name, for clients that sync files
+// and need the times they had elsewhere kept.
+func (w *Workspace) Chtimes(name string, modTime time.Time) error {
+	p, err := w.resolve(name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, modTime, modTime)
 }
 
 // WalkFiles returns all workspace-relative paths (files and directories).
@@ -264,6 +532,13 @@ func (w *Workspace) WalkFiles() ([]WalkEntry, error) {
 			return nil
 		}
 
+		if w.lockedAbs(path) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		// Skip hidden directories at root level
 		name := d.Name()
 		if d.IsDir() && len(name) > 0 && name[0] == '.' {
@@ -288,13 +563,23 @@ func (w *Workspace) ReadDir(name string) ([]fs.DirEntry, error) {
 	if err != nil {
 		return nil, err
 	}
-	return os.ReadDir(p)
+	entries, err := os.ReadDir(p)
+	if err != nil || w.aead == nil {
+		return entries, err
+	}
+	for i, e := range entries {
+		entries[i] = plaintextDirEntry{DirEntry: e, path: filepath.Join(p, e.Name()), w: w}
+	}
+	return entries, nil
 }
 
 // resolve validates that name is inside the workspace and returns the cleaned
 // absolute path. name can be relative (to the workspace root) or absolute.
 // Symlinks in the target are resolved before checking.
 func (w *Workspace) resolve(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
 	var abs string
 	if filepath.IsAbs(name) {
 		abs = filepath.Clean(name)
@@ -312,20 +597,38 @@ func (w *Workspace) resolve(name string) (string, error) {
 	if !isSubpath(w.root, resolved) {
 		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, name)
 	}
+	if err := w.checkLocked(name, resolved); err != nil {
+		return "", err
+	}
 
 	return resolved, nil
 }
 
+// resolveTree resolves name like resolve, for an operation on the whole
+// tree under it, which mustn't reach into a locked folder inside.
+func (w *Workspace) resolveTree(name string) (string, error) {
+	p, err := w.resolve(name)
+	if err != nil {
+		return "", err
+	}
+	for _, l := range w.locked {
+		if isSubpath(p, l) {
+			return "", fmt.Errorf("%w: %s contains one", ErrLocked, name)
+		}
+	}
+	return p, nil
+}
+
 // isSubpath checks whether child is under parent.
 // Both paths must be cleaned and absolute.
 func isSubpath(parent, child string) bool {
-	if child == parent {
+	if samePath(child, parent) {
 		return true
 	}
 	// Append separator to avoid prefix false positives
 	// e.g. /workspace-evil matching /workspace
 	return len(child) > len(parent) &&
-		child[:len(parent)] == parent &&
+		samePath(child[:len(parent)], parent) &&
 		child[len(parent)] == filepath.Separator
 }
 
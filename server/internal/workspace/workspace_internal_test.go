@@ -5,6 +5,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 )
 
 func TestWriteStreamFallsBackOnCrossDeviceRename(t *testing.T) {
@@ -47,3 +48,39 @@ func TestWriteStreamFallsBackOnCrossDeviceRename(t *testing.T) {
 		t.Fatalf("expected 2 rename attempts, got %d", renameCalls)
 	}
 }
+
+func TestMoveCopiesAcrossFilesystems(t *testing.T) {
+	ws, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("a.md", []byte("note"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := ws.Chtimes("a.md", old); err != nil {
+		t.Fatal(err)
+	}
+
+	originalRename := renameFile
+	t.Cleanup(func() {
+		renameFile = originalRename
+	})
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+
+	if err := ws.Move("a.md", "b.md"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, err := ws.Stat("a.md"); !os.IsNotExist(err) {
+		t.Fatalf("source still there: %v", err)
+	}
+	info, err := ws.Stat("b.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Fatalf("moved file modified at %v, want %v", info.ModTime(), old)
+	}
+}
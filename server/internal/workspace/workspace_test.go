@@ -3,10 +3,12 @@ package workspace_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/shrik450/wisdom/internal/workspace"
 )
@@ -168,6 +170,23 @@ func TestResolve(t *testing.T) {
 	})
 }
 
+func TestIsReservedName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"CON":         true,
+		"nul.txt":     true,
+		"Com1.tar.gz": true,
+		"LPT¹":        true,
+		"AUX .md":     true,
+		"console.md":  false,
+		"COM10":       false,
+		"notes":       false,
+	} {
+		if got := workspace.IsReservedName(name); got != want {
+			t.Errorf("IsReservedName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
 func TestFileOperations(t *testing.T) {
 	root := t.TempDir()
 	ws, err := workspace.New(root)
@@ -267,13 +286,13 @@ func TestFileOperations(t *testing.T) {
 		}
 		f.Close()
 
-		f, err = ws.Open("created.txt")
+		r, err := ws.Open("created.txt")
 		if err != nil {
 			t.Fatalf("Open: %v", err)
 		}
-		defer f.Close()
+		defer r.Close()
 		buf := make([]byte, 64)
-		n, err := f.Read(buf)
+		n, err := r.Read(buf)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -387,6 +406,46 @@ func TestFileOperations(t *testing.T) {
 		}
 	})
 
+	t.Run("Copy", func(t *testing.T) {
+		if err := ws.MkdirAll("tree/sub", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile("tree/sub/leaf.txt", []byte("leaf"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := ws.Chtimes("tree/sub/leaf.txt", old); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, preserve := range []bool{true, false} {
+			dst := fmt.Sprintf("copy-%v", preserve)
+			if err := ws.Copy("tree", dst, preserve); err != nil {
+				t.Fatalf("Copy: %v", err)
+			}
+			got, err := ws.ReadFile(dst + "/sub/leaf.txt")
+			if err != nil || string(got) != "leaf" {
+				t.Fatalf("ReadFile copy = %q, %v", got, err)
+			}
+			info, err := ws.Stat(dst + "/sub/leaf.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ModTime().Equal(old) != preserve {
+				t.Fatalf("preserve=%v: copy modified at %v, source at %v", preserve, info.ModTime(), old)
+			}
+		}
+		if _, err := ws.Stat("tree/sub/leaf.txt"); err != nil {
+			t.Fatalf("source gone after copy: %v", err)
+		}
+	})
+
+	t.Run("Copy into itself", func(t *testing.T) {
+		if err := ws.Copy("tree", "tree/inner", false); err == nil {
+			t.Fatal("expected error copying a folder into itself")
+		}
+	})
+
 	t.Run("ReadFile with escaping path", func(t *testing.T) {
 		_, err := ws.ReadFile("../../../etc/passwd")
 		if !errors.Is(err, workspace.ErrOutsideWorkspace) {
@@ -395,6 +454,62 @@ func TestFileOperations(t *testing.T) {
 	})
 }
 
+func TestLock(t *testing.T) {
+	ws, err := workspace.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"journal/private/secret.md", "journal/open.md", "journal/private-not.md"} {
+		if err := ws.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	view := ws.Lock([]string{"journal/private"})
+
+	for _, op := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"read", func() error { _, err := view.ReadFile("journal/private/secret.md"); return err }},
+		{"list", func() error { _, err := view.ReadDir("journal/private"); return err }},
+		{"write", func() error { return view.WriteFile("journal/private/new.md", nil, 0o644) }},
+		{"move out", func() error { return view.Move("journal/private/secret.md", "leak.md") }},
+		{"move into", func() error { return view.Move("journal/open.md", "journal/private/open.md") }},
+		{"move parent", func() error { return view.Move("journal", "diary") }},
+		{"copy parent", func() error { return view.Copy("journal", "diary", false) }},
+		{"remove parent", func() error { return view.RemoveAll("journal") }},
+	} {
+		if err := op.fn(); !errors.Is(err, workspace.ErrLocked) {
+			t.Errorf("%s: err = %v, want ErrLocked", op.name, err)
+		}
+	}
+	if _, err := view.ReadFile("journal/private-not.md"); err != nil {
+		t.Errorf("a sibling sharing the prefix is locked: %v", err)
+	}
+
+	entries, err := view.WalkFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Path, "journal/private/") || e.Path == "journal/private" {
+			t.Errorf("walk lists locked %s", e.Path)
+		}
+	}
+	if !view.IsLocked("journal/private/secret.md") || view.IsLocked("journal/open.md") {
+		t.Error("IsLocked disagrees with the lock")
+	}
+	if _, err := view.Full().ReadFile("journal/private/secret.md"); err != nil {
+		t.Errorf("the full workspace is locked too: %v", err)
+	}
+	if _, err := ws.ReadFile("journal/private/secret.md"); err != nil {
+		t.Errorf("locking a view locked the workspace: %v", err)
+	}
+}
+
 func TestContext(t *testing.T) {
 	t.Run("roundtrip", func(t *testing.T) {
 		ws, err := workspace.New(t.TempDir())
@@ -0,0 +1,53 @@
+package workspace_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrik450/wisdom/internal/workspace"
+)
+
+func TestResolveWindows(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{`C:notes`, `\\server\share\notes`, `D:\notes`} {
+		if _, err := ws.Resolve(name); !errors.Is(err, workspace.ErrOutsideWorkspace) {
+			t.Errorf("Resolve(%q) error = %v, want ErrOutsideWorkspace", name, err)
+		}
+	}
+	for _, name := range []string{`CON`, `notes\nul.md`, `notes/aux`, `plan.md.`, `plan.md `} {
+		if _, err := ws.Resolve(name); !errors.Is(err, workspace.ErrReservedName) {
+			t.Errorf("Resolve(%q) error = %v, want ErrReservedName", name, err)
+		}
+	}
+
+	t.Run("root in another case", func(t *testing.T) {
+		name := strings.ToUpper(filepath.Join(root, "notes.md"))
+		if _, err := ws.Resolve(name); err != nil {
+			t.Fatalf("Resolve(%q): %v", name, err)
+		}
+	})
+
+	t.Run("tree deeper than MAX_PATH", func(t *testing.T) {
+		p := strings.Repeat("a-fairly-long-folder-name/", 12) + "note.md"
+		if err := ws.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteFile(p, []byte("deep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ws.ReadFile(p)
+		if err != nil || string(data) != "deep" {
+			t.Fatalf("ReadFile = %q, %v", data, err)
+		}
+	})
+}
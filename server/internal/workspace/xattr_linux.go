@@ -0,0 +1,50 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// copyXattrs copies the user extended attributes of src to dst. Other
+// namespaces need privileges or describe the file's place on disk.
+func copyXattrs(src, dst string) error {
+	names, err := xattrCall(func(buf []byte) (int, error) { return syscall.Listxattr(src, buf) })
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	for name := range bytes.SplitSeq(names, []byte{0}) {
+		if !bytes.HasPrefix(name, []byte("user.")) {
+			continue
+		}
+		value, err := xattrCall(func(buf []byte) (int, error) { return syscall.Getxattr(src, string(name), buf) })
+		if err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(dst, string(name), value, 0); err != nil && !errors.Is(err, syscall.ENOTSUP) {
+			return err
+		}
+	}
+	return nil
+}
+
+// xattrCall calls fn with a buffer big enough for its result, sizing it
+// with a first call on an empty one.
+func xattrCall(fn func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := fn(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = fn(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			// It grew between the calls.
+			continue
+		}
+		return buf[:n], err
+	}
+}
@@ -0,0 +1,33 @@
+package workspace
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	ws, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteFile("a.md", []byte("note"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(ws.root, "a.md")
+	if err := syscall.Setxattr(src, "user.wisdom.test", []byte("kept"), 0); errors.Is(err, syscall.ENOTSUP) {
+		t.Skip("filesystem has no user extended attributes")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.Copy("a.md", "b.md", true); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := syscall.Getxattr(filepath.Join(ws.root, "b.md"), "user.wisdom.test", buf)
+	if err != nil || string(buf[:n]) != "kept" {
+		t.Fatalf("copied attribute = %q, %v", buf[:n], err)
+	}
+}
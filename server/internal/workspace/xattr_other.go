@@ -0,0 +1,8 @@
+//go:build !linux
+
+package workspace
+
+// Extended attributes are only copied on Linux.
+func copyXattrs(src, dst string) error {
+	return nil
+}
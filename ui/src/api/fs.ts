@@ -12,13 +12,28 @@ async function checkResponse(res: Response): Promise<void> {
   }
 }
 
+// Listings are paginated; the server sends the next page's cursor in this
+// header until the last page.
+const NEXT_CURSOR_HEADER = "Wisdom-Next-Cursor";
+
 export async function listDir(
   path: string,
   signal?: AbortSignal,
 ): Promise<DirEntry[]> {
-  const res = await fetch(buildFsApiUrl(path), { signal });
-  await checkResponse(res);
-  return res.json();
+  const entries: DirEntry[] = [];
+  let cursor: string | null = null;
+  do {
+    const url: string =
+      cursor === null
+        ? buildFsApiUrl(path)
+        : `${buildFsApiUrl(path)}?cursor=${encodeURIComponent(cursor)}`;
+    const res = await fetch(url, { signal });
+    await checkResponse(res);
+    const page: DirEntry[] = await res.json();
+    entries.push(...page);
+    cursor = res.headers.get(NEXT_CURSOR_HEADER);
+  } while (cursor !== null);
+  return entries;
 }
 
 export async function readFile(
@@ -27,7 +42,15 @@ export async function readFile(
 ): Promise<string> {
   const res = await fetch(buildFsApiUrl(path), { signal });
   await checkResponse(res);
-  return res.text();
+  // res.text() always decodes UTF-8; the server declares the charset of
+  // text files in other encodings.
+  const charset = /charset=([^;]+)/i.exec(
+    res.headers.get("Content-Type") ?? "",
+  )?.[1];
+  if (!charset || charset.trim().toLowerCase() === "utf-8") {
+    return res.text();
+  }
+  return new TextDecoder(charset.trim()).decode(await res.arrayBuffer());
 }
 
 export async function writeFile(path: string, content: string): Promise<void> {
@@ -1,3 +1,5 @@
+import { buildApiUrl } from "../path-utils";
+
 export interface PathSearchResult {
   path: string;
   score: number;
@@ -10,7 +12,7 @@ export async function searchPaths(
   signal?: AbortSignal,
 ): Promise<PathSearchResult[]> {
   const params = new URLSearchParams({ q: query, limit: String(limit) });
-  const response = await fetch(`/api/search/paths?${params}`, { signal });
+  const response = await fetch(buildApiUrl(`search/paths?${params}`), { signal });
   if (!response.ok) {
     throw new Error(`Search failed: ${response.status}`);
   }
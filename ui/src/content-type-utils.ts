@@ -25,6 +25,12 @@ export function isTextContentType(contentType: string | null): boolean {
   return TEXT_LIKE_MIME_TYPES.has(contentType);
 }
 
+// Files the server found binary, or too large to load whole, are left to
+// viewers that don't read them as text, whatever their content type.
+export function isOpenableText(contentClass: string | null): boolean {
+  return contentClass === null || contentClass === "text";
+}
+
 export function isLikelyTextFallback(
   contentType: string | null,
   extension: string | null,
@@ -1,9 +1,15 @@
 import { createRoot } from "react-dom/client";
+import { Router } from "wouter";
 import { App } from "./app";
+import { serverBasePath } from "./path-utils";
 
 const root = document.getElementById("root");
 if (!root) {
   throw new Error("Missing #root element");
 }
 
-createRoot(root).render(<App />);
+createRoot(root).render(
+  <Router base={serverBasePath()}>
+    <App />
+  </Router>,
+);
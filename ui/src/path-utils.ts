@@ -42,12 +42,25 @@ export function buildWorkspaceHref(path: string): string {
   return `/ws/${encodedPath}/`;
 }
 
+// The path the server is mounted under, such as "/wisdom", from the page's
+// <base> element: "" at the root, or outside a browser.
+export function serverBasePath(): string {
+  if (typeof document === "undefined") {
+    return "";
+  }
+  return new URL(document.baseURI).pathname.replace(/\/+$/, "");
+}
+
+export function buildApiUrl(path: string): string {
+  return `${serverBasePath()}/api/${path}`;
+}
+
 export function buildFsApiUrl(path: string): string {
   const encodedPath = encodeWorkspacePath(path);
   if (encodedPath === "") {
-    return "/api/fs/";
+    return buildApiUrl("fs/");
   }
-  return `/api/fs/${encodedPath}`;
+  return buildApiUrl(`fs/${encodedPath}`);
 }
 
 export function buildBreadcrumbs(path: string): Breadcrumb[] {
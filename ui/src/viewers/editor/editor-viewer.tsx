@@ -32,6 +32,7 @@ import { useActions, type ActionSpec } from "../../actions/action-registry";
 import { writeFile } from "../../api/fs";
 import {
   isLikelyTextFallback,
+  isOpenableText,
   isTextContentType,
 } from "../../content-type-utils";
 import { useFileContent } from "../../hooks/use-fs";
@@ -1847,6 +1848,7 @@ export const editorViewerRoute: ViewerRoute = {
   scope: "editor",
   match: (entry) =>
     entry.kind === "file" &&
+    isOpenableText(entry.contentClass) &&
     (isTextContentType(entry.contentType) ||
       isLikelyTextFallback(entry.contentType, entry.extension)),
   priority: 10,
@@ -1,7 +1,11 @@
 import { useCallback, useMemo } from "react";
 import { useActions, type ActionSpec } from "../actions/action-registry";
 import { useFileContent } from "../hooks/use-fs";
-import { isLikelyTextFallback, isTextContentType } from "../content-type-utils";
+import {
+  isLikelyTextFallback,
+  isOpenableText,
+  isTextContentType,
+} from "../content-type-utils";
 import type { KeyBindingDef } from "../keyboard/keybind-state-machine";
 import { type ViewerProps, type ViewerRoute } from "./registry";
 
@@ -59,6 +63,7 @@ export const plainTextViewerRoute: ViewerRoute = {
   scope: "plain-text",
   match: (entry) =>
     entry.kind === "file" &&
+    isOpenableText(entry.contentClass) &&
     (isTextContentType(entry.contentType) ||
       isLikelyTextFallback(entry.contentType, entry.extension)),
   priority: 0,
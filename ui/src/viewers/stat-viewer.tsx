@@ -46,6 +46,16 @@ function StatViewer({ entry }: ViewerProps) {
             <dd className="text-txt">{entry.contentType}</dd>
           </>
         )}
+        {entry.contentClass && entry.contentClass !== "text" && (
+          <>
+            <dt className="text-txt-muted">Content</dt>
+            <dd className="text-txt">
+              {entry.contentClass === "binary"
+                ? "Binary"
+                : "Text, too large to open"}
+            </dd>
+          </>
+        )}
         {entry.size !== null && (
           <>
             <dt className="text-txt-muted">Size</dt>
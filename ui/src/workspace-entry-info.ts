@@ -7,6 +7,12 @@ export type WorkspaceEntryKind = "file" | "directory" | "missing" | "unknown";
 // classify entries by Content-Type header alone, without body-sniffing.
 const DIRLIST_CONTENT_TYPE = "application/vnd.wisdom.dirlist+json";
 
+// The server sniffs files and says whether they are text that can be
+// opened, binary whatever their extension, or text too large to open.
+const CONTENT_HEADER = "Wisdom-Content";
+
+export type ContentClass = "text" | "binary" | "too-large";
+
 export interface WorkspaceEntryInfo {
   kind: WorkspaceEntryKind;
   path: string;
@@ -16,6 +22,7 @@ export interface WorkspaceEntryInfo {
   contentType: string | null;
   size: number | null;
   lastModified: string | null;
+  contentClass: ContentClass | null;
 }
 
 function pathSegments(path: string): string[] {
@@ -71,6 +78,7 @@ function buildEntry(
   contentType: string | null = null,
   size: number | null = null,
   lastModified: string | null = null,
+  contentClass: ContentClass | null = null,
 ): WorkspaceEntryInfo {
   const normalizedPath = normalizeWorkspacePath(path);
   const name = entryName(normalizedPath);
@@ -83,6 +91,7 @@ function buildEntry(
     contentType,
     size,
     lastModified,
+    contentClass,
   };
 }
 
@@ -108,5 +117,13 @@ export async function getWorkspaceEntryInfo(
 
   const size = parseSize(res.headers.get("Content-Length"));
   const lastModified = res.headers.get("Last-Modified");
-  return buildEntry(normalizedPath, "file", contentType, size, lastModified);
+  const contentClass = res.headers.get(CONTENT_HEADER) as ContentClass | null;
+  return buildEntry(
+    normalizedPath,
+    "file",
+    contentType,
+    size,
+    lastModified,
+    contentClass,
+  );
 }
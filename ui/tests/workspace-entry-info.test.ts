@@ -92,6 +92,7 @@ test("detects file metadata from non-directory payload", async () => {
         "Content-Type": "text/markdown",
         "Content-Length": "9",
         "Last-Modified": "Wed, 19 Feb 2026 12:00:00 GMT",
+        "Wisdom-Content": "text",
       },
     });
   }) as typeof fetch;
@@ -105,6 +106,7 @@ test("detects file metadata from non-directory payload", async () => {
     assert.equal(info.contentType, "text/markdown");
     assert.equal(info.size, 9);
     assert.equal(info.lastModified, "Wed, 19 Feb 2026 12:00:00 GMT");
+    assert.equal(info.contentClass, "text");
   } finally {
     globalThis.fetch = previousFetch;
   }